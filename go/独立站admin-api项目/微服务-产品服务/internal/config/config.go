@@ -17,6 +17,16 @@ type Config struct {
 	MongoLink       string `json:"MongoLink"`
 	MongoDBName     string `json:"MongoDBName"`
 	ENV             string
+	ImageIngest     ImageIngest
+	ImageFetch      ImageFetch
+	Outbox          Outbox
+	Watch           Watch
+	Webhook         Webhook
+	Sitemap         Sitemap
+	Sale            Sale
+	Rating          Rating
+	ProductCache    ProductCache
+	Idempotency     Idempotency
 }
 
 type StaticStorage struct {
@@ -32,3 +42,81 @@ type AliOss struct {
 	AccessKeySecret string
 	BucketName      string
 }
+
+type ImageIngest struct {
+	Workers      int   `json:",default=4"`
+	BatchSize    int64 `json:",default=20"`
+	PollInterval int64 `json:",default=2000"`   // 轮询间隔，毫秒
+	Lease        int64 `json:",default=300000"` // 任务租约，超时未完成的任务会被重新领取，毫秒
+	MaxAttempts  int64 `json:",default=8"`
+	BaseBackoff  int64 `json:",default=5000"`    // 首次重试等待，毫秒
+	MaxBackoff   int64 `json:",default=3600000"` // 最长重试等待，毫秒
+}
+
+type ImageFetch struct {
+	Timeout            int64 `json:",default=30000"`   // 单张图片下载总超时，毫秒
+	DialTimeout        int64 `json:",default=5000"`    // 建连超时，毫秒
+	MaxBytes           int64 `json:",default=8388608"` // 图片大小上限，默认8M
+	MaxRedirects       int   `json:",default=3"`
+	PerHostConcurrency int   `json:",default=4"` // 同一域名同时下载数
+	AllowPrivate       bool  `json:",optional"`  // 仅本地调试用，允许访问内网地址
+}
+
+type Outbox struct {
+	PollInterval int64 `json:",default=500"` // 轮询间隔，毫秒
+	BatchSize    int64 `json:",default=200"`
+	LockExpire   int   `json:",default=10"` // 投递锁过期时间，秒；同一时间只有一个实例在投递以保证顺序
+	Retention    int64 `json:",default=72"` // 已投递事件保留时长，小时
+}
+
+type Watch struct {
+	PollInterval int64 `json:",default=200"` // 轮询发件箱间隔，毫秒
+	BatchSize    int64 `json:",default=500"`
+	Buffer       int   `json:",default=256"` // 每个订阅者的缓冲事件数，满了之后订阅者回到数据库追赶
+}
+
+type Webhook struct {
+	PollInterval int64 `json:",default=1000"` // 轮询间隔，毫秒
+	BatchSize    int64 `json:",default=100"`
+	Concurrency  int   `json:",default=8"`     // 同时发送的请求数
+	Timeout      int64 `json:",default=10000"` // 单次请求超时，毫秒
+	MaxAttempts  int   `json:",default=10"`
+	BaseBackoff  int64 `json:",default=10000"`   // 首次重试等待，毫秒
+	MaxBackoff   int64 `json:",default=3600000"` // 最长重试等待，毫秒
+	DisableAfter int64 `json:",default=20"`      // 连续失败多少次后自动停用
+	Lease        int64 `json:",default=60000"`   // 单条记录的发送租约，需要大于 Timeout，毫秒
+	LockExpire   int   `json:",default=10"`      // 分发锁过期时间，秒
+}
+
+type ProductCache struct {
+	Expiry         int   `json:",default=3600"` // 产品聚合缓存过期时间，秒
+	NotFoundExpiry int   `json:",default=60"`   // 不存在的产品占位缓存时间，秒
+	PollInterval   int64 `json:",default=500"`  // 轮询发件箱间隔，毫秒
+	BatchSize      int64 `json:",default=500"`
+	LockExpire     int   `json:",default=10"` // 失效任务锁过期时间，秒
+}
+
+type Idempotency struct {
+	Expire        int `json:",default=86400"` // 幂等键和返回保存时间，秒
+	PendingExpire int `json:",default=60"`    // 处理中的幂等键过期时间，秒，进程中途退出时键在这之后释放
+}
+
+type Sitemap struct {
+	PollInterval int64 `json:",default=1000"` // 轮询发件箱间隔，毫秒
+	BatchSize    int64 `json:",default=500"`
+	PageSize     int64 `json:",default=1000"` // 生成时每次读取的记录数
+	LockExpire   int   `json:",default=10"`   // 标记锁过期时间，秒
+	GenerateLock int   `json:",default=600"`  // 同一店铺生成锁过期时间，秒
+}
+
+type Sale struct {
+	PollInterval int64 `json:",default=5000"` // 检查活动开始、结束的间隔，毫秒
+	BatchSize    int64 `json:",default=100"`  // 每批处理的产品数
+	LockExpire   int   `json:",default=30"`   // 调度锁过期时间，秒
+}
+
+type Rating struct {
+	PriorWeight      float64 `json:",default=10"` // 贝叶斯加权分的先验权重，相当于多少条按店铺平均分打分的评论
+	PriorMean        float64 `json:",optional"`   // 固定的先验平均分，为0时使用店铺平均分
+	RebuildBatchSize int64   `json:",default=200"`
+}
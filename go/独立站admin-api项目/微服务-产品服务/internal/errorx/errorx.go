@@ -0,0 +1,238 @@
+package errorx
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fetcher"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Domain 放在 ErrorInfo.Domain 中，区分其他服务返回的错误码
+const Domain = "product"
+
+// 错误码放在 ErrorInfo.Reason 中，调用方按错误码判断，不要解析错误信息。已经发布的错误码不能修改
+const (
+	CodeInternal             = "INTERNAL"
+	CodeInvalidArgument      = "INVALID_ARGUMENT"
+	CodeNotFound             = "NOT_FOUND"
+	CodeProductNotFound      = "PRODUCT_NOT_FOUND"
+	CodeVariantNotFound      = "VARIANT_NOT_FOUND"
+	CodeCategoryNotFound     = "CATEGORY_NOT_FOUND"
+	CodeReviewNotFound       = "REVIEW_NOT_FOUND"
+	CodeRedirectNotFound     = "REDIRECT_NOT_FOUND"
+	CodeWebhookNotFound      = "WEBHOOK_NOT_FOUND"
+	CodeMetafieldNotFound    = "METAFIELD_NOT_FOUND"
+	CodePriceListNotFound    = "PRICE_LIST_NOT_FOUND"
+	CodeSaleNotFound         = "SALE_NOT_FOUND"
+	CodeHandleConflict       = "HANDLE_CONFLICT"
+	CodeProductAlreadyExists = "PRODUCT_ALREADY_EXISTS"
+	CodeProductPublished     = "PRODUCT_PUBLISHED"
+	CodePriceListExists      = "PRICE_LIST_EXISTS"
+	CodeWebhookExists        = "WEBHOOK_EXISTS"
+	CodeVariantLimitExceeded = "VARIANT_LIMIT_EXCEEDED"
+	CodeInvalidImage         = "INVALID_IMAGE"
+	CodeUploadFailed         = "UPLOAD_FAILED"
+	CodeVersionConflict      = "VERSION_CONFLICT"
+	CodeReviewTransition     = "REVIEW_TRANSITION_NOT_ALLOWED"
+	CodeIdempotencyConflict  = "IDEMPOTENCY_CONFLICT"
+	CodeOperationInProgress  = "OPERATION_IN_PROGRESS"
+	CodeResumeTokenExpired   = "RESUME_TOKEN_EXPIRED"
+	CodeTooManyRequests      = "TOO_MANY_REQUESTS"
+	CodeCanceled             = "CANCELED"
+	CodeDeadlineExceeded     = "DEADLINE_EXCEEDED"
+)
+
+// catalog 错误码对应的 gRPC 状态码，网关按状态码转换 HTTP 状态
+var catalog = map[string]codes.Code{
+	CodeInternal:             codes.Internal,
+	CodeInvalidArgument:      codes.InvalidArgument,
+	CodeNotFound:             codes.NotFound,
+	CodeProductNotFound:      codes.NotFound,
+	CodeVariantNotFound:      codes.NotFound,
+	CodeCategoryNotFound:     codes.NotFound,
+	CodeReviewNotFound:       codes.NotFound,
+	CodeRedirectNotFound:     codes.NotFound,
+	CodeWebhookNotFound:      codes.NotFound,
+	CodeMetafieldNotFound:    codes.NotFound,
+	CodePriceListNotFound:    codes.NotFound,
+	CodeSaleNotFound:         codes.NotFound,
+	CodeHandleConflict:       codes.AlreadyExists,
+	CodeProductAlreadyExists: codes.AlreadyExists,
+	CodeProductPublished:     codes.FailedPrecondition,
+	CodePriceListExists:      codes.AlreadyExists,
+	CodeWebhookExists:        codes.AlreadyExists,
+	CodeVariantLimitExceeded: codes.FailedPrecondition,
+	CodeInvalidImage:         codes.InvalidArgument,
+	CodeUploadFailed:         codes.Unavailable,
+	CodeVersionConflict:      codes.FailedPrecondition,
+	CodeReviewTransition:     codes.FailedPrecondition,
+	CodeIdempotencyConflict:  codes.AlreadyExists,
+	CodeOperationInProgress:  codes.Aborted,
+	CodeResumeTokenExpired:   codes.OutOfRange,
+	CodeTooManyRequests:      codes.ResourceExhausted,
+	CodeCanceled:             codes.Canceled,
+	CodeDeadlineExceeded:     codes.DeadlineExceeded,
+}
+
+var (
+	ErrInternal             = New(CodeInternal, "internal server error")
+	ErrProductNotFound      = New(CodeProductNotFound, "product record not found")
+	ErrVariantNotFound      = New(CodeVariantNotFound, "variant record not found")
+	ErrCategoryNotFound     = New(CodeCategoryNotFound, "category record not found")
+	ErrReviewNotFound       = New(CodeReviewNotFound, "review not found")
+	ErrRedirectNotFound     = New(CodeRedirectNotFound, "redirect record not found")
+	ErrWebhookNotFound      = New(CodeWebhookNotFound, "webhook record not found")
+	ErrMetafieldNotFound    = New(CodeMetafieldNotFound, "metafield not found")
+	ErrPriceListNotFound    = New(CodePriceListNotFound, "price list not found")
+	ErrSaleNotFound         = New(CodeSaleNotFound, "sale not found")
+	ErrHandleConflict       = New(CodeHandleConflict, model.ErrHandleTaken.Error())
+	ErrProductAlreadyExists = New(CodeProductAlreadyExists, "product record already exists")
+	ErrVariantLimitExceeded = New(CodeVariantLimitExceeded, "max size of product variants is 125")
+	ErrInvalidImage         = New(CodeInvalidImage, "image in wrong format")
+	ErrUploadFailed         = New(CodeUploadFailed, "upload image failed")
+	ErrReviewTransition     = New(CodeReviewTransition, model.ErrReviewTransition.Error())
+)
+
+// New 按错误码返回 gRPC 错误，错误码放在 ErrorInfo 中。未登记的错误码按 Internal 返回
+func New(code, msg string) error {
+	grpcCode, ok := catalog[code]
+	if !ok {
+		grpcCode = codes.Internal
+	}
+	return withDetails(status.New(grpcCode, msg), &errdetails.ErrorInfo{Reason: code, Domain: Domain})
+}
+
+// InvalidField 参数校验失败，字段和原因放在 BadRequest 中
+func InvalidField(field, desc string) error {
+	return invalid(desc, &errdetails.BadRequest_FieldViolation{Field: field, Description: desc})
+}
+
+// Missing 缺少必填参数，传入多个字段时表示至少需要其中一个
+func Missing(fields ...string) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(fields))
+	for _, field := range fields {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: field, Description: field + " is missing"})
+	}
+	msg := fields[len(fields)-1]
+	if len(fields) > 1 {
+		msg = strings.Join(fields[:len(fields)-1], ", ") + " or " + msg
+	}
+	return invalid(msg+" is missing", violations...)
+}
+
+// VersionConflict 期望版本和当前版本不一致，当前版本放在 PreconditionFailure 的 Description 中
+func VersionConflict(subject string, conflict *model.VersionConflictError) error {
+	return withDetails(status.New(codes.FailedPrecondition, conflict.Error()),
+		&errdetails.ErrorInfo{Reason: CodeVersionConflict, Domain: Domain},
+		&errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{{
+				Type:        "VERSION",
+				Subject:     subject,
+				Description: strconv.FormatInt(conflict.Current, 10),
+			}},
+		})
+}
+
+// FromError 把各个包的错误转换成目录中的错误，已经是 gRPC 错误的原样返回，
+// 不认识的错误记录日志后按 Internal 返回，不把内部错误信息返回给调用方
+func FromError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch err {
+	case sqlc.ErrNotFound:
+		return New(CodeNotFound, "record not found")
+	case model.ErrHandleTaken:
+		return ErrHandleConflict
+	case model.ErrHandleInvalid:
+		return InvalidField("handle", err.Error())
+	case model.ErrIdsInvalid:
+		return InvalidField("ids", err.Error())
+	case model.ErrQueryArgMissing:
+		return Missing("product_id", "product_handler")
+	case model.ErrOnlyUnpublishedDeletable:
+		return New(CodeProductPublished, err.Error())
+	case model.ErrTitleBusy, model.ErrHandleBusy:
+		return New(CodeOperationInProgress, err.Error()+", please retry later")
+	case model.ErrReviewTransition:
+		return ErrReviewTransition
+	case pricing.ErrPriceListNotFound:
+		return ErrPriceListNotFound
+	case translation.ErrLocaleInvalid:
+		return InvalidField("locale", err.Error())
+	case metafield.ErrOwnerInvalid:
+		return InvalidField("owner_type", err.Error())
+	case metafield.ErrNameInvalid:
+		return InvalidField("key", err.Error())
+	case metafield.ErrTypeInvalid:
+		return InvalidField("type", err.Error())
+	case fetcher.ErrInvalidUrl, fetcher.ErrBlockedAddress, fetcher.ErrTooManyRedirects, fetcher.ErrTooLarge,
+		fetcher.ErrNotImage, fetcher.ErrUnexpectedStatus, fetcher.ErrUnsafeSvg:
+		return New(CodeInvalidImage, err.Error())
+	case fetcher.ErrHostBusy:
+		return New(CodeTooManyRequests, err.Error())
+	case context.Canceled:
+		return New(CodeCanceled, err.Error())
+	case context.DeadlineExceeded:
+		return New(CodeDeadlineExceeded, err.Error())
+	}
+	if conflict, ok := err.(*model.VersionConflictError); ok {
+		return VersionConflict("", conflict)
+	}
+	logx.Error("未登记的错误：", err)
+	return ErrInternal
+}
+
+// UnaryServerInterceptor 兜底转换逻辑层直接返回的错误，保证调用方拿到的都有错误码
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, FromError(err)
+	}
+}
+
+// StreamServerInterceptor 和 UnaryServerInterceptor 相同，用于流式接口
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return FromError(handler(srv, ss))
+	}
+}
+
+// Message 返回不带状态码前缀的错误信息，用于写入返回结果中的单行错误
+func Message(err error) string {
+	if st, ok := status.FromError(err); ok {
+		return st.Message()
+	}
+	return err.Error()
+}
+
+func invalid(msg string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	return withDetails(status.New(codes.InvalidArgument, msg),
+		&errdetails.ErrorInfo{Reason: CodeInvalidArgument, Domain: Domain},
+		&errdetails.BadRequest{FieldViolations: violations})
+}
+
+// withDetails 附加详情失败时退化为不带详情的错误，状态码和信息不变
+func withDetails(st *status.Status, details ...proto.Message) error {
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		logx.Error("附加错误详情失败：", err)
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -0,0 +1,320 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+)
+
+var (
+	ErrInvalidUrl       = errors.New("image url is invalid")
+	ErrBlockedAddress   = errors.New("image url resolves to a private address")
+	ErrTooManyRedirects = errors.New("image url redirected too many times")
+	ErrTooLarge         = errors.New("image size beyond limit")
+	ErrNotImage         = errors.New("image url is not an image")
+	ErrUnexpectedStatus = errors.New("image url responded with unexpected status")
+	ErrHostBusy         = errors.New("too many concurrent downloads for image host")
+	ErrUnsafeSvg        = errors.New("svg image contains script")
+	blockedNets         = parseCIDRs(
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"100.64.0.0/10", // 运营商级NAT，云厂商的元数据服务也常在这类内网段上
+		"169.254.0.0/16",
+		"fc00::/7",
+		"fe80::/10",
+	)
+	supportedContentType = map[string]string{
+		"image/png":     ".png",
+		"image/jpeg":    ".jpg",
+		"image/gif":     ".gif",
+		"image/bmp":     ".bmp",
+		"image/webp":    ".webp",
+		"image/svg+xml": ".svg",
+	}
+)
+
+type (
+	// Fetcher 下载商户提供的远程图片：拨号时拦截内网/链路本地地址，限制重定向次数，
+	// 边读边限制大小，并按内容而不是url后缀判断是否为图片
+	Fetcher struct {
+		client       *http.Client
+		maxBytes     int64
+		perHostLimit int
+		lock         sync.Mutex
+		hosts        map[string]*hostSlot
+	}
+
+	// hostSlot 同一个域名的下载并发槽，refs 为正在下载和排队的请求数，降为0时从 hosts 中删除
+	hostSlot struct {
+		sem  chan struct{}
+		refs int
+	}
+
+	Image struct {
+		Body        []byte
+		ContentType string
+		Ext         string
+		Width       int64
+	}
+)
+
+func NewFetcher(c config.ImageFetch) *Fetcher {
+	return &Fetcher{
+		client:       NewClient(c),
+		maxBytes:     c.MaxBytes,
+		perHostLimit: c.PerHostConcurrency,
+		hosts:        map[string]*hostSlot{},
+	}
+}
+
+// NewClient 返回拨号时拦截内网地址、限制重定向次数的 http.Client，
+// 其他需要请求商户提供的地址的地方（如webhook）也应使用它
+func NewClient(c config.ImageFetch) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: time.Duration(c.DialTimeout) * time.Millisecond,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if c.AllowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if isBlockedIP(net.ParseIP(host)) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   time.Duration(c.DialTimeout) * time.Millisecond,
+		ResponseHeaderTimeout: time.Duration(c.Timeout) * time.Millisecond,
+		MaxIdleConnsPerHost:   c.PerHostConcurrency,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(c.Timeout) * time.Millisecond,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > c.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidUrl
+			}
+			return nil
+		},
+	}
+}
+
+func (f *Fetcher) Fetch(ctx context.Context, rawUrl string) (*Image, error) {
+	u, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, ErrInvalidUrl
+	}
+
+	release, err := f.acquire(ctx, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidUrl
+	}
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		// 拨号和重定向阶段返回的错误会被 url.Error/net.OpError 包一层，这里还原成统一的错误
+		for _, known := range []error{ErrBlockedAddress, ErrTooManyRedirects, ErrInvalidUrl} {
+			if errors.Is(err, known) {
+				return nil, known
+			}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedStatus, resp.Status)
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > f.maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType, err := sniff(body)
+	if err != nil {
+		return nil, err
+	}
+	ext, ok := supportedContentType[contentType]
+	if !ok {
+		return nil, ErrNotImage
+	}
+	img := &Image{
+		Body:        body,
+		ContentType: contentType,
+		Ext:         ext,
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(body)); err == nil {
+		img.Width = int64(cfg.Width)
+	}
+	return img, nil
+}
+
+// acquire 限制同一个域名同时下载的数量，避免单个商户的图床被打爆或拖慢其他商户。
+// 域名没有下载和排队的请求时删除对应的槽，hosts 的大小不超过同时在下载的域名数
+func (f *Fetcher) acquire(ctx context.Context, host string) (func(), error) {
+	if f.perHostLimit <= 0 {
+		return func() {}, nil
+	}
+	f.lock.Lock()
+	slot, ok := f.hosts[host]
+	if !ok {
+		slot = &hostSlot{sem: make(chan struct{}, f.perHostLimit)}
+		f.hosts[host] = slot
+	}
+	slot.refs++
+	f.lock.Unlock()
+
+	timer := time.NewTimer(f.client.Timeout)
+	defer timer.Stop()
+	select {
+	case slot.sem <- struct{}{}:
+		return func() {
+			<-slot.sem
+			f.leave(host, slot)
+		}, nil
+	case <-timer.C:
+		f.leave(host, slot)
+		return nil, ErrHostBusy
+	case <-ctx.Done():
+		f.leave(host, slot)
+		return nil, ErrHostBusy
+	}
+}
+
+func (f *Fetcher) leave(host string, slot *hostSlot) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	slot.refs--
+	if slot.refs == 0 {
+		delete(f.hosts, host)
+	}
+}
+
+func sniff(body []byte) (string, error) {
+	contentType := http.DetectContentType(body)
+	if i := strings.Index(contentType, ";"); i != -1 {
+		contentType = contentType[:i]
+	}
+	if _, ok := supportedContentType[contentType]; ok {
+		return contentType, nil
+	}
+	// DetectContentType 不识别 svg，只把根节点是 svg 的 xml/文本 当作图片
+	if strings.HasPrefix(contentType, "text/") {
+		isSvg, err := inspectSvg(body)
+		if err != nil {
+			return "", err
+		}
+		if isSvg {
+			return "image/svg+xml", nil
+		}
+	}
+	return contentType, nil
+}
+
+// inspectSvg 解析整个文档，根节点不是 svg 时返回 false。svg 会按原样保存并在店铺域名下访问，
+// 包含脚本、foreignObject、on* 事件属性或 javascript: 链接时返回 ErrUnsafeSvg
+func inspectSvg(body []byte) (bool, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	root := true
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return !root, nil
+		}
+		if err != nil {
+			return false, nil
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if root {
+				if name != "svg" {
+					return false, nil
+				}
+				root = false
+			}
+			if name == "script" || name == "foreignobject" {
+				return true, ErrUnsafeSvg
+			}
+			for _, attr := range t.Attr {
+				attrName := strings.ToLower(attr.Name.Local)
+				value := strings.ToLower(strings.TrimSpace(attr.Value))
+				if strings.HasPrefix(attrName, "on") || strings.HasPrefix(value, "javascript:") {
+					return true, ErrUnsafeSvg
+				}
+			}
+		case xml.CharData:
+			if root && len(bytes.TrimSpace(t)) != 0 {
+				return false, nil
+			}
+		}
+	}
+}
+
+func isBlockedIP(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
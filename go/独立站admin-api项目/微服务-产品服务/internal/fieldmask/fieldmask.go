@@ -0,0 +1,86 @@
+package fieldmask
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schema 可以选择的字段，值为 nil 的是叶子字段，否则可以用 a.b 继续选择子字段
+type Schema map[string]Schema
+
+// Mask 解析后的字段选择，只选父字段时包含全部子字段
+type Mask struct {
+	all      bool
+	children map[string]*Mask
+}
+
+// All 返回包含全部字段的 Mask
+func All() Mask {
+	return Mask{all: true}
+}
+
+// Parse 解析逗号分隔的字段列表，例如 id,title,variants.sku；为空时返回全部字段，未知字段返回错误
+func Parse(raw string, schema Schema) (Mask, error) {
+	mask := Mask{}
+	for _, item := range strings.Split(raw, ",") {
+		path := strings.TrimSpace(item)
+		if path == "" {
+			continue
+		}
+		names := strings.Split(path, ".")
+		if !valid(names, schema) {
+			return Mask{}, fmt.Errorf("unknown field: %s", path)
+		}
+		mask.add(names)
+	}
+	if mask.children == nil {
+		return All(), nil
+	}
+	return mask, nil
+}
+
+func valid(names []string, schema Schema) bool {
+	for i, name := range names {
+		sub, ok := schema[name]
+		if !ok || (sub == nil && i < len(names)-1) {
+			return false
+		}
+		schema = sub
+	}
+	return true
+}
+
+func (m *Mask) add(names []string) {
+	if m.all {
+		return
+	}
+	if m.children == nil {
+		m.children = make(map[string]*Mask)
+	}
+	child := m.children[names[0]]
+	if child == nil {
+		child = &Mask{}
+		m.children[names[0]] = child
+	}
+	if len(names) == 1 {
+		child.all, child.children = true, nil
+		return
+	}
+	child.add(names[1:])
+}
+
+// Has 字段本身或它的任一子字段被选中
+func (m Mask) Has(name string) bool {
+	return m.all || m.children[name] != nil
+}
+
+// Sub 返回子字段的选择
+func (m Mask) Sub(name string) Mask {
+	if m.all {
+		return All()
+	}
+	if child := m.children[name]; child != nil {
+		return *child
+	}
+	return Mask{}
+}
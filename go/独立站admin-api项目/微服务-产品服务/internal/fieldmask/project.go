@@ -0,0 +1,178 @@
+package fieldmask
+
+import "gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+// ProductDefault 产品接口未传 fields 时返回的字段，metafields、rating 和 tags 需要单独查询，只在指定时返回
+const ProductDefault = "id,title,price,compare_price,weight,weight_unit,shop_url,product_url,body_html,seo_title,seo_desc,published_at,is_use_stock,soldout_policy,handler,status,created_at,updated_at,images,default_image,variants," +
+	"sub_title,attribute,comments,is_show_comment,scores,count_skus,count_success_views,is_read,count_sales,youtube_video_url,youtube_video_pos,vendor,product_type,currency,version"
+
+var (
+	Product  Schema
+	Variant  Schema
+	Image    Schema
+	Category Schema
+)
+
+// 字段名沿用 fields 参数原有的写法，例如 title、compare_price、handler，和 proto 字段名不完全一致
+var (
+	productFields = map[string]func(p *product.Product){
+		"id":                  func(p *product.Product) { p.ProductId = 0 },
+		"title":               func(p *product.Product) { p.ProductTitle = "" },
+		"price":               func(p *product.Product) { p.Price = 0 },
+		"compare_price":       func(p *product.Product) { p.CompareAtPrice = 0 },
+		"weight":              func(p *product.Product) { p.Weight = 0 },
+		"weight_unit":         func(p *product.Product) { p.WeightUnit = "" },
+		"shop_url":            func(p *product.Product) { p.ShopUrl = "" },
+		"product_url":         func(p *product.Product) { p.PreviewUrl = "" },
+		"body_html":           func(p *product.Product) { p.BodyHtml = "" },
+		"seo_title":           func(p *product.Product) { p.SeoTitle = "" },
+		"seo_desc":            func(p *product.Product) { p.SeoDesc = "" },
+		"published_at":        func(p *product.Product) { p.PublishedAt = "" },
+		"is_use_stock":        func(p *product.Product) { p.IsUseStock = 0 },
+		"soldout_policy":      func(p *product.Product) { p.SoldoutPolicy = "" },
+		"handler":             func(p *product.Product) { p.Handle = "" },
+		"status":              func(p *product.Product) { p.Status = 0 },
+		"created_at":          func(p *product.Product) { p.CreatedAt = "" },
+		"updated_at":          func(p *product.Product) { p.UpdatedAt = "" },
+		"images":              func(p *product.Product) { p.Images = nil },
+		"default_image":       func(p *product.Product) { p.DefaultImage = nil },
+		"variants":            func(p *product.Product) { p.Variants = nil },
+		"sub_title":           func(p *product.Product) { p.SubTitle = "" },
+		"attribute":           func(p *product.Product) { p.Attribute = "" },
+		"comments":            func(p *product.Product) { p.Comments = 0 },
+		"is_show_comment":     func(p *product.Product) { p.IsShowComment = 0 },
+		"scores":              func(p *product.Product) { p.Scores = 0 },
+		"count_skus":          func(p *product.Product) { p.CountSkus = 0 },
+		"count_success_views": func(p *product.Product) { p.CountSuccessViews = 0 },
+		"is_read":             func(p *product.Product) { p.IsRead = 0 },
+		"count_sales":         func(p *product.Product) { p.CountSales = 0 },
+		"youtube_video_url":   func(p *product.Product) { p.YoutubeVideoUrl = "" },
+		"youtube_video_pos":   func(p *product.Product) { p.YoutubeVideoPos = 0 },
+		"vendor":              func(p *product.Product) { p.Vendor = "" },
+		"product_type":        func(p *product.Product) { p.ProductType = "" },
+		"currency":            func(p *product.Product) { p.Currency = "" },
+		"metafields":          func(p *product.Product) { p.Metafields = nil },
+		"rating":              func(p *product.Product) { p.RatingAverage, p.RatingScore = 0, 0 },
+		"tags":                func(p *product.Product) { p.Tags = nil },
+		"version":             func(p *product.Product) { p.Version = 0 },
+	}
+	variantFields = map[string]func(v *product.ProductVariant){
+		"id":                 func(v *product.ProductVariant) { v.Id = 0 },
+		"sku":                func(v *product.ProductVariant) { v.Sku = "" },
+		"title":              func(v *product.ProductVariant) { v.Title = "" },
+		"price":              func(v *product.ProductVariant) { v.Price = 0 },
+		"compare_price":      func(v *product.ProductVariant) { v.ComparePrice = 0 },
+		"spec":               func(v *product.ProductVariant) { v.Spec = "" },
+		"weight":             func(v *product.ProductVariant) { v.Weight = 0 },
+		"weight_unit":        func(v *product.ProductVariant) { v.WeightUnit = "" },
+		"requires_shipping":  func(v *product.ProductVariant) { v.RequiresShipping = 0 },
+		"image_id":           func(v *product.ProductVariant) { v.ImageId = 0 },
+		"created_at":         func(v *product.ProductVariant) { v.CreatedAt = "" },
+		"updated_at":         func(v *product.ProductVariant) { v.UpdatedAt = "" },
+		"sort":               func(v *product.ProductVariant) { v.Sort = 0 },
+		"inventory_quantity": func(v *product.ProductVariant) { v.InventoryQuantity = 0 },
+		"inventory_policy":   func(v *product.ProductVariant) { v.InventoryPolicy = "" },
+		"product_id":         func(v *product.ProductVariant) { v.ProductId = 0 },
+		"is_show":            func(v *product.ProductVariant) { v.IsShow = 0 },
+		"image_url":          func(v *product.ProductVariant) { v.ImageUrl = "" },
+		"metafields":         func(v *product.ProductVariant) { v.Metafields = nil },
+		"version":            func(v *product.ProductVariant) { v.Version = 0 },
+	}
+	imageFields = map[string]func(i *product.ProductImage){
+		"id":          func(i *product.ProductImage) { i.Id = 0 },
+		"product_id":  func(i *product.ProductImage) { i.ProductId = 0 },
+		"sort":        func(i *product.ProductImage) { i.Sort = 0 },
+		"width":       func(i *product.ProductImage) { i.Width = 0 },
+		"url":         func(i *product.ProductImage) { i.Url = "" },
+		"variant_ids": func(i *product.ProductImage) { i.VariantIds = nil },
+		"file_key":    func(i *product.ProductImage) { i.FileKey = "" },
+		"created_at":  func(i *product.ProductImage) { i.CreatedAt = "" },
+		"updated_at":  func(i *product.ProductImage) { i.UpdatedAt = "" },
+	}
+	categoryFields = map[string]func(c *product.Category){
+		"id":                func(c *product.Category) { c.Id = 0 },
+		"handler":           func(c *product.Category) { c.Handler = "" },
+		"title":             func(c *product.Category) { c.Title = "" },
+		"body_html":         func(c *product.Category) { c.BodyHtml = "" },
+		"count_products":    func(c *product.Category) { c.CountProducts = 0 },
+		"product_ids":       func(c *product.Category) { c.ProductIds = "" },
+		"seo_title":         func(c *product.Category) { c.SeoTitle = "" },
+		"seo_desc":          func(c *product.Category) { c.SeoDesc = "" },
+		"created_at":        func(c *product.Category) { c.CreatedAt = "" },
+		"updated_at":        func(c *product.Category) { c.UpdatedAt = "" },
+		"product_sort_type": func(c *product.Category) { c.ProductSortType = "" },
+		"image_url":         func(c *product.Category) { c.ImageUrl = "" },
+	}
+)
+
+func init() {
+	Image = make(Schema)
+	for name := range imageFields {
+		Image[name] = nil
+	}
+	Variant = make(Schema)
+	for name := range variantFields {
+		Variant[name] = nil
+	}
+	Category = make(Schema)
+	for name := range categoryFields {
+		Category[name] = nil
+	}
+	Product = make(Schema)
+	for name := range productFields {
+		Product[name] = nil
+	}
+	Product["images"], Product["default_image"], Product["variants"] = Image, Image, Variant
+}
+
+// ProjectProduct 清空未选择的字段，在查询、翻译等处理都完成后调用
+func ProjectProduct(m Mask, p *product.Product) {
+	if m.all || p == nil {
+		return
+	}
+	for name, clear := range productFields {
+		if !m.Has(name) {
+			clear(p)
+		}
+	}
+	for _, image := range p.Images {
+		ProjectImage(m.Sub("images"), image)
+	}
+	ProjectImage(m.Sub("default_image"), p.DefaultImage)
+	for _, variant := range p.Variants {
+		ProjectVariant(m.Sub("variants"), variant)
+	}
+}
+
+func ProjectVariant(m Mask, v *product.ProductVariant) {
+	if m.all || v == nil {
+		return
+	}
+	for name, clear := range variantFields {
+		if !m.Has(name) {
+			clear(v)
+		}
+	}
+}
+
+func ProjectImage(m Mask, i *product.ProductImage) {
+	if m.all || i == nil {
+		return
+	}
+	for name, clear := range imageFields {
+		if !m.Has(name) {
+			clear(i)
+		}
+	}
+}
+
+func ProjectCategory(m Mask, c *product.Category) {
+	if m.all || c == nil {
+		return
+	}
+	for name, clear := range categoryFields {
+		if !m.Has(name) {
+			clear(c)
+		}
+	}
+}
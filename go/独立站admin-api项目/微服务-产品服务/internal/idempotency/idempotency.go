@@ -0,0 +1,166 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// MetadataKey 调用方在 gRPC metadata 中传入的幂等键，同一个键重试时返回第一次的结果
+	MetadataKey = "idempotency-key"
+
+	keyPrefix    = "product:idempotency:"
+	maxKeyLength = 128
+)
+
+var (
+	ErrKeyConflict = errorx.New(errorx.CodeIdempotencyConflict, "idempotency key was used with a different request")
+	ErrInProgress  = errorx.New(errorx.CodeOperationInProgress, "request with the same idempotency key is in progress")
+	ErrKeyInvalid  = errorx.InvalidField(MetadataKey, fmt.Sprintf("idempotency key must be 1-%d characters", maxKeyLength))
+
+	// 支持幂等键的写接口，值用于重放时反序列化保存的返回
+	responses = map[string]func() proto.Message{
+		"/product.ProductRPC/ProductAdd":         func() proto.Message { return &product.ProductAddResponse{} },
+		"/product.ProductRPC/ProductUpdate":      func() proto.Message { return &product.ProductUpdateResponse{} },
+		"/product.ProductRPC/ProductVariantAdd":  func() proto.Message { return &product.ProductVariantAddResponse{} },
+		"/product.ProductRPC/ProductImageAdd":    func() proto.Message { return &product.ProductImageAddResponse{} },
+		"/product.ProductRPC/CategoryProductAdd": func() proto.Message { return &product.CategoryProductAddResponse{} },
+	}
+)
+
+type (
+	// record 处理中的记录只有 Hash，完成后保存序列化的返回
+	record struct {
+		Hash     string `json:"hash"`
+		Done     bool   `json:"done"`
+		Response []byte `json:"response,omitempty"`
+	}
+
+	shopRequest interface {
+		GetShopId() int64
+	}
+)
+
+// UnaryServerInterceptor 带幂等键的写请求先占用键再执行，成功后保存请求摘要和返回。
+// 同一个键重试时摘要一致直接返回保存的结果，不一致返回冲突；执行失败会删除键，允许重试重新执行
+func UnaryServerInterceptor(rds *redis.Redis, conf config.Idempotency) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		newResponse, ok := responses[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		idempotencyKey := fromContext(ctx)
+		if idempotencyKey == "" {
+			return handler(ctx, req)
+		}
+		if len(idempotencyKey) > maxKeyLength {
+			return nil, ErrKeyInvalid
+		}
+
+		hash, err := digest(req.(proto.Message))
+		if err != nil {
+			logx.Error("计算请求摘要失败：", err)
+			return nil, errorx.ErrInternal
+		}
+		var shopId int64
+		if r, ok := req.(shopRequest); ok {
+			shopId = r.GetShopId()
+		}
+		key := fmt.Sprintf("%s%s:%d:%s", keyPrefix, info.FullMethod, shopId, idempotencyKey)
+
+		pending, _ := json.Marshal(record{Hash: hash})
+		acquired, err := rds.SetnxEx(key, string(pending), conf.PendingExpire)
+		if err != nil {
+			// redis不可用时不阻塞写入，退化为没有幂等保护
+			logx.Error("保存幂等键失败：", err)
+			return handler(ctx, req)
+		}
+		if !acquired {
+			return replay(rds, key, hash, newResponse)
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			if _, delErr := rds.Del(key); delErr != nil {
+				logx.Error("删除幂等键失败：", delErr)
+			}
+			return resp, err
+		}
+		data, err := proto.Marshal(resp.(proto.Message))
+		if err == nil {
+			var done []byte
+			done, err = json.Marshal(record{Hash: hash, Done: true, Response: data})
+			if err == nil {
+				err = rds.Setex(key, string(done), conf.Expire)
+			}
+		}
+		if err != nil {
+			logx.Errorf("保存幂等结果失败 key:%s err:%s", key, err)
+		}
+		return resp, nil
+	}
+}
+
+func replay(rds *redis.Redis, key, hash string, newResponse func() proto.Message) (interface{}, error) {
+	val, err := rds.Get(key)
+	if err != nil {
+		logx.Error("读取幂等键失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	if val == "" {
+		// 第一次请求刚好失败删除了键，让调用方重试
+		return nil, ErrInProgress
+	}
+	var saved record
+	if err := json.Unmarshal([]byte(val), &saved); err != nil {
+		logx.Errorf("幂等记录内容不合法 key:%s err:%s", key, err)
+		return nil, errorx.ErrInternal
+	}
+	if saved.Hash != hash {
+		return nil, ErrKeyConflict
+	}
+	if !saved.Done {
+		return nil, ErrInProgress
+	}
+	resp := newResponse()
+	if err := proto.Unmarshal(saved.Response, resp); err != nil {
+		logx.Errorf("幂等记录返回不合法 key:%s err:%s", key, err)
+		return nil, errorx.ErrInternal
+	}
+	return resp, nil
+}
+
+func fromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// digest 按字段顺序稳定序列化，map 字段的顺序不影响摘要
+func digest(req proto.Message) (string, error) {
+	var buf proto.Buffer
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(req); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,180 @@
+package jsonld
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	schemaContext = "https://schema.org/"
+
+	AvailabilityInStock    = "https://schema.org/InStock"
+	AvailabilityOutOfStock = "https://schema.org/OutOfStock"
+	AvailabilityBackOrder  = "https://schema.org/BackOrder"
+
+	conditionNew  = "https://schema.org/NewCondition"
+	priceListType = "https://schema.org/ListPrice"
+)
+
+type (
+	Product struct {
+		Context         string           `json:"@context"`
+		Type            string           `json:"@type"`
+		Id              string           `json:"@id"`
+		Name            string           `json:"name"`
+		Url             string           `json:"url"`
+		Description     string           `json:"description,omitempty"`
+		Sku             string           `json:"sku,omitempty"`
+		Image           []string         `json:"image,omitempty"`
+		Brand           *Brand           `json:"brand,omitempty"`
+		Offers          []Offer          `json:"offers"`
+		AggregateRating *AggregateRating `json:"aggregateRating,omitempty"`
+	}
+
+	Brand struct {
+		Type string `json:"@type"`
+		Name string `json:"name"`
+	}
+
+	Offer struct {
+		Type               string               `json:"@type"`
+		Url                string               `json:"url"`
+		Name               string               `json:"name,omitempty"`
+		Sku                string               `json:"sku,omitempty"`
+		Image              string               `json:"image,omitempty"`
+		Price              string               `json:"price"`
+		PriceCurrency      string               `json:"priceCurrency"`
+		Availability       string               `json:"availability"`
+		ItemCondition      string               `json:"itemCondition"`
+		PriceSpecification []PriceSpecification `json:"priceSpecification,omitempty"`
+	}
+
+	PriceSpecification struct {
+		Type          string `json:"@type"`
+		Price         string `json:"price"`
+		PriceCurrency string `json:"priceCurrency"`
+		PriceType     string `json:"priceType"`
+	}
+
+	AggregateRating struct {
+		Type        string `json:"@type"`
+		RatingValue string `json:"ratingValue"`
+		ReviewCount int64  `json:"reviewCount"`
+		BestRating  int64  `json:"bestRating"`
+		WorstRating int64  `json:"worstRating"`
+	}
+
+	// ProductInput 生成结构化数据需要的产品信息，价格单位与店铺币种一致
+	ProductInput struct {
+		Url          string
+		Title        string
+		Description  string
+		Sku          string
+		Vendor       string
+		Currency     string
+		Images       []string
+		Price        float64 // 没有子商品时使用产品上的价格和库存
+		ComparePrice float64
+		Inventory    int64
+		UseStock     bool
+		ContinueSell bool // 售罄后是否继续出售
+		Comments     int64
+		Scores       int64 // 评分总和
+		Variants     []VariantInput
+	}
+
+	VariantInput struct {
+		Id           int64
+		Title        string
+		Sku          string
+		Image        string
+		Price        float64
+		ComparePrice float64
+		Inventory    int64
+	}
+)
+
+// BuildProduct 生成 schema.org Product 文档，每个子商品对应一个 Offer，没有子商品时按产品生成一个 Offer。
+// 使用 encoding/json 默认的 HTML 转义，结果可以直接放进 <script type="application/ld+json">
+func BuildProduct(in ProductInput) ([]byte, error) {
+	doc := Product{
+		Context:     schemaContext,
+		Type:        "Product",
+		Id:          in.Url + "#product",
+		Name:        in.Title,
+		Url:         in.Url,
+		Description: in.Description,
+		Sku:         in.Sku,
+		Image:       in.Images,
+		Offers:      make([]Offer, 0, len(in.Variants)),
+	}
+	if in.Vendor != "" {
+		doc.Brand = &Brand{Type: "Brand", Name: in.Vendor}
+	}
+	for _, variant := range in.Variants {
+		offer := buildOffer(in, variant.Price, variant.ComparePrice, variant.Inventory)
+		offer.Url = in.Url + "?variant=" + strconv.FormatInt(variant.Id, 10)
+		offer.Name = variant.Title
+		offer.Sku = variant.Sku
+		offer.Image = variant.Image
+		doc.Offers = append(doc.Offers, offer)
+	}
+	if len(in.Variants) == 0 {
+		offer := buildOffer(in, in.Price, in.ComparePrice, in.Inventory)
+		offer.Sku = in.Sku
+		doc.Offers = append(doc.Offers, offer)
+	}
+	if in.Comments > 0 && in.Scores > 0 {
+		rating := float64(in.Scores) / float64(in.Comments)
+		if rating > 5 {
+			rating = 5
+		}
+		if rating < 1 {
+			rating = 1
+		}
+		doc.AggregateRating = &AggregateRating{
+			Type:        "AggregateRating",
+			RatingValue: strconv.FormatFloat(rating, 'f', 1, 64),
+			ReviewCount: in.Comments,
+			BestRating:  5,
+			WorstRating: 1,
+		}
+	}
+	return json.Marshal(doc)
+}
+
+func buildOffer(in ProductInput, price, comparePrice float64, inventory int64) Offer {
+	offer := Offer{
+		Type:          "Offer",
+		Url:           in.Url,
+		Price:         formatPrice(price),
+		PriceCurrency: in.Currency,
+		Availability:  Availability(in.UseStock, in.ContinueSell, inventory),
+		ItemCondition: conditionNew,
+	}
+	if comparePrice > price {
+		offer.PriceSpecification = []PriceSpecification{{
+			Type:          "UnitPriceSpecification",
+			Price:         formatPrice(comparePrice),
+			PriceCurrency: in.Currency,
+			PriceType:     priceListType,
+		}}
+	}
+	return offer
+}
+
+// Availability 未启用库存时始终有货；库存用完后按售罄策略区分可预订和缺货
+func Availability(useStock, continueSell bool, inventory int64) string {
+	switch {
+	case !useStock || inventory > 0:
+		return AvailabilityInStock
+	case continueSell:
+		return AvailabilityBackOrder
+	default:
+		return AvailabilityOutOfStock
+	}
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
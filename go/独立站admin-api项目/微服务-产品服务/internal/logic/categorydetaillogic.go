@@ -2,9 +2,11 @@ package logic
 
 import (
 	"context"
-	"errors"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
 	"time"
 
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
@@ -28,6 +30,11 @@ func NewCategoryDetailLogic(ctx context.Context, svcCtx *svc.ServiceContext) *Ca
 }
 
 func (l *CategoryDetailLogic) CategoryDetail(in *product.CategoryDetailRequest) (*product.CategoryDetailResponse, error) {
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Category)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return &product.CategoryDetailResponse{}, errorx.InvalidField("fields", err.Error())
+	}
 	options := make([]model.HandlerOption, 0)
 	if in.CategoryId != 0 {
 		options = append(options, model.WithId(in.CategoryId))
@@ -40,14 +47,17 @@ func (l *CategoryDetailLogic) CategoryDetail(in *product.CategoryDetailRequest)
 	case nil:
 		productIds := ""
 
-		count, err := l.svcCtx.ReadCategoryProductModel.Count(in.ShopId, respItem.Id)
-		switch err {
-		case nil:
+		var count int64
+		if mask.Has("count_products") {
+			count, err = l.svcCtx.ReadCategoryProductModel.Count(in.ShopId, respItem.Id)
+			switch err {
+			case nil:
 
-		case sqlc.ErrNotFound:
+			case sqlc.ErrNotFound:
 
-		default:
-			return &product.CategoryDetailResponse{}, err
+			default:
+				return &product.CategoryDetailResponse{}, errorx.ErrInternal
+			}
 		}
 		item := product.Category{
 			Id:              respItem.Id,
@@ -63,22 +73,35 @@ func (l *CategoryDetailLogic) CategoryDetail(in *product.CategoryDetailRequest)
 			ProductSortType: respItem.ProductSortType,
 		}
 
-		respImage, err := l.svcCtx.ReadImageModel.FindOne(respItem.ImageId)
-		switch err {
+		if mask.Has("image_url") {
+			respImage, err := l.svcCtx.ReadImageModel.FindOne(respItem.ImageId)
+			switch err {
+			case nil:
+				item.ImageUrl = respImage.FileKey
+			case sqlc.ErrNotFound:
+				l.Error("分类图片不存在")
+			default:
+				l.Error("获取分类图片出错：", err)
+			}
+		}
+		switch err := translateCategory(l.svcCtx, in.ShopId, in.Locale, &item); err {
 		case nil:
-			item.ImageUrl = respImage.FileKey
-		case sqlc.ErrNotFound:
-			l.Error("分类图片不存在")
+		case translation.ErrLocaleInvalid:
+			l.Error("locale参数不合法：", in.Locale)
+			return &product.CategoryDetailResponse{}, errorx.InvalidField("locale", err.Error())
 		default:
-			l.Error("获取分类图片出错：", err)
+			l.Error("查询分类翻译出错：", err)
+			return &product.CategoryDetailResponse{}, errorx.ErrInternal
 		}
 
+		fieldmask.ProjectCategory(mask, &item)
+
 		return &product.CategoryDetailResponse{Category: &item}, nil
 	case sqlc.ErrNotFound:
 		l.Error("查询分类为空")
-		return &product.CategoryDetailResponse{}, errors.New("category record not found")
+		return &product.CategoryDetailResponse{}, errorx.ErrCategoryNotFound
 	default:
 		l.Error("查询产品图片出错：", err)
-		return &product.CategoryDetailResponse{}, err
+		return &product.CategoryDetailResponse{}, errorx.ErrInternal
 	}
 }
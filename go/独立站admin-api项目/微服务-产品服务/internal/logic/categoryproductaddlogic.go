@@ -2,8 +2,8 @@ package logic
 
 import (
 	"context"
-	"errors"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
@@ -38,10 +38,10 @@ func (l *CategoryProductAddLogic) CategoryProductAdd(in *product.CategoryProduct
 
 	case sqlc.ErrNotFound:
 		l.Error("category record not found")
-		return nil, errors.New("category record not found")
+		return nil, errorx.ErrCategoryNotFound
 	default:
 		l.Error(err)
-		return nil, err
+		return nil, errorx.ErrInternal
 	}
 	_, err = l.svcCtx.ReadModel.FindOne(in.ShopId, model.WithId(in.ProductId))
 	switch err {
@@ -49,33 +49,33 @@ func (l *CategoryProductAddLogic) CategoryProductAdd(in *product.CategoryProduct
 
 	case sqlc.ErrNotFound:
 		l.Error("product record not found")
-		return nil, errors.New("product record not found")
+		return nil, errorx.ErrProductNotFound
 	default:
 		l.Error(err)
-		return nil, err
+		return nil, errorx.ErrInternal
 	}
 
 	_, err = l.svcCtx.ReadCategoryProductModel.FindOneByShopIdCategoryIdProductId(in.ShopId, in.CategoryId, in.ProductId)
 	switch err {
 	case nil:
 		l.Error("product record already exists")
-		return nil, errors.New("product record already exists")
+		return nil, errorx.ErrProductAlreadyExists
 	case sqlc.ErrNotFound:
 
 	default:
 		l.Error(err)
-		return nil, errors.New("internal server error")
+		return nil, errorx.ErrInternal
 	}
 
 	result, err := l.svcCtx.WriteCategoryProductModel.Insert(data)
 	if err != nil {
 		l.Error("insert category_product failed:", err)
-		return &product.CategoryProductAddResponse{}, err
+		return &product.CategoryProductAddResponse{}, errorx.ErrInternal
 	}
 	lastId, err := result.LastInsertId()
 	if err != nil {
 		l.Error("insert category_product failed:", err)
-		return &product.CategoryProductAddResponse{}, err
+		return &product.CategoryProductAddResponse{}, errorx.ErrInternal
 	}
 	resp, err := l.svcCtx.WriteCategoryProductModel.FindOne(lastId)
 	switch err {
@@ -89,9 +89,9 @@ func (l *CategoryProductAddLogic) CategoryProductAdd(in *product.CategoryProduct
 		return &product.CategoryProductAddResponse{CategoryProduct: &categoryProduct}, nil
 	case sqlc.ErrNotFound:
 		l.Error("insert category product error:", err)
-		return &product.CategoryProductAddResponse{}, errors.New("insert category product error")
+		return &product.CategoryProductAddResponse{}, errorx.ErrInternal
 	default:
 		l.Error("insert category product error:", err)
-		return &product.CategoryProductAddResponse{}, err
+		return &product.CategoryProductAddResponse{}, errorx.ErrInternal
 	}
 }
@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type MetafieldDeleteLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewMetafieldDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *MetafieldDeleteLogic {
+	return &MetafieldDeleteLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *MetafieldDeleteLogic) MetafieldDelete(in *product.MetafieldDeleteRequest) (*product.MetafieldDeleteResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Id == 0 {
+		l.Error("缺少id参数")
+		return nil, errorx.Missing("id")
+	}
+	err := l.svcCtx.WriteMetafieldModel.Delete(in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("自定义字段不存在 id:", in.Id)
+		return nil, errorx.ErrMetafieldNotFound
+	default:
+		l.Error("删除自定义字段失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.MetafieldDeleteResponse{}, nil
+}
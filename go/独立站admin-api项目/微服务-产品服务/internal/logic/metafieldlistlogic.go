@@ -0,0 +1,119 @@
+package logic
+
+import (
+	"context"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type MetafieldListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewMetafieldListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *MetafieldListLogic {
+	return &MetafieldListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *MetafieldListLogic) MetafieldList(in *product.MetafieldListRequest) (*product.MetafieldListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if !metafield.ValidOwner(in.OwnerType) {
+		l.Error("owner_type参数不合法：", in.OwnerType)
+		return nil, errorx.InvalidField("owner_type", metafield.ErrOwnerInvalid.Error())
+	}
+	if in.OwnerId == 0 {
+		l.Error("缺少owner_id参数")
+		return nil, errorx.Missing("owner_id")
+	}
+	resp, err := l.svcCtx.ReadMetafieldModel.FindList(in.ShopId, in.OwnerType, []int64{in.OwnerId}, in.Namespace)
+	if err != nil {
+		l.Error("查询自定义字段失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.MetafieldListResponse{Metafields: toMetafields(*resp)}, nil
+}
+
+func toMetafields(data []model.SailProductMetafield) []*product.Metafield {
+	result := make([]*product.Metafield, 0, len(data))
+	for _, item := range data {
+		result = append(result, &product.Metafield{
+			Id:        item.Id,
+			OwnerType: item.OwnerType,
+			OwnerId:   item.OwnerId,
+			Namespace: item.Namespace,
+			Key:       item.Key,
+			Type:      item.Type,
+			Value:     item.Value,
+			CreatedAt: item.CreatedAt.Local().Format(time.RFC3339),
+			UpdatedAt: item.UpdatedAt.Local().Format(time.RFC3339),
+		})
+	}
+	return result
+}
+
+// attachMetafields 批量查询产品和子商品的自定义字段并填入结果，
+// 产品的由 fields 中的 metafields 决定，子商品的由 variants.metafields 决定
+func attachMetafields(svcCtx *svc.ServiceContext, shopId int64, mask fieldmask.Mask, products []*product.Product) error {
+	withProduct, withVariant := mask.Has("metafields"), mask.Sub("variants").Has("metafields")
+	if len(products) == 0 || (!withProduct && !withVariant) {
+		return nil
+	}
+	if withProduct {
+		productIds := make([]int64, 0, len(products))
+		for _, p := range products {
+			productIds = append(productIds, p.ProductId)
+		}
+		productFields, err := svcCtx.ReadMetafieldModel.FindList(shopId, metafield.OwnerProduct, productIds, "")
+		if err != nil {
+			return err
+		}
+		byProduct := make(map[int64][]model.SailProductMetafield)
+		for _, item := range *productFields {
+			byProduct[item.OwnerId] = append(byProduct[item.OwnerId], item)
+		}
+		for _, p := range products {
+			p.Metafields = toMetafields(byProduct[p.ProductId])
+		}
+	}
+	if withVariant {
+		variantIds := make([]int64, 0)
+		for _, p := range products {
+			for _, variant := range p.Variants {
+				variantIds = append(variantIds, variant.Id)
+			}
+		}
+		if len(variantIds) == 0 {
+			return nil
+		}
+		variantFields, err := svcCtx.ReadMetafieldModel.FindList(shopId, metafield.OwnerVariant, variantIds, "")
+		if err != nil {
+			return err
+		}
+		byVariant := make(map[int64][]model.SailProductMetafield)
+		for _, item := range *variantFields {
+			byVariant[item.OwnerId] = append(byVariant[item.OwnerId], item)
+		}
+		for _, p := range products {
+			for _, variant := range p.Variants {
+				variant.Metafields = toMetafields(byVariant[variant.Id])
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const metafieldSetMax = 50
+
+type MetafieldSetLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewMetafieldSetLogic(ctx context.Context, svcCtx *svc.ServiceContext) *MetafieldSetLogic {
+	return &MetafieldSetLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *MetafieldSetLogic) MetafieldSet(in *product.MetafieldSetRequest) (*product.MetafieldSetResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if !metafield.ValidOwner(in.OwnerType) {
+		l.Error("owner_type参数不合法：", in.OwnerType)
+		return nil, errorx.InvalidField("owner_type", metafield.ErrOwnerInvalid.Error())
+	}
+	if len(in.Metafields) == 0 || len(in.Metafields) > metafieldSetMax {
+		l.Error("自定义字段条数不合法：", len(in.Metafields))
+		return nil, errorx.InvalidField("metafields", fmt.Sprintf("metafields must contain 1 to %d entries", metafieldSetMax))
+	}
+	if err := l.checkOwner(in.ShopId, in.OwnerType, in.OwnerId); err != nil {
+		return nil, err
+	}
+
+	data := make([]model.SailProductMetafield, 0, len(in.Metafields))
+	for _, item := range in.Metafields {
+		if !metafield.ValidName(item.Namespace) || !metafield.ValidName(item.Key) {
+			l.Error("自定义字段名不合法：", item.Namespace, ".", item.Key)
+			return nil, errorx.InvalidField("key", metafield.ErrNameInvalid.Error())
+		}
+		value, err := metafield.Normalize(item.Type, item.Value)
+		if err != nil {
+			l.Error("自定义字段值不合法：", item.Namespace, ".", item.Key, " ", err)
+			return nil, errorx.InvalidField("value", fmt.Sprintf("%s.%s: %s", item.Namespace, item.Key, err))
+		}
+		if item.Type == metafield.TypeProductReference {
+			id, _ := strconv.ParseInt(value, 10, 64)
+			if err := l.checkOwner(in.ShopId, metafield.OwnerProduct, id); err != nil {
+				if err == errorx.ErrInternal {
+					return nil, err
+				}
+				return nil, errorx.InvalidField("value", fmt.Sprintf("%s.%s: referenced %s", item.Namespace, item.Key, errorx.Message(err)))
+			}
+		}
+		data = append(data, model.SailProductMetafield{
+			Namespace: item.Namespace,
+			Key:       item.Key,
+			Type:      item.Type,
+			Value:     value,
+		})
+	}
+
+	if err := l.svcCtx.WriteMetafieldModel.Set(in.ShopId, in.OwnerType, in.OwnerId, data); err != nil {
+		l.Error("保存自定义字段失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err := l.svcCtx.WriteMetafieldModel.FindList(in.ShopId, in.OwnerType, []int64{in.OwnerId}, "")
+	if err != nil {
+		l.Error("查询自定义字段失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.MetafieldSetResponse{Metafields: toMetafields(*resp)}, nil
+}
+
+func (l *MetafieldSetLogic) checkOwner(shopId int64, ownerType string, ownerId int64) error {
+	if ownerId == 0 {
+		l.Error("缺少owner_id参数")
+		return errorx.Missing("owner_id")
+	}
+	ok, err := l.svcCtx.WriteMetafieldModel.OwnerExists(shopId, ownerType, ownerId)
+	if err != nil {
+		l.Error("查询自定义字段所属对象失败：", err)
+		return errorx.ErrInternal
+	}
+	if !ok {
+		l.Error("自定义字段所属对象不存在 owner_type:", ownerType, " owner_id:", ownerId)
+		return ownerNotFound(ownerType)
+	}
+	return nil
+}
+
+// ownerNotFound 自定义字段和翻译所属的产品、子商品或分类不存在
+func ownerNotFound(ownerType string) error {
+	switch ownerType {
+	case metafield.OwnerProduct:
+		return errorx.ErrProductNotFound
+	case metafield.OwnerVariant:
+		return errorx.ErrVariantNotFound
+	case metafield.OwnerCategory:
+		return errorx.ErrCategoryNotFound
+	}
+	return errorx.New(errorx.CodeNotFound, ownerType+" record not found")
+}
@@ -0,0 +1,98 @@
+package logic
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/slug"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const priceListMarketUniqueKey = "uniq_shop_market"
+
+var marketRegexp = regexp.MustCompile(`^[A-Z0-9_-]{1,32}$`)
+
+type PriceListCreateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewPriceListCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PriceListCreateLogic {
+	return &PriceListCreateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *PriceListCreateLogic) PriceListCreate(in *product.PriceListCreateRequest) (*product.PriceListCreateResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	in.Market = strings.ToUpper(strings.TrimSpace(in.Market))
+	if !marketRegexp.MatchString(in.Market) {
+		l.Error("market参数不合法：", in.Market)
+		return nil, errorx.InvalidField("market", "market is invalid")
+	}
+	if in.Status == 0 {
+		in.Status = model.PRICE_LIST_STATUS_ENABLED
+	}
+	data := model.SailProductPriceList{
+		ShopId:   in.ShopId,
+		Market:   in.Market,
+		Currency: strings.ToUpper(in.Currency),
+		Rate:     in.Rate,
+		Rounding: in.Rounding,
+		Status:   in.Status,
+	}
+	if err := validatePriceList(data); err != nil {
+		l.Error("价格表参数不合法：", err)
+		return nil, err
+	}
+
+	result, err := l.svcCtx.WritePriceListModel.Insert(data)
+	if slug.IsDuplicate(err, priceListMarketUniqueKey) {
+		l.Error("市场已存在价格表：", in.Market)
+		return nil, errorx.New(errorx.CodePriceListExists, "market already has a price list")
+	}
+	if err != nil {
+		l.Error("新增价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		l.Error(err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err := l.svcCtx.WritePriceListModel.FindOne(in.ShopId, id)
+	if err != nil {
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.PriceListCreateResponse{PriceList: toPriceList(*resp)}, nil
+}
+
+func validatePriceList(data model.SailProductPriceList) error {
+	if !currencyRegexp.MatchString(data.Currency) {
+		return errorx.InvalidField("currency", "currency is invalid")
+	}
+	if data.Rate <= 0 {
+		return errorx.InvalidField("rate", "rate must be greater than 0")
+	}
+	if !pricing.ValidRounding(data.Rounding) {
+		return errorx.InvalidField("rounding", "rounding is invalid")
+	}
+	if data.Status != model.PRICE_LIST_STATUS_ENABLED && data.Status != model.PRICE_LIST_STATUS_DISABLED {
+		return errorx.InvalidField("status", "status is invalid")
+	}
+	return nil
+}
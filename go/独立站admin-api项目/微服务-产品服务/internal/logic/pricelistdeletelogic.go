@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type PriceListDeleteLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewPriceListDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PriceListDeleteLogic {
+	return &PriceListDeleteLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// PriceListDelete 同时删除价格表中的固定价格
+func (l *PriceListDeleteLogic) PriceListDelete(in *product.PriceListDeleteRequest) (*product.PriceListDeleteResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Id == 0 {
+		l.Error("缺少id参数")
+		return nil, errorx.Missing("id")
+	}
+	err := l.svcCtx.WritePriceListModel.Delete(in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("价格表不存在")
+		return nil, errorx.ErrPriceListNotFound
+	default:
+		l.Error("删除价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.PriceListDeleteResponse{}, nil
+}
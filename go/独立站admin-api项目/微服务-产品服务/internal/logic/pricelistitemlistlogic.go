@@ -0,0 +1,67 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type PriceListItemListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewPriceListItemListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PriceListItemListLogic {
+	return &PriceListItemListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *PriceListItemListLogic) PriceListItemList(in *product.PriceListItemListRequest) (*product.PriceListItemListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.PriceListId == 0 {
+		l.Error("缺少price_list_id参数")
+		return nil, errorx.Missing("price_list_id")
+	}
+	_, err := l.svcCtx.ReadPriceListModel.FindOne(in.ShopId, in.PriceListId)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("价格表不存在")
+		return nil, errorx.ErrPriceListNotFound
+	default:
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	var productIds []int64
+	if in.ProductId != 0 {
+		productIds = []int64{in.ProductId}
+	}
+	resp, err := l.svcCtx.ReadPriceListModel.FindItems(in.PriceListId, productIds)
+	if err != nil {
+		l.Error("查询固定价格失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	result := &product.PriceListItemListResponse{}
+	for _, item := range *resp {
+		result.Items = append(result.Items, &product.PriceListItem{
+			ProductId:      item.ProductId,
+			VariantId:      item.VariantId,
+			Price:          item.Price,
+			CompareAtPrice: item.CompareAtPrice,
+		})
+	}
+	return result, nil
+}
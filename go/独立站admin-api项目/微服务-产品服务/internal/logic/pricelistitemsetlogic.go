@@ -0,0 +1,124 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const priceListItemSetMax = 250
+
+type PriceListItemSetLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewPriceListItemSetLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PriceListItemSetLogic {
+	return &PriceListItemSetLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *PriceListItemSetLogic) PriceListItemSet(in *product.PriceListItemSetRequest) (*product.PriceListItemSetResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.PriceListId == 0 {
+		l.Error("缺少price_list_id参数")
+		return nil, errorx.Missing("price_list_id")
+	}
+	if len(in.Items) == 0 || len(in.Items) > priceListItemSetMax {
+		l.Error("固定价格条数不合法：", len(in.Items))
+		return nil, errorx.InvalidField("items", fmt.Sprintf("items must contain 1 to %d entries", priceListItemSetMax))
+	}
+	_, err := l.svcCtx.WritePriceListModel.FindOne(in.ShopId, in.PriceListId)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("价格表不存在")
+		return nil, errorx.ErrPriceListNotFound
+	default:
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	items := make([]model.SailProductPriceListItem, 0, len(in.Items))
+	variantIds := make(map[int64]map[int64]bool)
+	for _, item := range in.Items {
+		if item.ProductId == 0 {
+			l.Error("缺少product_id参数")
+			return nil, errorx.Missing("product_id")
+		}
+		if item.Price < 0 || item.CompareAtPrice < 0 {
+			l.Error("固定价格不合法 product_id:", item.ProductId, " variant_id:", item.VariantId)
+			return nil, errorx.InvalidField("price", "price must not be negative")
+		}
+		if _, ok := variantIds[item.ProductId]; !ok {
+			variantIds[item.ProductId] = map[int64]bool{}
+		}
+		variantIds[item.ProductId][item.VariantId] = true
+		items = append(items, model.SailProductPriceListItem{
+			ShopId:         in.ShopId,
+			ProductId:      item.ProductId,
+			VariantId:      item.VariantId,
+			Price:          item.Price,
+			CompareAtPrice: item.CompareAtPrice,
+		})
+	}
+	for productId, ids := range variantIds {
+		if err := l.checkVariants(in.ShopId, productId, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.svcCtx.WritePriceListModel.SetItems(in.PriceListId, items); err != nil {
+		l.Error("保存固定价格失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.PriceListItemSetResponse{}, nil
+}
+
+// checkVariants 确认产品属于当前店铺，子商品属于该产品
+func (l *PriceListItemSetLogic) checkVariants(shopId, productId int64, ids map[int64]bool) error {
+	_, err := l.svcCtx.WriteModel.FindOne(shopId, model.WithId(productId))
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("商品记录不存在,product_id:", productId)
+		return errorx.ErrProductNotFound
+	default:
+		l.Error(err)
+		return errorx.ErrInternal
+	}
+	delete(ids, 0)
+	if len(ids) == 0 {
+		return nil
+	}
+	variants, err := l.svcCtx.WriteVariantModel.FindList(shopId, productId)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		variants = &[]model.SailShopProductVariant{}
+	default:
+		l.Error(err)
+		return errorx.ErrInternal
+	}
+	for _, variant := range *variants {
+		delete(ids, variant.Id)
+	}
+	for id := range ids {
+		l.Error("子商品不属于该产品 product_id:", productId, " variant_id:", id)
+		return errorx.ErrVariantNotFound
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package logic
+
+import (
+	"context"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type PriceListListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewPriceListListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PriceListListLogic {
+	return &PriceListListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *PriceListListLogic) PriceListList(in *product.PriceListListRequest) (*product.PriceListListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	resp, err := l.svcCtx.ReadPriceListModel.FindList(in.ShopId)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		return &product.PriceListListResponse{}, nil
+	default:
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	result := &product.PriceListListResponse{}
+	for _, item := range *resp {
+		result.PriceLists = append(result.PriceLists, toPriceList(item))
+	}
+	return result, nil
+}
+
+func toPriceList(data model.SailProductPriceList) *product.PriceList {
+	return &product.PriceList{
+		Id:        data.Id,
+		Market:    data.Market,
+		Currency:  data.Currency,
+		Rate:      data.Rate,
+		Rounding:  data.Rounding,
+		Status:    data.Status,
+		CreatedAt: data.CreatedAt.Local().Format(time.RFC3339),
+		UpdatedAt: data.UpdatedAt.Local().Format(time.RFC3339),
+	}
+}
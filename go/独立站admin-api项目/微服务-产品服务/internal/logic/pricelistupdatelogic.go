@@ -0,0 +1,70 @@
+package logic
+
+import (
+	"context"
+	"strings"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type PriceListUpdateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewPriceListUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PriceListUpdateLogic {
+	return &PriceListUpdateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// PriceListUpdate 市场不可修改，其余字段整体覆盖
+func (l *PriceListUpdateLogic) PriceListUpdate(in *product.PriceListUpdateRequest) (*product.PriceListUpdateResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Id == 0 {
+		l.Error("缺少id参数")
+		return nil, errorx.Missing("id")
+	}
+	resp, err := l.svcCtx.WritePriceListModel.FindOne(in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("价格表不存在")
+		return nil, errorx.ErrPriceListNotFound
+	default:
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	resp.Currency = strings.ToUpper(in.Currency)
+	resp.Rate = in.Rate
+	resp.Rounding = in.Rounding
+	if in.Status != 0 {
+		resp.Status = in.Status
+	}
+	if err := validatePriceList(*resp); err != nil {
+		l.Error("价格表参数不合法：", err)
+		return nil, err
+	}
+	if err := l.svcCtx.WritePriceListModel.Update(*resp); err != nil {
+		l.Error("修改价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err = l.svcCtx.WritePriceListModel.FindOne(in.ShopId, in.Id)
+	if err != nil {
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.PriceListUpdateResponse{PriceList: toPriceList(*resp)}, nil
+}
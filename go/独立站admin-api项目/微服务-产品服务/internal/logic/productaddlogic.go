@@ -3,11 +3,12 @@ package logic
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	strip "github.com/grokify/html-strip-tags-go"
 	"github.com/tal-tech/go-zero/core/logx"
 	"github.com/tal-tech/go-zero/core/mr"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
@@ -15,7 +16,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -36,16 +36,29 @@ func NewProductAddLogic(ctx context.Context, svcCtx *svc.ServiceContext) *Produc
 func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.ProductAddResponse, error) {
 	l.Info("shop_id:", in.ShopId)
 	if in.Fields == "" {
-		in.Fields = "id,title,price,compare_price,weight,weight_unit,shop_url,product_url,body_html,seo_title,seo_desc,published_at,is_use_stock,soldout_policy,handler,status,created_at,updated_at,images,default_image,variants"
+		in.Fields = fieldmask.ProductDefault
+	}
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Product)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return nil, errorx.InvalidField("fields", err.Error())
 	}
 
 	if in.ShopId == 0 {
 		l.Error("缺少shop_id参数")
-		return &product.ProductAddResponse{}, errors.New("internal server error")
+		return &product.ProductAddResponse{}, errorx.Missing("shop_id")
 	}
 	if in.Title == "" {
 		l.Error("缺少title参数")
-		return &product.ProductAddResponse{}, errors.New("product.title not set")
+		return &product.ProductAddResponse{}, errorx.InvalidField("product.title", "product.title not set")
+	}
+	if in.Handle != "" {
+		handle, err := model.NormalizeHandle(in.Handle)
+		if err != nil {
+			l.Error("handle参数不合法：", in.Handle)
+			return &product.ProductAddResponse{}, errorx.InvalidField("handle", err.Error())
+		}
+		in.Handle = handle
 	}
 
 	//var defaultImageErr error
@@ -69,7 +82,7 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 			ext := path.Ext(image.Url)
 			if ext != ".png" && ext != ".jpg" && ext != ".bmp" && ext != ".jpeg" && ext != ".svg" && ext != ".gif" {
 				l.Error("添加商品图片失败：图片格式有误")
-				return &product.ProductAddResponse{}, errors.New(" image in wrong format ")
+				return &product.ProductAddResponse{}, errorx.ErrInvalidImage
 			}
 
 			tempImage := model.ImageData{
@@ -111,6 +124,13 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 		}
 	}
 
+	// 兼容 metafields_global_* 传入的SEO信息，seo_title/seo_desc 优先
+	if in.SeoTitle == "" {
+		in.SeoTitle = in.MetafieldsGlobalTitleTag
+	}
+	if in.SeoDesc == "" {
+		in.SeoDesc = in.MetafieldsGlobalDescriptionTag
+	}
 	data := model.InsertProductData{
 		Title:             strip.StripTags(in.Title),
 		Price:             in.Price,
@@ -131,6 +151,8 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 		Attribute:         in.Attribute,
 		InventoryQuantity: in.InventoryQuantity,
 		Tags:              in.Tags,
+		Handler:           in.Handle,
+		Vendor:            in.Vendor,
 		DefaultImage: model.ImageData{
 			FileKey:    in.DefaultImageUrl,
 			ImageWidth: defaultWidth,
@@ -155,162 +177,15 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 
 	if err != nil {
 		l.Error("创建产品失败：", err)
-		errText := err.Error()
-		if err.Error() == " product title repeated, too many request " {
-			errText = " product title repeated, please retry later "
-		}
-		return &product.ProductAddResponse{}, errors.New(errText)
+		return &product.ProductAddResponse{}, errorx.FromError(err)
 	}
 	l.Error("pro:", pro)
 	lastId, err := pro.LastInsertId()
 	if err != nil {
 		l.Error("创建产品失败：", err)
-		return &product.ProductAddResponse{}, errors.New("internal server error")
+		return &product.ProductAddResponse{}, errorx.ErrInternal
 	}
 	l.Info("respImageData:", respImageData)
-	for _, i2 := range respImageData.Variants {
-		temp := model.ImageItem{Url: i2.Url}
-		respImageData.Images = append(respImageData.Images, temp)
-	}
-	imagesMap := sync.Map{}
-	for _, image := range respImageData.Images {
-		if image.Url != "" {
-			imagesMap.Store(image.Url, int64(0))
-		}
-	}
-	imagesSlice := make([]model.ImageItem, 0)
-	keyCheck := map[string]string{}
-	for _, image := range respImageData.Images {
-		if _, ok := keyCheck[image.Url]; ok {
-			continue
-		}
-		temp := model.ImageItem{
-			Url: image.Url,
-		}
-		imagesSlice = append(imagesSlice, temp)
-		keyCheck[image.Url] = "1"
-	}
-	respImageData.Images = imagesSlice
-	if respImageData != nil {
-		if len(respImageData.Variants) != 0 || len(respImageData.Images) != 0 {
-			go func() {
-				imageAddLogic := NewProductImageAddLogic(l.ctx, l.svcCtx)
-				//for s, i := range imagesMap {
-				//	var urlImage string
-				//	var width int64
-				//	var errImage error
-				//	if l.svcCtx.ProjectENV == "xshoppy" {
-				//		urlImage, width, errImage = imageAddLogic.UploadImage(in.DefaultImageUrl)
-				//	} else {
-				//		urlImage, width, errImage = imageAddLogic.UploadImageEmy(in.DefaultImageUrl)
-				//		urlImage = "uploader/" + path.Base(urlImage)
-				//	}
-				//
-				//
-				//
-				//}
-				l.Info("imagesmap:", &imagesMap)
-				l.Info("respImagedata:", respImageData)
-				l.Info("count:", len(respImageData.Images))
-				if len(respImageData.Images) != 0 {
-					l.Info("111111")
-					failedProImg := FailedProImg{
-						ProductId: lastId,
-					}
-
-					for _, image := range respImageData.Images {
-						imageData := product.ProductImageAddRequest{
-							ShopId:    in.ShopId,
-							ProductId: lastId,
-							Url:       image.Url,
-						}
-						respImages, errDefault := imageAddLogic.ProductImageAdd(&imageData)
-						if errDefault != nil {
-							l.Error("添加产品图片失败：", errDefault)
-							failedProImg.Urls = append(failedProImg.Urls, image.Url)
-							continue
-						}
-						l.Info("产品图链接：", image.Url, ":", respImages.Image.Id, ":", respImages.Image.Url)
-						imagesMap.Store(image.Url, respImages.Image.Id)
-					}
-					l.Error("imagesmap:", &imagesMap)
-					if len(failedProImg.Urls) != 0 {
-						defaultByte, errDefaultByte := json.Marshal(failedProImg)
-						if errDefaultByte != nil {
-							l.Error("序列化failedProImage失败：", err)
-						}
-						_, errDefaultPush := l.svcCtx.RedisClient.Lpush("admin-api:failedProImgs", string(defaultByte))
-						if errDefaultPush != nil {
-							l.Error("添加failedProImage队列失败：", err)
-						}
-					}
-
-				}
-
-				if len(respImageData.Images) > 0 {
-					in.DefaultImageUrl = respImageData.Images[0].Url
-				}
-				var imageId int64
-				defaultImgId, ok := imagesMap.Load(in.DefaultImageUrl)
-				if ok {
-					if val, ok := defaultImgId.(int64); ok {
-						imageId = val
-					} else {
-						imageId = int64(defaultImgId.(int))
-					}
-				}
-				errDefault := l.svcCtx.WriteModel.UpdateDefaultImage(imageId, lastId)
-				if errDefault != nil {
-					tempFailedDefaultImg := FailedDefaultProImg{
-						ProductId: lastId,
-						Url:       in.DefaultImageUrl,
-					}
-
-					defaultByte, errDefaultByte := json.Marshal(tempFailedDefaultImg)
-					if errDefaultByte != nil {
-						l.Error("序列化failedDefaultImage失败：", errDefaultByte)
-					}
-					_, errDefaultPush := l.svcCtx.RedisClient.Lpush("admin-api:failedDefaultProImg", string(defaultByte))
-					if errDefaultPush != nil {
-						l.Error("加入failedDefaultImage队列失败：", err)
-					}
-				}
-
-				if len(respImageData.Variants) != 0 {
-					failedProImg := FailedProImg{
-						ProductId: lastId,
-					}
-					for _, image := range respImageData.Variants {
-						var imageId int64
-						defaultImgId, ok := imagesMap.Load(image.Url)
-						if ok {
-							if val, ok := defaultImgId.(int64); ok {
-								imageId = val
-							} else {
-								imageId = int64(defaultImgId.(int))
-							}
-						}
-						errDefault := l.svcCtx.WriteVariantModel.UpdateImage(imageId, image.VariantId)
-						if errDefault != nil {
-							l.Error("添加产品图片失败：", errDefault)
-							failedProImg.Urls = append(failedProImg.Urls, image.Url)
-						}
-					}
-					if len(failedProImg.Urls) != 0 {
-						defaultByte, errDefaultByte := json.Marshal(failedProImg)
-						if errDefaultByte != nil {
-							l.Error("序列化failedVariantImage失败：", errDefaultByte)
-						}
-						_, errDefaultPush := l.svcCtx.RedisClient.Lpush("admin-api:failedVariantImg", string(defaultByte))
-						if errDefaultPush != nil {
-							l.Error("添加failedVariantImage队列失败：", errDefaultPush)
-						}
-					}
-				}
-			}()
-		}
-
-	}
 
 	resp, err := l.svcCtx.WriteModel.FindOne(in.ShopId, model.WithId(lastId))
 	switch err {
@@ -318,10 +193,10 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 
 	case sqlc.ErrNotFound:
 		l.Error("product record not found")
-		return &product.ProductAddResponse{}, errors.New("internal server error")
+		return &product.ProductAddResponse{}, errorx.ErrInternal
 	default:
 		l.Error(err)
-		return nil, err
+		return nil, errorx.ErrInternal
 	}
 
 	//var productDetail product.Product
@@ -331,7 +206,7 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 	var bodyHtml string
 
 	err = mr.Finish(func() error {
-		if strings.Contains(in.Fields, "body_html") {
+		if mask.Has("body_html") {
 			respDetail, err := l.svcCtx.ReadProductDetailModel.FindOne(lastId)
 			switch err {
 			case nil:
@@ -345,7 +220,7 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 
 		return nil
 	}, func() (err error) {
-		if strings.Contains(in.Fields, "default_image") {
+		if mask.Has("default_image") {
 			if resp.DefaultImageId != 0 {
 				respDefaultImage, err := l.svcCtx.ReadImageModel.FindOne(resp.DefaultImageId)
 				switch err {
@@ -365,14 +240,14 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 					//return sqlc.ErrNotFound
 				default:
 					l.Error(err)
-					return errors.New("internal server error")
+					return errorx.ErrInternal
 				}
 			}
 		}
 
 		return nil
 	}, func() (err error) {
-		if strings.Contains(in.Fields, "variants") {
+		if mask.Has("variants") {
 			variantResp, err := l.svcCtx.WriteVariantModel.FindList(in.ShopId, lastId)
 			switch err {
 			case nil:
@@ -384,7 +259,7 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 							inventoryPolicy = "Y"
 						}
 						imageUrl := ""
-						if valVar.ImageId != 0 {
+						if valVar.ImageId != 0 && mask.Sub("variants").Has("image_url") {
 							respImage, err := l.svcCtx.ReadImageModel.FindOne(valVar.ImageId)
 							switch err {
 							case nil:
@@ -421,13 +296,13 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 				//return sqlc.ErrNotFound
 			default:
 				l.Error(err)
-				return errors.New("internal server error")
+				return errorx.ErrInternal
 			}
 		}
 
 		return nil
 	}, func() (err error) {
-		if strings.Contains(in.Fields, "images") {
+		if mask.Has("images") {
 			imageSet := strings.Split(resp.ImageIds, ",")
 			imageMap := map[string]int{}
 			for k, v := range imageSet {
@@ -490,18 +365,18 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 
 	if err != nil {
 		l.Error(err)
-		return nil, errors.New("internal server error")
+		return nil, errorx.ErrInternal
 	}
 	var youtubeVideoPos int
 	if resp.YoutubeVideoPos != "" {
 		youtubeVideoPos, err = strconv.Atoi(resp.YoutubeVideoPos)
 		if err != nil {
 			l.Error(err)
-			return nil, errors.New("internal server error")
+			return nil, errorx.ErrInternal
 		}
 	}
 
-	return &product.ProductAddResponse{Product: &product.Product{
+	productDetail := &product.Product{
 		ProductTitle:    resp.Title,
 		ProductId:       resp.Id,
 		ShopUrl:         "",
@@ -533,9 +408,12 @@ func (l *ProductAddLogic) ProductAdd(in *product.ProductAddRequest) (*product.Pr
 		CountSales:      resp.CountSales,
 		YoutubeVideoPos: int64(youtubeVideoPos),
 		YoutubeVideoUrl: resp.YoutubeVideoUrl,
-		Vendor:          in.Vendor,
+		Vendor:          resp.Vendor,
 		ProductType:     in.ProductType,
-	}}, nil
+		Version:         resp.Version,
+	}
+	fieldmask.ProjectProduct(mask, productDetail)
+	return &product.ProductAddResponse{Product: productDetail}, nil
 
 }
 
@@ -0,0 +1,178 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const productBatchGetMax = 100
+
+type ProductBatchGetLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewProductBatchGetLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ProductBatchGetLogic {
+	return &ProductBatchGetLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// ProductBatchGet handle 和 sku 各用一条查询换成产品id，产品行读产品id缓存，关联数据和列表一样批量查询
+func (l *ProductBatchGetLogic) ProductBatchGet(in *product.ProductBatchGetRequest) (*product.ProductBatchGetResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if len(in.Identifiers) == 0 {
+		l.Error("缺少identifiers参数")
+		return nil, errorx.Missing("identifiers")
+	}
+	if len(in.Identifiers) > productBatchGetMax {
+		l.Error("identifiers数量超出限制：", len(in.Identifiers))
+		return nil, errorx.InvalidField("identifiers", fmt.Sprintf("at most %d identifiers are allowed", productBatchGetMax))
+	}
+	if in.Fields == "" {
+		in.Fields = fieldmask.ProductDefault
+	}
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Product)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return nil, errorx.InvalidField("fields", err.Error())
+	}
+
+	handles := make([]string, 0)
+	skus := make([]string, 0)
+	for i, identifier := range in.Identifiers {
+		identifier.Handle = strings.ToLower(strings.TrimSpace(identifier.Handle))
+		identifier.Sku = strings.TrimSpace(identifier.Sku)
+		set := 0
+		if identifier.Id != 0 {
+			set++
+		}
+		if identifier.Handle != "" {
+			set++
+			handles = append(handles, identifier.Handle)
+		}
+		if identifier.Sku != "" {
+			set++
+			skus = append(skus, identifier.Sku)
+		}
+		if set != 1 {
+			l.Error("identifiers参数不合法，下标：", i)
+			return nil, errorx.InvalidField(fmt.Sprintf("identifiers[%d]", i), fmt.Sprintf("identifiers[%d] must set exactly one of id, handle or sku", i))
+		}
+	}
+	handleIds, err := l.svcCtx.ProductBatchModel.FindIdsByHandles(in.ShopId, handles)
+	if err != nil {
+		l.Error("按handle查询产品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	skuIds, err := l.svcCtx.ProductBatchModel.FindIdsBySkus(in.ShopId, skus)
+	if err != nil {
+		l.Error("按sku查询产品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	resolved := make([]int64, len(in.Identifiers))
+	ids := make([]int64, 0, len(in.Identifiers))
+	seen := make(map[int64]bool)
+	for i, identifier := range in.Identifiers {
+		switch {
+		case identifier.Id != 0:
+			resolved[i] = identifier.Id
+		case identifier.Handle != "":
+			resolved[i] = handleIds[identifier.Handle]
+		default:
+			resolved[i] = skuIds[identifier.Sku]
+		}
+		if resolved[i] != 0 && !seen[resolved[i]] {
+			seen[resolved[i]] = true
+			ids = append(ids, resolved[i])
+		}
+	}
+	found, err := l.svcCtx.ReadModel.FindCachedByIds(in.ShopId, ids)
+	if err != nil {
+		l.Error("查询产品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	resp := &product.ProductBatchGetResponse{Products: []*product.Product{}, NotFound: []*product.ProductIdentifier{}}
+	rows := make([]model.SailShopProduct, 0, len(found))
+	added := make(map[int64]bool)
+	for i, identifier := range in.Identifiers {
+		row, ok := found[resolved[i]]
+		if !ok {
+			resp.NotFound = append(resp.NotFound, identifier)
+			continue
+		}
+		if !added[row.Id] {
+			added[row.Id] = true
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return resp, nil
+	}
+
+	productIds := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		productIds = append(productIds, row.Id)
+	}
+	resolver, err := pricing.Load(l.svcCtx.ReadPriceListModel, in.ShopId, in.Market, in.Currency, productIds)
+	switch err {
+	case nil:
+	case pricing.ErrPriceListNotFound:
+		l.Error("价格表不存在 market:", in.Market, " currency:", in.Currency)
+		return nil, errorx.ErrPriceListNotFound
+	default:
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	result, err := NewProductListLogic(l.ctx, l.svcCtx).assemble(in.ShopId, rows, mask)
+	if err != nil {
+		l.Error("查询产品关联数据失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	if resolver != nil {
+		for _, item := range result {
+			resolver.Apply(item)
+		}
+	}
+	if err := attachRatings(l.svcCtx, in.ShopId, mask, result); err != nil {
+		l.Error("查询产品评分失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	if err := attachMetafields(l.svcCtx, in.ShopId, mask, result); err != nil {
+		l.Error("查询自定义字段失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	switch err := translateProducts(l.svcCtx, in.ShopId, in.Locale, result); err {
+	case nil:
+	case translation.ErrLocaleInvalid:
+		l.Error("locale参数不合法：", in.Locale)
+		return nil, errorx.InvalidField("locale", err.Error())
+	default:
+		l.Error("查询翻译失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	for _, item := range result {
+		fieldmask.ProjectProduct(mask, item)
+	}
+	resp.Products = result
+	return resp, nil
+}
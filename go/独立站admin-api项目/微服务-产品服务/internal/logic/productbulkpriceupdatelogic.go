@@ -0,0 +1,209 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	bulkPriceLockExpire = 600
+	bulkPriceMaxBatch   = 500
+
+	bulkPriceSkipInvalid      = "invalid"
+	bulkPriceSkipCompareBelow = "compare_below_price"
+	bulkPriceSkipConflict     = "conflict"
+)
+
+type ProductBulkPriceUpdateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewProductBulkPriceUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ProductBulkPriceUpdateLogic {
+	return &ProductBulkPriceUpdateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// ProductBulkPriceUpdate 按产品id顺序分批改价，每批一个事务，处理完一批返回一次进度；
+// 中途断开时已提交的批次不会回滚，按相同条件重新执行会对这些产品再改一次，调用方应先 dry_run 确认
+func (l *ProductBulkPriceUpdateLogic) ProductBulkPriceUpdate(in *product.ProductBulkPriceUpdateRequest, stream product.ProductRPC_ProductBulkPriceUpdateServer) error {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return errorx.Missing("shop_id")
+	}
+	filter := model.BulkPriceFilter{
+		ProductIds:      in.ProductIds,
+		CategoryId:      in.CategoryId,
+		Tag:             in.Tag,
+		Vendor:          in.Vendor,
+		Title:           in.Title,
+		CreatedAtMin:    in.CreatedAtMin,
+		CreatedAtMax:    in.CreatedAtMax,
+		UpdatedAtMin:    in.UpdatedAtMin,
+		UpdatedAtMax:    in.UpdatedAtMax,
+		PublishedStatus: in.PublishedStatus,
+	}
+	if filter.Empty() {
+		l.Error("批量改价缺少筛选条件")
+		return errorx.InvalidField("product_ids", "at least one product filter is required")
+	}
+	expr := pricing.Expression{
+		Target:   in.Target,
+		Base:     in.Base,
+		Op:       in.Operation,
+		Value:    in.Value,
+		Rounding: in.Rounding,
+	}
+	if err := expr.Validate(); err != nil {
+		l.Error("改价表达式不合法：", err)
+		return errorx.InvalidField(expressionField(err), err.Error())
+	}
+	if in.BatchSize <= 0 || in.BatchSize > bulkPriceMaxBatch {
+		in.BatchSize = 100
+	}
+
+	if !in.DryRun {
+		lock := redis.NewRedisLock(l.svcCtx.RedisClientSaas, fmt.Sprintf("product:bulkprice:%d", in.ShopId))
+		lock.SetExpire(bulkPriceLockExpire)
+		if ok, err := lock.Acquire(); !ok || err != nil {
+			l.Error("批量改价正在执行中 shop_id:", in.ShopId)
+			return errorx.New(errorx.CodeOperationInProgress, "bulk price update in progress, please retry later")
+		}
+		defer lock.Release()
+	}
+
+	total, err := l.svcCtx.BulkPriceModel.Count(in.ShopId, filter)
+	if err != nil {
+		l.Error("统计批量改价产品失败：", err)
+		return errorx.ErrInternal
+	}
+	progress := &product.BulkPriceProgress{TotalProducts: total}
+	var afterId int64
+	for {
+		if err := stream.Context().Err(); err != nil {
+			l.Error("批量改价被调用方中断：", err)
+			return err
+		}
+		ids, err := l.svcCtx.BulkPriceModel.FindProductIds(in.ShopId, filter, afterId, in.BatchSize)
+		if err != nil {
+			l.Error("查询批量改价产品失败：", err)
+			return errorx.ErrInternal
+		}
+		if len(ids) == 0 {
+			break
+		}
+		afterId = ids[len(ids)-1]
+
+		changes, err := l.batch(in, expr, ids)
+		if err != nil {
+			return err
+		}
+		progress.ProcessedProducts += int64(len(ids))
+		progress.Changes = changes
+		for _, change := range changes {
+			if change.SkipReason == "" {
+				progress.Updated++
+			} else {
+				progress.Skipped++
+			}
+		}
+		if err := stream.Send(progress); err != nil {
+			l.Error("发送批量改价进度失败：", err)
+			return err
+		}
+		if int64(len(ids)) < in.BatchSize {
+			break
+		}
+	}
+	progress.Changes = nil
+	progress.Done = true
+	return stream.Send(progress)
+}
+
+// batch 计算一批产品的改价结果，非 dry_run 时写入
+func (l *ProductBulkPriceUpdateLogic) batch(in *product.ProductBulkPriceUpdateRequest, expr pricing.Expression, ids []int64) ([]*product.BulkPriceChange, error) {
+	rows, err := l.svcCtx.BulkPriceModel.FindPrices(in.ShopId, ids)
+	if err != nil {
+		l.Error("查询批量改价价格失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	changes := make([]*product.BulkPriceChange, 0, len(*rows))
+	pending := make([]model.BulkPriceChange, 0, len(*rows))
+	for _, row := range *rows {
+		change := &product.BulkPriceChange{
+			ProductId:         row.ProductId,
+			VariantId:         row.VariantId,
+			Title:             row.Title,
+			Price:             row.Price,
+			CompareAtPrice:    row.CompareAtPrice,
+			NewPrice:          row.Price,
+			NewCompareAtPrice: row.CompareAtPrice,
+		}
+		value, ok := expr.Eval(row.Price, row.CompareAtPrice)
+		if expr.Target == pricing.FieldPrice {
+			change.NewPrice = value
+		} else {
+			change.NewCompareAtPrice = value
+		}
+		switch {
+		case !ok:
+			change.SkipReason = bulkPriceSkipInvalid
+		case change.NewCompareAtPrice > 0 && change.NewCompareAtPrice < change.NewPrice:
+			change.SkipReason = bulkPriceSkipCompareBelow
+		case change.NewPrice == change.Price && change.NewCompareAtPrice == change.CompareAtPrice:
+			continue
+		default:
+			pending = append(pending, model.BulkPriceChange{
+				ProductId:         row.ProductId,
+				VariantId:         row.VariantId,
+				Price:             row.Price,
+				CompareAtPrice:    row.CompareAtPrice,
+				NewPrice:          change.NewPrice,
+				NewCompareAtPrice: change.NewCompareAtPrice,
+			})
+		}
+		changes = append(changes, change)
+	}
+	if in.DryRun || len(pending) == 0 {
+		return changes, nil
+	}
+
+	applied, err := l.svcCtx.BulkPriceModel.Apply(in.ShopId, pending)
+	if err != nil {
+		l.Error("批量改价失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	done := make(map[[2]int64]bool, len(applied))
+	for _, change := range applied {
+		done[[2]int64{change.ProductId, change.VariantId}] = true
+	}
+	for _, change := range changes {
+		if change.SkipReason == "" && !done[[2]int64{change.ProductId, change.VariantId}] {
+			change.SkipReason = bulkPriceSkipConflict
+		}
+	}
+	return changes, nil
+}
+
+// expressionField 改价表达式的错误信息以字段名开头，百分比错误对应 value 字段
+func expressionField(err error) string {
+	field := strings.Fields(err.Error())[0]
+	if field == "percent" {
+		return "value"
+	}
+	return field
+}
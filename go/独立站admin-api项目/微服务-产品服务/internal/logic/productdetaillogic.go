@@ -3,12 +3,15 @@ package logic
 import (
 	"context"
 	"errors"
-	"github.com/tal-tech/go-zero/core/mr"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/productcache"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -33,212 +36,111 @@ func NewProductDetailLogic(ctx context.Context, svcCtx *svc.ServiceContext) *Pro
 func (l *ProductDetailLogic) ProductDetail(in *product.ProductDetailRequest) (*product.ProductDetailResponse, error) {
 	l.Info("ProductDetail Called")
 	if in.Fields == "" {
-		in.Fields = "id,title,price,compare_price,weight,weight_unit,shop_url,product_url,body_html,seo_title,seo_desc,published_at,is_use_stock,soldout_policy,handler,status,created_at,updated_at,images,default_image,variants"
+		in.Fields = fieldmask.ProductDefault
+	}
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Product)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return nil, errorx.InvalidField("fields", err.Error())
 	}
-	options := make([]model.HandlerOption, 0)
 	l.Info("arguments:", in.ProductHandler, in.ProductId)
 	if in.ProductHandler == "" && in.ProductId == 0 {
 		l.Error("缺少查询参数")
-		return nil, errors.New("product_id or product_handler is missing")
+		return nil, errorx.Missing("product_id", "product_handler")
 	}
 	if in.ShopId == 0 {
 		l.Error("缺少店铺id参数")
-		return nil, errors.New("shop_id is missing")
+		return nil, errorx.Missing("shop_id")
 	}
+	var redirectTo string
+	var aggregate *productcache.Aggregate
 	if in.ProductId != 0 {
-		options = append(options, model.WithId(in.ProductId))
-	}
-	if in.ProductHandler != "" {
-		options = append(options, model.WithHandler(in.ProductHandler))
+		aggregate, err = l.svcCtx.ProductCache.Get(in.ShopId, in.ProductId)
+		if err == nil && in.ProductHandler != "" && !strings.EqualFold(aggregate.Product.Handler, in.ProductHandler) {
+			err = sqlc.ErrNotFound
+		}
+	} else {
+		aggregate, err = l.svcCtx.ProductCache.GetByHandle(in.ShopId, in.ProductHandler)
+		if err == sqlc.ErrNotFound {
+			aggregate, err = l.findByOldHandle(in.ShopId, in.ProductHandler)
+			if err == nil {
+				redirectTo = aggregate.Product.Handler
+			}
+		}
 	}
-
-	resp, err := l.svcCtx.ReadModel.FindOne(in.ShopId, options...)
 	switch err {
 	case nil:
 
 	case sqlc.ErrNotFound:
 		l.Error("商品记录不存在")
-		return nil, errors.New("product record not found")
+		return nil, errorx.ErrProductNotFound
 	default:
 		l.Error(err)
-		return nil, errors.New("internal server error")
+		return nil, errorx.ErrInternal
+	}
+	resp := &aggregate.Product
+
+	resolver, err := pricing.Load(l.svcCtx.ReadPriceListModel, in.ShopId, in.Market, in.Currency, []int64{resp.Id})
+	switch err {
+	case nil:
+	case pricing.ErrPriceListNotFound:
+		l.Error("价格表不存在 market:", in.Market, " currency:", in.Currency)
+		return nil, errorx.ErrPriceListNotFound
+	default:
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
 	}
 
 	var productDetail product.Product
 	var defaultImage product.ProductImage
 	var images []*product.ProductImage
 	var variants []*product.ProductVariant
-	var bodyHtml string
 
-	err = mr.Finish(func() error {
-		if strings.Contains(in.Fields, "body_html") {
-			respDetail, err := l.svcCtx.ReadProductDetailModel.FindOne(in.ProductId)
-			switch err {
-			case nil:
-				bodyHtml = respDetail.BodyHtml
-			case sqlc.ErrNotFound:
-				logx.Error("body_html not found")
-			default:
-				l.Error(err)
-			}
-		}
-
-		return nil
-	}, func() (err error) {
-		if strings.Contains(in.Fields, "default_image") {
-			if resp.DefaultImageId != 0 {
-				respDefaultImage, err := l.svcCtx.ReadImageModel.FindOne(resp.DefaultImageId)
-				switch err {
-				case nil:
-					defaultImage = product.ProductImage{
-						Id:         respDefaultImage.Id,
-						ProductId:  resp.Id,
-						Width:      respDefaultImage.ImageWidth,
-						Url:        respDefaultImage.FileKey,
-						VariantIds: nil,
-						FileKey:    respDefaultImage.FileKey,
-						CreatedAt:  respDefaultImage.CreatedAt.Local().Format(time.RFC3339),
-						UpdatedAt:  respDefaultImage.UpdatedAt.Local().Format(time.RFC3339),
-					}
-				case sqlc.ErrNotFound:
-					l.Error("图片记录不存在")
-					//return sqlc.ErrNotFound
-				default:
-					l.Error(err)
-					return errors.New("internal server error")
-				}
+	if image, ok := aggregate.Images[resp.DefaultImageId]; ok && mask.Has("default_image") {
+		defaultImage = *toProductImage(resp.Id, image)
+	}
+	if mask.Has("images") {
+		for _, imageId := range model.ParseVariantIds(resp.ImageIds) {
+			if image, ok := aggregate.Images[imageId]; ok {
+				images = append(images, toProductImage(resp.Id, image))
 			}
 		}
-
-		return nil
-	}, func() (err error) {
-		if strings.Contains(in.Fields, "variants") {
-			variantResp, err := l.svcCtx.ReadVariantModel.FindList(in.ShopId, resp.Id)
-			switch err {
-			case nil:
-				if variantResp != nil {
-					//var inventoryQuantity string
-					for _, valVar := range *variantResp {
-						inventoryPolicy := "N"
-						if resp.IsUseStock == 1 {
-							inventoryPolicy = "Y"
-						}
-						imageUrl := ""
-						if valVar.ImageId != 0 {
-							respImage, err := l.svcCtx.ReadImageModel.FindOne(valVar.ImageId)
-							switch err {
-							case nil:
-								imageUrl = respImage.FileKey
-							case sqlc.ErrNotFound:
-							default:
-
-							}
-						}
-						tempVariant := &product.ProductVariant{
-							Id:                valVar.Id,
-							Sku:               valVar.SkuCode,
-							Title:             valVar.Title,
-							Price:             valVar.Price,
-							ComparePrice:      valVar.CompareAtPrice,
-							Spec:              valVar.Spec.String,
-							Weight:            valVar.Weight,
-							WeightUnit:        valVar.WeightUnit,
-							RequiresShipping:  valVar.RequiresShipping,
-							ImageId:           valVar.ImageId,
-							CreatedAt:         valVar.CreatedAt.Local().Format(time.RFC3339),
-							UpdatedAt:         valVar.UpdatedAt.Local().Format(time.RFC3339),
-							Sort:              valVar.Sort,
-							InventoryQuantity: valVar.InventoryQuantity,
-							InventoryPolicy:   inventoryPolicy,
-							IsShow:            valVar.IsShow,
-							ImageUrl:          imageUrl,
-						}
-						variants = append(variants, tempVariant)
-					}
-				}
-			case sqlc.ErrNotFound:
-				l.Error("子商品记录不存在")
-				//return sqlc.ErrNotFound
-			default:
-				l.Error(err)
-				return errors.New("internal server error")
-			}
+	}
+	if mask.Has("variants") {
+		inventoryPolicy := "N"
+		if resp.IsUseStock == 1 {
+			inventoryPolicy = "Y"
 		}
-
-		return nil
-	}, func() (err error) {
-		if strings.Contains(in.Fields, "images") {
-			imageSet := strings.Split(resp.ImageIds, ",")
-			imageMap := map[string]int{}
-			for k, v := range imageSet {
-				imageMap[v] = k
-			}
-			resultImage, err := mr.MapReduce(func(source chan<- interface{}) {
-				for _, valPro := range imageSet {
-					source <- valPro
-				}
-			}, func(item interface{}, writer mr.Writer, cancel func(error)) {
-				uid := item.(string)
-				convId, err := strconv.Atoi(uid)
-				if err != nil {
-					l.Error(err)
-					return
-				}
-				if convId != 0 {
-					respDefaultImage, err := l.svcCtx.ReadImageModel.FindOne(int64(convId))
-					switch err {
-					case nil:
-						tempImage := product.ProductImage{
-							Id:         respDefaultImage.Id,
-							ProductId:  resp.Id,
-							Sort:       0,
-							Width:      respDefaultImage.ImageWidth,
-							Url:        respDefaultImage.FileKey,
-							VariantIds: nil,
-							FileKey:    respDefaultImage.FileKey,
-							CreatedAt:  respDefaultImage.CreatedAt.Local().Format(time.RFC3339),
-							UpdatedAt:  respDefaultImage.UpdatedAt.Local().Format(time.RFC3339),
-						}
-						writer.Write(&tempImage)
-					case sqlc.ErrNotFound:
-						l.Error("图片记录不存在")
-						//return sqlc.ErrNotFound
-					default:
-						l.Error(err)
-						//return errors.New("internal server error")
-					}
-				}
-			}, func(pipe <-chan interface{}, writer mr.Writer, cancel func(error)) {
-				var uids []*product.ProductImage
-				for p := range pipe {
-					uids = append(uids, p.(*product.ProductImage))
-				}
-				writer.Write(uids)
-			})
-			if err != nil {
-				logx.Error(err)
-				return err
-			}
-
-			images = resultImage.([]*product.ProductImage)
-			sort.Slice(images, func(i, j int) bool {
-				return imageMap[strconv.Itoa(int(images[i].Id))] < imageMap[strconv.Itoa(int(images[j].Id))]
+		for _, valVar := range aggregate.Variants {
+			variants = append(variants, &product.ProductVariant{
+				Id:                valVar.Id,
+				Sku:               valVar.SkuCode,
+				Title:             valVar.Title,
+				Price:             valVar.Price,
+				ComparePrice:      valVar.CompareAtPrice,
+				Spec:              valVar.Spec.String,
+				Weight:            valVar.Weight,
+				WeightUnit:        valVar.WeightUnit,
+				RequiresShipping:  valVar.RequiresShipping,
+				ImageId:           valVar.ImageId,
+				CreatedAt:         valVar.CreatedAt.Local().Format(time.RFC3339),
+				UpdatedAt:         valVar.UpdatedAt.Local().Format(time.RFC3339),
+				Sort:              valVar.Sort,
+				InventoryQuantity: valVar.InventoryQuantity,
+				InventoryPolicy:   inventoryPolicy,
+				IsShow:            valVar.IsShow,
+				ImageUrl:          aggregate.Images[valVar.ImageId].FileKey,
+				Version:           aggregate.VariantVersions[valVar.Id],
 			})
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		l.Error(err)
-		return nil, errors.New("internal server error")
 	}
+
 	var youtubeVideoPos int
 	if resp.YoutubeVideoPos != "" {
 		youtubeVideoPos, err = strconv.Atoi(resp.YoutubeVideoPos)
 		if err != nil {
 			l.Error(err)
-			return nil, errors.New("internal server error")
+			return nil, errorx.ErrInternal
 		}
 	}
 
@@ -251,7 +153,7 @@ func (l *ProductDetailLogic) ProductDetail(in *product.ProductDetailRequest) (*p
 		WeightUnit:      resp.WeightUnit,
 		ShopUrl:         "",
 		PreviewUrl:      "/products/" + resp.Handler,
-		BodyHtml:        bodyHtml,
+		BodyHtml:        aggregate.BodyHtml,
 		SeoTitle:        resp.SeoTitle,
 		SeoDesc:         resp.SeoDesc,
 		PublishedAt:     resp.PublishedAt.Local().Format(time.RFC3339),
@@ -269,14 +171,47 @@ func (l *ProductDetailLogic) ProductDetail(in *product.ProductDetailRequest) (*p
 		Comments:        resp.Comments,
 		IsShowComment:   resp.IsShowComment,
 		Scores:          resp.Scores,
+		Vendor:          resp.Vendor,
 		CountSkus:       resp.CountSkus,
 		IsRead:          resp.IsRead,
 		CountSales:      resp.CountSales,
 		YoutubeVideoPos: int64(youtubeVideoPos),
 		YoutubeVideoUrl: resp.YoutubeVideoUrl,
+		Version:         resp.Version,
+	}
+	if resolver != nil {
+		resolver.Apply(&productDetail)
+	}
+	if err := attachRatings(l.svcCtx, in.ShopId, mask, []*product.Product{&productDetail}); err != nil {
+		l.Error("查询产品评分失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	if err := attachMetafields(l.svcCtx, in.ShopId, mask, []*product.Product{&productDetail}); err != nil {
+		l.Error("查询自定义字段失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	switch err := translateProducts(l.svcCtx, in.ShopId, in.Locale, []*product.Product{&productDetail}); err {
+	case nil:
+	case translation.ErrLocaleInvalid:
+		l.Error("locale参数不合法：", in.Locale)
+		return nil, errorx.InvalidField("locale", err.Error())
+	default:
+		l.Error("查询翻译失败：", err)
+		return nil, errorx.ErrInternal
 	}
 
-	return &product.ProductDetailResponse{Product: &productDetail}, nil
+	fieldmask.ProjectProduct(mask, &productDetail)
+
+	return &product.ProductDetailResponse{Product: &productDetail, RedirectTo: redirectTo}, nil
+}
+
+// findByOldHandle 链接修改过的产品通过旧链接查找
+func (l *ProductDetailLogic) findByOldHandle(shopId int64, handle string) (*productcache.Aggregate, error) {
+	redirect, err := l.svcCtx.ReadRedirectModel.FindOneByOldHandle(shopId, handle)
+	if err != nil {
+		return nil, err
+	}
+	return l.svcCtx.ProductCache.Get(shopId, redirect.ProductId)
 }
 
 func (l *ProductDetailLogic) GetImage() error {
@@ -307,7 +242,7 @@ func (l *ProductDetailLogic) GetFunc(index, imageId, productId int64, imageChan
 				//return sqlc.ErrNotFound
 			default:
 				l.Error(err)
-				return errors.New("internal server error")
+				return errorx.ErrInternal
 			}
 		}
 		return err
@@ -6,29 +6,20 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"encoding/hex"
-	"errors"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
 
-	"io"
-	"io/ioutil"
 	"math/rand"
-	"net/http"
-	"os"
 	"path"
 
 	"strconv"
-	"strings"
 	"time"
 
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
-
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
 
@@ -53,49 +44,30 @@ func NewProductImageAddLogic(ctx context.Context, svcCtx *svc.ServiceContext) *P
 func (l *ProductImageAddLogic) ProductImageAdd(in *product.ProductImageAddRequest) (*product.ProductImageAddResponse, error) {
 	if in.Url == "" {
 		l.Error("添加图片失败,缺少url参数")
-		return &product.ProductImageAddResponse{}, errors.New("need url param")
-	}
-	ext := path.Ext(in.Url)
-	if ext != ".png" && ext != ".jpg" && ext != ".bmp" && ext != ".jpeg" && ext != ".svg" && ext != ".gif" {
-		l.Error("image.url is invalid")
-		return &product.ProductImageAddResponse{}, errors.New("image.url is invalid")
+		return &product.ProductImageAddResponse{}, errorx.Missing("url")
 	}
-	var url string
-	var width int64 = 0
-	var err error
-	if l.svcCtx.ProjectENV == "xshoppy" {
-		url, width, err = l.UploadImage(in.Url)
-	} else {
-		url, width, err = l.UploadImageEmy(in.Url)
-		url = "uploader/" + path.Base(url)
-	}
-
+	data, err := l.upload(in.ShopId, in.Url)
 	if err != nil {
 		l.Error("上传商品图片出错：", err)
-		return &product.ProductImageAddResponse{}, err
+		return &product.ProductImageAddResponse{}, errorx.FromError(err)
 	}
-	data := model.SailUpload{}
-	data.ShopId = in.ShopId
-	data.ImageWidth = width
-	data.FileKey = url
-	h := md5.New()
-	h.Write([]byte(url))
-	md5Str := hex.EncodeToString(h.Sum(nil))
-	data.FileMd5 = md5Str
 	resp, err := l.svcCtx.WriteImageModel.InsertProductImage(data, in.ProductId)
 	if err != nil {
 		l.Error("添加图片失败：", err)
-		return &product.ProductImageAddResponse{}, errors.New("internal server error")
+		return &product.ProductImageAddResponse{}, errorx.ErrInternal
+	}
+	if in.ProductId != 0 {
+		l.svcCtx.ProductCache.Invalidate(in.ShopId, in.ProductId)
 	}
 	lastId, err := resp.LastInsertId()
 	if err != nil {
 		l.Error("添加图片失败：", err)
-		return &product.ProductImageAddResponse{}, errors.New("internal server error")
+		return &product.ProductImageAddResponse{}, errorx.ErrInternal
 	}
 	respAdd, err := l.svcCtx.WriteImageModel.FindOne(lastId)
 	if err != nil {
 		l.Error("添加图片失败：", err)
-		return &product.ProductImageAddResponse{}, errors.New("internal server error")
+		return &product.ProductImageAddResponse{}, errorx.ErrInternal
 	}
 	variantIds := make([]int64, 0)
 	respVariants, err := l.svcCtx.ReadVariantModel.FindListByImageId(in.ShopId, in.ProductId, lastId)
@@ -128,71 +100,56 @@ func (l *ProductImageAddLogic) ProductImageAdd(in *product.ProductImageAddReques
 	}}, nil
 }
 
-func (l *ProductImageAddLogic) UploadImage(url string) (src string, width int64, err error) {
-
-	imgSplit := strings.Split(url, "/")
-	length := len(imgSplit)
-	if length < 4 {
-		l.Error("url参数不可用")
-		return "", 0, errors.New("url param invalid")
-	}
-	respHead, err := http.Head(url)
+// UploadReviewImage 转存评论图片，只保存图片不关联到产品
+func (l *ProductImageAddLogic) UploadReviewImage(shopId int64, url string) (int64, error) {
+	data, err := l.upload(shopId, url)
 	if err != nil {
-		l.Error("上传图片失败：", err)
-		return "", 0, err
+		l.Error("上传评论图片出错：", err)
+		return 0, err
 	}
-	defer respHead.Body.Close()
-	if respHead.ContentLength > 8388608 {
-		l.Error("图片超过8M")
-		return "", 0, errors.New(" image size beyond limit ")
+	resp, err := l.svcCtx.WriteImageModel.InsertImage(data)
+	if err != nil {
+		l.Error("保存评论图片失败：", err)
+		return 0, errorx.ErrInternal
 	}
+	return resp.LastInsertId()
+}
 
-	name := imgSplit[len(imgSplit)-1:][0]
-	now := time.Now()
-	rand.Seed(now.UnixNano())
-	timeStr := strconv.Itoa(int(now.UnixNano()))
-	numStr := strconv.Itoa(rand.Intn(999))
-	s := timeStr + numStr
-	h := sha1.New()
-	h.Write([]byte(s))
-	bs := hex.EncodeToString(h.Sum(nil))
-
-	name = bs + path.Ext(imgSplit[len(imgSplit)-2 : len(imgSplit)-1][0])
-
-	resp1, err := http.Get(url)
-
-	if err != nil {
-		l.Error("上传图片失败：", err)
-		return "", 0, err
+func (l *ProductImageAddLogic) upload(shopId int64, src string) (model.SailUpload, error) {
+	var url string
+	var width int64 = 0
+	var err error
+	if l.svcCtx.ProjectENV == "xshoppy" {
+		url, width, err = l.UploadImage(src)
+	} else {
+		url, width, err = l.UploadImageEmy(src)
+		url = "uploader/" + path.Base(url)
 	}
-	defer resp1.Body.Close()
-	body, err := ioutil.ReadAll(resp1.Body)
 	if err != nil {
-		l.Error("上传图片失败：", err)
-		return "", 0, err
+		return model.SailUpload{}, err
 	}
-	if resp1.StatusCode != 200 {
-		l.Error("上传图片失败：", resp1.Status)
-		return "", 0, errors.New("invalid image url")
-	}
-	out, err := os.Create(name + path.Ext(url))
+	data := model.SailUpload{}
+	data.ShopId = shopId
+	data.ImageWidth = width
+	data.FileKey = url
+	h := md5.New()
+	h.Write([]byte(url))
+	data.FileMd5 = hex.EncodeToString(h.Sum(nil))
+	return data, nil
+}
+
+func (l *ProductImageAddLogic) UploadImage(url string) (src string, width int64, err error) {
+	img, err := l.svcCtx.ImageFetcher.Fetch(l.ctx, url)
 	if err != nil {
-		l.Error("上传图片失败：", err)
+		l.Error("下载图片失败：", err)
 		return "", 0, err
 	}
-	defer os.Remove(name + path.Ext(url))
-	_, err = io.Copy(out, bytes.NewReader(body))
-	if err != nil {
-		l.Error("上传图片失败：", err)
-		return "", 0, errors.New("internal server error")
-	}
+	objectName := "uploader/" + randomImageName() + img.Ext
 
 	endpoint := l.svcCtx.AliOss.Endpoint
 	accessKeyId := l.svcCtx.AliOss.AccessKeyId
 	accessKeySecret := l.svcCtx.AliOss.AccessKeySecret
 	bucketName := l.svcCtx.AliOss.BucketName
-	objectName := "uploader/" + name + path.Ext(url)
-	localFileName := name + path.Ext(url)
 	// 创建OSSClient实例。
 	client, err := oss.New(endpoint, accessKeyId, accessKeySecret)
 	if err != nil {
@@ -205,92 +162,26 @@ func (l *ProductImageAddLogic) UploadImage(url string) (src string, width int64,
 		l.Error(err)
 		return "", 0, err
 	}
-	// 上传文件。
-	err = bucket.PutObjectFromFile(objectName, localFileName)
+	// 上传文件流。
+	err = bucket.PutObject(objectName, bytes.NewReader(img.Body), oss.ContentType(img.ContentType))
 	if err != nil {
 		l.Error(err)
 		return "", 0, err
 	}
-	return "uploader/" + name + path.Ext(url), width, nil
+	return objectName, img.Width, nil
 }
 
 func (l ProductImageAddLogic) UploadImageEmy(url string) (src string, width int64, err error) {
-	imgSplit := strings.Split(url, "/")
-	length := len(imgSplit)
-	if length < 4 {
-		l.Error("url参数不可用")
-		return "", 0, errors.New("url param invalid")
-	}
-	respHead, err := http.Head(url)
-	if err != nil {
-		l.Error("上传图片失败：", err)
-		return "", 0, err
-	}
-	defer respHead.Body.Close()
-	if respHead.ContentLength > 8388608 {
-		l.Error("图片超过8M")
-		return "", 0, errors.New(" image size beyond limit ")
-	}
-	name := imgSplit[len(imgSplit)-1:][0]
-	now := time.Now()
-	rand.Seed(now.UnixNano())
-	timeStr := strconv.Itoa(int(now.UnixNano()))
-	numStr := strconv.Itoa(rand.Intn(999))
-	s := timeStr + numStr
-	h := sha1.New() // md5加密类似md5.New()
-	h.Write([]byte(s))
-	bs := hex.EncodeToString(h.Sum(nil))
-
-	name = bs + path.Ext(imgSplit[len(imgSplit)-2 : len(imgSplit)-1][0])
-	l.Info("imageset:", imgSplit)
-
-	resp1, err := http.Get(url)
+	img, err := l.svcCtx.ImageFetcher.Fetch(l.ctx, url)
 	if err != nil {
-		l.Error("上传图片失败：", err)
+		l.Error("下载图片失败：", err)
 		return "", 0, err
 	}
-	defer resp1.Body.Close()
-
-	body, err := ioutil.ReadAll(resp1.Body)
-	if err != nil {
-		l.Error("上传图片失败：", err)
-		return "", 0, err
-	}
-	out, err := os.Create(name + path.Ext(url))
-	if err != nil {
-		l.Error("上传图片失败：", err)
-		return "", 0, err
-	}
-	defer os.Remove(name + path.Ext(url))
-	_, err = io.Copy(out, bytes.NewReader(body))
-	if err != nil {
-		l.Error("上传图片失败：", err)
-		return "", 0, errors.New("internal server error")
-	}
 
 	accessKey := l.svcCtx.StaticStorage.AccessKey
 	accessSecret := l.svcCtx.StaticStorage.AccessSecret
 	bucket := l.svcCtx.StaticStorage.Bucket
 	region := l.svcCtx.StaticStorage.Region
-	ext := path.Ext(url)
-	contentType := ""
-
-	switch ext {
-	case ".png":
-		contentType = "image/png"
-	case ".jpg":
-		contentType = "image/jpeg"
-	case ".gif":
-		contentType = "image/gif"
-	case ".bmp":
-		contentType = "image/bmp"
-	case ".jpeg":
-		contentType = "image/jpeg"
-	case ".svg":
-		contentType = "image/svg"
-	default:
-		return "", 0, errors.New("unsupported image format")
-	}
 
 	sess, err := session.NewSession(&aws.Config{
 		Credentials:      credentials.NewStaticCredentials(accessKey, accessSecret, ""),
@@ -301,15 +192,24 @@ func (l ProductImageAddLogic) UploadImageEmy(url string) (src string, width int6
 	uploader := s3manager.NewUploader(sess)
 	result, err := uploader.Upload(&s3manager.UploadInput{
 		Bucket:      aws.String(bucket),
-		Key:         aws.String("uploader/" + name + path.Ext(url)),
-		Body:        bytes.NewReader([]byte(body)),
-		ContentType: aws.String(contentType),
+		Key:         aws.String("uploader/" + randomImageName() + img.Ext),
+		Body:        bytes.NewReader(img.Body),
+		ContentType: aws.String(img.ContentType),
 	})
 	if err != nil {
 		l.Error("上传图片失败：", err)
-		return "", 0, errors.New("upload image failed")
+		return "", 0, errorx.ErrUploadFailed
 	}
 
-	return result.Location, width, nil
+	return result.Location, img.Width, nil
 
 }
+
+// randomImageName 按时间和随机数生成文件名，扩展名由下载到的图片内容决定
+func randomImageName() string {
+	now := time.Now()
+	s := strconv.Itoa(int(now.UnixNano())) + strconv.Itoa(rand.Intn(999))
+	h := sha1.New()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
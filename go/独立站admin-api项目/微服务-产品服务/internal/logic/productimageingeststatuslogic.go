@@ -0,0 +1,96 @@
+package logic
+
+import (
+	"context"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ProductImageIngestStatusLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewProductImageIngestStatusLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ProductImageIngestStatusLogic {
+	return &ProductImageIngestStatusLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ProductImageIngestStatusLogic) ProductImageIngestStatus(in *product.ProductImageIngestStatusRequest) (*product.ProductImageIngestStatusResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.ProductId == 0 {
+		l.Error("缺少product_id参数")
+		return nil, errorx.Missing("product_id")
+	}
+
+	resp := &product.ProductImageIngestStatusResponse{Status: "done"}
+	jobs, err := l.svcCtx.ImageJobModel.FindListByProductId(in.ShopId, in.ProductId)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		return resp, nil
+	default:
+		l.Error("查询图片任务出错：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	for _, job := range *jobs {
+		switch job.Status {
+		case model.IMAGE_JOB_STATUS_SUCCEEDED:
+			resp.Succeeded++
+		case model.IMAGE_JOB_STATUS_DEAD:
+			resp.Dead++
+		default:
+			resp.Pending++
+		}
+		resp.Jobs = append(resp.Jobs, &product.ProductImageIngestJob{
+			Id:         job.Id,
+			Url:        job.Url,
+			Status:     imageJobStatusText(job.Status),
+			Attempts:   job.Attempts,
+			ImageId:    job.ImageId,
+			IsDefault:  job.IsDefault == 1,
+			VariantIds: model.ParseVariantIds(job.VariantIds),
+			LastError:  job.LastError,
+			NextRunAt:  job.NextRunAt.Local().Format(time.RFC3339),
+			CreatedAt:  job.CreatedAt.Local().Format(time.RFC3339),
+			UpdatedAt:  job.UpdatedAt.Local().Format(time.RFC3339),
+		})
+	}
+	if resp.Pending > 0 {
+		resp.Status = "processing"
+	} else if resp.Dead > 0 {
+		resp.Status = "failed"
+	}
+
+	return resp, nil
+}
+
+func imageJobStatusText(status int64) string {
+	switch status {
+	case model.IMAGE_JOB_STATUS_PENDING:
+		return "pending"
+	case model.IMAGE_JOB_STATUS_PROCESSING:
+		return "processing"
+	case model.IMAGE_JOB_STATUS_SUCCEEDED:
+		return "succeeded"
+	case model.IMAGE_JOB_STATUS_DEAD:
+		return "dead"
+	default:
+		return ""
+	}
+}
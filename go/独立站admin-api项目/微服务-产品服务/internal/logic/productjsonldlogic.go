@@ -0,0 +1,167 @@
+package logic
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/jsonld"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+var currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type ProductJsonLdLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewProductJsonLdLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ProductJsonLdLogic {
+	return &ProductJsonLdLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ProductJsonLdLogic) ProductJsonLd(in *product.ProductJsonLdRequest) (*product.ProductJsonLdResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少店铺id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.ProductHandler == "" && in.ProductId == 0 {
+		l.Error("缺少查询参数")
+		return nil, errorx.Missing("product_id", "product_handler")
+	}
+	baseUrl, err := url.Parse(in.BaseUrl)
+	if err != nil || (baseUrl.Scheme != "http" && baseUrl.Scheme != "https") || baseUrl.Host == "" {
+		l.Error("base_url参数不合法：", in.BaseUrl)
+		return nil, errorx.InvalidField("base_url", "base_url is invalid")
+	}
+	in.Currency = strings.ToUpper(in.Currency)
+	if !currencyRegexp.MatchString(in.Currency) {
+		l.Error("currency参数不合法：", in.Currency)
+		return nil, errorx.InvalidField("currency", "currency is invalid")
+	}
+
+	options := make([]model.HandlerOption, 0)
+	if in.ProductId != 0 {
+		options = append(options, model.WithId(in.ProductId))
+	}
+	if in.ProductHandler != "" {
+		options = append(options, model.WithHandler(in.ProductHandler))
+	}
+	resp, err := l.svcCtx.ReadModel.FindOne(in.ShopId, options...)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		l.Error("商品记录不存在")
+		return nil, errorx.ErrProductNotFound
+	default:
+		l.Error(err)
+		return nil, errorx.ErrInternal
+	}
+
+	variants, err := l.svcCtx.ReadVariantModel.FindList(in.ShopId, resp.Id)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		// 没有子商品的产品按产品本身的价格和库存生成 Offer
+		variants = &[]model.SailShopProductVariant{}
+	default:
+		l.Error(err)
+		return nil, errorx.ErrInternal
+	}
+
+	imageIds := make([]int64, 0)
+	if resp.DefaultImageId != 0 {
+		imageIds = append(imageIds, resp.DefaultImageId)
+	}
+	for _, id := range strings.Split(resp.ImageIds, ",") {
+		imageId, err := strconv.ParseInt(id, 10, 64)
+		if err == nil && imageId != 0 && imageId != resp.DefaultImageId {
+			imageIds = append(imageIds, imageId)
+		}
+	}
+	queryIds := append([]int64{}, imageIds...)
+	for _, variant := range *variants {
+		if variant.ImageId != 0 {
+			queryIds = append(queryIds, variant.ImageId)
+		}
+	}
+	images, err := l.svcCtx.ReadImageModel.FindByIds(queryIds)
+	if err != nil {
+		l.Error("查询产品图片出错：", err)
+		return nil, errorx.ErrInternal
+	}
+	imageUrls := make(map[int64]string, len(*images))
+	for _, image := range *images {
+		if image.FileKey != "" {
+			imageUrls[image.Id] = cdnImageUrl(l.svcCtx.ImgCDN, image.FileKey)
+		}
+	}
+
+	description := resp.SeoDesc
+	if description == "" {
+		description = resp.SubTitle
+	}
+	input := jsonld.ProductInput{
+		Url:          strings.TrimRight(in.BaseUrl, "/") + "/products/" + url.PathEscape(resp.Handler),
+		Title:        resp.Title,
+		Description:  description,
+		Sku:          resp.DefaultSkuCode,
+		Vendor:       resp.Vendor,
+		Currency:     in.Currency,
+		Price:        resp.Price,
+		ComparePrice: resp.CompareAtPrice,
+		Inventory:    resp.ProductStock,
+		UseStock:     resp.IsUseStock == 1,
+		ContinueSell: resp.SoldoutPolicy.String != "N",
+		Comments:     resp.Comments,
+		Scores:       resp.Scores,
+	}
+	for _, id := range imageIds {
+		if imageUrl, ok := imageUrls[id]; ok {
+			input.Images = append(input.Images, imageUrl)
+		}
+	}
+	for _, variant := range *variants {
+		title := variant.Title
+		if title == "" {
+			title = resp.Title
+		}
+		input.Variants = append(input.Variants, jsonld.VariantInput{
+			Id:           variant.Id,
+			Title:        title,
+			Sku:          variant.SkuCode,
+			Image:        imageUrls[variant.ImageId],
+			Price:        variant.Price,
+			ComparePrice: variant.CompareAtPrice,
+			Inventory:    variant.InventoryQuantity,
+		})
+	}
+
+	doc, err := jsonld.BuildProduct(input)
+	if err != nil {
+		l.Error("生成结构化数据出错：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ProductJsonLdResponse{JsonLd: string(doc)}, nil
+}
+
+// cdnImageUrl 图片表中保存的是OSS路径，拼上CDN域名得到完整地址
+func cdnImageUrl(cdn, fileKey string) string {
+	if strings.HasPrefix(fileKey, "http://") || strings.HasPrefix(fileKey, "https://") {
+		return fileKey
+	}
+	return "https://" + cdn + "/" + strings.TrimLeft(fileKey, "/")
+}
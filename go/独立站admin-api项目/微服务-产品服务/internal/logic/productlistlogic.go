@@ -5,13 +5,18 @@ import (
 	"errors"
 	"github.com/tal-tech/go-zero/core/mr"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
 
 	"github.com/tal-tech/go-zero/core/logx"
@@ -34,7 +39,12 @@ func NewProductListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *Produ
 func (l *ProductListLogic) ProductList(in *product.ProductListRequest) (*product.ProductListResponse, error) {
 	l.Info(" ProductList Called ")
 	if in.Fields == "" {
-		in.Fields = "id,title,price,compare_price,weight,weight_unit,shop_url,product_url,body_html,seo_title,seo_desc,published_at,is_use_stock,soldout_policy,handler,status,created_at,updated_at,images,default_image,variants"
+		in.Fields = fieldmask.ProductDefault
+	}
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Product)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return nil, errorx.InvalidField("fields", err.Error())
 	}
 	if in.Ids != "" && in.Limit == 0 {
 		idSet := strings.Split(in.Ids, ",")
@@ -78,282 +88,117 @@ func (l *ProductListLogic) ProductList(in *product.ProductListRequest) (*product
 	l.Error("options:", options)
 	if in.ShopId == 0 {
 		l.Error("shop_id 参数不能为空")
-		return nil, errors.New(" argument shop_id is needed ")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.MetafieldNamespace != "" || in.MetafieldKey != "" || in.MetafieldValue != "" {
+		if in.MetafieldNamespace == "" || in.MetafieldKey == "" {
+			l.Error("按自定义字段筛选缺少namespace或key参数")
+			return nil, errorx.InvalidField("metafield_key", "metafield_namespace and metafield_key are needed")
+		}
+		// 筛选值按字段类型统一格式后再与存储的值比较
+		fieldType, err := l.svcCtx.ReadMetafieldModel.FindType(in.ShopId, metafield.OwnerProduct, in.MetafieldNamespace, in.MetafieldKey)
+		switch err {
+		case nil:
+		case model.ErrNotFound:
+			return &product.ProductListResponse{Products: []*product.Product{}}, nil
+		default:
+			l.Error("查询自定义字段类型失败：", err)
+			return nil, errorx.ErrInternal
+		}
+		value, err := metafield.Normalize(fieldType, in.MetafieldValue)
+		if err != nil {
+			l.Error("自定义字段筛选值不合法：", err)
+			return nil, errorx.InvalidField("metafield_value", err.Error())
+		}
+		options.Metafield = model.MetafieldFilter{Namespace: in.MetafieldNamespace, Key: in.MetafieldKey, Value: value}
+	}
+	switch in.SortBy {
+	case "":
+	case model.LIST_SORT_RATING:
+		prior, err := ratingPrior(l.svcCtx, in.ShopId)
+		if err != nil {
+			l.Error("查询店铺平均分失败：", err)
+			return nil, errorx.ErrInternal
+		}
+		options.SortBy = model.LIST_SORT_RATING
+		options.RatingPriorMean = prior
+		options.RatingPriorWeight = l.svcCtx.Config.Rating.PriorWeight
+	default:
+		l.Error("sort_by参数不合法：", in.SortBy)
+		return nil, errorx.InvalidField("sort_by", "sort_by must be empty or rating")
 	}
 	resp, err := l.svcCtx.ReadModel.FindList(options, in.ShopId)
 	switch err {
 	case nil:
 
 	case sqlc.ErrNotFound:
-		return nil, sqlc.ErrNotFound
+		return nil, errorx.ErrProductNotFound
 	default:
 		l.Error(err)
-		return nil, err
+		return nil, errorx.FromError(err)
 	}
 	if resp == nil {
 		l.Error("查询数据为空")
-		return nil, errors.New("查询列表数据为空")
+		return nil, errorx.ErrInternal
 	}
 
 	l.Error("列表条数", len(*resp))
+	if len(*resp) == 0 {
+		return &product.ProductListResponse{}, nil
+	}
 
-	//productSet := make([]*product.Product, 0)
-
-	r, err := mr.MapReduce(func(source chan<- interface{}) {
-		for _, valPro := range *resp {
-			source <- valPro
-		}
-	}, func(item interface{}, writer mr.Writer, cancel func(error)) {
-		valPro := item.(model.SailShopProduct)
-		resp, err := l.svcCtx.ReadModel.FindOne(in.ShopId, model.WithId(valPro.Id))
-		switch err {
-		case nil:
+	productIds := make([]int64, 0, len(*resp))
+	for _, valPro := range *resp {
+		productIds = append(productIds, valPro.Id)
+	}
+	resolver, err := pricing.Load(l.svcCtx.ReadPriceListModel, in.ShopId, in.Market, in.Currency, productIds)
+	switch err {
+	case nil:
+	case pricing.ErrPriceListNotFound:
+		l.Error("价格表不存在 market:", in.Market, " currency:", in.Currency)
+		return nil, errorx.ErrPriceListNotFound
+	default:
+		l.Error("查询价格表失败：", err)
+		return nil, errorx.ErrInternal
+	}
 
-		case sqlc.ErrNotFound:
-			l.Error("商品记录不存在,product_id:", valPro.Id)
-			return
-		default:
-			l.Error(err)
-			cancel(errors.New("internal server error"))
+	result, err := l.assemble(in.ShopId, *resp, mask)
+	if err != nil {
+		l.Error("查询产品列表出错：", err)
+		return nil, errorx.ErrInternal
+	}
+	if resolver != nil {
+		for _, item := range result {
+			resolver.Apply(item)
 		}
-
-		var productDetail product.Product
-		var defaultImage product.ProductImage
-		var images []*product.ProductImage
-		var variants []*product.ProductVariant
-		var bodyHtml string
-
-		err = mr.Finish(func() error {
-			if strings.Contains(in.Fields, "body_html") {
-				respDetail, err := l.svcCtx.ReadProductDetailModel.FindOne(valPro.Id)
-				switch err {
-				case nil:
-					bodyHtml = respDetail.BodyHtml
-				case sqlc.ErrNotFound:
-					logx.Error("body_html not found")
-				default:
-					l.Error(err)
-				}
-			}
-
-			return nil
-
-		}, func() (err error) {
-			if strings.Contains(in.Fields, "default_image") {
-				if resp.DefaultImageId != 0 {
-					respDefaultImage, err := l.svcCtx.ReadImageModel.FindOne(resp.DefaultImageId)
-					switch err {
-					case nil:
-						defaultImage = product.ProductImage{
-							Id:         respDefaultImage.Id,
-							ProductId:  valPro.Id,
-							Sort:       0,
-							Width:      respDefaultImage.ImageWidth,
-							Url:        respDefaultImage.FileKey,
-							VariantIds: nil,
-							FileKey:    respDefaultImage.FileKey,
-							CreatedAt:  respDefaultImage.CreatedAt.Local().Format(time.RFC3339),
-							UpdatedAt:  respDefaultImage.UpdatedAt.Local().Format(time.RFC3339),
-						}
-					case sqlc.ErrNotFound:
-						l.Error("图片记录不存在")
-						//return sqlc.ErrNotFound
-					default:
-						l.Error(err)
-						return errors.New("internal server error")
-					}
-				}
-			}
-
-			return nil
-		}, func() (err error) {
-			if strings.Contains(in.Fields, "variants") {
-				variantResp, err := l.svcCtx.ReadVariantModel.FindList(in.ShopId, valPro.Id)
-				//_, err = l.svcCtx.ReadVariantModel.FindList(in.ShopId, in.ProductId)
-				switch err {
-				case nil:
-					if variantResp != nil {
-						for _, valVar := range *variantResp {
-							inventoryPolicy := "N"
-							if valPro.IsUseStock == 1 {
-								inventoryPolicy = "Y"
-							}
-							imageUrl := ""
-							if valVar.ImageId != 0 {
-								respImage, err := l.svcCtx.ReadImageModel.FindOne(valVar.ImageId)
-								switch err {
-								case nil:
-									imageUrl = respImage.FileKey
-								case sqlc.ErrNotFound:
-								default:
-
-								}
-							}
-							tempVariant := &product.ProductVariant{
-								Id:                valVar.Id,
-								Sku:               valVar.SkuCode,
-								Title:             valVar.Title,
-								Price:             valVar.Price,
-								ComparePrice:      valVar.CompareAtPrice,
-								Spec:              valVar.Spec.String,
-								Weight:            valVar.Weight,
-								WeightUnit:        valVar.WeightUnit,
-								RequiresShipping:  valVar.RequiresShipping,
-								ImageId:           valVar.ImageId,
-								CreatedAt:         valVar.CreatedAt.Local().Format(time.RFC3339),
-								UpdatedAt:         valVar.UpdatedAt.Local().Format(time.RFC3339),
-								Sort:              valVar.Sort,
-								InventoryQuantity: valVar.InventoryQuantity,
-								InventoryPolicy:   inventoryPolicy,
-								IsShow:            valVar.IsShow,
-								ImageUrl:          imageUrl,
-							}
-							variants = append(variants, tempVariant)
-						}
-					}
-				case sqlc.ErrNotFound:
-					l.Error("子商品记录不存在")
-					//return sqlc.ErrNotFound
-				default:
-					l.Error(err)
-					//return errors.New("internal server error")
-				}
-			}
-
-			return nil
-		}, func() (err error) {
-			if strings.Contains(in.Fields, "images") {
-				imageSet := strings.Split(resp.ImageIds, ",")
-				imageMap := map[string]int{}
-				for k, v := range imageSet {
-					imageMap[v] = k
-				}
-				resultImage, err := mr.MapReduce(func(source chan<- interface{}) {
-					for _, valPro := range imageSet {
-						source <- valPro
-					}
-				}, func(item interface{}, writer mr.Writer, cancel func(error)) {
-					uid := item.(string)
-					convId, err := strconv.Atoi(uid)
-					if err != nil {
-						l.Error(err)
-						return
-					}
-					if convId != 0 {
-						respDefaultImage, err := l.svcCtx.ReadImageModel.FindOne(int64(convId))
-						switch err {
-						case nil:
-							tempImage := product.ProductImage{
-								Id:         respDefaultImage.Id,
-								ProductId:  valPro.Id,
-								Sort:       0,
-								Width:      respDefaultImage.ImageWidth,
-								Url:        respDefaultImage.FileKey,
-								VariantIds: nil,
-								FileKey:    respDefaultImage.FileKey,
-								CreatedAt:  respDefaultImage.CreatedAt.Local().Format(time.RFC3339),
-								UpdatedAt:  respDefaultImage.UpdatedAt.Local().Format(time.RFC3339),
-							}
-							writer.Write(&tempImage)
-						case sqlc.ErrNotFound:
-							l.Error("图片记录不存在")
-							//return sqlc.ErrNotFound
-						default:
-							l.Error(err)
-							//return errors.New("internal server error")
-						}
-					}
-				}, func(pipe <-chan interface{}, writer mr.Writer, cancel func(error)) {
-					var uids []*product.ProductImage
-					for p := range pipe {
-						uids = append(uids, p.(*product.ProductImage))
-					}
-					writer.Write(uids)
-				})
-				if err != nil {
-					logx.Error(err)
-					return err
-				}
-
-				images = resultImage.([]*product.ProductImage)
-				sort.Slice(images, func(i, j int) bool {
-					return imageMap[strconv.Itoa(int(images[i].Id))] < imageMap[strconv.Itoa(int(images[j].Id))]
-				})
-			}
-
-			return nil
+	}
+	// 按评分排序时保持数据库排好的顺序
+	if options.SortBy != model.LIST_SORT_RATING {
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].ProductId > result[j].ProductId
 		})
+	}
+	if err := attachRatings(l.svcCtx, in.ShopId, mask, result); err != nil {
+		l.Error("查询产品评分失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	if err := attachMetafields(l.svcCtx, in.ShopId, mask, result); err != nil {
+		l.Error("查询自定义字段失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	switch err := translateProducts(l.svcCtx, in.ShopId, in.Locale, result); err {
+	case nil:
+	case translation.ErrLocaleInvalid:
+		l.Error("locale参数不合法：", in.Locale)
+		return nil, errorx.InvalidField("locale", err.Error())
+	default:
+		l.Error("查询翻译失败：", err)
+		return nil, errorx.ErrInternal
+	}
 
-		if err != nil {
-			l.Error(err)
-			cancel(errors.New("internal server error"))
-		}
-
-		var youtubeVideoPos int
-		if resp.YoutubeVideoPos != "" {
-			youtubeVideoPos, err = strconv.Atoi(resp.YoutubeVideoPos)
-			if err != nil {
-				l.Error(err)
-				cancel(errors.New("internal server error"))
-			}
-		}
-
-		productDetail = product.Product{
-			ProductTitle:    resp.Title,
-			ProductId:       valPro.Id,
-			Price:           resp.Price,
-			CompareAtPrice:  resp.CompareAtPrice,
-			Weight:          resp.Weight,
-			WeightUnit:      resp.WeightUnit,
-			ShopUrl:         "",
-			PreviewUrl:      "/products/" + resp.Handler,
-			BodyHtml:        bodyHtml,
-			SeoTitle:        resp.SeoTitle,
-			SeoDesc:         resp.SeoDesc,
-			PublishedAt:     resp.PublishedAt.Local().Format(time.RFC3339),
-			IsUseStock:      resp.IsUseStock,
-			SoldoutPolicy:   resp.SoldoutPolicy.String,
-			Handle:          resp.Handler,
-			Status:          resp.Status,
-			CreatedAt:       resp.CreatedAt.Local().Format(time.RFC3339),
-			UpdatedAt:       resp.UpdatedAt.Local().Format(time.RFC3339),
-			Images:          images,
-			DefaultImage:    &defaultImage,
-			Variants:        variants,
-			SubTitle:        resp.SubTitle,
-			Attribute:       resp.Attribute.String,
-			Comments:        resp.Comments,
-			IsShowComment:   resp.IsShowComment,
-			Scores:          resp.Scores,
-			CountSkus:       resp.CountSkus,
-			IsRead:          resp.IsRead,
-			CountSales:      resp.CountSales,
-			YoutubeVideoPos: int64(youtubeVideoPos),
-			YoutubeVideoUrl: resp.YoutubeVideoUrl,
-		}
-		//tempImage := &product.Image{}
-		if err != nil {
-			l.Error(err)
-			cancel(err)
-		}
-
-		writer.Write(&productDetail)
-
-	}, func(pipe <-chan interface{}, writer mr.Writer, cancel func(error)) {
-		var set []*product.Product
-		for p := range pipe {
-			set = append(set, p.(*product.Product))
-		}
-		writer.Write(set)
-	})
-	if err != nil {
-		l.Error("查询产品列表出错: %v", err)
-		return nil, err
+	for _, item := range result {
+		fieldmask.ProjectProduct(mask, item)
 	}
-	result := r.([]*product.Product)
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].ProductId > result[j].ProductId
-	})
 
 	return &product.ProductListResponse{Products: result}, nil
 }
@@ -393,3 +238,169 @@ func (l *ProductListLogic) GetFunc(index, imageId, productId int64, imageChan ch
 		return err
 	}
 }
+
+// assemble 列表的关联数据按产品id批量查询后在内存中组装，查询次数和列表条数无关
+func (l *ProductListLogic) assemble(shopId int64, rows []model.SailShopProduct, mask fieldmask.Mask) ([]*product.Product, error) {
+	productIds := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		productIds = append(productIds, row.Id)
+	}
+
+	var bodyHtml map[int64]string
+	var variants map[int64][]model.SailShopProductVariant
+	var tags map[int64][]string
+	var variantVersions map[int64]int64
+	err := mr.Finish(func() (err error) {
+		if mask.Has("body_html") {
+			bodyHtml, err = l.svcCtx.ProductBatchModel.FindBodyHtml(productIds)
+		}
+		return err
+	}, func() (err error) {
+		if mask.Has("variants") {
+			variants, err = l.svcCtx.ProductBatchModel.FindVariants(shopId, productIds)
+		}
+		return err
+	}, func() (err error) {
+		if mask.Has("tags") {
+			tags, err = l.svcCtx.ProductBatchModel.FindTags(shopId, productIds)
+		}
+		return err
+	}, func() (err error) {
+		if mask.Sub("variants").Has("version") {
+			variantVersions, err = l.svcCtx.ProductBatchModel.FindVariantVersions(shopId, productIds)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 产品图片、主图和子商品图片合并成一次查询
+	imageIds := make([]int64, 0)
+	seen := make(map[int64]bool)
+	addImage := func(id int64) {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			imageIds = append(imageIds, id)
+		}
+	}
+	for _, row := range rows {
+		if mask.Has("images") {
+			for _, id := range model.ParseVariantIds(row.ImageIds) {
+				addImage(id)
+			}
+		}
+		if mask.Has("default_image") {
+			addImage(row.DefaultImageId)
+		}
+		if mask.Sub("variants").Has("image_url") {
+			for _, variant := range variants[row.Id] {
+				addImage(variant.ImageId)
+			}
+		}
+	}
+	respImages, err := l.svcCtx.ReadImageModel.FindByIds(imageIds)
+	if err != nil {
+		return nil, err
+	}
+	images := make(map[int64]model.SailUpload, len(*respImages))
+	for _, image := range *respImages {
+		images[image.Id] = image
+	}
+
+	result := make([]*product.Product, 0, len(rows))
+	for _, row := range rows {
+		var youtubeVideoPos int
+		if row.YoutubeVideoPos != "" {
+			if youtubeVideoPos, err = strconv.Atoi(row.YoutubeVideoPos); err != nil {
+				return nil, err
+			}
+		}
+		defaultImage := &product.ProductImage{}
+		if image, ok := images[row.DefaultImageId]; ok {
+			defaultImage = toProductImage(row.Id, image)
+		}
+		var productImages []*product.ProductImage
+		for _, id := range model.ParseVariantIds(row.ImageIds) {
+			if image, ok := images[id]; ok {
+				productImages = append(productImages, toProductImage(row.Id, image))
+			}
+		}
+		inventoryPolicy := "N"
+		if row.IsUseStock == 1 {
+			inventoryPolicy = "Y"
+		}
+		var productVariants []*product.ProductVariant
+		for _, valVar := range variants[row.Id] {
+			productVariants = append(productVariants, &product.ProductVariant{
+				Id:                valVar.Id,
+				Sku:               valVar.SkuCode,
+				Title:             valVar.Title,
+				Price:             valVar.Price,
+				ComparePrice:      valVar.CompareAtPrice,
+				Spec:              valVar.Spec.String,
+				Weight:            valVar.Weight,
+				WeightUnit:        valVar.WeightUnit,
+				RequiresShipping:  valVar.RequiresShipping,
+				ImageId:           valVar.ImageId,
+				CreatedAt:         valVar.CreatedAt.Local().Format(time.RFC3339),
+				UpdatedAt:         valVar.UpdatedAt.Local().Format(time.RFC3339),
+				Sort:              valVar.Sort,
+				InventoryQuantity: valVar.InventoryQuantity,
+				InventoryPolicy:   inventoryPolicy,
+				IsShow:            valVar.IsShow,
+				ImageUrl:          images[valVar.ImageId].FileKey,
+				Version:           variantVersions[valVar.Id],
+			})
+		}
+		result = append(result, &product.Product{
+			ProductTitle:    row.Title,
+			ProductId:       row.Id,
+			Price:           row.Price,
+			CompareAtPrice:  row.CompareAtPrice,
+			Weight:          row.Weight,
+			WeightUnit:      row.WeightUnit,
+			ShopUrl:         "",
+			PreviewUrl:      "/products/" + row.Handler,
+			BodyHtml:        bodyHtml[row.Id],
+			SeoTitle:        row.SeoTitle,
+			SeoDesc:         row.SeoDesc,
+			PublishedAt:     row.PublishedAt.Local().Format(time.RFC3339),
+			IsUseStock:      row.IsUseStock,
+			SoldoutPolicy:   row.SoldoutPolicy.String,
+			Handle:          row.Handler,
+			Status:          row.Status,
+			CreatedAt:       row.CreatedAt.Local().Format(time.RFC3339),
+			UpdatedAt:       row.UpdatedAt.Local().Format(time.RFC3339),
+			Images:          productImages,
+			DefaultImage:    defaultImage,
+			Variants:        productVariants,
+			SubTitle:        row.SubTitle,
+			Attribute:       row.Attribute.String,
+			Comments:        row.Comments,
+			IsShowComment:   row.IsShowComment,
+			Scores:          row.Scores,
+			Vendor:          row.Vendor,
+			CountSkus:       row.CountSkus,
+			IsRead:          row.IsRead,
+			CountSales:      row.CountSales,
+			YoutubeVideoPos: int64(youtubeVideoPos),
+			YoutubeVideoUrl: row.YoutubeVideoUrl,
+			Tags:            tags[row.Id],
+			Version:         row.Version,
+		})
+	}
+	return result, nil
+}
+
+func toProductImage(productId int64, image model.SailUpload) *product.ProductImage {
+	return &product.ProductImage{
+		Id:        image.Id,
+		ProductId: productId,
+		Width:     image.ImageWidth,
+		Url:       image.FileKey,
+		FileKey:   image.FileKey,
+		CreatedAt: image.CreatedAt.Local().Format(time.RFC3339),
+		UpdatedAt: image.UpdatedAt.Local().Format(time.RFC3339),
+	}
+}
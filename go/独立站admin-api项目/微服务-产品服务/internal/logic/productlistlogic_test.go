@@ -0,0 +1,272 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+)
+
+// 模拟一次数据库往返的耗时，让基准测试的耗时包含查询开销
+const listQueryRoundTrip = 200 * time.Microsecond
+
+// listFixture 内存中的产品数据。下面的模型桩每个方法对应真实模型里的一条 SQL（不走缓存），
+// 按方法名记录调用次数
+type listFixture struct {
+	mu       sync.Mutex
+	queries  map[string]int
+	rtt      time.Duration
+	products map[int64]model.SailShopProduct
+	variants map[int64][]model.SailShopProductVariant
+	images   map[int64]model.SailUpload
+}
+
+func (f *listFixture) query(name string) {
+	f.mu.Lock()
+	f.queries[name]++
+	f.mu.Unlock()
+	if f.rtt > 0 {
+		time.Sleep(f.rtt)
+	}
+}
+
+// newListFixture 每个产品3张图片，第一张为主图，2个子商品各有一张图片
+func newListFixture(n int, rtt time.Duration) (*listFixture, []model.SailShopProduct) {
+	f := &listFixture{
+		queries:  make(map[string]int),
+		rtt:      rtt,
+		products: make(map[int64]model.SailShopProduct, n),
+		variants: make(map[int64][]model.SailShopProductVariant, n),
+		images:   make(map[int64]model.SailUpload, n*5),
+	}
+	rows := make([]model.SailShopProduct, 0, n)
+	var imageId, variantId int64
+	for i := 1; i <= n; i++ {
+		productId := int64(i)
+		imageIds := make([]string, 0, 3)
+		for j := 0; j < 3; j++ {
+			imageId++
+			f.images[imageId] = model.SailUpload{Id: imageId, ShopId: 1, FileKey: fmt.Sprintf("images/%d.jpg", imageId)}
+			imageIds = append(imageIds, strconv.FormatInt(imageId, 10))
+		}
+		row := model.SailShopProduct{
+			Id:             productId,
+			ShopId:         1,
+			Title:          fmt.Sprintf("product %d", productId),
+			Handler:        fmt.Sprintf("product-%d", productId),
+			ImageIds:       strings.Join(imageIds, ","),
+			DefaultImageId: imageId - 2,
+		}
+		f.products[productId] = row
+		rows = append(rows, row)
+		for j := 0; j < 2; j++ {
+			imageId++
+			variantId++
+			f.images[imageId] = model.SailUpload{Id: imageId, ShopId: 1, FileKey: fmt.Sprintf("images/%d.jpg", imageId)}
+			f.variants[productId] = append(f.variants[productId], model.SailShopProductVariant{Id: variantId, ImageId: imageId})
+		}
+	}
+	return f, rows
+}
+
+func (f *listFixture) total() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int
+	for _, count := range f.queries {
+		n += count
+	}
+	return n
+}
+
+func (f *listFixture) logic() *ProductListLogic {
+	svcCtx := &svc.ServiceContext{
+		ReadModel:              listProductModel{listFixture: f},
+		ReadImageModel:         listImageModel{listFixture: f},
+		ReadVariantModel:       listVariantModel{listFixture: f},
+		ReadProductDetailModel: listDetailModel{listFixture: f},
+		ProductBatchModel:      listBatchModel{listFixture: f},
+	}
+	return NewProductListLogic(context.Background(), svcCtx)
+}
+
+type listProductModel struct {
+	model.SailShopProductModel
+	*listFixture
+}
+
+func (m listProductModel) FindOne(shopId int64, filter ...model.HandlerOption) (*model.SailShopProduct, error) {
+	m.query("ReadModel.FindOne")
+	row, ok := m.products[model.SetOptions(filter...).Id]
+	if !ok {
+		return nil, sqlc.ErrNotFound
+	}
+	return &row, nil
+}
+
+type listDetailModel struct {
+	model.SailShopProductDetailModel
+	*listFixture
+}
+
+func (m listDetailModel) FindOne(productId int64) (*model.SailShopProductDetail, error) {
+	m.query("ReadProductDetailModel.FindOne")
+	return &model.SailShopProductDetail{ProductId: productId, BodyHtml: "<p>body</p>"}, nil
+}
+
+type listVariantModel struct {
+	model.SailShopProductVariantModel
+	*listFixture
+}
+
+func (m listVariantModel) FindList(shopId, productId int64) (*[]model.SailShopProductVariant, error) {
+	m.query("ReadVariantModel.FindList")
+	variants, ok := m.variants[productId]
+	if !ok {
+		return nil, sqlc.ErrNotFound
+	}
+	return &variants, nil
+}
+
+type listImageModel struct {
+	model.SailUploadModel
+	*listFixture
+}
+
+func (m listImageModel) FindOne(id int64) (*model.SailUpload, error) {
+	m.query("ReadImageModel.FindOne")
+	image, ok := m.images[id]
+	if !ok {
+		return nil, sqlc.ErrNotFound
+	}
+	return &image, nil
+}
+
+func (m listImageModel) FindByIds(ids []int64) (*[]model.SailUpload, error) {
+	resp := make([]model.SailUpload, 0, len(ids))
+	if len(ids) == 0 {
+		return &resp, nil
+	}
+	m.query("ReadImageModel.FindByIds")
+	for _, id := range ids {
+		if image, ok := m.images[id]; ok {
+			resp = append(resp, image)
+		}
+	}
+	return &resp, nil
+}
+
+type listBatchModel struct {
+	model.SailProductBatchModel
+	*listFixture
+}
+
+func (m listBatchModel) FindBodyHtml(productIds []int64) (map[int64]string, error) {
+	m.query("FindBodyHtml")
+	resp := make(map[int64]string, len(productIds))
+	for _, id := range productIds {
+		resp[id] = "<p>body</p>"
+	}
+	return resp, nil
+}
+
+func (m listBatchModel) FindVariants(shopId int64, productIds []int64) (map[int64][]model.SailShopProductVariant, error) {
+	m.query("FindVariants")
+	resp := make(map[int64][]model.SailShopProductVariant, len(productIds))
+	for _, id := range productIds {
+		resp[id] = m.variants[id]
+	}
+	return resp, nil
+}
+
+func (m listBatchModel) FindTags(shopId int64, productIds []int64) (map[int64][]string, error) {
+	m.query("FindTags")
+	return map[int64][]string{}, nil
+}
+
+func (m listBatchModel) FindVariantVersions(shopId int64, productIds []int64) (map[int64]int64, error) {
+	m.query("FindVariantVersions")
+	resp := make(map[int64]int64)
+	for _, id := range productIds {
+		for _, variant := range m.variants[id] {
+			resp[variant.Id] = 1
+		}
+	}
+	return resp, nil
+}
+
+// 逐条查询的方法，批量组装时不应再被调用
+var listPerRowQueries = []string{
+	"ReadModel.FindOne",
+	"ReadProductDetailModel.FindOne",
+	"ReadVariantModel.FindList",
+	"ReadImageModel.FindOne",
+}
+
+func TestProductListAssembleQueriesIndependentOfPageSize(t *testing.T) {
+	mask, err := fieldmask.Parse(fieldmask.ProductDefault, fieldmask.Product)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counts []int
+	for _, n := range []int{1, 10, 250} {
+		f, rows := newListFixture(n, 0)
+		result, err := f.logic().assemble(1, rows, mask)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != n {
+			t.Fatalf("assemble returned %d products, want %d", len(result), n)
+		}
+		for _, item := range result {
+			if len(item.Images) != 3 || len(item.Variants) != 2 || item.DefaultImage.Id == 0 || item.Variants[0].ImageUrl == "" {
+				t.Fatalf("product %d assembled incompletely: %+v", item.ProductId, item)
+			}
+		}
+		for _, name := range listPerRowQueries {
+			if f.queries[name] != 0 {
+				t.Fatalf("page of %d: %s called %d times, want 0", n, name, f.queries[name])
+			}
+		}
+		for name, count := range f.queries {
+			if count != 1 {
+				t.Fatalf("page of %d: %s called %d times, want 1", n, name, count)
+			}
+		}
+		if f.queries["ReadImageModel.FindByIds"] != 1 || f.queries["FindVariants"] != 1 {
+			t.Fatalf("page of %d: queries = %v, want images and variants loaded in one query each", n, f.queries)
+		}
+		counts = append(counts, f.total())
+	}
+	for _, count := range counts[1:] {
+		if count != counts[0] {
+			t.Fatalf("queries per page = %v, want the same count for every page size", counts)
+		}
+	}
+}
+
+// BenchmarkProductListAssemble 一页250个产品的组装耗时和查询次数（queries/page）
+func BenchmarkProductListAssemble(b *testing.B) {
+	mask, err := fieldmask.Parse(fieldmask.ProductDefault, fieldmask.Product)
+	if err != nil {
+		b.Fatal(err)
+	}
+	f, rows := newListFixture(250, listQueryRoundTrip)
+	l := f.logic()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := l.assemble(1, rows, mask); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(f.total())/float64(b.N), "queries/page")
+}
@@ -0,0 +1,244 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	strip "github.com/grokify/html-strip-tags-go"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+// productPatchFields update_mask 可以写入的产品字段，链接、图片、子商品和标签仍然用 ProductUpdate 修改
+var productPatchFields = []string{"title", "price", "compare_price", "weight", "weight_unit", "body_html", "seo_title", "seo_desc", "status", "is_use_stock",
+	"soldout_policy", "requires_shipping", "youtube_video_url", "youtube_video_pos", "sub_title", "vendor", "attribute", "inventory_quantity", "sku"}
+
+// 单规格产品的价格、重量、库存等同时写入隐藏的默认子商品，和 ProductUpdate 一致
+var productPatchVariantColumns = map[string]string{
+	"price":              "price",
+	"compare_price":      "compare_at_price",
+	"weight":             "weight",
+	"weight_unit":        "weight_unit",
+	"requires_shipping":  "requires_shipping",
+	"inventory_quantity": "inventory_quantity",
+	"sku":                "sku_code",
+}
+
+type ProductPatchLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewProductPatchLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ProductPatchLogic {
+	return &ProductPatchLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ProductPatchLogic) ProductPatch(in *product.ProductPatchRequest) (*product.ProductPatchResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.ProductId == 0 {
+		l.Error("缺少product_id参数")
+		return nil, errorx.Missing("product_id")
+	}
+	if in.Fields == "" {
+		in.Fields = fieldmask.ProductDefault
+	}
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Product)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return nil, errorx.InvalidField("fields", err.Error())
+	}
+	updateMask, err := parseUpdateMask(in.UpdateMask, productPatchFields)
+	if err != nil {
+		l.Error("update_mask参数不合法：", err)
+		return nil, err
+	}
+	patch := in.Product
+	if patch == nil {
+		patch = &product.ProductPatch{}
+	}
+
+	data := model.ProductPatchData{ExpectedVersion: in.ExpectedVersion}
+	for _, name := range productPatchFields {
+		if !updateMask.Has(name) {
+			continue
+		}
+		if name == "body_html" {
+			data.BodyHtml = &patch.BodyHtml
+			continue
+		}
+		columns, err := productPatchColumns(name, patch)
+		if err != nil {
+			l.Error("产品字段不合法：", err)
+			return nil, err
+		}
+		data.Columns = append(data.Columns, columns...)
+		if column, ok := productPatchVariantColumns[name]; ok {
+			data.VariantColumns = append(data.VariantColumns, model.PatchColumn{Name: column, Value: columns[0].Value})
+		}
+	}
+
+	_, err = l.svcCtx.WriteModel.FindOne(in.ShopId, model.WithId(in.ProductId))
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		l.Error("商品记录不存在")
+		return nil, errorx.ErrProductNotFound
+	default:
+		l.Error(err)
+		return nil, errorx.ErrInternal
+	}
+	if len(data.VariantColumns) != 0 {
+		variants, err := l.svcCtx.WriteVariantModel.FindList(in.ShopId, in.ProductId)
+		switch err {
+		case nil:
+			if len(*variants) == 1 && (*variants)[0].IsShow == 0 {
+				data.DefaultVariantId = (*variants)[0].Id
+			}
+		case sqlc.ErrNotFound:
+		default:
+			l.Error("查询子商品失败：", err)
+			return nil, errorx.ErrInternal
+		}
+	}
+
+	err = l.svcCtx.WriteModel.Patch(in.ShopId, in.ProductId, data)
+	if err != nil {
+		l.Error("修改产品失败：", err)
+		if conflict, ok := err.(*model.VersionConflictError); ok {
+			return nil, errorx.VersionConflict(fmt.Sprintf("product/%d", in.ProductId), conflict)
+		}
+		if err == sqlc.ErrNotFound {
+			return nil, errorx.ErrProductNotFound
+		}
+		return nil, errorx.ErrInternal
+	}
+	l.svcCtx.ProductCache.Invalidate(in.ShopId, in.ProductId)
+
+	row, err := l.svcCtx.WriteModel.FindOne(in.ShopId, model.WithId(in.ProductId))
+	if err != nil {
+		l.Error("查询产品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	result, err := NewProductListLogic(l.ctx, l.svcCtx).assemble(in.ShopId, []model.SailShopProduct{*row}, mask)
+	if err != nil {
+		l.Error("查询产品关联数据失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	if data.BodyHtml != nil {
+		// 从库可能还没有同步刚写入的描述
+		result[0].BodyHtml = *data.BodyHtml
+	}
+	fieldmask.ProjectProduct(mask, result[0])
+	return &product.ProductPatchResponse{Product: result[0]}, nil
+}
+
+// parseUpdateMask update_mask 不能为空，只能包含 allowed 中的字段
+func parseUpdateMask(raw string, allowed []string) (fieldmask.Mask, error) {
+	if strings.TrimSpace(raw) == "" {
+		return fieldmask.Mask{}, errorx.Missing("update_mask")
+	}
+	schema := make(fieldmask.Schema, len(allowed))
+	for _, name := range allowed {
+		schema[name] = nil
+	}
+	mask, err := fieldmask.Parse(raw, schema)
+	if err != nil {
+		return fieldmask.Mask{}, errorx.InvalidField("update_mask", err.Error())
+	}
+	return mask, nil
+}
+
+func productPatchColumns(name string, p *product.ProductPatch) ([]model.PatchColumn, error) {
+	column := func(column string, value interface{}) []model.PatchColumn {
+		return []model.PatchColumn{{Name: column, Value: value}}
+	}
+	switch name {
+	case "title":
+		title := strings.TrimSpace(strip.StripTags(p.Title))
+		if title == "" || utf8.RuneCountInString(title) > 255 {
+			return nil, errorx.InvalidField("title", "title must be 1-255 characters")
+		}
+		return column("title", title), nil
+	case "price":
+		if p.Price < 0 {
+			return nil, errorx.InvalidField("price", "price must not be negative")
+		}
+		return column("price", p.Price), nil
+	case "compare_price":
+		if p.ComparePrice < 0 {
+			return nil, errorx.InvalidField("compare_price", "compare_price must not be negative")
+		}
+		return column("compare_at_price", p.ComparePrice), nil
+	case "weight":
+		if p.Weight < 0 {
+			return nil, errorx.InvalidField("weight", "weight must not be negative")
+		}
+		return column("weight", p.Weight), nil
+	case "weight_unit":
+		return column("weight_unit", p.WeightUnit), nil
+	case "seo_title":
+		return column("seo_title", p.SeoTitle), nil
+	case "seo_desc":
+		return column("seo_desc", p.SeoDesc), nil
+	case "status":
+		switch p.Status {
+		case "published":
+			return []model.PatchColumn{{Name: "status", Value: 1}, {Name: "published_at", Value: time.Now()}}, nil
+		case "unpublished":
+			return []model.PatchColumn{{Name: "status", Value: 2}, {Name: "published_at", Value: model.UnpublishedAt}}, nil
+		}
+		return nil, errorx.InvalidField("status", "status must be published or unpublished")
+	case "is_use_stock":
+		if p.IsUseStock != 0 && p.IsUseStock != 1 {
+			return nil, errorx.InvalidField("is_use_stock", "is_use_stock must be 0 or 1")
+		}
+		return column("is_use_stock", p.IsUseStock), nil
+	case "requires_shipping":
+		if p.RequiresShipping != 0 && p.RequiresShipping != 1 {
+			return nil, errorx.InvalidField("requires_shipping", "requires_shipping must be 0 or 1")
+		}
+		return column("is_logistics", p.RequiresShipping), nil
+	case "soldout_policy":
+		if p.SoldoutPolicy != "Y" && p.SoldoutPolicy != "N" {
+			return nil, errorx.InvalidField("soldout_policy", "soldout_policy must be Y or N")
+		}
+		return column("soldout_policy", p.SoldoutPolicy), nil
+	case "youtube_video_url":
+		return column("youtube_video_url", p.YoutubeVideoUrl), nil
+	case "youtube_video_pos":
+		// 小于 0 时清空
+		if p.YoutubeVideoPos < 0 {
+			return column("youtube_video_pos", ""), nil
+		}
+		return column("youtube_video_pos", strconv.FormatInt(p.YoutubeVideoPos, 10)), nil
+	case "sub_title":
+		return column("sub_title", p.SubTitle), nil
+	case "vendor":
+		return column("vendor", p.Vendor), nil
+	case "attribute":
+		return column("attribute", p.Attribute), nil
+	case "inventory_quantity":
+		return column("product_stock", p.InventoryQuantity), nil
+	case "sku":
+		return column("default_sku_code", p.Sku), nil
+	}
+	return nil, fmt.Errorf("unknown field: %s", name)
+}
@@ -0,0 +1,111 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/rating"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ProductRatingListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewProductRatingListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ProductRatingListLogic {
+	return &ProductRatingListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ProductRatingListLogic) ProductRatingList(in *product.ProductRatingListRequest) (*product.ProductRatingListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	productIds := model.ParseVariantIds(in.ProductIds)
+	if len(productIds) == 0 || len(productIds) > 250 {
+		l.Error("product_ids条数不合法：", len(productIds))
+		return nil, errorx.InvalidField("product_ids", "product_ids must contain 1 to 250 ids")
+	}
+	prior, err := ratingPrior(l.svcCtx, in.ShopId)
+	if err != nil {
+		l.Error("查询店铺平均分失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err := l.svcCtx.RatingModel.FindList(in.ShopId, productIds)
+	if err != nil {
+		l.Error("查询产品评分失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	ratings := make(map[int64]model.SailProductRating)
+	for _, item := range *resp {
+		ratings[item.ProductId] = item
+	}
+	result := make([]*product.ProductRating, 0, len(productIds))
+	for _, productId := range productIds {
+		item := ratings[productId]
+		result = append(result, &product.ProductRating{
+			ProductId:   productId,
+			ScoreOne:    item.ScoreOne,
+			ScoreTwo:    item.ScoreTwo,
+			ScoreThree:  item.ScoreThree,
+			ScoreFour:   item.ScoreFour,
+			ScoreFive:   item.ScoreFive,
+			ReviewCount: item.ReviewCount,
+			Average:     rating.Average(item.ReviewCount, item.ScoreSum),
+			Score:       rating.Bayesian(item.ReviewCount, item.ScoreSum, prior, l.svcCtx.Config.Rating.PriorWeight),
+		})
+	}
+	return &product.ProductRatingListResponse{Ratings: result}, nil
+}
+
+// ratingPrior 贝叶斯加权分的先验平均分，未配置时使用店铺平均分
+func ratingPrior(svcCtx *svc.ServiceContext, shopId int64) (float64, error) {
+	if svcCtx.Config.Rating.PriorMean > 0 {
+		return svcCtx.Config.Rating.PriorMean, nil
+	}
+	count, sum, err := svcCtx.RatingModel.ShopTotal(shopId)
+	if err != nil {
+		return 0, err
+	}
+	return rating.Average(count, sum), nil
+}
+
+// attachRatings fields 包含 rating 时填入产品的平均分和贝叶斯加权分
+func attachRatings(svcCtx *svc.ServiceContext, shopId int64, mask fieldmask.Mask, products []*product.Product) error {
+	if !mask.Has("rating") || len(products) == 0 {
+		return nil
+	}
+	prior, err := ratingPrior(svcCtx, shopId)
+	if err != nil {
+		return err
+	}
+	productIds := make([]int64, 0, len(products))
+	for _, p := range products {
+		productIds = append(productIds, p.ProductId)
+	}
+	resp, err := svcCtx.RatingModel.FindList(shopId, productIds)
+	if err != nil {
+		return err
+	}
+	ratings := make(map[int64]model.SailProductRating)
+	for _, item := range *resp {
+		ratings[item.ProductId] = item
+	}
+	for _, p := range products {
+		item := ratings[p.ProductId]
+		p.RatingAverage = rating.Average(item.ReviewCount, item.ScoreSum)
+		p.RatingScore = rating.Bayesian(item.ReviewCount, item.ScoreSum, prior, svcCtx.Config.Rating.PriorWeight)
+	}
+	return nil
+}
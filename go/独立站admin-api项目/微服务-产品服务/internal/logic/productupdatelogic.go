@@ -5,18 +5,19 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
+	"fmt"
 	strip "github.com/grokify/html-strip-tags-go"
 	"github.com/tal-tech/go-zero/core/mr"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
 	"path"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
 
@@ -41,15 +42,28 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 	l.Info("shop_id:", in.ShopId)
 
 	if in.Fields == "" {
-		in.Fields = "id,title,price,compare_price,weight,weight_unit,shop_url,product_url,body_html,seo_title,seo_desc,published_at,is_use_stock,soldout_policy,handler,status,created_at,updated_at,images,default_image,variants"
+		in.Fields = fieldmask.ProductDefault
+	}
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Product)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return nil, errorx.InvalidField("fields", err.Error())
 	}
 	if in.ShopId == 0 {
 		l.Error("缺少shop_id参数")
-		return &product.ProductUpdateResponse{}, errors.New("internal server error")
+		return &product.ProductUpdateResponse{}, errorx.Missing("shop_id")
 	}
 	if in.Id == 0 {
 		l.Error("缺少product_id参数")
-		return &product.ProductUpdateResponse{}, errors.New("internal server error")
+		return &product.ProductUpdateResponse{}, errorx.Missing("id")
+	}
+	if in.Handle != "" {
+		handle, err := model.NormalizeHandle(in.Handle)
+		if err != nil {
+			l.Error("handle参数不合法：", in.Handle)
+			return &product.ProductUpdateResponse{}, errorx.InvalidField("handle", err.Error())
+		}
+		in.Handle = handle
 	}
 
 	productInfo, err := l.svcCtx.ReadModel.FindOne(in.ShopId, model.WithId(in.Id))
@@ -64,10 +78,10 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 		}
 	case sqlc.ErrNotFound:
 		l.Error("product record not found")
-		return &product.ProductUpdateResponse{}, errors.New("product record not found")
+		return &product.ProductUpdateResponse{}, errorx.ErrProductNotFound
 	default:
 		l.Error(err)
-		return &product.ProductUpdateResponse{}, err
+		return &product.ProductUpdateResponse{}, errorx.ErrInternal
 	}
 
 	//var defaultImageErr error
@@ -82,7 +96,7 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 			ext := path.Ext(image.Url)
 			if ext != ".png" && ext != ".jpg" && ext != ".bmp" && ext != ".jpeg" && ext != ".svg" && ext != ".gif" {
 				l.Error("添加商品图片失败：图片格式有误")
-				return &product.ProductUpdateResponse{}, errors.New(" image in wrong format ")
+				return &product.ProductUpdateResponse{}, errorx.ErrInvalidImage
 			}
 
 			tempImage := model.ImageData{
@@ -137,9 +151,9 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 				variantsReq = append(variantsReq, tempVariant)
 			}
 		case sqlc.ErrNotFound:
-			return &product.ProductUpdateResponse{}, sqlc.ErrNotFound
+			return &product.ProductUpdateResponse{}, errorx.ErrProductNotFound
 		default:
-			return &product.ProductUpdateResponse{}, err
+			return &product.ProductUpdateResponse{}, errorx.ErrInternal
 		}
 	}
 
@@ -163,6 +177,9 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 		YoutubeVideoPos:   strconv.Itoa(int(in.YoutubeVideoPos)),
 		InventoryQuantity: in.InventoryQuantity,
 		Tags:              in.Tags,
+		Handler:           in.Handle,
+		Vendor:            in.Vendor,
+		ExpectedVersion:   in.ExpectedVersion,
 		DefaultImage: model.ImageData{
 			FileKey:    in.DefaultImageUrl,
 			ImageWidth: defaultWidth,
@@ -189,162 +206,29 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 
 	if err != nil {
 		l.Error("创建产品失败：", err)
-		return &product.ProductUpdateResponse{}, errors.New("internal server error")
-	}
-
-	l.Info("respImageData:", respImageData)
-	for _, i2 := range respImageData.Variants {
-		temp := model.ImageItem{Url: i2.Url}
-		respImageData.Images = append(respImageData.Images, temp)
-	}
-	imagesMap := sync.Map{}
-	for _, image := range respImageData.Images {
-		if image.Url != "" {
-			imagesMap.Store(image.Url, int64(0))
+		if err == model.ErrHandleTaken {
+			return &product.ProductUpdateResponse{}, errorx.ErrHandleConflict
 		}
-	}
-	imagesSlice := make([]model.ImageItem, 0)
-	keyCheck := map[string]string{}
-	for _, image := range respImageData.Images {
-		if _, ok := keyCheck[image.Url]; ok {
-			continue
+		if conflict, ok := err.(*model.VersionConflictError); ok {
+			return &product.ProductUpdateResponse{}, errorx.VersionConflict(fmt.Sprintf("product/%d", in.Id), conflict)
 		}
-		temp := model.ImageItem{
-			Url: image.Url,
+		if err == sqlc.ErrNotFound {
+			return &product.ProductUpdateResponse{}, errorx.ErrProductNotFound
 		}
-		imagesSlice = append(imagesSlice, temp)
-		keyCheck[image.Url] = "1"
+		return &product.ProductUpdateResponse{}, errorx.FromError(err)
 	}
-	respImageData.Images = imagesSlice
-	if respImageData != nil {
-		if len(respImageData.Variants) != 0 || len(respImageData.Images) != 0 {
-			go func() {
-				imageAddLogic := NewProductImageAddLogic(l.ctx, l.svcCtx)
-				//for s, i := range imagesMap {
-				//	var urlImage string
-				//	var width int64
-				//	var errImage error
-				//	if l.svcCtx.ProjectENV == "xshoppy" {
-				//		urlImage, width, errImage = imageAddLogic.UploadImage(in.DefaultImageUrl)
-				//	} else {
-				//		urlImage, width, errImage = imageAddLogic.UploadImageEmy(in.DefaultImageUrl)
-				//		urlImage = "uploader/" + path.Base(urlImage)
-				//	}
-				//
-				//
-				//
-				//}
-				l.Info("imagesmap:", &imagesMap)
-				l.Info("respImagedata:", respImageData)
-				l.Info("count:", len(respImageData.Images))
-				if len(respImageData.Images) != 0 {
-					l.Info("111111")
-					failedProImg := FailedProImg{
-						ProductId: in.Id,
-					}
+	l.svcCtx.ProductCache.Invalidate(in.ShopId, in.Id)
 
-					for _, image := range respImageData.Images {
-						imageData := product.ProductImageAddRequest{
-							ShopId:    in.ShopId,
-							ProductId: in.Id,
-							Url:       image.Url,
-						}
-						respImages, errDefault := imageAddLogic.ProductImageAdd(&imageData)
-						if errDefault != nil {
-							l.Error("添加产品图片失败：", errDefault)
-							failedProImg.Urls = append(failedProImg.Urls, image.Url)
-							continue
-						}
-						l.Info("产品图链接：", image.Url, ":", respImages.Image.Id, ":", respImages.Image.Url)
-						imagesMap.Store(image.Url, respImages.Image.Id)
-					}
-					l.Error("imagesmap:", &imagesMap)
-					if len(failedProImg.Urls) != 0 {
-						defaultByte, errDefaultByte := json.Marshal(failedProImg)
-						if errDefaultByte != nil {
-							l.Error("序列化failedProImage失败：", err)
-						}
-						_, errDefaultPush := l.svcCtx.RedisClient.Lpush("admin-api:failedProImgs", string(defaultByte))
-						if errDefaultPush != nil {
-							l.Error("添加failedProImage队列失败：", err)
-						}
-					}
-
-				}
-
-				if len(respImageData.Images) > 0 {
-					in.DefaultImageUrl = respImageData.Images[0].Url
-				}
-				var imageId int64
-				defaultImgId, ok := imagesMap.Load(in.DefaultImageUrl)
-				if ok {
-					if val, ok := defaultImgId.(int64); ok {
-						imageId = val
-					} else {
-						imageId = int64(defaultImgId.(int))
-					}
-				}
-				errDefault := l.svcCtx.WriteModel.UpdateDefaultImage(imageId, in.Id)
-				if errDefault != nil {
-					tempFailedDefaultImg := FailedDefaultProImg{
-						ProductId: in.Id,
-						Url:       in.DefaultImageUrl,
-					}
-
-					defaultByte, errDefaultByte := json.Marshal(tempFailedDefaultImg)
-					if errDefaultByte != nil {
-						l.Error("序列化failedDefaultImage失败：", errDefaultByte)
-					}
-					_, errDefaultPush := l.svcCtx.RedisClient.Lpush("admin-api:failedDefaultProImg", string(defaultByte))
-					if errDefaultPush != nil {
-						l.Error("加入failedDefaultImage队列失败：", err)
-					}
-				}
-
-				if len(respImageData.Variants) != 0 {
-					failedProImg := FailedProImg{
-						ProductId: in.Id,
-					}
-					for _, image := range respImageData.Variants {
-						var imageId int64
-						defaultImgId, ok := imagesMap.Load(image.Url)
-						if ok {
-							if val, ok := defaultImgId.(int64); ok {
-								imageId = val
-							} else {
-								imageId = int64(defaultImgId.(int))
-							}
-						}
-						errDefault := l.svcCtx.WriteVariantModel.UpdateImage(imageId, image.VariantId)
-						if errDefault != nil {
-							l.Error("添加产品图片失败：", errDefault)
-							failedProImg.Urls = append(failedProImg.Urls, image.Url)
-						}
-					}
-					if len(failedProImg.Urls) != 0 {
-						defaultByte, errDefaultByte := json.Marshal(failedProImg)
-						if errDefaultByte != nil {
-							l.Error("序列化failedVariantImage失败：", errDefaultByte)
-						}
-						_, errDefaultPush := l.svcCtx.RedisClient.Lpush("admin-api:failedVariantImg", string(defaultByte))
-						if errDefaultPush != nil {
-							l.Error("添加failedVariantImage队列失败：", errDefaultPush)
-						}
-					}
-				}
-			}()
-		}
-
-	}
+	l.Info("respImageData:", respImageData)
 
 	resp, err := l.svcCtx.WriteModel.FindOne(in.ShopId, model.WithId(in.Id))
 	switch err {
 	case nil:
 
 	case sqlc.ErrNotFound:
-		return &product.ProductUpdateResponse{}, errors.New("internal server error")
+		return &product.ProductUpdateResponse{}, errorx.ErrInternal
 	default:
-		return nil, err
+		return nil, errorx.ErrInternal
 	}
 
 	//var productDetail product.Product
@@ -354,7 +238,7 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 	var bodyHtml string
 
 	err = mr.Finish(func() error {
-		if strings.Contains(in.Fields, "body_html") {
+		if mask.Has("body_html") {
 			respDetail, err := l.svcCtx.ReadProductDetailModel.FindOne(in.Id)
 			switch err {
 			case nil:
@@ -368,7 +252,7 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 
 		return nil
 	}, func() (err error) {
-		if strings.Contains(in.Fields, "default_image") {
+		if mask.Has("default_image") {
 			if resp.DefaultImageId != 0 {
 				respDefaultImage, err := l.svcCtx.ReadImageModel.FindOne(resp.DefaultImageId)
 				switch err {
@@ -388,14 +272,14 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 					//return sqlc.ErrNotFound
 				default:
 					l.Error(err)
-					return errors.New("internal server error")
+					return errorx.ErrInternal
 				}
 			}
 		}
 
 		return nil
 	}, func() (err error) {
-		if strings.Contains(in.Fields, "variants") {
+		if mask.Has("variants") {
 			variantResp, err := l.svcCtx.WriteVariantModel.FindList(in.ShopId, in.Id)
 			switch err {
 			case nil:
@@ -407,7 +291,7 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 							inventoryPolicy = "Y"
 						}
 						imageUrl := ""
-						if valVar.ImageId != 0 {
+						if valVar.ImageId != 0 && mask.Sub("variants").Has("image_url") {
 							respImage, err := l.svcCtx.ReadImageModel.FindOne(valVar.ImageId)
 							switch err {
 							case nil:
@@ -444,13 +328,13 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 				//return sqlc.ErrNotFound
 			default:
 				l.Error(err)
-				return errors.New("internal server error")
+				return errorx.ErrInternal
 			}
 		}
 
 		return nil
 	}, func() (err error) {
-		if strings.Contains(in.Fields, "images") {
+		if mask.Has("images") {
 			imageSet := strings.Split(resp.ImageIds, ",")
 			imageMap := map[string]int{}
 			for k, v := range imageSet {
@@ -513,18 +397,18 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 
 	if err != nil {
 		l.Error(err)
-		return nil, errors.New("internal server error")
+		return nil, errorx.ErrInternal
 	}
 	var youtubeVideoPos int
 	if resp.YoutubeVideoPos != "" {
 		youtubeVideoPos, err = strconv.Atoi(resp.YoutubeVideoPos)
 		if err != nil {
 			l.Error(err)
-			return nil, errors.New("internal server error")
+			return nil, errorx.ErrInternal
 		}
 	}
 
-	return &product.ProductUpdateResponse{Product: &product.Product{
+	productDetail := &product.Product{
 		ProductTitle:    resp.Title,
 		ProductId:       resp.Id,
 		ShopUrl:         "",
@@ -551,12 +435,16 @@ func (l *ProductUpdateLogic) ProductUpdate(in *product.ProductUpdateRequest) (*p
 		Comments:        resp.Comments,
 		IsShowComment:   resp.IsShowComment,
 		Scores:          resp.Scores,
+		Vendor:          resp.Vendor,
 		CountSkus:       resp.CountSkus,
 		IsRead:          resp.IsRead,
 		CountSales:      resp.CountSales,
 		YoutubeVideoPos: int64(youtubeVideoPos),
 		YoutubeVideoUrl: resp.YoutubeVideoUrl,
-	}}, nil
+		Version:         resp.Version,
+	}
+	fieldmask.ProjectProduct(mask, productDetail)
+	return &product.ProductUpdateResponse{Product: productDetail}, nil
 
 }
 
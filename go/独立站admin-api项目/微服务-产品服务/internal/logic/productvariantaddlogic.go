@@ -3,13 +3,14 @@ package logic
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
 	"path"
 	"reflect"
 	"time"
 
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
 
@@ -35,23 +36,29 @@ func (l *ProductVariantAddLogic) ProductVariantAdd(in *product.ProductVariantAdd
 	var width int64
 	var err error
 
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Variant)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return &product.ProductVariantAddResponse{}, errorx.InvalidField("fields", err.Error())
+	}
+
 	productInfo, err := l.svcCtx.ReadModel.FindOne(in.ShopId, model.WithId(in.ProductId))
 	switch err {
 	case nil:
 		if productInfo.CountSkus > 125-1 {
 			l.Error(" max size of product variants is 125 ")
-			return &product.ProductVariantAddResponse{}, errors.New(" max size of product variants is 125 ")
+			return &product.ProductVariantAddResponse{}, errorx.ErrVariantLimitExceeded
 		}
 		if productInfo.IsUseStock == 1 && productInfo.SoldoutPolicy.String == "Y" && in.Variant.InventoryQuantity == 0 {
 			l.Error("variant.inventory_quantity not set")
-			return &product.ProductVariantAddResponse{}, errors.New("variant.inventory_quantity not set")
+			return &product.ProductVariantAddResponse{}, errorx.InvalidField("variant.inventory_quantity", "variant.inventory_quantity not set")
 		}
 	case sqlc.ErrNotFound:
 		l.Error(" product record not found ")
-		return &product.ProductVariantAddResponse{}, errors.New(" product record not found")
+		return &product.ProductVariantAddResponse{}, errorx.ErrProductNotFound
 	default:
 		l.Error("query product info error:", err)
-		return &product.ProductVariantAddResponse{}, errors.New(" internal server error ")
+		return &product.ProductVariantAddResponse{}, errorx.ErrInternal
 	}
 	variants, err := l.svcCtx.ReadVariantModel.FindList(in.ShopId, in.ProductId)
 	switch err {
@@ -62,7 +69,7 @@ func (l *ProductVariantAddLogic) ProductVariantAdd(in *product.ProductVariantAdd
 			err := json.Unmarshal([]byte(variant.Spec.String), &temp)
 			if err != nil {
 				l.Error("unmarshal error:", err)
-				return &product.ProductVariantAddResponse{}, errors.New(" internal server error")
+				return &product.ProductVariantAddResponse{}, errorx.ErrInternal
 			}
 			for k, _ := range temp {
 				specKeysMap[k] = "1"
@@ -72,36 +79,36 @@ func (l *ProductVariantAddLogic) ProductVariantAdd(in *product.ProductVariantAdd
 		err := json.Unmarshal([]byte(in.Variant.Spec), &specMap)
 		if err != nil {
 			l.Error("unmarshal error:", err)
-			return &product.ProductVariantAddResponse{}, errors.New(" internal server error")
+			return &product.ProductVariantAddResponse{}, errorx.ErrInternal
 		}
 		for _, i2 := range *variants {
 			temp := map[string]string{}
 			err := json.Unmarshal([]byte(i2.Spec.String), &temp)
 			if err != nil {
 				l.Error("unmarshal error:", err)
-				return &product.ProductVariantAddResponse{}, errors.New(" internal server error")
+				return &product.ProductVariantAddResponse{}, errorx.ErrInternal
 			}
 			if reflect.DeepEqual(temp, specMap) {
 				l.Error("error:", "field spec repeated")
-				return &product.ProductVariantAddResponse{}, errors.New("field spec repeated")
+				return &product.ProductVariantAddResponse{}, errorx.InvalidField("spec", "field spec repeated")
 			}
 		}
 
 		if len(specMap) != len(specKeysMap) {
 			l.Error("error:", "field spec.name invalid")
-			return &product.ProductVariantAddResponse{}, errors.New("field spec.name invalid")
+			return &product.ProductVariantAddResponse{}, errorx.InvalidField("spec", "field spec.name invalid")
 		}
 		for i, _ := range specMap {
 			if _, ok := specKeysMap[i]; !ok {
 				l.Error("error:", "field spec.name invalid")
-				return &product.ProductVariantAddResponse{}, errors.New("field spec.name invalid")
+				return &product.ProductVariantAddResponse{}, errorx.InvalidField("spec", "field spec.name invalid")
 			}
 		}
 	case sqlc.ErrNotFound:
 
 	default:
 		l.Error(err)
-		return &product.ProductVariantAddResponse{}, err
+		return &product.ProductVariantAddResponse{}, errorx.ErrInternal
 	}
 
 	if in.Variant.ImageUrl != "" {
@@ -114,7 +121,7 @@ func (l *ProductVariantAddLogic) ProductVariantAdd(in *product.ProductVariantAdd
 		}
 		if err != nil {
 			l.Error("添加商品图片失败：", err)
-			return &product.ProductVariantAddResponse{}, errors.New("internal server error")
+			return &product.ProductVariantAddResponse{}, errorx.ErrInternal
 		}
 	}
 
@@ -139,35 +146,41 @@ func (l *ProductVariantAddLogic) ProductVariantAdd(in *product.ProductVariantAdd
 
 	//addData.Spec = sql.NullString(in.Variant.Spec)
 
-	resp, err := l.svcCtx.WriteVariantModel.Insert(in.ShopId, in.ProductId, addReq, l.svcCtx.RedisClientSaas)
+	resp, err := l.svcCtx.WriteModel.InsertVariant(in.ShopId, in.ProductId, addReq, l.svcCtx.RedisClientSaas)
 	if err != nil {
 		l.Error(err)
-		return &product.ProductVariantAddResponse{}, err
+		return &product.ProductVariantAddResponse{}, errorx.ErrInternal
 	}
+	l.svcCtx.ProductCache.Invalidate(in.ShopId, in.ProductId)
 	lastId, err := resp.LastInsertId()
 	if err != nil {
-		return &product.ProductVariantAddResponse{}, err
+		return &product.ProductVariantAddResponse{}, errorx.ErrInternal
 	}
 
 	imageUrl := ""
 	respVariant, err := l.svcCtx.WriteVariantModel.FindOne(in.ShopId, lastId)
 	switch err {
 	case nil:
-		if respVariant.ImageId != 0 {
+		if respVariant.ImageId != 0 && mask.Has("image_url") {
 			respImage, err := l.svcCtx.ReadImageModel.FindOne(respVariant.ImageId)
 			switch err {
 			case nil:
 				imageUrl = respImage.FileKey
 			case sqlc.ErrNotFound:
 			default:
-				return nil, err
+				return nil, errorx.ErrInternal
 			}
 		}
 	case sqlc.ErrNotFound:
 		l.Error("添加子商品出错")
-		return nil, errors.New("添加子商品出错")
+		return nil, errorx.ErrInternal
 	default:
-		return nil, err
+		return nil, errorx.ErrInternal
+	}
+	version, err := l.svcCtx.WriteModel.FindVariantVersion(in.ShopId, lastId)
+	if err != nil {
+		l.Error("查询子商品版本失败：", err)
+		return nil, errorx.ErrInternal
 	}
 	inventoryPolicy := "N"
 	if productInfo.IsUseStock == 1 {
@@ -193,6 +206,8 @@ func (l *ProductVariantAddLogic) ProductVariantAdd(in *product.ProductVariantAdd
 		InventoryPolicy:   inventoryPolicy,
 		IsShow:            respVariant.IsShow,
 		ImageUrl:          imageUrl,
+		Version:           version,
 	}}
+	fieldmask.ProjectVariant(mask, res.Variant)
 	return &res, nil
 }
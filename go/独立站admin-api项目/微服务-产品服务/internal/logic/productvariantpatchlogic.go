@@ -0,0 +1,198 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+// variantPatchFields update_mask 可以写入的子商品字段，规格和图片仍然用 ProductVariantUpdate 修改
+var variantPatchFields = []string{"price", "compare_price", "weight", "weight_unit", "requires_shipping", "inventory_quantity", "sku", "title", "sort"}
+
+type ProductVariantPatchLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewProductVariantPatchLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ProductVariantPatchLogic {
+	return &ProductVariantPatchLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ProductVariantPatchLogic) ProductVariantPatch(in *product.ProductVariantPatchRequest) (*product.ProductVariantPatchResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.ProductId == 0 || in.VariantId == 0 {
+		l.Error("缺少product_id或variant_id参数")
+		return nil, errorx.Missing("product_id", "variant_id")
+	}
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Variant)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return nil, errorx.InvalidField("fields", err.Error())
+	}
+	updateMask, err := parseUpdateMask(in.UpdateMask, variantPatchFields)
+	if err != nil {
+		l.Error("update_mask参数不合法：", err)
+		return nil, err
+	}
+	patch := in.Variant
+	if patch == nil {
+		patch = &product.ProductVariantPatch{}
+	}
+
+	data := model.VariantPatchData{ExpectedVersion: in.ExpectedVersion}
+	for _, name := range variantPatchFields {
+		if !updateMask.Has(name) {
+			continue
+		}
+		column, err := variantPatchColumn(name, patch)
+		if err != nil {
+			l.Error("子商品字段不合法：", err)
+			return nil, err
+		}
+		data.Columns = append(data.Columns, column)
+	}
+
+	productInfo, err := l.svcCtx.WriteModel.FindOne(in.ShopId, model.WithId(in.ProductId))
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		l.Error("商品记录不存在")
+		return nil, errorx.ErrProductNotFound
+	default:
+		l.Error(err)
+		return nil, errorx.ErrInternal
+	}
+
+	oldVariant, err := l.svcCtx.WriteVariantModel.FindOne(in.ShopId, in.VariantId)
+	switch {
+	case err == nil && oldVariant.ProductId == in.ProductId:
+	case err == nil || err == sqlc.ErrNotFound:
+		l.Error("子商品记录不存在")
+		return nil, errorx.ErrVariantNotFound
+	default:
+		l.Error(err)
+		return nil, errorx.ErrInternal
+	}
+
+	err = l.svcCtx.WriteModel.PatchVariant(in.ShopId, in.ProductId, in.VariantId, data)
+	if err != nil {
+		l.Error("修改子商品失败：", err)
+		if conflict, ok := err.(*model.VersionConflictError); ok {
+			return nil, errorx.VersionConflict(fmt.Sprintf("variant/%d", in.VariantId), conflict)
+		}
+		if err == sqlc.ErrNotFound {
+			return nil, errorx.ErrVariantNotFound
+		}
+		return nil, errorx.ErrInternal
+	}
+	l.svcCtx.ProductCache.Invalidate(in.ShopId, in.ProductId)
+
+	respVariant, err := l.svcCtx.WriteVariantModel.FindOne(in.ShopId, in.VariantId)
+	if err != nil {
+		l.Error("查询子商品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	imageUrl := ""
+	if respVariant.ImageId != 0 && mask.Has("image_url") {
+		respImage, err := l.svcCtx.ReadImageModel.FindOne(respVariant.ImageId)
+		switch err {
+		case nil:
+			imageUrl = respImage.FileKey
+		case sqlc.ErrNotFound:
+		default:
+			l.Error(err)
+			return nil, errorx.ErrInternal
+		}
+	}
+	version, err := l.svcCtx.WriteModel.FindVariantVersion(in.ShopId, in.VariantId)
+	if err != nil {
+		l.Error("查询子商品版本失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	inventoryPolicy := "N"
+	if productInfo.IsUseStock == 1 {
+		inventoryPolicy = "Y"
+	}
+
+	res := product.ProductVariantPatchResponse{Variant: &product.ProductVariant{
+		Id:                respVariant.Id,
+		ProductId:         respVariant.ProductId,
+		Sku:               respVariant.SkuCode,
+		Title:             respVariant.Title,
+		Price:             respVariant.Price,
+		ComparePrice:      respVariant.CompareAtPrice,
+		Spec:              respVariant.Spec.String,
+		Weight:            respVariant.Weight,
+		WeightUnit:        respVariant.WeightUnit,
+		RequiresShipping:  respVariant.RequiresShipping,
+		ImageId:           respVariant.ImageId,
+		CreatedAt:         respVariant.CreatedAt.Local().Format(time.RFC3339),
+		UpdatedAt:         respVariant.UpdatedAt.Local().Format(time.RFC3339),
+		Sort:              respVariant.Sort,
+		InventoryQuantity: respVariant.InventoryQuantity,
+		InventoryPolicy:   inventoryPolicy,
+		IsShow:            respVariant.IsShow,
+		ImageUrl:          imageUrl,
+		Version:           version,
+	}}
+	fieldmask.ProjectVariant(mask, res.Variant)
+	return &res, nil
+}
+
+func variantPatchColumn(name string, v *product.ProductVariantPatch) (model.PatchColumn, error) {
+	switch name {
+	case "price":
+		if v.Price < 0 {
+			return model.PatchColumn{}, errorx.InvalidField("price", "price must not be negative")
+		}
+		return model.PatchColumn{Name: "price", Value: v.Price}, nil
+	case "compare_price":
+		if v.ComparePrice < 0 {
+			return model.PatchColumn{}, errorx.InvalidField("compare_price", "compare_price must not be negative")
+		}
+		return model.PatchColumn{Name: "compare_at_price", Value: v.ComparePrice}, nil
+	case "weight":
+		if v.Weight < 0 {
+			return model.PatchColumn{}, errorx.InvalidField("weight", "weight must not be negative")
+		}
+		return model.PatchColumn{Name: "weight", Value: v.Weight}, nil
+	case "weight_unit":
+		return model.PatchColumn{Name: "weight_unit", Value: v.WeightUnit}, nil
+	case "requires_shipping":
+		if v.RequiresShipping != 0 && v.RequiresShipping != 1 {
+			return model.PatchColumn{}, errorx.InvalidField("requires_shipping", "requires_shipping must be 0 or 1")
+		}
+		return model.PatchColumn{Name: "requires_shipping", Value: v.RequiresShipping}, nil
+	case "inventory_quantity":
+		return model.PatchColumn{Name: "inventory_quantity", Value: v.InventoryQuantity}, nil
+	case "sku":
+		return model.PatchColumn{Name: "sku_code", Value: strings.TrimSpace(v.Sku)}, nil
+	case "title":
+		if utf8.RuneCountInString(v.Title) > 255 {
+			return model.PatchColumn{}, errorx.InvalidField("title", "title must be at most 255 characters")
+		}
+		return model.PatchColumn{Name: "title", Value: v.Title}, nil
+	case "sort":
+		return model.PatchColumn{Name: "sort", Value: v.Sort}, nil
+	}
+	return model.PatchColumn{}, fmt.Errorf("unknown field: %s", name)
+}
@@ -3,13 +3,15 @@ package logic
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
 	"path"
 	"reflect"
 	"time"
 
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fieldmask"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
 
@@ -35,6 +37,12 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 	var width int64
 	var err error
 
+	mask, err := fieldmask.Parse(in.Fields, fieldmask.Variant)
+	if err != nil {
+		l.Error("fields参数不合法：", err)
+		return &product.ProductVariantUpdateResponse{}, errorx.InvalidField("fields", err.Error())
+	}
+
 	productInfo, err := l.svcCtx.ReadModel.FindOne(in.ShopId, model.WithId(in.ProductId))
 	switch err {
 	case nil:
@@ -44,14 +52,14 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 		//}
 		if productInfo.IsUseStock == 1 && productInfo.SoldoutPolicy.String == "Y" && in.Variant.InventoryQuantity == 0 {
 			l.Error("variant.inventory_quantity not set")
-			return &product.ProductVariantUpdateResponse{}, errors.New("variant.inventory_quantity not set")
+			return &product.ProductVariantUpdateResponse{}, errorx.InvalidField("variant.inventory_quantity", "variant.inventory_quantity not set")
 		}
 	case sqlc.ErrNotFound:
 		l.Error(" product record not found ")
-		return &product.ProductVariantUpdateResponse{}, errors.New(" product record not found")
+		return &product.ProductVariantUpdateResponse{}, errorx.ErrProductNotFound
 	default:
 		l.Error("query product info error:", err)
-		return &product.ProductVariantUpdateResponse{}, errors.New(" internal server error ")
+		return &product.ProductVariantUpdateResponse{}, errorx.ErrInternal
 	}
 	variants, err := l.svcCtx.ReadVariantModel.FindList(in.ShopId, in.ProductId)
 	switch err {
@@ -62,7 +70,7 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 			err := json.Unmarshal([]byte(variant.Spec.String), &temp)
 			if err != nil {
 				l.Error("unmarshal error:", err)
-				return &product.ProductVariantUpdateResponse{}, errors.New(" internal server error")
+				return &product.ProductVariantUpdateResponse{}, errorx.ErrInternal
 			}
 			for k, _ := range temp {
 				specKeysMap[k] = "1"
@@ -72,7 +80,7 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 		err := json.Unmarshal([]byte(in.Variant.Spec), &specMap)
 		if err != nil {
 			l.Error("unmarshal error:", err)
-			return &product.ProductVariantUpdateResponse{}, errors.New(" internal server error")
+			return &product.ProductVariantUpdateResponse{}, errorx.ErrInternal
 		}
 		for _, i2 := range *variants {
 			if i2.Id != in.VariantId {
@@ -80,29 +88,29 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 				err := json.Unmarshal([]byte(i2.Spec.String), &temp)
 				if err != nil {
 					l.Error("unmarshal error:", err)
-					return &product.ProductVariantUpdateResponse{}, errors.New(" internal server error")
+					return &product.ProductVariantUpdateResponse{}, errorx.ErrInternal
 				}
 				if reflect.DeepEqual(temp, specMap) {
 					l.Error("error:", err)
-					return &product.ProductVariantUpdateResponse{}, errors.New("field spec repeated")
+					return &product.ProductVariantUpdateResponse{}, errorx.InvalidField("spec", "field spec repeated")
 				}
 			}
 		}
 		if len(specMap) != len(specKeysMap) {
 			l.Error("error:", "field spec invalid")
-			return &product.ProductVariantUpdateResponse{}, errors.New("field spec invalid")
+			return &product.ProductVariantUpdateResponse{}, errorx.InvalidField("spec", "field spec invalid")
 		}
 		for i, _ := range specMap {
 			if _, ok := specKeysMap[i]; !ok {
 				l.Error("error:", "field spec.name invalid")
-				return &product.ProductVariantUpdateResponse{}, errors.New("field spec.name invalid")
+				return &product.ProductVariantUpdateResponse{}, errorx.InvalidField("spec", "field spec.name invalid")
 			}
 		}
 	case sqlc.ErrNotFound:
 
 	default:
 		l.Error(err)
-		return &product.ProductVariantUpdateResponse{}, err
+		return &product.ProductVariantUpdateResponse{}, errorx.ErrInternal
 	}
 
 	if in.Variant.ImageUrl != "" {
@@ -115,7 +123,7 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 		}
 		if err != nil {
 			l.Error("添加商品图片失败：", err)
-			return &product.ProductVariantUpdateResponse{}, errors.New("internal server error")
+			return &product.ProductVariantUpdateResponse{}, errorx.ErrInternal
 		}
 	}
 
@@ -131,28 +139,33 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 			FileKey:    url,
 			ImageWidth: width,
 		},
-		Spec:      in.Variant.Spec,
-		Sort:      in.Variant.Sort,
-		SkuCode:   in.Variant.Sku,
-		Title:     in.Variant.Title,
-		Options:   in.Variant.Options,
-		IsChecked: in.Variant.IsChecked,
+		Spec:            in.Variant.Spec,
+		Sort:            in.Variant.Sort,
+		SkuCode:         in.Variant.Sku,
+		Title:           in.Variant.Title,
+		Options:         in.Variant.Options,
+		IsChecked:       in.Variant.IsChecked,
+		ExpectedVersion: in.ExpectedVersion,
 	}
 
 	//addData.Spec = sql.NullString(in.Variant.Spec)
 
-	err = l.svcCtx.WriteVariantModel.Update(addReq, in.ShopId, in.ProductId, in.VariantId)
+	err = l.svcCtx.WriteModel.UpdateVariant(addReq, in.ShopId, in.ProductId, in.VariantId)
 	if err != nil {
 		l.Error(err)
+		if conflict, ok := err.(*model.VersionConflictError); ok {
+			return &product.ProductVariantUpdateResponse{}, errorx.VersionConflict(fmt.Sprintf("variant/%d", in.VariantId), conflict)
+		}
 		return &product.ProductVariantUpdateResponse{}, err
 	}
+	l.svcCtx.ProductCache.Invalidate(in.ShopId, in.ProductId)
 
 	imageUrl := ""
 
 	respVariant, err := l.svcCtx.WriteVariantModel.FindOne(in.ShopId, in.VariantId)
 	switch err {
 	case nil:
-		if respVariant.ImageId != 0 {
+		if respVariant.ImageId != 0 && mask.Has("image_url") {
 			respImage, err := l.svcCtx.ReadImageModel.FindOne(respVariant.ImageId)
 			switch err {
 			case nil:
@@ -160,14 +173,19 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 			case sqlc.ErrNotFound:
 			default:
 				l.Error(err)
-				return nil, err
+				return nil, errorx.ErrInternal
 			}
 		}
 	case sqlc.ErrNotFound:
 		l.Error("添加子商品出错")
-		return nil, errors.New("添加子商品出错")
+		return nil, errorx.ErrInternal
 	default:
-		return nil, err
+		return nil, errorx.ErrInternal
+	}
+	version, err := l.svcCtx.WriteModel.FindVariantVersion(in.ShopId, in.VariantId)
+	if err != nil {
+		l.Error("查询子商品版本失败：", err)
+		return nil, errorx.ErrInternal
 	}
 	inventoryPolicy := "N"
 	if productInfo.IsUseStock == 1 {
@@ -193,6 +211,8 @@ func (l *ProductVariantUpdateLogic) ProductVariantUpdate(in *product.ProductVari
 		InventoryPolicy:   inventoryPolicy,
 		IsShow:            respVariant.IsShow,
 		ImageUrl:          imageUrl,
+		Version:           version,
 	}}
+	fieldmask.ProjectVariant(mask, res.Variant)
 	return &res, nil
 }
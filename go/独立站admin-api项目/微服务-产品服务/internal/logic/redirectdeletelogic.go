@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type RedirectDeleteLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewRedirectDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RedirectDeleteLogic {
+	return &RedirectDeleteLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *RedirectDeleteLogic) RedirectDelete(in *product.RedirectDeleteRequest) (*product.RedirectDeleteResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Id == 0 {
+		l.Error("缺少id参数")
+		return nil, errorx.Missing("id")
+	}
+	err := l.svcCtx.WriteRedirectModel.Delete(in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("产品链接跳转记录不存在")
+		return nil, errorx.ErrRedirectNotFound
+	default:
+		l.Error("删除产品链接跳转出错：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.RedirectDeleteResponse{}, nil
+}
@@ -0,0 +1,71 @@
+package logic
+
+import (
+	"context"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/mr"
+)
+
+type RedirectListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewRedirectListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RedirectListLogic {
+	return &RedirectListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *RedirectListLogic) RedirectList(in *product.RedirectListRequest) (*product.RedirectListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Limit <= 0 || in.Limit > 250 {
+		in.Limit = 50
+	}
+
+	var redirects []model.SailProductRedirect
+	var count int64
+	err := mr.Finish(func() error {
+		resp, err := l.svcCtx.ReadRedirectModel.FindList(in.ShopId, in.ProductId, in.Limit, in.Page)
+		switch err {
+		case nil:
+			redirects = *resp
+		case model.ErrNotFound:
+		default:
+			return err
+		}
+		return nil
+	}, func() (err error) {
+		count, err = l.svcCtx.ReadRedirectModel.Count(in.ShopId, in.ProductId)
+		return err
+	})
+	if err != nil {
+		l.Error("查询产品链接跳转出错：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	resp := &product.RedirectListResponse{Count: count}
+	for _, redirect := range redirects {
+		resp.Redirects = append(resp.Redirects, &product.ProductRedirect{
+			Id:        redirect.Id,
+			ProductId: redirect.ProductId,
+			OldHandle: redirect.OldHandle,
+			NewHandle: redirect.NewHandle,
+			CreatedAt: redirect.CreatedAt.Local().Format(time.RFC3339),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	reviewCommentMaxLength = 5000
+	reviewMaxImages        = 9
+)
+
+type ReviewCreateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewCreateLogic {
+	return &ReviewCreateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewCreateLogic) ReviewCreate(in *product.ReviewCreateRequest) (*product.ReviewCreateResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.ProductId == 0 {
+		l.Error("缺少product_id参数")
+		return nil, errorx.Missing("product_id")
+	}
+	if in.Status == 0 {
+		in.Status = model.REVIEW_STATUS_PENDING
+	}
+	if in.Status != model.REVIEW_STATUS_PENDING && in.Status != model.REVIEW_STATUS_APPROVED && in.Status != model.REVIEW_STATUS_REJECTED {
+		l.Error("status参数不合法：", in.Status)
+		return nil, errorx.InvalidField("status", "status must be 1, 2 or 3")
+	}
+	data, err := buildReview(in.Observer, in.Score, in.Comment, in.ImagesId, in.CommentAt)
+	if err != nil {
+		l.Error("评论参数不合法：", err)
+		return nil, err
+	}
+	productInfo, err := l.svcCtx.WriteModel.FindOne(in.ShopId, model.WithId(in.ProductId))
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("产品不存在 product_id:", in.ProductId)
+		return nil, errorx.ErrProductNotFound
+	default:
+		l.Error("查询产品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	data.ShopId = in.ShopId
+	data.ProductId = in.ProductId
+	data.ProductName = productInfo.Title
+	data.ObserverIcon = in.ObserverIcon
+	data.Email = in.Email
+	data.Country = in.Country
+	data.Status = in.Status
+	data.Source = in.Source
+	data.AdminUser = in.AdminUser
+	data.IsShow = 1
+	id, err := l.svcCtx.WriteReviewModel.Insert(*data, nil)
+	if err != nil {
+		l.Error("新增评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err := l.svcCtx.WriteReviewModel.FindOne(in.ShopId, id)
+	if err != nil {
+		l.Error("查询评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewCreateResponse{Review: toReview(*resp)}, nil
+}
+
+// buildReview 校验新增和修改共用的评论内容
+func buildReview(observer string, score int64, comment, imagesId, commentAt string) (*model.SailProductReview, error) {
+	observer = strings.TrimSpace(observer)
+	if observer == "" {
+		return nil, errorx.Missing("observer")
+	}
+	if score < 1 || score > 5 {
+		return nil, errorx.InvalidField("score", "score must be between 1 and 5")
+	}
+	if utf8.RuneCountInString(comment) > reviewCommentMaxLength {
+		return nil, errorx.InvalidField("comment", fmt.Sprintf("comment exceeds %d characters", reviewCommentMaxLength))
+	}
+	data := &model.SailProductReview{
+		Observer:  observer,
+		Score:     score,
+		Comment:   comment,
+		CommentAt: time.Now(),
+	}
+	if imageIds := model.ParseVariantIds(imagesId); len(imageIds) != 0 {
+		if len(imageIds) > reviewMaxImages {
+			return nil, errorx.InvalidField("images_id", fmt.Sprintf("at most %d images are allowed", reviewMaxImages))
+		}
+		data.ImageId = imageIds[0]
+		data.ImagesId = joinIds(imageIds)
+	}
+	if commentAt != "" {
+		t, err := time.Parse(time.RFC3339, commentAt)
+		if err != nil {
+			return nil, errorx.InvalidField("comment_at", "comment_at must be RFC3339")
+		}
+		data.CommentAt = t
+	}
+	return data, nil
+}
@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewDeleteLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewDeleteLogic {
+	return &ReviewDeleteLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewDeleteLogic) ReviewDelete(in *product.ReviewDeleteRequest) (*product.ReviewDeleteResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Id == 0 {
+		l.Error("缺少id参数")
+		return nil, errorx.Missing("id")
+	}
+	err := l.svcCtx.WriteReviewModel.Delete(in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("评论不存在 id:", in.Id)
+		return nil, errorx.ErrReviewNotFound
+	default:
+		l.Error("删除评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewDeleteResponse{}, nil
+}
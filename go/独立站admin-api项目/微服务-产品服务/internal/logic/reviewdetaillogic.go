@@ -0,0 +1,44 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewDetailLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewDetailLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewDetailLogic {
+	return &ReviewDetailLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewDetailLogic) ReviewDetail(in *product.ReviewDetailRequest) (*product.ReviewDetailResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	resp, err := l.svcCtx.ReadReviewModel.FindOne(in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("评论不存在 id:", in.Id)
+		return nil, errorx.ErrReviewNotFound
+	default:
+		l.Error("查询评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewDetailResponse{Review: toReview(*resp)}, nil
+}
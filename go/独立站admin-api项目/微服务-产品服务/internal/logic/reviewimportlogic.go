@@ -0,0 +1,481 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	reviewImportLockExpire = 600
+	reviewImportMaxRows    = 10000
+	reviewImportMaxBatch   = 500
+
+	reviewImportFormatCSV  = "csv"
+	reviewImportFormatJSON = "json"
+
+	reviewImportCreated   = "created"
+	reviewImportValid     = "valid"
+	reviewImportDuplicate = "duplicate"
+	reviewImportFailed    = "failed"
+)
+
+// reviewImportRecord 导入文件中的一行，解析失败时记录 err，该行按失败返回
+type reviewImportRecord struct {
+	ProductId     int64
+	ProductHandle string
+	Sku           string
+	SourceId      int64
+	Observer      string
+	ObserverIcon  string
+	Email         string
+	Country       string
+	Score         int64
+	Comment       string
+	ImageUrls     []string
+	CommentAt     string
+	err           error
+}
+
+type ReviewImportLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewImportLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewImportLogic {
+	return &ReviewImportLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// ReviewImport 边接收文件边逐行导入评论，每处理一批返回一次进度；source_id 相同的评论只导入一次，
+// 图片通过图片导入任务转存，评论先创建，图片转存成功后追加。
+// 文件中间出现格式错误时，之前的批次已经导入，返回错误前发送的进度里有每行的结果
+func (l *ReviewImportLogic) ReviewImport(stream product.ProductRPC_ReviewImportServer) error {
+	in, err := stream.Recv()
+	if err == io.EOF {
+		l.Error("缺少shop_id参数")
+		return errorx.Missing("shop_id")
+	}
+	if err != nil {
+		l.Error("接收评论导入请求失败：", err)
+		return err
+	}
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return errorx.Missing("shop_id")
+	}
+	if in.Status == 0 {
+		in.Status = model.REVIEW_STATUS_APPROVED
+	}
+	if in.Status != model.REVIEW_STATUS_PENDING && in.Status != model.REVIEW_STATUS_APPROVED && in.Status != model.REVIEW_STATUS_REJECTED {
+		l.Error("status参数不合法：", in.Status)
+		return errorx.InvalidField("status", "status must be 1, 2 or 3")
+	}
+	if in.BatchSize <= 0 || in.BatchSize > reviewImportMaxBatch {
+		in.BatchSize = 100
+	}
+	reader := &reviewImportReader{stream: stream, buf: in.Data}
+	var next func() (reviewImportRecord, error)
+	switch strings.ToLower(in.Format) {
+	case reviewImportFormatCSV:
+		next, err = newReviewCSVDecoder(reader)
+	case reviewImportFormatJSON:
+		next, err = newReviewJSONDecoder(reader)
+	default:
+		l.Error("format参数不合法：", in.Format)
+		return errorx.InvalidField("format", "format must be csv or json")
+	}
+	if err != nil {
+		return l.decodeError(reader, err)
+	}
+
+	if !in.DryRun {
+		lock := redis.NewRedisLock(l.svcCtx.RedisClientSaas, fmt.Sprintf("product:reviewimport:%d", in.ShopId))
+		lock.SetExpire(reviewImportLockExpire)
+		if ok, err := lock.Acquire(); !ok || err != nil {
+			l.Error("评论导入正在执行中 shop_id:", in.ShopId)
+			return errorx.New(errorx.CodeOperationInProgress, "review import in progress, please retry later")
+		}
+		defer lock.Release()
+	}
+
+	progress := &product.ReviewImportProgress{}
+	products := make(map[string]*model.SailShopProduct)
+	seen := make(map[int64]bool)
+	records := make([]reviewImportRecord, 0, in.BatchSize)
+	flush := func() error {
+		if len(records) == 0 {
+			return nil
+		}
+		rows, err := l.batch(in, records, int(progress.Processed), products, seen)
+		if err != nil {
+			return err
+		}
+		records = records[:0]
+		progress.Processed += int64(len(rows))
+		progress.Rows = rows
+		for _, row := range rows {
+			switch row.Status {
+			case reviewImportCreated, reviewImportValid:
+				progress.Created++
+			case reviewImportDuplicate:
+				progress.Duplicates++
+			default:
+				progress.Failed++
+			}
+		}
+		if err := stream.Send(progress); err != nil {
+			l.Error("发送评论导入进度失败：", err)
+			return err
+		}
+		return nil
+	}
+	for {
+		if err := stream.Context().Err(); err != nil {
+			l.Error("评论导入被调用方中断：", err)
+			return err
+		}
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return l.decodeError(reader, err)
+		}
+		if progress.TotalRows++; progress.TotalRows > reviewImportMaxRows {
+			l.Error("评论导入行数超出限制：", progress.TotalRows)
+			return errorx.InvalidField("data", fmt.Sprintf("at most %d rows are allowed", reviewImportMaxRows))
+		}
+		records = append(records, record)
+		if int64(len(records)) >= in.BatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	progress.Rows = nil
+	progress.Done = true
+	return stream.Send(progress)
+}
+
+// decodeError 接收请求流出错时原样返回，否则是文件格式错误
+func (l *ReviewImportLogic) decodeError(reader *reviewImportReader, err error) error {
+	if reader.err != nil && reader.err != io.EOF {
+		l.Error("接收评论导入文件失败：", reader.err)
+		return reader.err
+	}
+	l.Error("解析评论导入文件失败：", err)
+	return errorx.InvalidField("data", err.Error())
+}
+
+// batch 处理一批数据行，offset 为该批第一行在文件中的下标；
+// seen 记录已导入（dry_run 时为校验通过）的 source_id，文件内重复的行同样按重复返回
+func (l *ReviewImportLogic) batch(in *product.ReviewImportRequest, records []reviewImportRecord, offset int, products map[string]*model.SailShopProduct, seen map[int64]bool) ([]*product.ReviewImportRow, error) {
+	sourceIds := make([]int64, 0, len(records))
+	for _, record := range records {
+		if record.err == nil && record.SourceId != 0 {
+			sourceIds = append(sourceIds, record.SourceId)
+		}
+	}
+	existing, err := l.svcCtx.WriteReviewModel.FindSourceIds(in.ShopId, in.Source, sourceIds)
+	if err != nil {
+		l.Error("查询已导入评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	for _, id := range existing {
+		seen[id] = true
+	}
+
+	rows := make([]*product.ReviewImportRow, 0, len(records))
+	for i, record := range records {
+		row := &product.ReviewImportRow{Row: int64(offset + i + 1), Images: int64(len(record.ImageUrls))}
+		rows = append(rows, row)
+		if record.err != nil {
+			row.Status, row.Error = reviewImportFailed, record.err.Error()
+			continue
+		}
+		if record.SourceId != 0 && seen[record.SourceId] {
+			row.Status = reviewImportDuplicate
+			continue
+		}
+		data, err := l.buildRecord(in, record)
+		if err == nil {
+			var productInfo *model.SailShopProduct
+			if productInfo, err = l.findProduct(in.ShopId, record, products); err == nil {
+				data.ProductId = productInfo.Id
+				data.ProductName = productInfo.Title
+				row.ProductId = productInfo.Id
+			}
+		}
+		if err != nil {
+			row.Status, row.Error = reviewImportFailed, errorx.Message(err)
+			continue
+		}
+		if in.DryRun {
+			row.Status = reviewImportValid
+		} else if row.ReviewId, err = l.svcCtx.WriteReviewModel.Insert(*data, record.ImageUrls); err == model.ErrReviewDuplicate {
+			// 查询之后被同时执行的导入写入，由唯一索引拦下
+			row.Status = reviewImportDuplicate
+		} else if err != nil {
+			// 写入失败的行不记入 seen，文件中后面相同 source_id 的行还可以再导入
+			l.Error("导入评论失败：", err)
+			row.Status, row.Error = reviewImportFailed, "internal server error"
+			continue
+		} else {
+			row.Status = reviewImportCreated
+		}
+		if record.SourceId != 0 {
+			seen[record.SourceId] = true
+		}
+	}
+	return rows, nil
+}
+
+func (l *ReviewImportLogic) buildRecord(in *product.ReviewImportRequest, record reviewImportRecord) (*model.SailProductReview, error) {
+	if len(record.ImageUrls) > reviewMaxImages {
+		return nil, fmt.Errorf("at most %d images are allowed", reviewMaxImages)
+	}
+	for _, url := range record.ImageUrls {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return nil, fmt.Errorf("image url %s is invalid", url)
+		}
+	}
+	data, err := buildReview(record.Observer, record.Score, record.Comment, "", record.CommentAt)
+	if err != nil {
+		return nil, err
+	}
+	data.ShopId = in.ShopId
+	data.ObserverIcon = record.ObserverIcon
+	data.Email = record.Email
+	data.Country = record.Country
+	data.Status = in.Status
+	data.Source = in.Source
+	data.SourceId = record.SourceId
+	data.AdminUser = in.AdminUser
+	data.IsShow = 1
+	return data, nil
+}
+
+// findProduct 按 product_id、product_handle、sku 的顺序定位产品，同一次导入里查过的产品不再重复查询
+func (l *ReviewImportLogic) findProduct(shopId int64, record reviewImportRecord, products map[string]*model.SailShopProduct) (*model.SailShopProduct, error) {
+	var key string
+	var option model.HandlerOption
+	switch {
+	case record.ProductId != 0:
+		key, option = fmt.Sprintf("id:%d", record.ProductId), model.WithId(record.ProductId)
+	case record.ProductHandle != "":
+		key, option = "handle:"+record.ProductHandle, model.WithHandler(record.ProductHandle)
+	case record.Sku != "":
+		key = "sku:" + record.Sku
+	default:
+		return nil, errorx.Missing("product_id", "product_handle", "sku")
+	}
+	if productInfo, ok := products[key]; ok {
+		if productInfo == nil {
+			return nil, errorx.ErrProductNotFound
+		}
+		return productInfo, nil
+	}
+	if option == nil {
+		productId, err := l.svcCtx.WriteReviewModel.FindProductIdBySku(shopId, record.Sku)
+		switch err {
+		case nil:
+			option = model.WithId(productId)
+		case model.ErrNotFound:
+			products[key] = nil
+			return nil, errorx.ErrProductNotFound
+		default:
+			l.Error("按sku查询产品失败：", err)
+			return nil, errorx.ErrInternal
+		}
+	}
+	productInfo, err := l.svcCtx.WriteModel.FindOne(shopId, option)
+	switch err {
+	case nil:
+		products[key] = productInfo
+		return productInfo, nil
+	case model.ErrNotFound:
+		products[key] = nil
+		return nil, errorx.ErrProductNotFound
+	default:
+		l.Error("查询产品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+}
+
+// reviewImportReader 把请求流中各条请求的 data 依次拼成一个文件
+type reviewImportReader struct {
+	stream product.ProductRPC_ReviewImportServer
+	buf    []byte
+	err    error
+}
+
+func (r *reviewImportReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		in, err := r.stream.Recv()
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		r.buf = in.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// newReviewCSVDecoder 第一行为表头，列名不区分大小写，未知列忽略；返回的函数每次读取一行，读完返回 io.EOF
+func newReviewCSVDecoder(r io.Reader) (func() (reviewImportRecord, error), error) {
+	buffered := bufio.NewReader(r)
+	if bom, err := buffered.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		buffered.Discard(3)
+	}
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv header is missing")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %s", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return func() (reviewImportRecord, error) {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return reviewImportRecord{}, io.EOF
+		}
+		if err != nil {
+			return reviewImportRecord{}, fmt.Errorf("invalid csv: %s", err)
+		}
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(line) {
+				return strings.TrimSpace(line[i])
+			}
+			return ""
+		}
+		fields := make(map[string]interface{})
+		for name := range columns {
+			fields[name] = value(name)
+		}
+		if urls := value("image_urls"); urls != "" {
+			fields["image_urls"] = strings.Split(urls, ",")
+		}
+		return newReviewImportRecord(fields), nil
+	}, nil
+}
+
+// newReviewJSONDecoder 数据为对象数组，逐个对象解析，不需要把整个数组读进内存
+func newReviewJSONDecoder(r io.Reader) (func() (reviewImportRecord, error), error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New("invalid json: data must be an array of objects")
+	}
+	done := false
+	return func() (reviewImportRecord, error) {
+		if done {
+			return reviewImportRecord{}, io.EOF
+		}
+		if !decoder.More() {
+			if _, err := decoder.Token(); err != nil {
+				return reviewImportRecord{}, fmt.Errorf("invalid json: %s", err)
+			}
+			done = true
+			return reviewImportRecord{}, io.EOF
+		}
+		var item map[string]interface{}
+		if err := decoder.Decode(&item); err != nil {
+			return reviewImportRecord{}, fmt.Errorf("invalid json: %s", err)
+		}
+		fields := make(map[string]interface{}, len(item))
+		for name, value := range item {
+			fields[strings.ToLower(name)] = value
+		}
+		if urls, ok := fields["image_urls"].(string); ok {
+			fields["image_urls"] = strings.Split(urls, ",")
+		}
+		return newReviewImportRecord(fields), nil
+	}, nil
+}
+
+func newReviewImportRecord(fields map[string]interface{}) reviewImportRecord {
+	record := reviewImportRecord{
+		ProductHandle: importString(fields["product_handle"]),
+		Sku:           importString(fields["sku"]),
+		Observer:      importString(fields["observer"]),
+		ObserverIcon:  importString(fields["observer_icon"]),
+		Email:         importString(fields["email"]),
+		Country:       importString(fields["country"]),
+		Comment:       importString(fields["comment"]),
+		CommentAt:     importString(fields["comment_at"]),
+	}
+	if urls, ok := fields["image_urls"].([]interface{}); ok {
+		for _, url := range urls {
+			record.ImageUrls = append(record.ImageUrls, importString(url))
+		}
+	} else if urls, ok := fields["image_urls"].([]string); ok {
+		record.ImageUrls = urls
+	}
+	urls := record.ImageUrls[:0]
+	for _, url := range record.ImageUrls {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	record.ImageUrls = urls
+	for name, target := range map[string]*int64{"product_id": &record.ProductId, "source_id": &record.SourceId, "score": &record.Score} {
+		value := importString(fields[name])
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			record.err = fmt.Errorf("%s must be an integer", name)
+			continue
+		}
+		*target = n
+	}
+	return record
+}
+
+func importString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,109 @@
+package logic
+
+import (
+	"context"
+	"github.com/tal-tech/go-zero/core/mr"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewListLogic {
+	return &ReviewListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewListLogic) ReviewList(in *product.ReviewListRequest) (*product.ReviewListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Limit <= 0 || in.Limit > 250 {
+		in.Limit = 50
+	}
+	filter := model.ReviewFilter{
+		ProductId: in.ProductId,
+		Score:     in.Score,
+		Status:    in.Status,
+		HasImage:  in.HasImage,
+		IsShow:    in.IsShow,
+	}
+
+	var reviews []model.SailProductReview
+	var count int64
+	err := mr.Finish(func() error {
+		resp, err := l.svcCtx.ReadReviewModel.FindList(in.ShopId, filter, in.Limit, in.Page)
+		switch err {
+		case nil:
+			reviews = *resp
+		case model.ErrNotFound:
+		default:
+			return err
+		}
+		return nil
+	}, func() (err error) {
+		count, err = l.svcCtx.ReadReviewModel.Count(in.ShopId, filter)
+		return err
+	})
+	if err != nil {
+		l.Error("查询评论列表出错：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	resp := &product.ReviewListResponse{Count: count}
+	for _, review := range reviews {
+		resp.Reviews = append(resp.Reviews, toReview(review))
+	}
+	return resp, nil
+}
+
+func toReview(data model.SailProductReview) *product.ProductComment {
+	item := &product.ProductComment{
+		Id:           data.Id,
+		Code:         data.Code,
+		ShopId:       data.ShopId,
+		ProductId:    data.ProductId,
+		ProductName:  data.ProductName,
+		Observer:     data.Observer,
+		ObserverIcon: data.ObserverIcon,
+		AdminUser:    data.AdminUser,
+		Country:      data.Country,
+		Score:        data.Score,
+		ImageId:      data.ImageId,
+		ImagesId:     data.ImagesId,
+		Comment:      data.Comment,
+		Email:        data.Email,
+		Helpful:      data.Helpful,
+		Status:       data.Status,
+		IsShow:       data.IsShow,
+		IsDel:        data.IsDel,
+		IsTop:        data.IsTop,
+		Source:       data.Source,
+		ExpressImage: data.ExpressImage,
+		IsUp:         data.IsUp,
+		Sort:         data.Sort,
+		CommentAt:    data.CommentAt.Local().Format(time.RFC3339),
+		CreatedAt:    data.CreatedAt.Local().Format(time.RFC3339),
+		UpdatedAt:    data.UpdatedAt.Local().Format(time.RFC3339),
+		SourceId:     data.SourceId,
+		Reply:        data.Reply.String,
+	}
+	if data.RepliedAt.Valid {
+		item.RepliedAt = data.RepliedAt.Time.Local().Format(time.RFC3339)
+	}
+	return item
+}
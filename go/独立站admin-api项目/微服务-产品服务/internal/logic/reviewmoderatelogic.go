@@ -0,0 +1,70 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"strconv"
+	"strings"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewModerateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewModerateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewModerateLogic {
+	return &ReviewModerateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewModerateLogic) ReviewModerate(in *product.ReviewModerateRequest) (*product.ReviewModerateResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Status != model.REVIEW_STATUS_APPROVED && in.Status != model.REVIEW_STATUS_REJECTED {
+		l.Error("status参数不合法：", in.Status)
+		return nil, errorx.InvalidField("status", "status must be 2 or 3")
+	}
+	ids, err := parseReviewIds(in.Ids)
+	if err != nil {
+		l.Error("ids参数不合法：", in.Ids)
+		return nil, errorx.InvalidField("ids", err.Error())
+	}
+	if len(ids) == 0 || len(ids) > 250 {
+		l.Error("ids条数不合法：", len(ids))
+		return nil, errorx.InvalidField("ids", "ids must contain 1 to 250 entries")
+	}
+	updated, err := l.svcCtx.WriteReviewModel.SetStatus(in.ShopId, ids, in.Status)
+	if err != nil {
+		l.Error("审核评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewModerateResponse{UpdatedIds: updated}, nil
+}
+
+func parseReviewIds(ids string) ([]int64, error) {
+	result := make([]int64, 0)
+	for _, s := range strings.Split(ids, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id %q", s)
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewPinLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewPinLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewPinLogic {
+	return &ReviewPinLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewPinLogic) ReviewPin(in *product.ReviewPinRequest) (*product.ReviewPinResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	err := l.svcCtx.WriteReviewModel.SetTop(in.ShopId, in.Id, in.Pinned)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("评论不存在 id:", in.Id)
+		return nil, errorx.ErrReviewNotFound
+	default:
+		l.Error("设置评论置顶失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewPinResponse{}, nil
+}
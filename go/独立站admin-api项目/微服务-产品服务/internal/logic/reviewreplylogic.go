@@ -0,0 +1,55 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"unicode/utf8"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewReplyLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewReplyLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewReplyLogic {
+	return &ReviewReplyLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewReplyLogic) ReviewReply(in *product.ReviewReplyRequest) (*product.ReviewReplyResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if utf8.RuneCountInString(in.Reply) > reviewCommentMaxLength {
+		l.Error("回复内容过长")
+		return nil, errorx.InvalidField("reply", fmt.Sprintf("reply exceeds %d characters", reviewCommentMaxLength))
+	}
+	err := l.svcCtx.WriteReviewModel.Reply(in.ShopId, in.Id, in.Reply, in.AdminUser)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("评论不存在 id:", in.Id)
+		return nil, errorx.ErrReviewNotFound
+	default:
+		l.Error("回复评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err := l.svcCtx.WriteReviewModel.FindOne(in.ShopId, in.Id)
+	if err != nil {
+		l.Error("查询评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewReplyResponse{Review: toReview(*resp)}, nil
+}
@@ -0,0 +1,44 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewShowLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewShowLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewShowLogic {
+	return &ReviewShowLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewShowLogic) ReviewShow(in *product.ReviewShowRequest) (*product.ReviewShowResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	err := l.svcCtx.WriteReviewModel.SetShow(in.ShopId, in.Id, in.Shown)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("评论不存在 id:", in.Id)
+		return nil, errorx.ErrReviewNotFound
+	default:
+		l.Error("设置评论显示失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewShowResponse{}, nil
+}
@@ -0,0 +1,77 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewUpdateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewUpdateLogic {
+	return &ReviewUpdateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewUpdateLogic) ReviewUpdate(in *product.ReviewUpdateRequest) (*product.ReviewUpdateResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Id == 0 {
+		l.Error("缺少id参数")
+		return nil, errorx.Missing("id")
+	}
+	data, err := buildReview(in.Observer, in.Score, in.Comment, in.ImagesId, in.CommentAt)
+	if err != nil {
+		l.Error("评论参数不合法：", err)
+		return nil, err
+	}
+	data.ShopId = in.ShopId
+	data.Id = in.Id
+	data.ObserverIcon = in.ObserverIcon
+	data.Email = in.Email
+	data.Country = in.Country
+	if in.CommentAt == "" {
+		// 未传评论时间时保留原值
+		resp, err := l.svcCtx.WriteReviewModel.FindOne(in.ShopId, in.Id)
+		switch err {
+		case nil:
+			data.CommentAt = resp.CommentAt
+		case model.ErrNotFound:
+			l.Error("评论不存在 id:", in.Id)
+			return nil, errorx.ErrReviewNotFound
+		default:
+			l.Error("查询评论失败：", err)
+			return nil, errorx.ErrInternal
+		}
+	}
+	err = l.svcCtx.WriteReviewModel.Update(*data)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("评论不存在 id:", in.Id)
+		return nil, errorx.ErrReviewNotFound
+	default:
+		l.Error("修改评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err := l.svcCtx.WriteReviewModel.FindOne(in.ShopId, in.Id)
+	if err != nil {
+		l.Error("查询评论失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewUpdateResponse{Review: toReview(*resp)}, nil
+}
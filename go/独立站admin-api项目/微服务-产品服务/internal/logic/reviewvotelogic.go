@@ -0,0 +1,50 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"strings"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type ReviewVoteLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewReviewVoteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReviewVoteLogic {
+	return &ReviewVoteLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *ReviewVoteLogic) ReviewVote(in *product.ReviewVoteRequest) (*product.ReviewVoteResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	voter := strings.TrimSpace(in.Voter)
+	if voter == "" || len(voter) > 64 {
+		l.Error("voter参数不合法：", in.Voter)
+		return nil, errorx.InvalidField("voter", "voter must be 1 to 64 characters")
+	}
+	counted, helpful, err := l.svcCtx.WriteReviewModel.Vote(in.ShopId, in.Id, voter)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("评论不存在 id:", in.Id)
+		return nil, errorx.ErrReviewNotFound
+	default:
+		l.Error("评论投票失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.ReviewVoteResponse{Counted: counted, Helpful: helpful}, nil
+}
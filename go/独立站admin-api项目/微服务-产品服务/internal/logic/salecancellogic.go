@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type SaleCancelLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewSaleCancelLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SaleCancelLogic {
+	return &SaleCancelLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// SaleCancel 把结束时间提前到现在，原价由 SaleScheduler 恢复
+func (l *SaleCancelLogic) SaleCancel(in *product.SaleCancelRequest) (*product.SaleCancelResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Id == 0 {
+		l.Error("缺少id参数")
+		return nil, errorx.Missing("id")
+	}
+	err := l.svcCtx.SaleModel.Cancel(in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		l.Error("促销活动不存在或已结束 id:", in.Id)
+		return nil, errorx.New(errorx.CodeSaleNotFound, "sale not found or already ended")
+	default:
+		l.Error("取消促销活动失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.SaleCancelResponse{}, nil
+}
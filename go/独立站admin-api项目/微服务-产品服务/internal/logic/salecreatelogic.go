@@ -0,0 +1,113 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const saleMaxTargetIds = 1000
+
+type SaleCreateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewSaleCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SaleCreateLogic {
+	return &SaleCreateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *SaleCreateLogic) SaleCreate(in *product.SaleCreateRequest) (*product.SaleCreateResponse, error) {
+	data, err := buildSale(in)
+	if err != nil {
+		l.Error("促销活动参数不合法：", err)
+		return nil, err
+	}
+	if !data.EndsAt.After(time.Now()) {
+		l.Error("促销活动结束时间已过：", in.EndsAt)
+		return nil, errorx.InvalidField("ends_at", "ends_at must be in the future")
+	}
+	result, err := l.svcCtx.SaleModel.Insert(data)
+	if err != nil {
+		l.Error("新增促销活动失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		l.Error(err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err := l.svcCtx.SaleModel.FindOne(in.ShopId, id)
+	if err != nil {
+		l.Error("查询促销活动失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.SaleCreateResponse{Sale: toSale(*resp)}, nil
+}
+
+// buildSale 校验活动定义，预览未保存的活动时也用它
+func buildSale(in *product.SaleCreateRequest) (model.SailProductSale, error) {
+	data := model.SailProductSale{
+		ShopId:        in.ShopId,
+		Title:         strings.TrimSpace(in.Title),
+		ProductIds:    sql.NullString{String: joinIds(in.ProductIds), Valid: true},
+		VariantIds:    sql.NullString{String: joinIds(in.VariantIds), Valid: true},
+		FilterVendor:  strings.TrimSpace(in.FilterVendor),
+		FilterTitle:   strings.TrimSpace(in.FilterTitle),
+		DiscountType:  in.DiscountType,
+		DiscountValue: in.DiscountValue,
+		Status:        model.SALE_STATUS_SCHEDULED,
+	}
+	if in.ShopId == 0 {
+		return data, errorx.Missing("shop_id")
+	}
+	if data.Title == "" {
+		return data, errorx.Missing("title")
+	}
+	if len(in.ProductIds)+len(in.VariantIds) > saleMaxTargetIds {
+		return data, errorx.InvalidField("product_ids", fmt.Sprintf("product_ids and variant_ids may contain at most %d entries", saleMaxTargetIds))
+	}
+	if data.ProductIds.String == "" && data.VariantIds.String == "" && !data.HasFilter() {
+		return data, errorx.InvalidField("product_ids", "product_ids, variant_ids or a filter is required")
+	}
+	if !pricing.ValidDiscount(in.DiscountType, in.DiscountValue) {
+		return data, errorx.InvalidField("discount_value", "discount_type or discount_value is invalid")
+	}
+	var err error
+	if data.StartsAt, err = time.ParseInLocation(time.RFC3339, in.StartsAt, time.Local); err != nil {
+		return data, errorx.InvalidField("starts_at", "starts_at is invalid")
+	}
+	if data.EndsAt, err = time.ParseInLocation(time.RFC3339, in.EndsAt, time.Local); err != nil {
+		return data, errorx.InvalidField("ends_at", "ends_at is invalid")
+	}
+	if !data.EndsAt.After(data.StartsAt) {
+		return data, errorx.InvalidField("ends_at", "ends_at must be after starts_at")
+	}
+	return data, nil
+}
+
+func joinIds(ids []int64) string {
+	set := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id > 0 {
+			set = append(set, strconv.FormatInt(id, 10))
+		}
+	}
+	return strings.Join(set, ",")
+}
@@ -0,0 +1,89 @@
+package logic
+
+import (
+	"context"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/mr"
+)
+
+type SaleListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewSaleListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SaleListLogic {
+	return &SaleListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *SaleListLogic) SaleList(in *product.SaleListRequest) (*product.SaleListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Limit <= 0 || in.Limit > 250 {
+		in.Limit = 50
+	}
+
+	var sales []model.SailProductSale
+	var count int64
+	err := mr.Finish(func() error {
+		resp, err := l.svcCtx.SaleModel.FindList(in.ShopId, in.Status, in.Limit, in.Page)
+		switch err {
+		case nil:
+			sales = *resp
+		case model.ErrNotFound:
+		default:
+			return err
+		}
+		return nil
+	}, func() (err error) {
+		count, err = l.svcCtx.SaleModel.Count(in.ShopId, in.Status)
+		return err
+	})
+	if err != nil {
+		l.Error("查询促销活动出错：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	resp := &product.SaleListResponse{Count: count}
+	for _, sale := range sales {
+		resp.Sales = append(resp.Sales, toSale(sale))
+	}
+	return resp, nil
+}
+
+func toSale(data model.SailProductSale) *product.Sale {
+	sale := &product.Sale{
+		Id:            data.Id,
+		Title:         data.Title,
+		ProductIds:    model.ParseVariantIds(data.ProductIds.String),
+		VariantIds:    model.ParseVariantIds(data.VariantIds.String),
+		FilterVendor:  data.FilterVendor,
+		FilterTitle:   data.FilterTitle,
+		DiscountType:  data.DiscountType,
+		DiscountValue: data.DiscountValue,
+		StartsAt:      data.StartsAt.Local().Format(time.RFC3339),
+		EndsAt:        data.EndsAt.Local().Format(time.RFC3339),
+		Status:        data.Status,
+		CreatedAt:     data.CreatedAt.Local().Format(time.RFC3339),
+	}
+	if data.Status != model.SALE_STATUS_SCHEDULED {
+		sale.AppliedAt = data.AppliedAt.Local().Format(time.RFC3339)
+	}
+	if data.Status == model.SALE_STATUS_ENDED {
+		sale.EndedAt = data.EndedAt.Local().Format(time.RFC3339)
+	}
+	return sale
+}
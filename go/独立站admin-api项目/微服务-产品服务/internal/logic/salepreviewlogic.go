@@ -0,0 +1,163 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	saleSkipNoDiscount = "no_discount"
+	saleSkipOtherSale  = "other_sale"
+)
+
+type SalePreviewLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewSalePreviewLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SalePreviewLogic {
+	return &SalePreviewLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// SalePreview 按当前价格计算改价结果，不修改数据；已开始的活动显示活动前的价格
+func (l *SalePreviewLogic) SalePreview(in *product.SalePreviewRequest) (*product.SalePreviewResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Limit <= 0 || in.Limit > 100 {
+		in.Limit = 20
+	}
+
+	var sale model.SailProductSale
+	if in.SaleId != 0 {
+		resp, err := l.svcCtx.SaleModel.FindOne(in.ShopId, in.SaleId)
+		switch err {
+		case nil:
+			sale = *resp
+		case model.ErrNotFound:
+			l.Error("促销活动不存在 id:", in.SaleId)
+			return nil, errorx.ErrSaleNotFound
+		default:
+			l.Error("查询促销活动失败：", err)
+			return nil, errorx.ErrInternal
+		}
+	} else {
+		if in.Sale == nil {
+			l.Error("缺少sale_id或sale参数")
+			return nil, errorx.Missing("sale_id", "sale")
+		}
+		in.Sale.ShopId = in.ShopId
+		var err error
+		if sale, err = buildSale(in.Sale); err != nil {
+			l.Error("促销活动参数不合法：", err)
+			return nil, err
+		}
+	}
+
+	productIds, err := l.svcCtx.SaleModel.FindTargetProducts(sale, in.AfterProductId, in.Limit)
+	if err != nil {
+		l.Error("查询促销活动产品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	items, err := l.svcCtx.SaleModel.FindAppliedItems(productIds)
+	if err != nil {
+		l.Error("查询促销活动改价记录失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	applied := make(map[int64]map[int64]model.SailProductSaleItem)
+	for _, item := range *items {
+		if _, ok := applied[item.ProductId]; !ok {
+			applied[item.ProductId] = map[int64]model.SailProductSaleItem{}
+		}
+		applied[item.ProductId][item.VariantId] = item
+	}
+	variantIds := make(map[int64]bool)
+	for _, id := range model.ParseVariantIds(sale.VariantIds.String) {
+		variantIds[id] = true
+	}
+
+	resp := &product.SalePreviewResponse{}
+	for _, productId := range productIds {
+		respProduct, err := l.svcCtx.ReadModel.FindOne(in.ShopId, model.WithId(productId))
+		switch err {
+		case nil:
+		case model.ErrNotFound:
+			continue
+		default:
+			l.Error(err)
+			return nil, errorx.ErrInternal
+		}
+		variants, err := l.svcCtx.ReadVariantModel.FindList(in.ShopId, productId)
+		switch err {
+		case nil:
+		case model.ErrNotFound:
+			variants = &[]model.SailShopProductVariant{}
+		default:
+			l.Error(err)
+			return nil, errorx.ErrInternal
+		}
+
+		wholeProduct := sale.CoversProduct(productId, respProduct.Vendor, respProduct.Title)
+		if wholeProduct {
+			resp.Items = append(resp.Items, l.previewItem(sale, applied[productId], productId, 0, respProduct.Title, respProduct.Price, respProduct.CompareAtPrice))
+		}
+		for _, variant := range *variants {
+			if !wholeProduct && !variantIds[variant.Id] {
+				continue
+			}
+			title := respProduct.Title
+			if variant.Title != "" {
+				title += " - " + variant.Title
+			}
+			resp.Items = append(resp.Items, l.previewItem(sale, applied[productId], productId, variant.Id, title, variant.Price, variant.CompareAtPrice))
+		}
+	}
+	if int64(len(productIds)) == in.Limit {
+		resp.NextProductId = productIds[len(productIds)-1]
+	}
+	return resp, nil
+}
+
+func (l *SalePreviewLogic) previewItem(sale model.SailProductSale, applied map[int64]model.SailProductSaleItem, productId, variantId int64, title string, price, compareAtPrice float64) *product.SalePreviewItem {
+	item := &product.SalePreviewItem{
+		ProductId:          productId,
+		VariantId:          variantId,
+		Title:              title,
+		Price:              price,
+		CompareAtPrice:     compareAtPrice,
+		SalePrice:          price,
+		SaleCompareAtPrice: compareAtPrice,
+	}
+	if record, ok := applied[variantId]; ok {
+		if record.SaleId != sale.Id {
+			item.SkipReason = saleSkipOtherSale
+			return item
+		}
+		item.Price = record.OriginalPrice
+		item.CompareAtPrice = record.OriginalCompareAtPrice
+		item.SalePrice = record.SalePrice
+		item.SaleCompareAtPrice = record.OriginalPrice
+		return item
+	}
+	salePrice, ok := pricing.SalePrice(sale.DiscountType, sale.DiscountValue, price)
+	if !ok {
+		item.SkipReason = saleSkipNoDiscount
+		return item
+	}
+	item.SalePrice = salePrice
+	item.SaleCompareAtPrice = price
+	return item
+}
@@ -0,0 +1,312 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/sitemap"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const sitemapChunkSize = 64 * 1024
+
+var sitemapPaths = map[string]string{
+	model.SITEMAP_KIND_PRODUCT:  "/products/",
+	model.SITEMAP_KIND_CATEGORY: "/collections/",
+}
+
+type (
+	SitemapGenerateLogic struct {
+		ctx    context.Context
+		svcCtx *svc.ServiceContext
+		logx.Logger
+	}
+
+	sitemapRecord struct {
+		Id       int64
+		Handler  string
+		Lastmod  time.Time
+		ImageIds []int64
+	}
+
+	// sitemapChunkWriter 把写入的内容按块发送给调用方
+	sitemapChunkWriter struct {
+		file   string
+		buf    bytes.Buffer
+		stream product.ProductRPC_SitemapGenerateServer
+	}
+)
+
+func NewSitemapGenerateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SitemapGenerateLogic {
+	return &SitemapGenerateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// SitemapGenerate 只重新生成收到过变更事件的产品子文件，分类没有变更事件，每次都重新生成；
+// 最后返回引用全部子文件的 sitemap.xml。调用方保留未返回的子文件即可
+func (l *SitemapGenerateLogic) SitemapGenerate(in *product.SitemapGenerateRequest, stream product.ProductRPC_SitemapGenerateServer) error {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return errorx.Missing("shop_id")
+	}
+	baseUrl, err := url.Parse(in.BaseUrl)
+	if err != nil || (baseUrl.Scheme != "http" && baseUrl.Scheme != "https") || baseUrl.Host == "" {
+		l.Error("base_url参数不合法：", in.BaseUrl)
+		return errorx.InvalidField("base_url", "base_url is invalid")
+	}
+	base := strings.TrimRight(in.BaseUrl, "/")
+
+	lock := redis.NewRedisLock(l.svcCtx.RedisClientSaas, fmt.Sprintf("product:sitemap:generate:%d", in.ShopId))
+	lock.SetExpire(l.svcCtx.Config.Sitemap.GenerateLock)
+	if ok, err := lock.Acquire(); !ok || err != nil {
+		l.Error("sitemap正在生成中 shop_id:", in.ShopId)
+		return errorx.New(errorx.CodeOperationInProgress, "sitemap generation in progress, please retry later")
+	}
+	defer lock.Release()
+
+	files, err := l.findFiles(in.ShopId)
+	if err != nil {
+		return err
+	}
+	hasProduct := false
+	for _, file := range files {
+		if file.Kind == model.SITEMAP_KIND_PRODUCT {
+			hasProduct = true
+		}
+	}
+	if in.Full || !hasProduct {
+		if err := l.svcCtx.SitemapModel.Reset(in.ShopId, model.SITEMAP_KIND_PRODUCT); err != nil {
+			l.Error("重置sitemap文件划分失败：", err)
+			return errorx.ErrInternal
+		}
+	}
+	if err := l.svcCtx.SitemapModel.Reset(in.ShopId, model.SITEMAP_KIND_CATEGORY); err != nil {
+		l.Error("重置sitemap文件划分失败：", err)
+		return errorx.ErrInternal
+	}
+
+	// 写满拆分出的新文件要重新读取划分才能拿到，直到没有新的待生成文件为止
+	done := map[int64]bool{}
+	for {
+		files, err = l.findFiles(in.ShopId)
+		if err != nil {
+			return err
+		}
+		generated := false
+		for _, file := range files {
+			if file.Dirty == 0 || done[file.Id] {
+				continue
+			}
+			if err := l.generate(file, base, stream); err != nil {
+				return err
+			}
+			done[file.Id] = true
+			generated = true
+		}
+		if !generated {
+			break
+		}
+	}
+
+	entries := make([]sitemap.IndexEntry, 0, len(files))
+	for _, file := range files {
+		if file.UrlCount == 0 {
+			continue
+		}
+		entries = append(entries, sitemap.IndexEntry{
+			Loc:     base + "/" + sitemap.FileName(file.Kind, file.Seq),
+			Lastmod: file.Lastmod,
+		})
+	}
+	out := &sitemapChunkWriter{file: sitemap.IndexFile, stream: stream}
+	if err := sitemap.WriteIndex(out, entries); err != nil {
+		l.Error("发送sitemap失败：", err)
+		return err
+	}
+	return out.Close(time.Now(), int64(len(entries)))
+}
+
+func (l *SitemapGenerateLogic) findFiles(shopId int64) ([]model.SailProductSitemap, error) {
+	resp, err := l.svcCtx.SitemapModel.FindList(shopId)
+	switch err {
+	case nil:
+		return *resp, nil
+	case sqlc.ErrNotFound:
+		return nil, nil
+	default:
+		l.Error("查询sitemap文件划分失败：", err)
+		return nil, errorx.ErrInternal
+	}
+}
+
+// generate 生成一个子文件。最后一个文件写满上限时在最后写入的记录处截止，剩余的记录拆分到新文件
+func (l *SitemapGenerateLogic) generate(file model.SailProductSitemap, base string, stream product.ProductRPC_SitemapGenerateServer) error {
+	out := &sitemapChunkWriter{file: sitemap.FileName(file.Kind, file.Seq), stream: stream}
+	w := sitemap.NewUrlSetWriter(out)
+	afterId := file.FirstId - 1
+	var count int64
+	var lastmod time.Time
+	full := false
+	for !full {
+		records, err := l.fetch(file, afterId)
+		if err != nil {
+			l.Error("读取sitemap记录失败：", err)
+			return errorx.ErrInternal
+		}
+		images, err := l.loadImages(records)
+		if err != nil {
+			l.Error("读取sitemap图片失败：", err)
+			return errorx.ErrInternal
+		}
+		for _, record := range records {
+			if file.LastId == 0 && count == model.SITEMAP_MAX_URLS {
+				if err := l.svcCtx.SitemapModel.Split(file, afterId); err != nil {
+					l.Error("拆分sitemap文件失败：", err)
+					return errorx.ErrInternal
+				}
+				full = true
+				break
+			}
+			item := sitemap.Url{
+				Loc:     base + sitemapPaths[file.Kind] + url.PathEscape(record.Handler),
+				Lastmod: record.Lastmod,
+			}
+			for _, imageId := range record.ImageIds {
+				if image, ok := images[imageId]; ok {
+					item.Images = append(item.Images, image)
+				}
+			}
+			if err := w.Write(item); err != nil {
+				l.Error("发送sitemap失败：", err)
+				return err
+			}
+			count++
+			afterId = record.Id
+			if record.Lastmod.After(lastmod) {
+				lastmod = record.Lastmod
+			}
+		}
+		if int64(len(records)) < l.svcCtx.Config.Sitemap.PageSize {
+			break
+		}
+	}
+	if err := w.Close(); err != nil {
+		l.Error("发送sitemap失败：", err)
+		return err
+	}
+	if err := out.Close(lastmod, count); err != nil {
+		l.Error("发送sitemap失败：", err)
+		return err
+	}
+	if err := l.svcCtx.SitemapModel.MarkGenerated(file.Id, file.Dirty, count, lastmod); err != nil {
+		l.Error("保存sitemap生成状态失败：", err)
+		return errorx.ErrInternal
+	}
+	return nil
+}
+
+// fetch 从主库读取，避免从库延迟导致刚变更的产品被漏掉后又清除了待生成标记
+func (l *SitemapGenerateLogic) fetch(file model.SailProductSitemap, afterId int64) ([]sitemapRecord, error) {
+	pageSize := l.svcCtx.Config.Sitemap.PageSize
+	records := make([]sitemapRecord, 0, pageSize)
+	switch file.Kind {
+	case model.SITEMAP_KIND_PRODUCT:
+		resp, err := l.svcCtx.WriteModel.FindPublishedAfter(file.ShopId, afterId, file.LastId, pageSize)
+		switch err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return records, nil
+		default:
+			return nil, errorx.ErrInternal
+		}
+		for _, item := range *resp {
+			record := sitemapRecord{Id: item.Id, Handler: item.Handler, Lastmod: item.UpdatedAt}
+			if item.DefaultImageId != 0 {
+				record.ImageIds = append(record.ImageIds, item.DefaultImageId)
+			}
+			for _, id := range strings.Split(item.ImageIds, ",") {
+				imageId, err := strconv.ParseInt(id, 10, 64)
+				if err == nil && imageId != 0 && imageId != item.DefaultImageId {
+					record.ImageIds = append(record.ImageIds, imageId)
+				}
+			}
+			records = append(records, record)
+		}
+	case model.SITEMAP_KIND_CATEGORY:
+		resp, err := l.svcCtx.SitemapModel.FindCategories(file.ShopId, afterId, file.LastId, pageSize)
+		switch err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return records, nil
+		default:
+			return nil, errorx.ErrInternal
+		}
+		for _, item := range *resp {
+			record := sitemapRecord{Id: item.Id, Handler: item.Handler, Lastmod: item.UpdatedAt}
+			if item.ImageId != 0 {
+				record.ImageIds = append(record.ImageIds, item.ImageId)
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (l *SitemapGenerateLogic) loadImages(records []sitemapRecord) (map[int64]string, error) {
+	ids := make([]int64, 0)
+	for _, record := range records {
+		ids = append(ids, record.ImageIds...)
+	}
+	images := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return images, nil
+	}
+	resp, err := l.svcCtx.WriteImageModel.FindByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range *resp {
+		if image.FileKey != "" {
+			images[image.Id] = cdnImageUrl(l.svcCtx.ImgCDN, image.FileKey)
+		}
+	}
+	return images, nil
+}
+
+func (w *sitemapChunkWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for w.buf.Len() >= sitemapChunkSize {
+		if err := w.stream.Send(&product.SitemapChunk{File: w.file, Data: w.buf.Next(sitemapChunkSize)}); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *sitemapChunkWriter) Close(lastmod time.Time, urlCount int64) error {
+	chunk := &product.SitemapChunk{
+		File:     w.file,
+		Data:     w.buf.Bytes(),
+		Eof:      true,
+		UrlCount: urlCount,
+	}
+	if !lastmod.IsZero() {
+		chunk.Lastmod = lastmod.Local().Format(time.RFC3339)
+	}
+	return w.stream.Send(chunk)
+}
@@ -0,0 +1,66 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
+	"strings"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type TranslationDeleteLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewTranslationDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *TranslationDeleteLogic {
+	return &TranslationDeleteLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *TranslationDeleteLogic) TranslationDelete(in *product.TranslationDeleteRequest) (*product.TranslationDeleteResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if !metafield.ValidOwner(in.OwnerType) {
+		l.Error("owner_type参数不合法：", in.OwnerType)
+		return nil, errorx.InvalidField("owner_type", metafield.ErrOwnerInvalid.Error())
+	}
+	if in.OwnerId == 0 {
+		l.Error("缺少owner_id参数")
+		return nil, errorx.Missing("owner_id")
+	}
+	locale, err := translation.NormalizeLocale(in.Locale)
+	if err != nil {
+		l.Error("locale参数不合法：", in.Locale)
+		return nil, errorx.InvalidField("locale", err.Error())
+	}
+	var fields []string
+	for _, field := range strings.Split(in.Fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !translation.ValidField(in.OwnerType, field) {
+			l.Error("翻译字段不合法：", in.OwnerType, ".", field)
+			return nil, errorx.InvalidField("fields", "fields contains an invalid field")
+		}
+		fields = append(fields, field)
+	}
+	deleted, err := l.svcCtx.WriteTranslationModel.Delete(in.ShopId, in.OwnerType, in.OwnerId, locale, fields)
+	if err != nil {
+		l.Error("删除翻译失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.TranslationDeleteResponse{Deleted: deleted}, nil
+}
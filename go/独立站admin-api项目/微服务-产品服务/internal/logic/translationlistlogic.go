@@ -0,0 +1,136 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type TranslationListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewTranslationListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *TranslationListLogic {
+	return &TranslationListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *TranslationListLogic) TranslationList(in *product.TranslationListRequest) (*product.TranslationListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if !metafield.ValidOwner(in.OwnerType) {
+		l.Error("owner_type参数不合法：", in.OwnerType)
+		return nil, errorx.InvalidField("owner_type", metafield.ErrOwnerInvalid.Error())
+	}
+	if in.OwnerId == 0 {
+		l.Error("缺少owner_id参数")
+		return nil, errorx.Missing("owner_id")
+	}
+	var locale string
+	if in.Locale != "" {
+		var err error
+		if locale, err = translation.NormalizeLocale(in.Locale); err != nil {
+			l.Error("locale参数不合法：", in.Locale)
+			return nil, errorx.InvalidField("locale", err.Error())
+		}
+	}
+	resp, err := l.svcCtx.ReadTranslationModel.FindList(in.ShopId, in.OwnerType, []int64{in.OwnerId}, locale)
+	if err != nil {
+		l.Error("查询翻译失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.TranslationListResponse{Translations: toTranslations(*resp)}, nil
+}
+
+func toTranslations(data []model.SailProductTranslation) []*product.Translation {
+	result := make([]*product.Translation, 0, len(data))
+	for _, item := range data {
+		result = append(result, &product.Translation{
+			Id:        item.Id,
+			OwnerType: item.OwnerType,
+			OwnerId:   item.OwnerId,
+			Locale:    item.Locale,
+			Field:     item.Field,
+			Value:     item.Value,
+			CreatedAt: item.CreatedAt.Local().Format(time.RFC3339),
+			UpdatedAt: item.UpdatedAt.Local().Format(time.RFC3339),
+		})
+	}
+	return result
+}
+
+// translateProducts 用 locale 的翻译替换产品和子商品的文本，没有翻译的字段保留原值
+func translateProducts(svcCtx *svc.ServiceContext, shopId int64, locale string, products []*product.Product) error {
+	if locale == "" || len(products) == 0 {
+		return nil
+	}
+	locale, err := translation.NormalizeLocale(locale)
+	if err != nil {
+		return err
+	}
+	productIds := make([]int64, 0, len(products))
+	variantIds := make([]int64, 0)
+	for _, p := range products {
+		productIds = append(productIds, p.ProductId)
+		for _, variant := range p.Variants {
+			variantIds = append(variantIds, variant.Id)
+		}
+	}
+	productValues, err := svcCtx.ReadTranslationModel.FindValues(shopId, metafield.OwnerProduct, productIds, locale)
+	if err != nil {
+		return err
+	}
+	variantValues, err := svcCtx.ReadTranslationModel.FindValues(shopId, metafield.OwnerVariant, variantIds, locale)
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		values := productValues[p.ProductId]
+		p.Title = values.Get(translation.FieldTitle, p.Title)
+		p.SubTitle = values.Get(translation.FieldSubTitle, p.SubTitle)
+		p.BodyHtml = values.Get(translation.FieldBodyHtml, p.BodyHtml)
+		p.SeoTitle = values.Get(translation.FieldSeoTitle, p.SeoTitle)
+		p.SeoDesc = values.Get(translation.FieldSeoDesc, p.SeoDesc)
+		for _, variant := range p.Variants {
+			values := variantValues[variant.Id]
+			variant.Title = values.Get(translation.FieldTitle, variant.Title)
+			variant.Spec = values.Get(translation.FieldSpec, variant.Spec)
+		}
+	}
+	return nil
+}
+
+// translateCategory 用 locale 的翻译替换分类的文本，没有翻译的字段保留原值
+func translateCategory(svcCtx *svc.ServiceContext, shopId int64, locale string, category *product.Category) error {
+	if locale == "" {
+		return nil
+	}
+	locale, err := translation.NormalizeLocale(locale)
+	if err != nil {
+		return err
+	}
+	values, err := svcCtx.ReadTranslationModel.FindValues(shopId, metafield.OwnerCategory, []int64{category.Id}, locale)
+	if err != nil {
+		return err
+	}
+	category.Title = values[category.Id].Get(translation.FieldTitle, category.Title)
+	category.BodyHtml = values[category.Id].Get(translation.FieldBodyHtml, category.BodyHtml)
+	category.SeoTitle = values[category.Id].Get(translation.FieldSeoTitle, category.SeoTitle)
+	category.SeoDesc = values[category.Id].Get(translation.FieldSeoDesc, category.SeoDesc)
+	return nil
+}
@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"context"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	translationMissingDefaultLimit = 50
+	translationMissingMaxLimit     = 250
+)
+
+type TranslationMissingLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewTranslationMissingLogic(ctx context.Context, svcCtx *svc.ServiceContext) *TranslationMissingLogic {
+	return &TranslationMissingLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *TranslationMissingLogic) TranslationMissing(in *product.TranslationMissingRequest) (*product.TranslationMissingResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	locale, err := translation.NormalizeLocale(in.Locale)
+	if err != nil {
+		l.Error("locale参数不合法：", in.Locale)
+		return nil, errorx.InvalidField("locale", err.Error())
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = translationMissingDefaultLimit
+	}
+	if limit > translationMissingMaxLimit {
+		limit = translationMissingMaxLimit
+	}
+	resp, nextSinceId, err := l.svcCtx.ReadTranslationModel.FindMissing(in.ShopId, locale, in.SinceId, limit)
+	if err != nil {
+		l.Error("查询缺少翻译的产品失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	items := make([]*product.TranslationMissingItem, 0, len(*resp))
+	for _, item := range *resp {
+		items = append(items, &product.TranslationMissingItem{
+			ProductId:       item.ProductId,
+			Title:           item.Title,
+			MissingFields:   item.MissingFields,
+			MissingVariants: item.MissingVariants,
+		})
+	}
+	return &product.TranslationMissingResponse{Items: items, NextSinceId: nextSinceId}, nil
+}
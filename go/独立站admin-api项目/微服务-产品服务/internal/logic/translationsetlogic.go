@@ -0,0 +1,86 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type TranslationSetLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewTranslationSetLogic(ctx context.Context, svcCtx *svc.ServiceContext) *TranslationSetLogic {
+	return &TranslationSetLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *TranslationSetLogic) TranslationSet(in *product.TranslationSetRequest) (*product.TranslationSetResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if !metafield.ValidOwner(in.OwnerType) {
+		l.Error("owner_type参数不合法：", in.OwnerType)
+		return nil, errorx.InvalidField("owner_type", metafield.ErrOwnerInvalid.Error())
+	}
+	if in.OwnerId == 0 {
+		l.Error("缺少owner_id参数")
+		return nil, errorx.Missing("owner_id")
+	}
+	locale, err := translation.NormalizeLocale(in.Locale)
+	if err != nil {
+		l.Error("locale参数不合法：", in.Locale)
+		return nil, errorx.InvalidField("locale", err.Error())
+	}
+	if len(in.Translations) == 0 {
+		l.Error("缺少translations参数")
+		return nil, errorx.Missing("translations")
+	}
+
+	data := make([]model.SailProductTranslation, 0, len(in.Translations))
+	for _, item := range in.Translations {
+		if !translation.ValidField(in.OwnerType, item.Field) {
+			l.Error("翻译字段不合法：", in.OwnerType, ".", item.Field)
+			return nil, errorx.InvalidField("translations", fmt.Sprintf("%s field %q can not be translated", in.OwnerType, item.Field))
+		}
+		if err := translation.Validate(item.Field, item.Value); err != nil {
+			l.Error("翻译内容不合法：", item.Field, " ", err)
+			return nil, errorx.InvalidField("translations", err.Error())
+		}
+		data = append(data, model.SailProductTranslation{Field: item.Field, Value: item.Value})
+	}
+
+	ok, err := l.svcCtx.WriteTranslationModel.OwnerExists(in.ShopId, in.OwnerType, in.OwnerId)
+	if err != nil {
+		l.Error("查询翻译所属对象失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	if !ok {
+		l.Error("翻译所属对象不存在 owner_type:", in.OwnerType, " owner_id:", in.OwnerId)
+		return nil, ownerNotFound(in.OwnerType)
+	}
+	if err := l.svcCtx.WriteTranslationModel.Set(in.ShopId, in.OwnerType, in.OwnerId, locale, data); err != nil {
+		l.Error("保存翻译失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	resp, err := l.svcCtx.WriteTranslationModel.FindList(in.ShopId, in.OwnerType, []int64{in.OwnerId}, locale)
+	if err != nil {
+		l.Error("查询翻译失败：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.TranslationSetResponse{Translations: toTranslations(*resp)}, nil
+}
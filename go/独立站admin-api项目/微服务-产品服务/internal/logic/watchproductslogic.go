@@ -0,0 +1,136 @@
+package logic
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/watch"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type WatchProductsLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewWatchProductsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WatchProductsLogic {
+	return &WatchProductsLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+// WatchProducts 先从数据库补发 resume_token 之后的事件，追上之后改为接收 Hub 的实时分发；
+// 如果消费太慢被 Hub 移除，会回到数据库从最后发送的位置继续补发
+func (l *WatchProductsLogic) WatchProducts(in *product.WatchProductsRequest, stream product.ProductRPC_WatchProductsServer) error {
+	position := l.svcCtx.WatchHub.Head()
+	if in.ResumeToken != "" {
+		var err error
+		position, err = strconv.ParseInt(in.ResumeToken, 10, 64)
+		if err != nil || position < 0 {
+			l.Error("resume_token参数不合法")
+			return errorx.InvalidField("resume_token", "resume_token is invalid")
+		}
+		minSeq, _, err := l.svcCtx.OutboxModel.FindSeqRange()
+		if err != nil {
+			l.Error("查询产品变更事件失败：", err)
+			return errorx.ErrInternal
+		}
+		if minSeq > position+1 {
+			l.Error("resume_token已过期")
+			return errorx.New(errorx.CodeResumeTokenExpired, "resume_token expired, please resync with ProductList")
+		}
+	}
+	events := map[string]bool{}
+	for _, event := range in.Events {
+		events[event] = true
+	}
+
+	for {
+		sub := l.svcCtx.WatchHub.Subscribe(in.ShopId)
+		var lagged bool
+		var err error
+		position, err = l.catchUp(in.ShopId, position, events, stream)
+		if err == nil {
+			position, lagged, err = l.follow(sub, position, events, stream)
+		}
+		l.svcCtx.WatchHub.Unsubscribe(sub)
+		if err != nil {
+			return err
+		}
+		if !lagged {
+			return nil
+		}
+		l.Infof("变更订阅落后，回到数据库补发 shop_id:%d position:%d", in.ShopId, position)
+	}
+}
+
+func (l *WatchProductsLogic) catchUp(shopId, position int64, events map[string]bool, stream product.ProductRPC_WatchProductsServer) (int64, error) {
+	batchSize := l.svcCtx.Config.Watch.BatchSize
+	for {
+		head := l.svcCtx.WatchHub.Head()
+		if position >= head {
+			return position, nil
+		}
+		resp, err := l.svcCtx.OutboxModel.FindAfter(position, head, shopId, batchSize)
+		switch err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return head, nil
+		default:
+			l.Error("查询产品变更事件失败：", err)
+			return position, errorx.ErrInternal
+		}
+		for _, event := range *resp {
+			if err := l.send(event, events, stream); err != nil {
+				return position, err
+			}
+			position = event.Seq
+		}
+		if int64(len(*resp)) < batchSize {
+			return head, nil
+		}
+	}
+}
+
+func (l *WatchProductsLogic) follow(sub *watch.Subscriber, position int64, events map[string]bool, stream product.ProductRPC_WatchProductsServer) (int64, bool, error) {
+	for {
+		select {
+		case <-l.ctx.Done():
+			return position, false, nil
+		case <-sub.Lagged():
+			return position, true, nil
+		case event := <-sub.Events():
+			if event.Seq <= position {
+				continue
+			}
+			if err := l.send(event, events, stream); err != nil {
+				return position, false, err
+			}
+			position = event.Seq
+		}
+	}
+}
+
+func (l *WatchProductsLogic) send(event model.SailProductOutbox, events map[string]bool, stream product.ProductRPC_WatchProductsServer) error {
+	if len(events) != 0 && !events[event.Event] {
+		return nil
+	}
+	return stream.Send(&product.ProductChangeEvent{
+		Event:       event.Event,
+		ShopId:      event.ShopId,
+		ProductId:   event.ProductId,
+		Version:     event.Version,
+		ResumeToken: strconv.FormatInt(event.Seq, 10),
+		CreatedAt:   event.CreatedAt.Local().Format(time.RFC3339),
+	})
+}
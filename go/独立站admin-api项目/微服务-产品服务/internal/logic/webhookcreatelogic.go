@@ -0,0 +1,96 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model/mongo/webhook"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type WebhookCreateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewWebhookCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WebhookCreateLogic {
+	return &WebhookCreateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *WebhookCreateLogic) WebhookCreate(in *product.WebhookCreateRequest) (*product.WebhookCreateResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Format == "" {
+		in.Format = webhook.FORMAT_JSON
+	}
+	if err := validateWebhook(in.Topic, in.Address, in.Format); err != nil {
+		l.Error(err)
+		return nil, err
+	}
+
+	_, err := l.svcCtx.WebhookModel.FindOneByAddress(l.ctx, in.ShopId, in.Topic, in.Address)
+	switch err {
+	case nil:
+		l.Error("webhook已存在")
+		return nil, errorx.New(errorx.CodeWebhookExists, "webhook address already subscribed to this topic")
+	case webhook.ErrNotFound:
+	default:
+		l.Error("查询webhook出错：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		l.Error("生成webhook密钥出错：", err)
+		return nil, errorx.ErrInternal
+	}
+	now := time.Now()
+	data := &webhook.Webhook{
+		ShopId:    in.ShopId,
+		Topic:     in.Topic,
+		Address:   in.Address,
+		Format:    in.Format,
+		Secret:    hex.EncodeToString(secret),
+		Status:    webhook.STATUS_ENABLED,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err = l.svcCtx.WebhookModel.Insert(l.ctx, data); err != nil {
+		l.Error("添加webhook出错：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	resp := toProductWebhook(data)
+	resp.Secret = data.Secret
+	return &product.WebhookCreateResponse{Webhook: resp}, nil
+}
+
+func validateWebhook(topic, address, format string) error {
+	switch topic {
+	case webhook.TOPIC_PRODUCT_CREATE, webhook.TOPIC_PRODUCT_UPDATE, webhook.TOPIC_PRODUCT_DELETE:
+	default:
+		return errorx.InvalidField("topic", "topic is invalid")
+	}
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errorx.InvalidField("address", "address is invalid")
+	}
+	if format != webhook.FORMAT_JSON && format != webhook.FORMAT_XML {
+		return errorx.InvalidField("format", "format is invalid")
+	}
+	return nil
+}
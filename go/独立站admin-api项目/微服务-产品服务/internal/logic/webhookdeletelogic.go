@@ -0,0 +1,44 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model/mongo/webhook"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type WebhookDeleteLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewWebhookDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WebhookDeleteLogic {
+	return &WebhookDeleteLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *WebhookDeleteLogic) WebhookDelete(in *product.WebhookDeleteRequest) (*product.WebhookDeleteResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	err := l.svcCtx.WebhookModel.Delete(l.ctx, in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case webhook.ErrNotFound, webhook.ErrInvalidObjectId:
+		l.Error("webhook记录不存在")
+		return nil, errorx.ErrWebhookNotFound
+	default:
+		l.Error("删除webhook出错：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.WebhookDeleteResponse{}, nil
+}
@@ -0,0 +1,73 @@
+package logic
+
+import (
+	"context"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model/mongo/webhook"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type WebhookDeliveryListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewWebhookDeliveryListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WebhookDeliveryListLogic {
+	return &WebhookDeliveryListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *WebhookDeliveryListLogic) WebhookDeliveryList(in *product.WebhookDeliveryListRequest) (*product.WebhookDeliveryListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	if in.Limit <= 0 || in.Limit > 250 {
+		in.Limit = 50
+	}
+	deliveries, err := l.svcCtx.WebhookDeliveryModel.FindList(l.ctx, in.ShopId, in.WebhookId, int(in.Limit))
+	switch err {
+	case nil:
+	case webhook.ErrInvalidObjectId:
+		l.Error("webhook_id参数不合法")
+		return nil, errorx.InvalidField("webhook_id", "webhook_id is invalid")
+	default:
+		l.Error("查询webhook发送记录出错：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	resp := &product.WebhookDeliveryListResponse{}
+	for _, delivery := range deliveries {
+		item := &product.WebhookDelivery{
+			Id:        delivery.ID.Hex(),
+			WebhookId: delivery.WebhookId.Hex(),
+			Topic:     delivery.Topic,
+			ProductId: delivery.ProductId,
+			Address:   delivery.Address,
+			Status:    delivery.Status,
+			Payload:   delivery.Payload,
+			NextRunAt: delivery.NextRunAt.Local().Format(time.RFC3339),
+			CreatedAt: delivery.CreatedAt.Local().Format(time.RFC3339),
+		}
+		for _, attempt := range delivery.Attempts {
+			item.Attempts = append(item.Attempts, &product.WebhookDeliveryAttempt{
+				StatusCode: int64(attempt.StatusCode),
+				Error:      attempt.Error,
+				Response:   attempt.Response,
+				Duration:   attempt.Duration,
+				CreatedAt:  attempt.CreatedAt.Local().Format(time.RFC3339),
+			})
+		}
+		resp.Deliveries = append(resp.Deliveries, item)
+	}
+	return resp, nil
+}
@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"context"
+	"time"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model/mongo/webhook"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type WebhookListLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewWebhookListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WebhookListLogic {
+	return &WebhookListLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *WebhookListLogic) WebhookList(in *product.WebhookListRequest) (*product.WebhookListResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	hooks, err := l.svcCtx.WebhookModel.FindList(l.ctx, in.ShopId, in.Topic)
+	if err != nil {
+		l.Error("查询webhook出错：", err)
+		return nil, errorx.ErrInternal
+	}
+	resp := &product.WebhookListResponse{}
+	for _, hook := range hooks {
+		resp.Webhooks = append(resp.Webhooks, toProductWebhook(hook))
+	}
+	return resp, nil
+}
+
+// toProductWebhook 不返回密钥，密钥只在创建时返回一次
+func toProductWebhook(hook *webhook.Webhook) *product.Webhook {
+	return &product.Webhook{
+		Id:             hook.ID.Hex(),
+		ShopId:         hook.ShopId,
+		Topic:          hook.Topic,
+		Address:        hook.Address,
+		Format:         hook.Format,
+		Status:         hook.Status,
+		FailureCount:   hook.FailureCount,
+		DisabledReason: hook.DisabledReason,
+		CreatedAt:      hook.CreatedAt.Local().Format(time.RFC3339),
+		UpdatedAt:      hook.UpdatedAt.Local().Format(time.RFC3339),
+	}
+}
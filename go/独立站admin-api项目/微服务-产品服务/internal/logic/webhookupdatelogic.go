@@ -0,0 +1,73 @@
+package logic
+
+import (
+	"context"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model/mongo/webhook"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+type WebhookUpdateLogic struct {
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	logx.Logger
+}
+
+func NewWebhookUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *WebhookUpdateLogic {
+	return &WebhookUpdateLogic{
+		ctx:    ctx,
+		svcCtx: svcCtx,
+		Logger: logx.WithContext(ctx),
+	}
+}
+
+func (l *WebhookUpdateLogic) WebhookUpdate(in *product.WebhookUpdateRequest) (*product.WebhookUpdateResponse, error) {
+	if in.ShopId == 0 {
+		l.Error("缺少shop_id参数")
+		return nil, errorx.Missing("shop_id")
+	}
+	hook, err := l.svcCtx.WebhookModel.FindOne(l.ctx, in.ShopId, in.Id)
+	switch err {
+	case nil:
+	case webhook.ErrNotFound, webhook.ErrInvalidObjectId:
+		l.Error("webhook记录不存在")
+		return nil, errorx.ErrWebhookNotFound
+	default:
+		l.Error("查询webhook出错：", err)
+		return nil, errorx.ErrInternal
+	}
+
+	if in.Address != "" {
+		hook.Address = in.Address
+	}
+	if in.Format != "" {
+		hook.Format = in.Format
+	}
+	if err = validateWebhook(hook.Topic, hook.Address, hook.Format); err != nil {
+		l.Error(err)
+		return nil, err
+	}
+	switch in.Status {
+	case "":
+	case webhook.STATUS_ENABLED:
+		hook.Status = webhook.STATUS_ENABLED
+		hook.FailureCount = 0
+		hook.DisabledReason = ""
+	case webhook.STATUS_DISABLED:
+		hook.Status = webhook.STATUS_DISABLED
+		hook.DisabledReason = "手动停用"
+	default:
+		l.Error("status参数不合法")
+		return nil, errorx.InvalidField("status", "status is invalid")
+	}
+
+	if err = l.svcCtx.WebhookModel.Update(l.ctx, hook); err != nil {
+		l.Error("更新webhook出错：", err)
+		return nil, errorx.ErrInternal
+	}
+	return &product.WebhookUpdateResponse{Webhook: toProductWebhook(hook)}, nil
+}
@@ -0,0 +1,104 @@
+package metafield
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeString           = "string"
+	TypeInteger          = "integer"
+	TypeDecimal          = "decimal"
+	TypeBoolean          = "boolean"
+	TypeJson             = "json"
+	TypeDate             = "date"
+	TypeUrl              = "url"
+	TypeProductReference = "product_reference"
+
+	OwnerProduct  = "product"
+	OwnerVariant  = "variant"
+	OwnerCategory = "category"
+
+	MaxValueLength = 65535
+	DateLayout     = "2006-01-02"
+)
+
+var (
+	ErrTypeInvalid  = errors.New("metafield type is invalid")
+	ErrOwnerInvalid = errors.New("owner_type must be product, variant or category")
+	ErrNameInvalid  = errors.New("namespace and key may only contain letters, numbers, '-' and '_', at most 64 characters")
+
+	nameRegexp    = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	decimalRegexp = regexp.MustCompile(`^-?\d{1,14}(\.\d{1,6})?$`)
+)
+
+func ValidOwner(ownerType string) bool {
+	return ownerType == OwnerProduct || ownerType == OwnerVariant || ownerType == OwnerCategory
+}
+
+func ValidName(name string) bool {
+	return nameRegexp.MatchString(name)
+}
+
+// Normalize 校验值是否符合类型并返回统一格式，按值筛选时用同样的格式比较
+func Normalize(fieldType, value string) (string, error) {
+	if len(value) > MaxValueLength {
+		return "", fmt.Errorf("metafield value exceeds %d bytes", MaxValueLength)
+	}
+	switch fieldType {
+	case TypeString:
+		return value, nil
+	case TypeInteger:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", errors.New("value must be an integer")
+		}
+		return strconv.FormatInt(n, 10), nil
+	case TypeDecimal:
+		value = strings.TrimSpace(value)
+		if !decimalRegexp.MatchString(value) {
+			return "", errors.New("value must be a decimal with at most 6 fractional digits")
+		}
+		return value, nil
+	case TypeBoolean:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", errors.New("value must be true or false")
+		}
+		return strconv.FormatBool(b), nil
+	case TypeJson:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(value)); err != nil {
+			return "", errors.New("value must be valid json")
+		}
+		return buf.String(), nil
+	case TypeDate:
+		t, err := time.Parse(DateLayout, strings.TrimSpace(value))
+		if err != nil {
+			return "", errors.New("value must be a date in YYYY-MM-DD format")
+		}
+		return t.Format(DateLayout), nil
+	case TypeUrl:
+		value = strings.TrimSpace(value)
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", errors.New("value must be an http or https url")
+		}
+		return value, nil
+	case TypeProductReference:
+		id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || id <= 0 {
+			return "", errors.New("value must be a product id")
+		}
+		return strconv.FormatInt(id, 10), nil
+	default:
+		return "", ErrTypeInvalid
+	}
+}
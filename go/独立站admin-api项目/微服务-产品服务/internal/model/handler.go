@@ -21,6 +21,8 @@ type HandlerItem struct {
 
 type HandlerOption func(option *HandlerItem)
 
+var ErrQueryArgMissing = errors.New("need query argument id or handler")
+
 func (h *HandlerItem) SetId(id int64) {
 	h.Id = id
 }
@@ -56,8 +58,8 @@ func SetOptions(opts ...HandlerOption) *HandlerItem {
 
 func SetQueryStr(filter []HandlerOption) (args []interface{}, extraWhere string, err error) {
 	if len(filter) == 0 {
-		logx.Error("need query argument id or handler")
-		return nil, "", errors.New("need query argument id or handler")
+		logx.Error(ErrQueryArgMissing)
+		return nil, "", ErrQueryArgMissing
 	}
 	filters := SetOptions(filter...)
 
@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	cachec "github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/mongo"
+	"github.com/tal-tech/go-zero/core/stores/mongoc"
+)
+
+type DeliveryModel interface {
+	Insert(ctx context.Context, data *Delivery) error
+	FindDue(ctx context.Context, limit int) ([]*Delivery, error)
+	FindList(ctx context.Context, shopId int64, webhookId string, limit int) ([]*Delivery, error)
+	Claim(ctx context.Context, id bson.ObjectId, owner string, leaseEnd time.Time) error
+	RecordAttempt(ctx context.Context, id bson.ObjectId, owner string, attempt Attempt, status string, nextRunAt time.Time) error
+}
+
+type defaultDeliveryModel struct {
+	*mongoc.Model
+}
+
+func NewDeliveryModel(url, collection string, c cachec.CacheConf) DeliveryModel {
+	return &defaultDeliveryModel{
+		Model: mongoc.MustNewModel(url, collection, c),
+	}
+}
+
+func (m *defaultDeliveryModel) Insert(ctx context.Context, data *Delivery) error {
+	if !data.ID.Valid() {
+		data.ID = bson.NewObjectId()
+	}
+
+	session, err := m.TakeSession()
+	if err != nil {
+		return err
+	}
+
+	defer m.PutSession(session)
+	return m.GetCollection(session).Insert(data)
+}
+
+// FindDue 取出到期需要发送的记录，包括租约已过期的发送中记录，按下次发送时间排序
+func (m *defaultDeliveryModel) FindDue(ctx context.Context, limit int) ([]*Delivery, error) {
+	session, err := m.TakeSession()
+	if err != nil {
+		return nil, err
+	}
+
+	defer m.PutSession(session)
+	var data []*Delivery
+	query := dueQuery(time.Now())
+	err = m.GetCollection(session).FindAllNoCache(&data, query, func(q mongo.Query) mongo.Query {
+		return q.Sort("next_run_at").Limit(limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (m *defaultDeliveryModel) FindList(ctx context.Context, shopId int64, webhookId string, limit int) ([]*Delivery, error) {
+	query := bson.M{"shop_id": shopId}
+	if webhookId != "" {
+		if !bson.IsObjectIdHex(webhookId) {
+			return nil, ErrInvalidObjectId
+		}
+		query["webhook_id"] = bson.ObjectIdHex(webhookId)
+	}
+
+	session, err := m.TakeSession()
+	if err != nil {
+		return nil, err
+	}
+
+	defer m.PutSession(session)
+	var data []*Delivery
+	err = m.GetCollection(session).FindAllNoCache(&data, query, func(q mongo.Query) mongo.Query {
+		return q.Sort("-created_at").Limit(limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Claim 把到期的记录改为发送中并写入领取的实例和租约，记录已被其他实例领取时返回 ErrNotFound。
+// 条件和修改在同一次更新中完成，多个实例同时领取时只有一个会成功
+func (m *defaultDeliveryModel) Claim(ctx context.Context, id bson.ObjectId, owner string, leaseEnd time.Time) error {
+	session, err := m.TakeSession()
+	if err != nil {
+		return err
+	}
+
+	defer m.PutSession(session)
+	now := time.Now()
+	query := dueQuery(now)
+	query["_id"] = id
+	err = m.GetCollection(session).UpdateNoCache(query, bson.M{"$set": bson.M{
+		"status":     DELIVERY_STATUS_SENDING,
+		"owner":      owner,
+		"lease_end":  leaseEnd,
+		"updated_at": now,
+	}})
+	switch err {
+	case nil:
+		return nil
+	case mongoc.ErrNotFound:
+		return ErrNotFound
+	default:
+		return err
+	}
+}
+
+// RecordAttempt 记录一次发送结果并释放领取，租约已被其他实例接手时返回 ErrNotFound
+func (m *defaultDeliveryModel) RecordAttempt(ctx context.Context, id bson.ObjectId, owner string, attempt Attempt, status string, nextRunAt time.Time) error {
+	session, err := m.TakeSession()
+	if err != nil {
+		return err
+	}
+
+	defer m.PutSession(session)
+	err = m.GetCollection(session).UpdateNoCache(bson.M{"_id": id, "owner": owner}, bson.M{
+		"$push": bson.M{"attempts": attempt},
+		"$set": bson.M{
+			"status":      status,
+			"next_run_at": nextRunAt,
+			"owner":       "",
+			"updated_at":  time.Now(),
+		},
+	})
+	switch err {
+	case nil:
+		return nil
+	case mongoc.ErrNotFound:
+		return ErrNotFound
+	default:
+		return err
+	}
+}
+
+func dueQuery(now time.Time) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"status": DELIVERY_STATUS_PENDING, "next_run_at": bson.M{"$lte": now}},
+		{"status": DELIVERY_STATUS_SENDING, "lease_end": bson.M{"$lte": now}},
+	}}
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+//go:generate goctl model mongo -t Webhook
+type Webhook struct {
+	ID             bson.ObjectId `bson:"_id" json:"id"`
+	ShopId         int64         `bson:"shop_id" json:"shop_id"`
+	Topic          string        `bson:"topic" json:"topic"`
+	Address        string        `bson:"address" json:"address"`
+	Format         string        `bson:"format" json:"format"`
+	Secret         string        `bson:"secret" json:"-"`
+	Status         string        `bson:"status" json:"status"`
+	FailureCount   int64         `bson:"failure_count" json:"failure_count"` // 连续失败次数，成功一次后清零
+	DisabledReason string        `bson:"disabled_reason" json:"disabled_reason"`
+	CreatedAt      time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+//go:generate goctl model mongo -t Delivery
+type Delivery struct {
+	ID        bson.ObjectId `bson:"_id" json:"id"`
+	WebhookId bson.ObjectId `bson:"webhook_id" json:"webhook_id"`
+	ShopId    int64         `bson:"shop_id" json:"shop_id"`
+	Topic     string        `bson:"topic" json:"topic"`
+	ProductId int64         `bson:"product_id" json:"product_id"`
+	EventId   int64         `bson:"event_id" json:"event_id"` // sail_product_outbox.id
+	Address   string        `bson:"address" json:"address"`
+	Format    string        `bson:"format" json:"format"`
+	Payload   string        `bson:"payload" json:"payload"`
+	Status    string        `bson:"status" json:"status"`
+	Attempts  []Attempt     `bson:"attempts" json:"attempts"`
+	NextRunAt time.Time     `bson:"next_run_at" json:"next_run_at"`
+	Owner     string        `bson:"owner" json:"-"`     // 正在发送的实例
+	LeaseEnd  time.Time     `bson:"lease_end" json:"-"` // 发送租约到期时间，到期后其他实例可以重新领取
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+type Attempt struct {
+	StatusCode int       `bson:"status_code" json:"status_code"`
+	Error      string    `bson:"error" json:"error"`
+	Response   string    `bson:"response" json:"response"` // 响应内容，截断保存
+	Duration   int64     `bson:"duration" json:"duration"` // 请求耗时，毫秒
+	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
+}
+
+const (
+	STATUS_ENABLED  = "enabled"
+	STATUS_DISABLED = "disabled"
+
+	DELIVERY_STATUS_PENDING   = "pending"
+	DELIVERY_STATUS_SENDING   = "sending"
+	DELIVERY_STATUS_SUCCEEDED = "succeeded"
+	DELIVERY_STATUS_FAILED    = "failed"
+
+	TOPIC_PRODUCT_CREATE = "product/create"
+	TOPIC_PRODUCT_UPDATE = "product/update"
+	TOPIC_PRODUCT_DELETE = "product/delete"
+
+	FORMAT_JSON = "json"
+	FORMAT_XML  = "xml"
+)
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	cachec "github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/mongo"
+	"github.com/tal-tech/go-zero/core/stores/mongoc"
+)
+
+type WebhookModel interface {
+	Insert(ctx context.Context, data *Webhook) error
+	FindOne(ctx context.Context, shopId int64, id string) (*Webhook, error)
+	FindOneByAddress(ctx context.Context, shopId int64, topic, address string) (*Webhook, error)
+	FindList(ctx context.Context, shopId int64, topic string) ([]*Webhook, error)
+	FindEnabled(ctx context.Context, shopId int64, topic string) ([]*Webhook, error)
+	Update(ctx context.Context, data *Webhook) error
+	RecordSuccess(ctx context.Context, id bson.ObjectId) error
+	RecordFailure(ctx context.Context, id bson.ObjectId) (*Webhook, error)
+	Disable(ctx context.Context, id bson.ObjectId, reason string) error
+	Delete(ctx context.Context, shopId int64, id string) error
+}
+
+type defaultWebhookModel struct {
+	*mongoc.Model
+}
+
+func NewWebhookModel(url, collection string, c cachec.CacheConf) WebhookModel {
+	return &defaultWebhookModel{
+		Model: mongoc.MustNewModel(url, collection, c),
+	}
+}
+
+func (m *defaultWebhookModel) Insert(ctx context.Context, data *Webhook) error {
+	if !data.ID.Valid() {
+		data.ID = bson.NewObjectId()
+	}
+
+	session, err := m.TakeSession()
+	if err != nil {
+		return err
+	}
+
+	defer m.PutSession(session)
+	return m.GetCollection(session).Insert(data)
+}
+
+func (m *defaultWebhookModel) FindOne(ctx context.Context, shopId int64, id string) (*Webhook, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrInvalidObjectId
+	}
+
+	session, err := m.TakeSession()
+	if err != nil {
+		return nil, err
+	}
+
+	defer m.PutSession(session)
+	var data Webhook
+
+	err = m.GetCollection(session).FindOneNoCache(&data, bson.M{"_id": bson.ObjectIdHex(id), "shop_id": shopId})
+	switch err {
+	case nil:
+		return &data, nil
+	case mongoc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultWebhookModel) FindOneByAddress(ctx context.Context, shopId int64, topic, address string) (*Webhook, error) {
+	session, err := m.TakeSession()
+	if err != nil {
+		return nil, err
+	}
+
+	defer m.PutSession(session)
+	var data Webhook
+
+	err = m.GetCollection(session).FindOneNoCache(&data, bson.M{"shop_id": shopId, "topic": topic, "address": address})
+	switch err {
+	case nil:
+		return &data, nil
+	case mongoc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultWebhookModel) FindList(ctx context.Context, shopId int64, topic string) ([]*Webhook, error) {
+	query := bson.M{"shop_id": shopId}
+	if topic != "" {
+		query["topic"] = topic
+	}
+	return m.findAll(query)
+}
+
+func (m *defaultWebhookModel) FindEnabled(ctx context.Context, shopId int64, topic string) ([]*Webhook, error) {
+	return m.findAll(bson.M{"shop_id": shopId, "topic": topic, "status": STATUS_ENABLED})
+}
+
+func (m *defaultWebhookModel) Update(ctx context.Context, data *Webhook) error {
+	session, err := m.TakeSession()
+	if err != nil {
+		return err
+	}
+
+	defer m.PutSession(session)
+	data.UpdatedAt = time.Now()
+	return m.GetCollection(session).UpdateIdNoCache(data.ID, bson.M{"$set": bson.M{
+		"address":         data.Address,
+		"format":          data.Format,
+		"status":          data.Status,
+		"failure_count":   data.FailureCount,
+		"disabled_reason": data.DisabledReason,
+		"updated_at":      data.UpdatedAt,
+	}})
+}
+
+func (m *defaultWebhookModel) RecordSuccess(ctx context.Context, id bson.ObjectId) error {
+	session, err := m.TakeSession()
+	if err != nil {
+		return err
+	}
+
+	defer m.PutSession(session)
+	return m.GetCollection(session).UpdateIdNoCache(id, bson.M{"$set": bson.M{"failure_count": 0}})
+}
+
+// RecordFailure 连续失败次数加一，返回更新后的记录
+func (m *defaultWebhookModel) RecordFailure(ctx context.Context, id bson.ObjectId) (*Webhook, error) {
+	session, err := m.TakeSession()
+	if err != nil {
+		return nil, err
+	}
+
+	defer m.PutSession(session)
+	collection := m.GetCollection(session)
+	if err = collection.UpdateIdNoCache(id, bson.M{"$inc": bson.M{"failure_count": 1}}); err != nil {
+		return nil, err
+	}
+	var data Webhook
+	err = collection.FindOneIdNoCache(&data, id)
+	switch err {
+	case nil:
+		return &data, nil
+	case mongoc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultWebhookModel) Disable(ctx context.Context, id bson.ObjectId, reason string) error {
+	session, err := m.TakeSession()
+	if err != nil {
+		return err
+	}
+
+	defer m.PutSession(session)
+	return m.GetCollection(session).UpdateIdNoCache(id, bson.M{"$set": bson.M{
+		"status":          STATUS_DISABLED,
+		"disabled_reason": reason,
+		"updated_at":      time.Now(),
+	}})
+}
+
+func (m *defaultWebhookModel) Delete(ctx context.Context, shopId int64, id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrInvalidObjectId
+	}
+
+	session, err := m.TakeSession()
+	if err != nil {
+		return err
+	}
+
+	defer m.PutSession(session)
+	err = m.GetCollection(session).RemoveNoCache(bson.M{"_id": bson.ObjectIdHex(id), "shop_id": shopId})
+	switch err {
+	case nil:
+		return nil
+	case mongoc.ErrNotFound:
+		return ErrNotFound
+	default:
+		return err
+	}
+}
+
+func (m *defaultWebhookModel) findAll(query bson.M) ([]*Webhook, error) {
+	session, err := m.TakeSession()
+	if err != nil {
+		return nil, err
+	}
+
+	defer m.PutSession(session)
+	var data []*Webhook
+	err = m.GetCollection(session).FindAllNoCache(&data, query, func(q mongo.Query) mongo.Query {
+		return q.Sort("created_at")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
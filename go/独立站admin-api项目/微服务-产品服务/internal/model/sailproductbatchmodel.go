@@ -0,0 +1,170 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+)
+
+type (
+	// SailProductBatchModel 按产品id批量读取列表需要的关联数据，每种数据一条 in 查询，结果按产品id分组
+	SailProductBatchModel interface {
+		FindBodyHtml(productIds []int64) (map[int64]string, error)
+		FindVariants(shopId int64, productIds []int64) (map[int64][]SailShopProductVariant, error)
+		FindTags(shopId int64, productIds []int64) (map[int64][]string, error)
+		FindVariantVersions(shopId int64, productIds []int64) (map[int64]int64, error)
+		FindIdsByHandles(shopId int64, handles []string) (map[string]int64, error)
+		FindIdsBySkus(shopId int64, skus []string) (map[string]int64, error)
+	}
+
+	defaultSailProductBatchModel struct {
+		sqlc.CachedConn
+	}
+
+	productBodyHtml struct {
+		ProductId int64  `db:"product_id"`
+		BodyHtml  string `db:"body_html"`
+	}
+
+	productTag struct {
+		ProductId int64  `db:"product_id"`
+		Name      string `db:"name"`
+	}
+
+	variantVersion struct {
+		Id      int64 `db:"id"`
+		Version int64 `db:"version"`
+	}
+
+	productKey struct {
+		ProductId int64  `db:"product_id"`
+		Key       string `db:"key"`
+	}
+)
+
+func NewSailProductBatchModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductBatchModel {
+	return &defaultSailProductBatchModel{
+		CachedConn: sqlc.NewConn(conn, c),
+	}
+}
+
+func (m *defaultSailProductBatchModel) FindBodyHtml(productIds []int64) (map[int64]string, error) {
+	resp := make(map[int64]string, len(productIds))
+	if len(productIds) == 0 {
+		return resp, nil
+	}
+	var rows []productBodyHtml
+	query := fmt.Sprintf("select `product_id`, ifnull(`body_html`, '') as `body_html` from `sail_shop_product_detail` where `product_id` in (%s) ", placeholders(len(productIds)))
+	err := m.QueryRowsNoCache(&rows, query, int64Args(productIds)...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+	default:
+		return nil, err
+	}
+	for _, row := range rows {
+		resp[row.ProductId] = row.BodyHtml
+	}
+	return resp, nil
+}
+
+func (m *defaultSailProductBatchModel) FindVariants(shopId int64, productIds []int64) (map[int64][]SailShopProductVariant, error) {
+	resp := make(map[int64][]SailShopProductVariant, len(productIds))
+	if len(productIds) == 0 {
+		return resp, nil
+	}
+	var rows []SailShopProductVariant
+	query := fmt.Sprintf("select %s from `sail_shop_product_variant` where `shop_id` = ? and `product_id` in (%s) and `is_del` = 0 order by `id` ", sailShopProductVariantRows, placeholders(len(productIds)))
+	err := m.QueryRowsNoCache(&rows, query, append([]interface{}{shopId}, int64Args(productIds)...)...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+	default:
+		return nil, err
+	}
+	for _, row := range rows {
+		resp[row.ProductId] = append(resp[row.ProductId], row)
+	}
+	return resp, nil
+}
+
+func (m *defaultSailProductBatchModel) FindTags(shopId int64, productIds []int64) (map[int64][]string, error) {
+	resp := make(map[int64][]string, len(productIds))
+	if len(productIds) == 0 {
+		return resp, nil
+	}
+	var rows []productTag
+	query := fmt.Sprintf("select tp.`product_id`, t.`name` from `sail_shop_tags_product` tp join `sail_shop_tags` t on t.`id` = tp.`tag_id` "+
+		"where tp.`shop_id` = ? and tp.`product_id` in (%s) and tp.`is_del` = 0 and t.`is_del` = 0 order by tp.`id` ", placeholders(len(productIds)))
+	err := m.QueryRowsNoCache(&rows, query, append([]interface{}{shopId}, int64Args(productIds)...)...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+	default:
+		return nil, err
+	}
+	for _, row := range rows {
+		resp[row.ProductId] = append(resp[row.ProductId], row.Name)
+	}
+	return resp, nil
+}
+
+// FindVariantVersions 返回子商品id到版本号
+func (m *defaultSailProductBatchModel) FindVariantVersions(shopId int64, productIds []int64) (map[int64]int64, error) {
+	resp := make(map[int64]int64)
+	if len(productIds) == 0 {
+		return resp, nil
+	}
+	var rows []variantVersion
+	query := fmt.Sprintf("select `id`, `version` from `sail_shop_product_variant` where `shop_id` = ? and `product_id` in (%s) and `is_del` = 0 ", placeholders(len(productIds)))
+	err := m.QueryRowsNoCache(&rows, query, append([]interface{}{shopId}, int64Args(productIds)...)...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+	default:
+		return nil, err
+	}
+	for _, row := range rows {
+		resp[row.Id] = row.Version
+	}
+	return resp, nil
+}
+
+func (m *defaultSailProductBatchModel) FindIdsByHandles(shopId int64, handles []string) (map[string]int64, error) {
+	query := "select `id` as `product_id`, `handler` as `key` from `sail_shop_product` where `shop_id` = ? and `handler` in (%s) and `is_del` = 0 "
+	return m.findIdsByKeys(query, shopId, handles)
+}
+
+// FindIdsBySkus 同一个 sku 对应多个子商品时取最早的
+func (m *defaultSailProductBatchModel) FindIdsBySkus(shopId int64, skus []string) (map[string]int64, error) {
+	query := "select `product_id`, `sku_code` as `key` from `sail_shop_product_variant` where `shop_id` = ? and `sku_code` in (%s) and `is_del` = 0 order by `id` desc "
+	return m.findIdsByKeys(query, shopId, skus)
+}
+
+func (m *defaultSailProductBatchModel) findIdsByKeys(query string, shopId int64, keys []string) (map[string]int64, error) {
+	resp := make(map[string]int64, len(keys))
+	if len(keys) == 0 {
+		return resp, nil
+	}
+	args := []interface{}{shopId}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	var rows []productKey
+	err := m.QueryRowsNoCache(&rows, fmt.Sprintf(query, placeholders(len(keys))), args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+	default:
+		return nil, err
+	}
+	for _, row := range rows {
+		resp[row.Key] = row.ProductId
+	}
+	return resp, nil
+}
+
+func int64Args(ids []int64) []interface{} {
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return args
+}
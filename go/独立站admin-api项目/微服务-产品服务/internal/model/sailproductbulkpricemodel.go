@@ -0,0 +1,220 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+)
+
+type (
+	// SailProductBulkPriceModel 批量改价。按筛选条件分批读取产品和子商品的价格，
+	// 每批在一个事务里写入，只有价格仍是读取时的值才修改，避免覆盖期间的其他改动
+	SailProductBulkPriceModel interface {
+		Count(shopId int64, filter BulkPriceFilter) (int64, error)
+		FindProductIds(shopId int64, filter BulkPriceFilter, afterId, limit int64) ([]int64, error)
+		FindPrices(shopId int64, productIds []int64) (*[]BulkPriceRow, error)
+		Apply(shopId int64, changes []BulkPriceChange) (applied []BulkPriceChange, err error)
+	}
+
+	defaultSailProductBulkPriceModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	// BulkPriceFilter 条件之间是且的关系
+	BulkPriceFilter struct {
+		ProductIds      []int64
+		CategoryId      int64
+		Tag             string
+		Vendor          string
+		Title           string
+		CreatedAtMin    string
+		CreatedAtMax    string
+		UpdatedAtMin    string
+		UpdatedAtMax    string
+		PublishedStatus string
+	}
+
+	// BulkPriceRow VariantId 为0表示产品本身的价格
+	BulkPriceRow struct {
+		ProductId      int64   `db:"product_id"`
+		VariantId      int64   `db:"variant_id"`
+		Title          string  `db:"title"`
+		Price          float64 `db:"price"`
+		CompareAtPrice float64 `db:"compare_at_price"`
+	}
+
+	BulkPriceChange struct {
+		ProductId         int64
+		VariantId         int64
+		Price             float64
+		CompareAtPrice    float64
+		NewPrice          float64
+		NewCompareAtPrice float64
+	}
+)
+
+func NewSailProductBulkPriceModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductBulkPriceModel {
+	return &defaultSailProductBulkPriceModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_shop_product`",
+	}
+}
+
+func (m *defaultSailProductBulkPriceModel) Count(shopId int64, filter BulkPriceFilter) (int64, error) {
+	var count int64
+	where, args := bulkPriceWhere(shopId, filter)
+	query := fmt.Sprintf("select count(*) from %s where %s ", m.table, where)
+	err := m.QueryRowNoCache(&count, query, args...)
+	return count, err
+}
+
+func (m *defaultSailProductBulkPriceModel) FindProductIds(shopId int64, filter BulkPriceFilter, afterId, limit int64) ([]int64, error) {
+	var ids []int64
+	where, args := bulkPriceWhere(shopId, filter)
+	args = append(args, afterId, limit)
+	query := fmt.Sprintf("select `id` from %s where %s and `id` > ? order by `id` limit ? ", m.table, where)
+	err := m.QueryRowsNoCache(&ids, query, args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return ids, nil
+	default:
+		return nil, err
+	}
+}
+
+// FindPrices 读取产品本身和子商品的价格，按产品、子商品id排序
+func (m *defaultSailProductBulkPriceModel) FindPrices(shopId int64, productIds []int64) (*[]BulkPriceRow, error) {
+	resp := make([]BulkPriceRow, 0)
+	if len(productIds) == 0 {
+		return &resp, nil
+	}
+	in := placeholders(len(productIds))
+	args := []interface{}{shopId}
+	for _, id := range productIds {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+	query := fmt.Sprintf("select `id` as `product_id`, 0 as `variant_id`, `title`, `price`, `compare_at_price` from %s where `shop_id` = ? and `id` in (%s) and `is_del` = 0 "+
+		"union all select `product_id`, `id` as `variant_id`, `title`, `price`, `compare_at_price` from `sail_shop_product_variant` where `shop_id` = ? and `product_id` in (%s) and `is_del` = 0 "+
+		"order by `product_id`, `variant_id` ", m.table, in, in)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return &resp, nil
+	default:
+		return nil, err
+	}
+}
+
+// Apply 在一个事务里写入一批改价，返回实际修改的条目；价格已被其他操作修改的条目跳过
+func (m *defaultSailProductBulkPriceModel) Apply(shopId int64, changes []BulkPriceChange) ([]BulkPriceChange, error) {
+	var applied []BulkPriceChange
+	err := m.Transact(func(session sqlx.Session) error {
+		applied = applied[:0]
+		products := make(map[int64]bool)
+		for _, change := range changes {
+			var query string
+			args := []interface{}{change.NewPrice, change.NewCompareAtPrice, shopId}
+			if change.VariantId == 0 {
+				query = fmt.Sprintf("update %s set `price` = ?, `compare_at_price` = ?, `version` = `version` + 1 where `shop_id` = ? and `id` = ? and `price` = ? and `compare_at_price` = ? and `is_del` = 0", m.table)
+				args = append(args, change.ProductId)
+			} else {
+				query = "update `sail_shop_product_variant` set `price` = ?, `compare_at_price` = ?, `version` = `version` + 1 where `shop_id` = ? and `product_id` = ? and `id` = ? and `price` = ? and `compare_at_price` = ? and `is_del` = 0"
+				args = append(args, change.ProductId, change.VariantId)
+			}
+			args = append(args, change.Price, change.CompareAtPrice)
+			stmt, err := session.Prepare(query)
+			if err != nil {
+				logx.Error("批量改价失败：", err)
+				return err
+			}
+			result, err := stmt.Exec(args...)
+			stmt.Close()
+			if err != nil {
+				logx.Error("批量改价失败：", err)
+				return err
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if affected != 0 {
+				applied = append(applied, change)
+				products[change.ProductId] = true
+			}
+		}
+		for productId := range products {
+			if err := StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range applied {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, change.ProductId))
+	}
+	return applied, nil
+}
+
+func bulkPriceWhere(shopId int64, filter BulkPriceFilter) (string, []interface{}) {
+	conditions := []string{"`shop_id` = ?", "`is_del` = 0"}
+	args := []interface{}{shopId}
+	if len(filter.ProductIds) != 0 {
+		conditions = append(conditions, fmt.Sprintf("`id` in (%s)", placeholders(len(filter.ProductIds))))
+		for _, id := range filter.ProductIds {
+			args = append(args, id)
+		}
+	}
+	if filter.CategoryId != 0 {
+		conditions = append(conditions, "`id` in (select `product_id` from `sail_shop_category_product` where `shop_id` = ? and `category_id` = ?)")
+		args = append(args, shopId, filter.CategoryId)
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, "`id` in (select tp.`product_id` from `sail_shop_tags_product` tp join `sail_shop_tags` t on t.`id` = tp.`tag_id` where t.`shop_id` = ? and t.`name` = ?)")
+		args = append(args, shopId, filter.Tag)
+	}
+	if filter.Vendor != "" {
+		conditions = append(conditions, "`vendor` = ?")
+		args = append(args, filter.Vendor)
+	}
+	if filter.Title != "" {
+		conditions = append(conditions, "`title` like ?")
+		args = append(args, filter.Title+"%")
+	}
+	if filter.CreatedAtMin != "" {
+		conditions = append(conditions, "`created_at` >= ?")
+		args = append(args, filter.CreatedAtMin)
+	}
+	if filter.CreatedAtMax != "" {
+		conditions = append(conditions, "`created_at` <= ?")
+		args = append(args, filter.CreatedAtMax)
+	}
+	if filter.UpdatedAtMin != "" {
+		conditions = append(conditions, "`updated_at` >= ?")
+		args = append(args, filter.UpdatedAtMin)
+	}
+	if filter.UpdatedAtMax != "" {
+		conditions = append(conditions, "`updated_at` <= ?")
+		args = append(args, filter.UpdatedAtMax)
+	}
+	switch filter.PublishedStatus {
+	case "published":
+		conditions = append(conditions, "`status` = 1")
+	case "unpublished":
+		conditions = append(conditions, "`status` = 2")
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+func (f BulkPriceFilter) Empty() bool {
+	return len(f.ProductIds) == 0 && f.CategoryId == 0 && f.Tag == "" && f.Vendor == "" && f.Title == "" &&
+		f.CreatedAtMin == "" && f.CreatedAtMax == "" && f.UpdatedAtMin == "" && f.UpdatedAtMax == "" && f.PublishedStatus == ""
+}
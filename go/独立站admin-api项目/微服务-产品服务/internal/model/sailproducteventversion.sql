@@ -0,0 +1,6 @@
+CREATE TABLE `sail_product_event_version` (
+  `product_id` bigint(20) NOT NULL COMMENT '产品ID',
+  `version` bigint(20) NOT NULL DEFAULT '0' COMMENT '当前最新的变更版本号',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品变更版本号';
@@ -0,0 +1,22 @@
+CREATE TABLE `sail_product_image_job` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `product_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '产品ID',
+  `url` varchar(1024) NOT NULL DEFAULT '' COMMENT '原始图片地址',
+  `is_default` tinyint(3) NOT NULL DEFAULT '0' COMMENT '是否设为产品主图',
+  `variant_ids` varchar(3000) NOT NULL DEFAULT '' COMMENT '需要关联该图片的子商品id集合，按逗号拼接',
+  `comment_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '评论图片所属的评论ID，不为0时图片只关联到评论',
+  `image_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '上传成功后的图片id',
+  `status` tinyint(3) NOT NULL DEFAULT '1' COMMENT '任务状态(1:待处理;2:处理中;3:成功;4:死信)',
+  `attempts` int(11) NOT NULL DEFAULT '0' COMMENT '已尝试次数',
+  `last_error` varchar(1024) NOT NULL DEFAULT '' COMMENT '最近一次失败原因',
+  `locked_by` varchar(128) NOT NULL DEFAULT '' COMMENT '领取任务的实例和领取批次',
+  `locked_at` datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '领取时间',
+  `next_run_at` datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '下次执行时间',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_status_next_run` (`status`,`next_run_at`),
+  KEY `idx_locked_by` (`locked_by`,`status`),
+  KEY `idx_shop_product` (`shop_id`,`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品图片导入任务表';
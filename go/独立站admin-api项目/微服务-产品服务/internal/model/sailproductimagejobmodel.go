@@ -0,0 +1,254 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+)
+
+var (
+	sailProductImageJobFieldNames = builderx.RawFieldNames(&SailProductImageJob{})
+	sailProductImageJobRows       = strings.Join(sailProductImageJobFieldNames, ",")
+)
+
+type (
+	SailProductImageJobModel interface {
+		Insert(data SailProductImageJob) (sql.Result, error)
+		InsertList(jobs []SailProductImageJob) error
+		Claim(owner string, limit int64, lease time.Duration) (*[]SailProductImageJob, error)
+		SetImageId(id, imageId int64) error
+		MarkSucceeded(id int64) error
+		MarkFailed(id int64, lastError string, nextRunAt time.Time) error
+		MarkDead(id int64, lastError string) error
+		FindListByProductId(shopId, productId int64) (*[]SailProductImageJob, error)
+	}
+
+	defaultSailProductImageJobModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	SailProductImageJob struct {
+		Id         int64     `db:"id"`
+		ShopId     int64     `db:"shop_id"`     // 商店唯一ID
+		ProductId  int64     `db:"product_id"`  // 产品ID
+		Url        string    `db:"url"`         // 原始图片地址
+		IsDefault  int64     `db:"is_default"`  // 是否设为产品主图
+		VariantIds string    `db:"variant_ids"` // 需要关联该图片的子商品id集合，按逗号拼接
+		CommentId  int64     `db:"comment_id"`  // 评论图片所属的评论ID，不为0时图片只关联到评论
+		ImageId    int64     `db:"image_id"`    // 上传成功后的图片id
+		Status     int64     `db:"status"`      // 任务状态(1:待处理;2:处理中;3:成功;4:死信)
+		Attempts   int64     `db:"attempts"`    // 已尝试次数
+		LastError  string    `db:"last_error"`  // 最近一次失败原因
+		LockedBy   string    `db:"locked_by"`   // 领取任务的实例和领取批次
+		LockedAt   time.Time `db:"locked_at"`   // 领取时间
+		NextRunAt  time.Time `db:"next_run_at"` // 下次执行时间
+		CreatedAt  time.Time `db:"created_at"`  // 创建时间
+		UpdatedAt  time.Time `db:"updated_at"`  // 更新时间
+	}
+)
+
+func NewSailProductImageJobModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductImageJobModel {
+	return &defaultSailProductImageJobModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_image_job`",
+	}
+}
+
+func (m *defaultSailProductImageJobModel) Insert(data SailProductImageJob) (sql.Result, error) {
+	if data.ShopId == 0 || data.ProductId == 0 || data.Url == "" {
+		return nil, errors.New("image job need shop_id, product_id and url")
+	}
+	var result sql.Result
+	err := m.Transact(func(session sqlx.Session) error {
+		res, err := StmtInsertImageJob(session, data)
+		if err != nil {
+			return err
+		}
+		result = res
+		return nil
+	})
+	return result, err
+}
+
+// InsertList 在一个事务中写入多个任务，全部成功或全部失败
+func (m *defaultSailProductImageJobModel) InsertList(jobs []SailProductImageJob) error {
+	for _, job := range jobs {
+		if job.ShopId == 0 || job.ProductId == 0 || job.Url == "" {
+			return errors.New("image job need shop_id, product_id and url")
+		}
+	}
+	return m.Transact(func(session sqlx.Session) error {
+		return StmtInsertImageJobs(session, jobs)
+	})
+}
+
+// Claim 领取到期的待处理任务，同时把租约过期的处理中任务放回队列，
+// 多个实例同时领取时依靠 update ... limit 的行锁保证同一任务只会被一个实例拿到。
+// 每次领取的 locked_by 带上本次的时间戳，只返回本次领取的任务，上一轮还在处理中的任务不会再次返回
+func (m *defaultSailProductImageJobModel) Claim(owner string, limit int64, lease time.Duration) (*[]SailProductImageJob, error) {
+	now := time.Now()
+	token := fmt.Sprintf("%s:%d", owner, now.UnixNano())
+	reclaimQuery := fmt.Sprintf("update %s set `status` = ?, `locked_by` = '' where `status` = ? and `locked_at` < ? ", m.table)
+	if _, err := m.ExecNoCache(reclaimQuery, IMAGE_JOB_STATUS_PENDING, IMAGE_JOB_STATUS_PROCESSING, now.Add(-lease)); err != nil {
+		logx.Error("回收超时图片任务失败：", err)
+	}
+
+	claimQuery := fmt.Sprintf("update %s set `status` = ?, `locked_by` = ?, `locked_at` = ?, `attempts` = `attempts` + 1 where `status` = ? and `next_run_at` <= ? order by `id` limit ? ", m.table)
+	result, err := m.ExecNoCache(claimQuery, IMAGE_JOB_STATUS_PROCESSING, token, now, IMAGE_JOB_STATUS_PENDING, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, ErrNotFound
+	}
+
+	var resp []SailProductImageJob
+	query := fmt.Sprintf("select %s from %s where `locked_by` = ? and `status` = ? order by `id` ", sailProductImageJobRows, m.table)
+	err = m.QueryRowsNoCache(&resp, query, token, IMAGE_JOB_STATUS_PROCESSING)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultSailProductImageJobModel) SetImageId(id, imageId int64) error {
+	query := fmt.Sprintf("update %s set `image_id` = ? where `id` = ? ", m.table)
+	_, err := m.ExecNoCache(query, imageId, id)
+	return err
+}
+
+func (m *defaultSailProductImageJobModel) MarkSucceeded(id int64) error {
+	query := fmt.Sprintf("update %s set `status` = ?, `locked_by` = '', `last_error` = '' where `id` = ? ", m.table)
+	_, err := m.ExecNoCache(query, IMAGE_JOB_STATUS_SUCCEEDED, id)
+	return err
+}
+
+func (m *defaultSailProductImageJobModel) MarkFailed(id int64, lastError string, nextRunAt time.Time) error {
+	query := fmt.Sprintf("update %s set `status` = ?, `locked_by` = '', `last_error` = ?, `next_run_at` = ? where `id` = ? ", m.table)
+	_, err := m.ExecNoCache(query, IMAGE_JOB_STATUS_PENDING, SubString(lastError, 0, 1024), nextRunAt, id)
+	return err
+}
+
+func (m *defaultSailProductImageJobModel) MarkDead(id int64, lastError string) error {
+	query := fmt.Sprintf("update %s set `status` = ?, `locked_by` = '', `last_error` = ? where `id` = ? ", m.table)
+	_, err := m.ExecNoCache(query, IMAGE_JOB_STATUS_DEAD, SubString(lastError, 0, 1024), id)
+	return err
+}
+
+func (m *defaultSailProductImageJobModel) FindListByProductId(shopId, productId int64) (*[]SailProductImageJob, error) {
+	var resp []SailProductImageJob
+	query := fmt.Sprintf("select %s from %s where `shop_id` = ? and `product_id` = ? order by `id` ", sailProductImageJobRows, m.table)
+	err := m.QueryRowsNoCache(&resp, query, shopId, productId)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// BuildImageJobs 把新增/更新商品时需要上传的图片整理成任务，同一个url只上传一次，
+// 第一张图片作为主图，子商品按url关联到对应的任务上
+func BuildImageJobs(shopId, productId int64, respImageData *RespImageData) []SailProductImageJob {
+	jobs := make([]SailProductImageJob, 0)
+	if respImageData == nil {
+		return jobs
+	}
+	index := map[string]int{}
+	add := func(url string) int {
+		if k, ok := index[url]; ok {
+			return k
+		}
+		jobs = append(jobs, SailProductImageJob{
+			ShopId:    shopId,
+			ProductId: productId,
+			Url:       url,
+			Status:    IMAGE_JOB_STATUS_PENDING,
+		})
+		index[url] = len(jobs) - 1
+		return len(jobs) - 1
+	}
+	for _, image := range respImageData.Images {
+		if image.Url != "" {
+			add(image.Url)
+		}
+	}
+	for _, variant := range respImageData.Variants {
+		if variant.Url == "" {
+			continue
+		}
+		k := add(variant.Url)
+		variantId := strconv.Itoa(int(variant.VariantId))
+		if jobs[k].VariantIds == "" {
+			jobs[k].VariantIds = variantId
+		} else {
+			jobs[k].VariantIds += "," + variantId
+		}
+	}
+	if len(jobs) != 0 {
+		jobs[0].IsDefault = 1
+	}
+	return jobs
+}
+
+func StmtInsertImageJob(session sqlx.Session, data SailProductImageJob) (sql.Result, error) {
+	if data.Status == 0 {
+		data.Status = IMAGE_JOB_STATUS_PENDING
+	}
+	if data.NextRunAt.IsZero() {
+		data.NextRunAt = time.Now()
+	}
+	fields := []string{"`shop_id`", "`product_id`", "`url`", "`is_default`", "`variant_ids`", "`comment_id`", "`image_id`", "`status`", "`next_run_at`"}
+	args := make([]interface{}, 0)
+	args = append(args, data.ShopId, data.ProductId, data.Url, data.IsDefault, data.VariantIds, data.CommentId, data.ImageId, data.Status, data.NextRunAt)
+	result, err := StmtInsert(session, "sail_product_image_job", fields, args)
+	if err != nil {
+		logx.Error("新增图片任务失败：", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func StmtInsertImageJobs(session sqlx.Session, jobs []SailProductImageJob) error {
+	for _, job := range jobs {
+		if _, err := StmtInsertImageJob(session, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ParseVariantIds(variantIds string) []int64 {
+	ids := make([]int64, 0)
+	for _, s := range strings.Split(variantIds, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+const (
+	IMAGE_JOB_STATUS_PENDING    = 1
+	IMAGE_JOB_STATUS_PROCESSING = 2
+	IMAGE_JOB_STATUS_SUCCEEDED  = 3
+	IMAGE_JOB_STATUS_DEAD       = 4
+)
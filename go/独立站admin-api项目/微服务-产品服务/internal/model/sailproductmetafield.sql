@@ -0,0 +1,15 @@
+CREATE TABLE `sail_product_metafield` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `owner_type` varchar(16) NOT NULL DEFAULT '' COMMENT '所属对象类型(product;variant;category)',
+  `owner_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '所属对象ID',
+  `namespace` varchar(64) NOT NULL DEFAULT '' COMMENT '命名空间',
+  `key` varchar(64) NOT NULL DEFAULT '' COMMENT '字段名',
+  `type` varchar(32) NOT NULL DEFAULT '' COMMENT '值类型(string;integer;decimal;boolean;json;date;url;product_reference)',
+  `value` text NOT NULL COMMENT '按类型校验并统一格式后的值',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_owner_namespace_key` (`shop_id`,`owner_type`,`owner_id`,`namespace`,`key`),
+  KEY `idx_filter` (`shop_id`,`owner_type`,`namespace`,`key`,`value`(191))
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品、子商品、分类的自定义字段';
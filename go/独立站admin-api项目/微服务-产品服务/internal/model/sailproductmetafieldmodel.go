@@ -0,0 +1,225 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+)
+
+var (
+	sailProductMetafieldFieldNames = builderx.RawFieldNames(&SailProductMetafield{})
+	sailProductMetafieldRows       = strings.Join(sailProductMetafieldFieldNames, ",")
+)
+
+type (
+	SailProductMetafieldModel interface {
+		FindOne(shopId, id int64) (*SailProductMetafield, error)
+		FindList(shopId int64, ownerType string, ownerIds []int64, namespace string) (*[]SailProductMetafield, error)
+		Set(shopId int64, ownerType string, ownerId int64, data []SailProductMetafield) error
+		Delete(shopId, id int64) error
+		OwnerExists(shopId int64, ownerType string, ownerId int64) (bool, error)
+		FindType(shopId int64, ownerType, namespace, key string) (string, error)
+	}
+
+	defaultSailProductMetafieldModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	SailProductMetafield struct {
+		Id        int64     `db:"id"`
+		ShopId    int64     `db:"shop_id"`    // 商店唯一ID
+		OwnerType string    `db:"owner_type"` // 所属对象类型(product;variant;category)
+		OwnerId   int64     `db:"owner_id"`   // 所属对象ID
+		Namespace string    `db:"namespace"`  // 命名空间
+		Key       string    `db:"key"`        // 字段名
+		Type      string    `db:"type"`       // 值类型
+		Value     string    `db:"value"`      // 按类型校验并统一格式后的值
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+
+	// MetafieldFilter 按产品自定义字段的值筛选
+	MetafieldFilter struct {
+		Namespace string
+		Key       string
+		Value     string
+	}
+)
+
+func NewSailProductMetafieldModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductMetafieldModel {
+	return &defaultSailProductMetafieldModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_metafield`",
+	}
+}
+
+func (m *defaultSailProductMetafieldModel) FindOne(shopId, id int64) (*SailProductMetafield, error) {
+	var resp SailProductMetafield
+	query := fmt.Sprintf("select %s from %s where `shop_id` = ? and `id` = ? limit 1", sailProductMetafieldRows, m.table)
+	err := m.QueryRowNoCache(&resp, query, shopId, id)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// FindList 批量查询多个对象的自定义字段，namespace 为空时不限制
+func (m *defaultSailProductMetafieldModel) FindList(shopId int64, ownerType string, ownerIds []int64, namespace string) (*[]SailProductMetafield, error) {
+	resp := make([]SailProductMetafield, 0)
+	if len(ownerIds) == 0 {
+		return &resp, nil
+	}
+	args := []interface{}{shopId, ownerType}
+	for _, id := range ownerIds {
+		args = append(args, id)
+	}
+	where := fmt.Sprintf("`shop_id` = ? and `owner_type` = ? and `owner_id` in (%s)", placeholders(len(ownerIds)))
+	if namespace != "" {
+		where += " and `namespace` = ?"
+		args = append(args, namespace)
+	}
+	query := fmt.Sprintf("select %s from %s where %s order by `owner_id`, `namespace`, `key` ", sailProductMetafieldRows, m.table, where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return &resp, nil
+	default:
+		return nil, err
+	}
+}
+
+// Set 按 namespace+key 新增或覆盖一个对象的自定义字段，产品和子商品的修改会记录变更事件
+func (m *defaultSailProductMetafieldModel) Set(shopId int64, ownerType string, ownerId int64, data []SailProductMetafield) error {
+	query := fmt.Sprintf("insert into %s (`shop_id`, `owner_type`, `owner_id`, `namespace`, `key`, `type`, `value`) values (?, ?, ?, ?, ?, ?, ?) "+
+		"on duplicate key update `type` = values(`type`), `value` = values(`value`)", m.table)
+	var productId int64
+	err := m.Transact(func(session sqlx.Session) error {
+		for _, item := range data {
+			if err := stmtExec(session, query, shopId, ownerType, ownerId, item.Namespace, item.Key, item.Type, item.Value); err != nil {
+				logx.Error("保存自定义字段失败：", err)
+				return err
+			}
+		}
+		var err error
+		productId, err = stmtOwnerProductId(session, shopId, ownerType, ownerId)
+		if err != nil || productId == 0 {
+			return err
+		}
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return err
+	}
+	if productId != 0 {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	}
+	return nil
+}
+
+func (m *defaultSailProductMetafieldModel) Delete(shopId, id int64) error {
+	var productId int64
+	err := m.Transact(func(session sqlx.Session) error {
+		var data SailProductMetafield
+		err := stmtQueryRow(session, &data, fmt.Sprintf("select %s from %s where `shop_id` = ? and `id` = ? for update", sailProductMetafieldRows, m.table), shopId, id)
+		switch err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return ErrNotFound
+		default:
+			return err
+		}
+		if err = stmtExec(session, fmt.Sprintf("delete from %s where `id` = ? ", m.table), id); err != nil {
+			return err
+		}
+		productId, err = stmtOwnerProductId(session, shopId, data.OwnerType, data.OwnerId)
+		if err != nil || productId == 0 {
+			return err
+		}
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return err
+	}
+	if productId != 0 {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	}
+	return nil
+}
+
+func (m *defaultSailProductMetafieldModel) OwnerExists(shopId int64, ownerType string, ownerId int64) (bool, error) {
+	return ownerExists(m.CachedConn, shopId, ownerType, ownerId)
+}
+
+// ownerExists 检查产品、子商品或分类是否存在且未删除
+func ownerExists(conn sqlc.CachedConn, shopId int64, ownerType string, ownerId int64) (bool, error) {
+	var table string
+	switch ownerType {
+	case metafield.OwnerProduct:
+		table = "`sail_shop_product`"
+	case metafield.OwnerVariant:
+		table = "`sail_shop_product_variant`"
+	case metafield.OwnerCategory:
+		table = "`sail_shop_category`"
+	default:
+		return false, metafield.ErrOwnerInvalid
+	}
+	var count int64
+	query := fmt.Sprintf("select count(*) from %s where `shop_id` = ? and `id` = ? and `is_del` = 0 ", table)
+	err := conn.QueryRowNoCache(&count, query, shopId, ownerId)
+	return count > 0, err
+}
+
+// FindType 返回同名字段最近一次保存的类型，按值筛选前用它统一筛选值的格式
+func (m *defaultSailProductMetafieldModel) FindType(shopId int64, ownerType, namespace, key string) (string, error) {
+	var fieldType string
+	query := fmt.Sprintf("select `type` from %s where `shop_id` = ? and `owner_type` = ? and `namespace` = ? and `key` = ? order by `updated_at` desc limit 1", m.table)
+	err := m.QueryRowNoCache(&fieldType, query, shopId, ownerType, namespace, key)
+	switch err {
+	case nil:
+		return fieldType, nil
+	case sqlc.ErrNotFound:
+		return "", ErrNotFound
+	default:
+		return "", err
+	}
+}
+
+// stmtOwnerProductId 返回对象所属的产品，分类返回0
+func stmtOwnerProductId(session sqlx.Session, shopId int64, ownerType string, ownerId int64) (int64, error) {
+	switch ownerType {
+	case metafield.OwnerProduct:
+		return ownerId, nil
+	case metafield.OwnerVariant:
+		var productId int64
+		err := stmtQueryRow(session, &productId, "select `product_id` from `sail_shop_product_variant` where `shop_id` = ? and `id` = ? ", shopId, ownerId)
+		if err == sqlc.ErrNotFound {
+			return 0, nil
+		}
+		return productId, err
+	default:
+		return 0, nil
+	}
+}
+
+// StmtDeleteProductMetafields 删除产品及其子商品的自定义字段
+func StmtDeleteProductMetafields(session sqlx.Session, shopId, productId int64) error {
+	query := "delete from `sail_product_metafield` where `shop_id` = ? and ((`owner_type` = ? and `owner_id` = ?) or " +
+		"(`owner_type` = ? and `owner_id` in (select `id` from `sail_shop_product_variant` where `shop_id` = ? and `product_id` = ?)))"
+	err := stmtExec(session, query, shopId, metafield.OwnerProduct, productId, metafield.OwnerVariant, shopId, productId)
+	if err != nil {
+		logx.Error("删除产品自定义字段失败：", err)
+	}
+	return err
+}
@@ -0,0 +1,24 @@
+CREATE TABLE `sail_product_outbox` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `product_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '产品ID',
+  `event` varchar(32) NOT NULL DEFAULT '' COMMENT '变更类型(add;update;delete;inventory)',
+  `version` bigint(20) NOT NULL DEFAULT '0' COMMENT '产品变更版本号，同一产品单调递增',
+  `status` tinyint(3) NOT NULL DEFAULT '1' COMMENT '投递状态(1:待投递;2:已投递)',
+  `seq` bigint(20) NOT NULL DEFAULT '0' COMMENT '提交顺序号，事件提交后由 Hub 分配，0表示未分配',
+  `published_at` datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '投递时间',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_status_id` (`status`,`id`),
+  KEY `idx_product_version` (`product_id`,`version`),
+  KEY `idx_published_at` (`published_at`),
+  KEY `idx_seq` (`seq`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品变更事件发件箱';
+
+CREATE TABLE `sail_product_outbox_seq` (
+  `id` tinyint(3) unsigned NOT NULL,
+  `seq` bigint(20) NOT NULL DEFAULT '0' COMMENT '已分配的最大提交顺序号',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品变更事件提交顺序号';
+
+INSERT INTO `sail_product_outbox_seq` (`id`, `seq`) VALUES (1, 0);
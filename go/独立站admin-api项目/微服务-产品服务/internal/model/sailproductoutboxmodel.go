@@ -0,0 +1,223 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+)
+
+var (
+	sailProductOutboxFieldNames = builderx.RawFieldNames(&SailProductOutbox{})
+	sailProductOutboxRows       = strings.Join(sailProductOutboxFieldNames, ",")
+)
+
+type (
+	SailProductOutboxModel interface {
+		FindPending(limit int64) (*[]SailProductOutbox, error)
+		MarkPublished(ids []int64) error
+		DeletePublishedBefore(before time.Time, limit int64) (int64, error)
+		Sequence(limit int64) (int64, error)
+		FindAfter(afterSeq, maxSeq, shopId, limit int64) (*[]SailProductOutbox, error)
+		FindSeqRange() (minSeq, maxSeq int64, err error)
+	}
+
+	defaultSailProductOutboxModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	SailProductOutbox struct {
+		Id          int64     `db:"id"`
+		ShopId      int64     `db:"shop_id"`      // 商店唯一ID
+		ProductId   int64     `db:"product_id"`   // 产品ID
+		Event       string    `db:"event"`        // 变更类型(add;update;delete;inventory)
+		Version     int64     `db:"version"`      // 产品变更版本号，同一产品单调递增
+		Status      int64     `db:"status"`       // 投递状态(1:待投递;2:已投递)
+		Seq         int64     `db:"seq"`          // 提交顺序号，事件提交后由 Hub 分配，0表示未分配
+		PublishedAt time.Time `db:"published_at"` // 投递时间
+		CreatedAt   time.Time `db:"created_at"`   // 创建时间
+	}
+)
+
+func NewSailProductOutboxModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductOutboxModel {
+	return &defaultSailProductOutboxModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_outbox`",
+	}
+}
+
+// FindPending 按id顺序取出待投递的事件，同一产品的事件id顺序与版本号顺序一致
+func (m *defaultSailProductOutboxModel) FindPending(limit int64) (*[]SailProductOutbox, error) {
+	var resp []SailProductOutbox
+	query := fmt.Sprintf("select %s from %s where `status` = ? order by `id` limit ? ", sailProductOutboxRows, m.table)
+	err := m.QueryRowsNoCache(&resp, query, OUTBOX_STATUS_PENDING, limit)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultSailProductOutboxModel) MarkPublished(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := make([]string, 0, len(ids))
+	args := make([]interface{}, 0, len(ids)+2)
+	args = append(args, OUTBOX_STATUS_PUBLISHED, time.Now())
+	for _, id := range ids {
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+	query := fmt.Sprintf("update %s set `status` = ?, `published_at` = ? where `id` in (%s) ", m.table, strings.Join(placeholders, ","))
+	_, err := m.ExecNoCache(query, args...)
+	return err
+}
+
+func (m *defaultSailProductOutboxModel) DeletePublishedBefore(before time.Time, limit int64) (int64, error) {
+	query := fmt.Sprintf("delete from %s where `status` = ? and `published_at` < ? limit ? ", m.table)
+	result, err := m.ExecNoCache(query, OUTBOX_STATUS_PUBLISHED, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Sequence 给已提交但还没有顺序号的事件按id顺序分配连续的顺序号，返回分配后的最大顺序号。
+// 自增id在事务提交前就已分配，后分配的id可能先提交，按id推进会漏掉提交较晚的事件；
+// 顺序号在事件可见之后才分配，并且和计数行在同一个事务里提交，已分配的顺序号总是从1开始连续，
+// 读取方按顺序号推进不会遗漏。多个实例通过计数行的行锁排队分配
+func (m *defaultSailProductOutboxModel) Sequence(limit int64) (int64, error) {
+	var seq int64
+	err := m.Transact(func(session sqlx.Session) error {
+		if err := stmtQueryRow(session, &seq, "select `seq` from `sail_product_outbox_seq` where `id` = 1 for update"); err != nil {
+			return err
+		}
+		// 普通读取，不加锁，未提交的事件不可见也不会阻塞
+		var ids []int64
+		query := fmt.Sprintf("select `id` from %s where `seq` = 0 order by `id` limit ? ", m.table)
+		switch err := stmtQueryRows(session, &ids, query, limit); err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return nil
+		default:
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		cases := make([]string, 0, len(ids))
+		args := make([]interface{}, 0, len(ids)*3)
+		for _, id := range ids {
+			seq++
+			cases = append(cases, "when ? then ?")
+			args = append(args, id, seq)
+		}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		query = fmt.Sprintf("update %s set `seq` = case `id` %s end where `seq` = 0 and `id` in (%s) ", m.table, strings.Join(cases, " "), placeholders(len(ids)))
+		if err := stmtExec(session, query, args...); err != nil {
+			return err
+		}
+		return stmtExec(session, "update `sail_product_outbox_seq` set `seq` = ? where `id` = 1 ", seq)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// FindAfter 按顺序号取出 (afterSeq, maxSeq] 之间的事件，maxSeq 为0时不限制上界，shopId 为0时不限制店铺
+func (m *defaultSailProductOutboxModel) FindAfter(afterSeq, maxSeq, shopId, limit int64) (*[]SailProductOutbox, error) {
+	var resp []SailProductOutbox
+	where := "`seq` > ?"
+	args := []interface{}{afterSeq}
+	if maxSeq != 0 {
+		where += " and `seq` <= ?"
+		args = append(args, maxSeq)
+	}
+	if shopId != 0 {
+		where += " and `shop_id` = ?"
+		args = append(args, shopId)
+	}
+	args = append(args, limit)
+	query := fmt.Sprintf("select %s from %s where %s order by `seq` limit ? ", sailProductOutboxRows, m.table, where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// FindSeqRange 返回已分配顺序号的事件中最小和最大的顺序号
+func (m *defaultSailProductOutboxModel) FindSeqRange() (minSeq, maxSeq int64, err error) {
+	var resp struct {
+		MinSeq int64 `db:"min_seq"`
+		MaxSeq int64 `db:"max_seq"`
+	}
+	query := fmt.Sprintf("select ifnull(min(`seq`), 0) as min_seq, ifnull(max(`seq`), 0) as max_seq from %s where `seq` > 0 ", m.table)
+	err = m.QueryRowNoCache(&resp, query)
+	switch err {
+	case nil:
+		return resp.MinSeq, resp.MaxSeq, nil
+	case sqlc.ErrNotFound:
+		return 0, 0, nil
+	default:
+		return 0, 0, err
+	}
+}
+
+// StmtInsertProductEvent 在写产品数据的事务里记录一条变更事件，由 OutboxRelay 投递到es同步队列；
+// 版本号计数行在事务提交前一直持有行锁，同一产品的并发写入会在这里排队，保证版本号和事件id同序递增
+func StmtInsertProductEvent(session sqlx.Session, shopId, productId int64, event string) error {
+	versionQuery := "insert into `sail_product_event_version` (`product_id`, `version`) values (?, 1) on duplicate key update `version` = `version` + 1"
+	stmt, err := session.Prepare(versionQuery)
+	if err != nil {
+		logx.Error("记录产品变更事件失败：", err)
+		return err
+	}
+	defer stmt.Close()
+	if _, err = stmt.Exec(productId); err != nil {
+		logx.Error("记录产品变更事件失败：", err)
+		return err
+	}
+
+	var version int64
+	stmtVersion, err := session.Prepare("select `version` from `sail_product_event_version` where `product_id` = ? ")
+	if err != nil {
+		logx.Error("记录产品变更事件失败：", err)
+		return err
+	}
+	defer stmtVersion.Close()
+	if err = stmtVersion.QueryRow(&version, productId); err != nil {
+		logx.Error("记录产品变更事件失败：", err)
+		return err
+	}
+
+	fields := []string{"`shop_id`", "`product_id`", "`event`", "`version`", "`status`"}
+	args := []interface{}{shopId, productId, event, version, OUTBOX_STATUS_PENDING}
+	if _, err = StmtInsert(session, "`sail_product_outbox`", fields, args); err != nil {
+		logx.Error("记录产品变更事件失败：", err)
+		return err
+	}
+	return nil
+}
+
+const (
+	OUTBOX_STATUS_PENDING   = 1
+	OUTBOX_STATUS_PUBLISHED = 2
+)
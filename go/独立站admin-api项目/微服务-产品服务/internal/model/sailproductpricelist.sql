@@ -0,0 +1,29 @@
+CREATE TABLE `sail_product_price_list` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `market` varchar(32) NOT NULL DEFAULT '' COMMENT '市场标识，例如 EU、UK',
+  `currency` char(3) NOT NULL DEFAULT '' COMMENT '币种，ISO 4217',
+  `rate` decimal(18,8) NOT NULL DEFAULT '1.00000000' COMMENT '没有固定价格时从店铺币种换算的汇率',
+  `rounding` varchar(16) NOT NULL DEFAULT '' COMMENT '换算后的取整规则(空:保留两位小数;0.99;0.95;0.00)',
+  `status` tinyint(3) NOT NULL DEFAULT '1' COMMENT '状态(1:启用;2:停用)',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_shop_market` (`shop_id`,`market`),
+  KEY `idx_shop_currency` (`shop_id`,`currency`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品多币种价格表';
+
+CREATE TABLE `sail_product_price_list_item` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `price_list_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '价格表ID',
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `product_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '产品ID',
+  `variant_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '子商品ID，0表示产品本身的价格',
+  `price` decimal(12,2) NOT NULL DEFAULT '0.00' COMMENT '固定价格',
+  `compare_at_price` decimal(12,2) NOT NULL DEFAULT '0.00' COMMENT '固定对比价格，0表示按汇率换算',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_list_product_variant` (`price_list_id`,`product_id`,`variant_id`),
+  KEY `idx_shop_product` (`shop_id`,`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品多币种固定价格';
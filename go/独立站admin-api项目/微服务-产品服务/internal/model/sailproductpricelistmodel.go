@@ -0,0 +1,196 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+)
+
+var (
+	sailProductPriceListFieldNames     = builderx.RawFieldNames(&SailProductPriceList{})
+	sailProductPriceListRows           = strings.Join(sailProductPriceListFieldNames, ",")
+	sailProductPriceListItemFieldNames = builderx.RawFieldNames(&SailProductPriceListItem{})
+	sailProductPriceListItemRows       = strings.Join(sailProductPriceListItemFieldNames, ",")
+)
+
+type (
+	// SailProductPriceListModel 价格表和它的固定价格。价格表按市场区分，同一币种可以有多个市场
+	SailProductPriceListModel interface {
+		Insert(data SailProductPriceList) (sql.Result, error)
+		FindOne(shopId, id int64) (*SailProductPriceList, error)
+		FindByMarket(shopId int64, market string) (*SailProductPriceList, error)
+		FindByCurrency(shopId int64, currency string) (*SailProductPriceList, error)
+		FindList(shopId int64) (*[]SailProductPriceList, error)
+		Update(data SailProductPriceList) error
+		Delete(shopId, id int64) error
+		FindItems(priceListId int64, productIds []int64) (*[]SailProductPriceListItem, error)
+		SetItems(priceListId int64, items []SailProductPriceListItem) error
+	}
+
+	defaultSailProductPriceListModel struct {
+		sqlc.CachedConn
+		table     string
+		itemTable string
+	}
+
+	SailProductPriceList struct {
+		Id        int64     `db:"id"`
+		ShopId    int64     `db:"shop_id"`  // 商店唯一ID
+		Market    string    `db:"market"`   // 市场标识，例如 EU、UK
+		Currency  string    `db:"currency"` // 币种，ISO 4217
+		Rate      float64   `db:"rate"`     // 没有固定价格时从店铺币种换算的汇率
+		Rounding  string    `db:"rounding"` // 换算后的取整规则(空:保留两位小数;0.99;0.95;0.00)
+		Status    int64     `db:"status"`   // 状态(1:启用;2:停用)
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+
+	SailProductPriceListItem struct {
+		Id             int64     `db:"id"`
+		PriceListId    int64     `db:"price_list_id"`    // 价格表ID
+		ShopId         int64     `db:"shop_id"`          // 商店唯一ID
+		ProductId      int64     `db:"product_id"`       // 产品ID
+		VariantId      int64     `db:"variant_id"`       // 子商品ID，0表示产品本身的价格
+		Price          float64   `db:"price"`            // 固定价格
+		CompareAtPrice float64   `db:"compare_at_price"` // 固定对比价格，0表示按汇率换算
+		CreatedAt      time.Time `db:"created_at"`
+		UpdatedAt      time.Time `db:"updated_at"`
+	}
+)
+
+func NewSailProductPriceListModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductPriceListModel {
+	return &defaultSailProductPriceListModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_price_list`",
+		itemTable:  "`sail_product_price_list_item`",
+	}
+}
+
+func (m *defaultSailProductPriceListModel) Insert(data SailProductPriceList) (sql.Result, error) {
+	query := fmt.Sprintf("insert into %s (`shop_id`, `market`, `currency`, `rate`, `rounding`, `status`) values (?, ?, ?, ?, ?, ?)", m.table)
+	return m.ExecNoCache(query, data.ShopId, data.Market, data.Currency, data.Rate, data.Rounding, data.Status)
+}
+
+func (m *defaultSailProductPriceListModel) FindOne(shopId, id int64) (*SailProductPriceList, error) {
+	return m.findOne("`shop_id` = ? and `id` = ?", shopId, id)
+}
+
+func (m *defaultSailProductPriceListModel) FindByMarket(shopId int64, market string) (*SailProductPriceList, error) {
+	return m.findOne("`shop_id` = ? and `market` = ? and `status` = ?", shopId, market, PRICE_LIST_STATUS_ENABLED)
+}
+
+// FindByCurrency 同一币种有多个市场时取最早创建的
+func (m *defaultSailProductPriceListModel) FindByCurrency(shopId int64, currency string) (*SailProductPriceList, error) {
+	return m.findOne("`shop_id` = ? and `currency` = ? and `status` = ? order by `id`", shopId, currency, PRICE_LIST_STATUS_ENABLED)
+}
+
+func (m *defaultSailProductPriceListModel) FindList(shopId int64) (*[]SailProductPriceList, error) {
+	var resp []SailProductPriceList
+	query := fmt.Sprintf("select %s from %s where `shop_id` = ? order by `id` ", sailProductPriceListRows, m.table)
+	err := m.QueryRowsNoCache(&resp, query, shopId)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultSailProductPriceListModel) Update(data SailProductPriceList) error {
+	query := fmt.Sprintf("update %s set `currency` = ?, `rate` = ?, `rounding` = ?, `status` = ? where `shop_id` = ? and `id` = ? ", m.table)
+	_, err := m.ExecNoCache(query, data.Currency, data.Rate, data.Rounding, data.Status, data.ShopId, data.Id)
+	return err
+}
+
+func (m *defaultSailProductPriceListModel) Delete(shopId, id int64) error {
+	return m.Transact(func(session sqlx.Session) error {
+		result, err := session.Exec(fmt.Sprintf("delete from %s where `shop_id` = ? and `id` = ? ", m.table), shopId, id)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrNotFound
+		}
+		_, err = session.Exec(fmt.Sprintf("delete from %s where `price_list_id` = ? ", m.itemTable), id)
+		return err
+	})
+}
+
+// FindItems 查询价格表中这些产品的固定价格，productIds 为空时查询整个价格表
+func (m *defaultSailProductPriceListModel) FindItems(priceListId int64, productIds []int64) (*[]SailProductPriceListItem, error) {
+	var resp []SailProductPriceListItem
+	where := "`price_list_id` = ?"
+	args := []interface{}{priceListId}
+	if len(productIds) != 0 {
+		placeholders := make([]string, 0, len(productIds))
+		for _, id := range productIds {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		where += fmt.Sprintf(" and `product_id` in (%s)", strings.Join(placeholders, ","))
+	}
+	query := fmt.Sprintf("select %s from %s where %s order by `product_id`, `variant_id` ", sailProductPriceListItemRows, m.itemTable, where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return &resp, nil
+	default:
+		return nil, err
+	}
+}
+
+// SetItems 批量写入固定价格，价格为0的条目表示取消固定价格，回到按汇率换算
+func (m *defaultSailProductPriceListModel) SetItems(priceListId int64, items []SailProductPriceListItem) error {
+	upsert := fmt.Sprintf("insert into %s (`price_list_id`, `shop_id`, `product_id`, `variant_id`, `price`, `compare_at_price`) values (?, ?, ?, ?, ?, ?) "+
+		"on duplicate key update `price` = values(`price`), `compare_at_price` = values(`compare_at_price`)", m.itemTable)
+	remove := fmt.Sprintf("delete from %s where `price_list_id` = ? and `product_id` = ? and `variant_id` = ? ", m.itemTable)
+	return m.Transact(func(session sqlx.Session) error {
+		for _, item := range items {
+			var err error
+			if item.Price <= 0 {
+				_, err = session.Exec(remove, priceListId, item.ProductId, item.VariantId)
+			} else {
+				_, err = session.Exec(upsert, priceListId, item.ShopId, item.ProductId, item.VariantId, item.Price, item.CompareAtPrice)
+			}
+			if err != nil {
+				logx.Error("保存固定价格失败：", err)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *defaultSailProductPriceListModel) findOne(where string, args ...interface{}) (*SailProductPriceList, error) {
+	var resp SailProductPriceList
+	query := fmt.Sprintf("select %s from %s where %s limit 1", sailProductPriceListRows, m.table, where)
+	err := m.QueryRowNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+const (
+	PRICE_LIST_STATUS_ENABLED  = 1
+	PRICE_LIST_STATUS_DISABLED = 2
+)
@@ -0,0 +1,16 @@
+CREATE TABLE `sail_product_rating` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `product_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '产品ID',
+  `score_one` bigint(20) NOT NULL DEFAULT '0' COMMENT '1星评论数',
+  `score_two` bigint(20) NOT NULL DEFAULT '0' COMMENT '2星评论数',
+  `score_three` bigint(20) NOT NULL DEFAULT '0' COMMENT '3星评论数',
+  `score_four` bigint(20) NOT NULL DEFAULT '0' COMMENT '4星评论数',
+  `score_five` bigint(20) NOT NULL DEFAULT '0' COMMENT '5星评论数',
+  `review_count` bigint(20) NOT NULL DEFAULT '0' COMMENT '计入评分的评论数',
+  `score_sum` bigint(20) NOT NULL DEFAULT '0' COMMENT '评分总和',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_shop_product` (`shop_id`,`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品评分聚合，随评论增量更新，上线后需执行一次 -rebuild-ratings';
@@ -0,0 +1,218 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+)
+
+var (
+	sailProductRatingFieldNames = builderx.RawFieldNames(&SailProductRating{})
+	sailProductRatingRows       = strings.Join(sailProductRatingFieldNames, ",")
+)
+
+type (
+	// SailProductRatingModel 产品评分聚合，评论修改时由 SailProductReviewModel 增量更新，Rebuild 从评论表重新统计
+	SailProductRatingModel interface {
+		FindList(shopId int64, productIds []int64) (*[]SailProductRating, error)
+		ShopTotal(shopId int64) (count, sum int64, err error)
+		Rebuild(shopId, afterId, limit int64) (lastId int64, err error)
+	}
+
+	defaultSailProductRatingModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	SailProductRating struct {
+		Id          int64     `db:"id"`
+		ShopId      int64     `db:"shop_id"`      // 商店唯一ID
+		ProductId   int64     `db:"product_id"`   // 产品ID
+		ScoreOne    int64     `db:"score_one"`    // 1星评论数
+		ScoreTwo    int64     `db:"score_two"`    // 2星评论数
+		ScoreThree  int64     `db:"score_three"`  // 3星评论数
+		ScoreFour   int64     `db:"score_four"`   // 4星评论数
+		ScoreFive   int64     `db:"score_five"`   // 5星评论数
+		ReviewCount int64     `db:"review_count"` // 计入评分的评论数
+		ScoreSum    int64     `db:"score_sum"`    // 评分总和
+		CreatedAt   time.Time `db:"created_at"`
+		UpdatedAt   time.Time `db:"updated_at"`
+	}
+
+	// ratingChange 一条评论计入(1)或移出(-1)评分
+	ratingChange struct {
+		ProductId int64
+		Score     int64
+		Delta     int64
+	}
+
+	ratingBuckets [6]int64 // 下标为评分，0不使用
+)
+
+func NewSailProductRatingModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductRatingModel {
+	return &defaultSailProductRatingModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_rating`",
+	}
+}
+
+func (m *defaultSailProductRatingModel) FindList(shopId int64, productIds []int64) (*[]SailProductRating, error) {
+	resp := make([]SailProductRating, 0)
+	if len(productIds) == 0 {
+		return &resp, nil
+	}
+	args := []interface{}{shopId}
+	for _, id := range productIds {
+		args = append(args, id)
+	}
+	query := fmt.Sprintf("select %s from %s where `shop_id` = ? and `product_id` in (%s) ", sailProductRatingRows, m.table, placeholders(len(productIds)))
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return &resp, nil
+	default:
+		return nil, err
+	}
+}
+
+// ShopTotal 店铺所有产品计入评分的评论数和评分总和，用于计算店铺平均分
+func (m *defaultSailProductRatingModel) ShopTotal(shopId int64) (int64, int64, error) {
+	var resp struct {
+		Count int64 `db:"count"`
+		Sum   int64 `db:"sum"`
+	}
+	query := fmt.Sprintf("select coalesce(sum(`review_count`), 0) as `count`, coalesce(sum(`score_sum`), 0) as `sum` from %s where `shop_id` = ? ", m.table)
+	err := m.QueryRowNoCache(&resp, query, shopId)
+	return resp.Count, resp.Sum, err
+}
+
+// Rebuild 按产品id从评论表重新统计 afterId 之后的 limit 个产品，shopId 为0时处理所有店铺，
+// 返回本批最后一个产品id，为0表示已处理完。先锁定聚合行再用加锁读统计评论，期间的评论修改会等待本批提交
+func (m *defaultSailProductRatingModel) Rebuild(shopId, afterId, limit int64) (int64, error) {
+	var products []struct {
+		Id       int64 `db:"id"`
+		ShopId   int64 `db:"shop_id"`
+		Comments int64 `db:"comments"`
+		Scores   int64 `db:"scores"`
+	}
+	query := "select `id`, `shop_id`, `comments`, `scores` from `sail_shop_product` where `id` > ? and `is_del` = 0 "
+	args := []interface{}{afterId}
+	if shopId != 0 {
+		query += "and `shop_id` = ? "
+		args = append(args, shopId)
+	}
+	query += "order by `id` limit ?"
+	args = append(args, limit)
+	if err := m.QueryRowsNoCache(&products, query, args...); err != nil && err != sqlc.ErrNotFound {
+		return 0, err
+	}
+	if len(products) == 0 {
+		return 0, nil
+	}
+
+	var changed []int64
+	err := m.Transact(func(session sqlx.Session) error {
+		changed = changed[:0]
+		for _, p := range products {
+			if err := stmtExec(session, fmt.Sprintf("insert ignore into %s (`shop_id`, `product_id`) values (?, ?)", m.table), p.ShopId, p.Id); err != nil {
+				return err
+			}
+			var id int64
+			if err := stmtQueryRow(session, &id, fmt.Sprintf("select `id` from %s where `shop_id` = ? and `product_id` = ? for update", m.table), p.ShopId, p.Id); err != nil {
+				return err
+			}
+			var rows []struct {
+				Score int64 `db:"score"`
+				Count int64 `db:"count"`
+			}
+			query := "select `score`, count(*) as `count` from `sail_product_comments` where `shop_id` = ? and `product_id` = ? and `status` = ? and `is_show` = 1 and `is_del` = 0 " +
+				"group by `score` lock in share mode"
+			if err := stmtQueryRows(session, &rows, query, p.ShopId, p.Id, REVIEW_STATUS_APPROVED); err != nil && err != sqlc.ErrNotFound {
+				return err
+			}
+			var b ratingBuckets
+			for _, row := range rows {
+				if row.Score >= 1 && row.Score <= 5 {
+					b[row.Score] = row.Count
+				}
+			}
+			count, sum := b.total()
+			query = fmt.Sprintf("update %s set `score_one` = ?, `score_two` = ?, `score_three` = ?, `score_four` = ?, `score_five` = ?, `review_count` = ?, `score_sum` = ? where `id` = ? ", m.table)
+			if err := stmtExec(session, query, b[1], b[2], b[3], b[4], b[5], count, sum, id); err != nil {
+				return err
+			}
+			if count == p.Comments && sum == p.Scores {
+				continue
+			}
+			if err := stmtExec(session, "update `sail_shop_product` set `comments` = ?, `scores` = ? where `shop_id` = ? and `id` = ? ", count, sum, p.ShopId, p.Id); err != nil {
+				return err
+			}
+			if err := StmtInsertProductEvent(session, p.ShopId, p.Id, ES_SYNC_EVENT_UPDATE); err != nil {
+				return err
+			}
+			changed = append(changed, p.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, productId := range changed {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	}
+	return products[len(products)-1].Id, nil
+}
+
+func (b *ratingBuckets) total() (count, sum int64) {
+	for score := int64(1); score <= 5; score++ {
+		count += b[score]
+		sum += b[score] * score
+	}
+	return count, sum
+}
+
+// stmtApplyRatingChanges 把评论的变化累加到评分聚合和产品的 comments、scores，返回计数有变化的产品。
+// 按产品id顺序更新，避免并发事务互相等待
+func stmtApplyRatingChanges(session sqlx.Session, shopId int64, changes []ratingChange) ([]int64, error) {
+	buckets := make(map[int64]*ratingBuckets)
+	for _, change := range changes {
+		if change.Score < 1 || change.Score > 5 {
+			continue
+		}
+		if buckets[change.ProductId] == nil {
+			buckets[change.ProductId] = &ratingBuckets{}
+		}
+		buckets[change.ProductId][change.Score] += change.Delta
+	}
+	productIds := make([]int64, 0, len(buckets))
+	for productId, b := range buckets {
+		if *b != (ratingBuckets{}) {
+			productIds = append(productIds, productId)
+		}
+	}
+	sort.Slice(productIds, func(i, j int) bool { return productIds[i] < productIds[j] })
+
+	query := "insert into `sail_product_rating` (`shop_id`, `product_id`, `score_one`, `score_two`, `score_three`, `score_four`, `score_five`, `review_count`, `score_sum`) " +
+		"values (?, ?, ?, ?, ?, ?, ?, ?, ?) on duplicate key update `score_one` = `score_one` + values(`score_one`), `score_two` = `score_two` + values(`score_two`), " +
+		"`score_three` = `score_three` + values(`score_three`), `score_four` = `score_four` + values(`score_four`), `score_five` = `score_five` + values(`score_five`), " +
+		"`review_count` = `review_count` + values(`review_count`), `score_sum` = `score_sum` + values(`score_sum`)"
+	for _, productId := range productIds {
+		b := buckets[productId]
+		count, sum := b.total()
+		if err := stmtExec(session, query, shopId, productId, b[1], b[2], b[3], b[4], b[5], count, sum); err != nil {
+			return nil, err
+		}
+		if err := stmtExec(session, "update `sail_shop_product` set `comments` = `comments` + ?, `scores` = `scores` + ? where `shop_id` = ? and `id` = ? ", count, sum, shopId, productId); err != nil {
+			logx.Error("更新产品评论计数失败：", err)
+			return nil, err
+		}
+	}
+	return productIds, nil
+}
@@ -0,0 +1,12 @@
+CREATE TABLE `sail_product_redirect` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `product_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '产品ID',
+  `old_handle` varchar(255) NOT NULL DEFAULT '' COMMENT '旧的产品标题字符串索引',
+  `new_handle` varchar(255) NOT NULL DEFAULT '' COMMENT '跳转到的产品标题字符串索引',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_shop_old_handle` (`shop_id`,`old_handle`),
+  KEY `idx_shop_product` (`shop_id`,`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品链接跳转';
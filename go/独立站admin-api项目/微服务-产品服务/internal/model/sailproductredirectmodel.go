@@ -0,0 +1,196 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+)
+
+var (
+	sailProductRedirectFieldNames = builderx.RawFieldNames(&SailProductRedirect{})
+	sailProductRedirectRows       = strings.Join(sailProductRedirectFieldNames, ",")
+)
+
+type (
+	SailProductRedirectModel interface {
+		FindOneByOldHandle(shopId int64, oldHandle string) (*SailProductRedirect, error)
+		FindList(shopId, productId, limit, page int64) (*[]SailProductRedirect, error)
+		Count(shopId, productId int64) (int64, error)
+		Delete(shopId, id int64) error
+	}
+
+	defaultSailProductRedirectModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	SailProductRedirect struct {
+		Id        int64     `db:"id"`
+		ShopId    int64     `db:"shop_id"`    // 商店唯一ID
+		ProductId int64     `db:"product_id"` // 产品ID
+		OldHandle string    `db:"old_handle"` // 旧的产品标题字符串索引
+		NewHandle string    `db:"new_handle"` // 跳转到的产品标题字符串索引
+		CreatedAt time.Time `db:"created_at"` // 创建时间
+		UpdatedAt time.Time `db:"updated_at"` // 更新时间
+	}
+)
+
+func NewSailProductRedirectModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductRedirectModel {
+	return &defaultSailProductRedirectModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_redirect`",
+	}
+}
+
+func (m *defaultSailProductRedirectModel) FindOneByOldHandle(shopId int64, oldHandle string) (*SailProductRedirect, error) {
+	var resp SailProductRedirect
+	query := fmt.Sprintf("select %s from %s where `shop_id` = ? and `old_handle` = ? limit 1", sailProductRedirectRows, m.table)
+	err := m.QueryRowNoCache(&resp, query, shopId, oldHandle)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// FindList 按创建时间倒序分页查询，productId 为0时查询整个店铺
+func (m *defaultSailProductRedirectModel) FindList(shopId, productId, limit, page int64) (*[]SailProductRedirect, error) {
+	var resp []SailProductRedirect
+	where, args := m.listWhere(shopId, productId)
+	if page < 1 {
+		page = 1
+	}
+	args = append(args, limit, (page-1)*limit)
+	query := fmt.Sprintf("select %s from %s where %s order by `id` desc limit ? offset ? ", sailProductRedirectRows, m.table, where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultSailProductRedirectModel) Count(shopId, productId int64) (int64, error) {
+	var count int64
+	where, args := m.listWhere(shopId, productId)
+	query := fmt.Sprintf("select count(*) from %s where %s ", m.table, where)
+	err := m.QueryRowNoCache(&count, query, args...)
+	switch err {
+	case nil:
+		return count, nil
+	case sqlc.ErrNotFound:
+		return 0, nil
+	default:
+		return 0, err
+	}
+}
+
+func (m *defaultSailProductRedirectModel) Delete(shopId, id int64) error {
+	query := fmt.Sprintf("delete from %s where `shop_id` = ? and `id` = ? ", m.table)
+	result, err := m.ExecNoCache(query, shopId, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *defaultSailProductRedirectModel) listWhere(shopId, productId int64) (string, []interface{}) {
+	where := "`shop_id` = ?"
+	args := []interface{}{shopId}
+	if productId != 0 {
+		where += " and `product_id` = ?"
+		args = append(args, productId)
+	}
+	return where, args
+}
+
+// StmtChangeProductHandle 在更新产品的事务里写入新链接并把产品版本号加一，同时记录旧链接到新链接的跳转。
+// 该产品已有的跳转一并改为指向新链接，避免多次改名后出现跳转链
+func StmtChangeProductHandle(session sqlx.Session, shopId, productId int64, oldHandle, newHandle string) error {
+	if oldHandle == "" || oldHandle == newHandle {
+		return nil
+	}
+	if err := StmtReleaseRedirectHandle(session, shopId, newHandle); err != nil {
+		return err
+	}
+	queries := []struct {
+		query string
+		args  []interface{}
+	}{
+		{
+			query: "update `sail_shop_product` set `handler` = ?, `version` = `version` + 1 where `shop_id` = ? and `id` = ? ",
+			args:  []interface{}{newHandle, shopId, productId},
+		},
+		{
+			query: "update `sail_product_redirect` set `new_handle` = ? where `shop_id` = ? and `product_id` = ? ",
+			args:  []interface{}{newHandle, shopId, productId},
+		},
+		{
+			query: "insert into `sail_product_redirect` (`shop_id`, `product_id`, `old_handle`, `new_handle`) values (?, ?, ?, ?) on duplicate key update `product_id` = values(`product_id`), `new_handle` = values(`new_handle`)",
+			args:  []interface{}{shopId, productId, oldHandle, newHandle},
+		},
+	}
+	for _, q := range queries {
+		stmt, err := session.Prepare(q.query)
+		if err != nil {
+			logx.Error("记录产品链接跳转失败：", err)
+			return err
+		}
+		_, err = stmt.Exec(q.args...)
+		stmt.Close()
+		if err != nil {
+			logx.Error("记录产品链接跳转失败：", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// StmtReleaseRedirectHandle 链接被产品占用后，删除以它为旧链接的跳转
+func StmtReleaseRedirectHandle(session sqlx.Session, shopId int64, handle string) error {
+	stmt, err := session.Prepare("delete from `sail_product_redirect` where `shop_id` = ? and `old_handle` = ? ")
+	if err != nil {
+		logx.Error("删除产品链接跳转失败：", err)
+		return err
+	}
+	defer stmt.Close()
+	if _, err = stmt.Exec(shopId, handle); err != nil {
+		logx.Error("删除产品链接跳转失败：", err)
+		return err
+	}
+	return nil
+}
+
+// StmtDeleteProductRedirects 删除产品时释放它的旧链接
+func StmtDeleteProductRedirects(session sqlx.Session, shopId, productId int64) error {
+	stmt, err := session.Prepare("delete from `sail_product_redirect` where `shop_id` = ? and `product_id` = ? ")
+	if err != nil {
+		logx.Error("删除产品链接跳转失败：", err)
+		return err
+	}
+	defer stmt.Close()
+	if _, err = stmt.Exec(shopId, productId); err != nil {
+		logx.Error("删除产品链接跳转失败：", err)
+		return err
+	}
+	return nil
+}
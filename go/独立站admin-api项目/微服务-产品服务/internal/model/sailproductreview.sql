@@ -0,0 +1,18 @@
+-- 导入的评论按 (shop_id, source, source_id) 唯一。未导入的评论 source_id 为0、已删除的评论都不参与唯一约束，
+-- 由生成列转成 NULL。已有重复的导入评论时需要先通过 ReviewDelete 删除多余的几条，否则唯一索引无法创建
+ALTER TABLE `sail_product_comments`
+  ADD COLUMN `reply` text COMMENT '商家回复' AFTER `comment`,
+  ADD COLUMN `replied_at` timestamp NULL DEFAULT NULL COMMENT '商家回复时间' AFTER `reply`,
+  ADD COLUMN `source_key` bigint(20) GENERATED ALWAYS AS (if(`source_id` = 0 or `is_del` = 1, NULL, `source_id`)) VIRTUAL COMMENT '导入评论去重用的来源评论ID',
+  ADD KEY `idx_shop_product_status` (`shop_id`,`product_id`,`status`,`is_show`,`is_del`),
+  ADD UNIQUE KEY `uniq_shop_source` (`shop_id`,`source`,`source_key`);
+
+CREATE TABLE `sail_product_comment_vote` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `comment_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '评论ID',
+  `voter` varchar(64) NOT NULL DEFAULT '' COMMENT '投票人标识，例如客户ID或访客ID',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_comment_voter` (`comment_id`,`voter`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='评论有用投票';
@@ -0,0 +1,490 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+)
+
+const (
+	REVIEW_STATUS_PENDING  = 1 // 待审核
+	REVIEW_STATUS_APPROVED = 2 // 审核通过
+	REVIEW_STATUS_REJECTED = 3 // 审核拒绝
+
+	REVIEW_HAS_IMAGE_YES = 1
+	REVIEW_HAS_IMAGE_NO  = 2
+)
+
+var (
+	sailProductReviewFieldNames = builderx.RawFieldNames(&SailProductReview{})
+	sailProductReviewRows       = strings.Join(sailProductReviewFieldNames, ",")
+
+	ErrReviewTransition = errors.New("review status transition is not allowed")
+	ErrReviewDuplicate  = errors.New("review with the same source_id is already imported")
+
+	// reviewTransitions 审核状态允许的变更，待审核只能作为初始状态
+	reviewTransitions = map[int64][]int64{
+		REVIEW_STATUS_PENDING:  {REVIEW_STATUS_APPROVED, REVIEW_STATUS_REJECTED},
+		REVIEW_STATUS_APPROVED: {REVIEW_STATUS_REJECTED},
+		REVIEW_STATUS_REJECTED: {REVIEW_STATUS_APPROVED},
+	}
+)
+
+type (
+	// SailProductReviewModel 产品评论。审核通过、显示且未删除的评论计入产品的 comments(条数)、scores(评分总和)
+	// 和评分聚合表，每次修改在同一事务里按修改前后的差值增量更新
+	SailProductReviewModel interface {
+		Insert(data SailProductReview, imageUrls []string) (int64, error)
+		FindOne(shopId, id int64) (*SailProductReview, error)
+		FindList(shopId int64, filter ReviewFilter, limit, page int64) (*[]SailProductReview, error)
+		Count(shopId int64, filter ReviewFilter) (int64, error)
+		Update(data SailProductReview) error
+		Delete(shopId, id int64) error
+		SetStatus(shopId int64, ids []int64, status int64) ([]int64, error)
+		SetTop(shopId, id int64, isTop bool) error
+		SetShow(shopId, id int64, isShow bool) error
+		Reply(shopId, id int64, reply, adminUser string) error
+		Vote(shopId, id int64, voter string) (bool, int64, error)
+		FindSourceIds(shopId, source int64, sourceIds []int64) ([]int64, error)
+		FindProductIdBySku(shopId int64, sku string) (int64, error)
+		AppendImage(shopId, id, imageId int64) error
+	}
+
+	defaultSailProductReviewModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	SailProductReview struct {
+		Id           int64          `db:"id"`
+		Code         string         `db:"code"`
+		ShopId       int64          `db:"shop_id"`       // 商店唯一ID
+		ProductId    int64          `db:"product_id"`    // 产品ID
+		ProductName  string         `db:"product_name"`  // 评论时的产品标题
+		Observer     string         `db:"observer"`      // 评论人
+		ObserverIcon string         `db:"observer_icon"` // 评论人头像
+		AdminUser    string         `db:"admin_user"`    // 回复或创建评论的管理员
+		Country      string         `db:"country"`
+		Score        int64          `db:"score"`     // 评分(1-5)
+		ImageId      int64          `db:"image_id"`  // 第一张图片ID
+		ImagesId     string         `db:"images_id"` // 图片ID，逗号分隔
+		Comment      string         `db:"comment"`
+		Reply        sql.NullString `db:"reply"` // 商家回复
+		RepliedAt    sql.NullTime   `db:"replied_at"`
+		Email        string         `db:"email"`
+		Helpful      int64          `db:"helpful"` // 有用投票数
+		Status       int64          `db:"status"`  // 审核状态(1:待审核;2:通过;3:拒绝)
+		IsShow       int64          `db:"is_show"`
+		IsDel        int64          `db:"is_del"`
+		IsTop        int64          `db:"is_top"`
+		Source       int64          `db:"source"`
+		ExpressImage string         `db:"express_image"`
+		IsUp         int64          `db:"is_up"`
+		Sort         int64          `db:"sort"`
+		CommentAt    time.Time      `db:"comment_at"`
+		CreatedAt    time.Time      `db:"created_at"`
+		UpdatedAt    time.Time      `db:"updated_at"`
+		SourceId     int64          `db:"source_id"`
+	}
+
+	// ReviewFilter 为0或空的条件不限制
+	ReviewFilter struct {
+		ProductId int64
+		Score     int64
+		Status    int64
+		HasImage  int64 // 1:有图;2:无图
+		IsShow    int64 // 1:显示;2:隐藏
+	}
+)
+
+func NewSailProductReviewModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductReviewModel {
+	return &defaultSailProductReviewModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_comments`",
+	}
+}
+
+// Insert imageUrls 在同一事务里创建图片任务，由图片导入任务转存后追加到评论图片；
+// 导入的评论 source_id 已存在时不写入，返回 ErrReviewDuplicate
+func (m *defaultSailProductReviewModel) Insert(data SailProductReview, imageUrls []string) (int64, error) {
+	var id int64
+	err := m.transact(data.ShopId, func(session sqlx.Session) ([]ratingChange, error) {
+		query := fmt.Sprintf("insert into %s (`code`, `shop_id`, `product_id`, `product_name`, `observer`, `observer_icon`, `admin_user`, `country`, `score`, `image_id`, `images_id`, `comment`, `email`, `status`, `is_show`, `is_top`, `source`, `comment_at`, `source_id`) "+
+			"values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) on duplicate key update `id` = `id`", m.table)
+		stmt, err := session.Prepare(query)
+		if err != nil {
+			return nil, err
+		}
+		defer stmt.Close()
+		result, err := stmt.Exec(data.Code, data.ShopId, data.ProductId, data.ProductName, data.Observer, data.ObserverIcon, data.AdminUser, data.Country, data.Score,
+			data.ImageId, data.ImagesId, data.Comment, data.Email, data.Status, data.IsShow, data.IsTop, data.Source, data.CommentAt, data.SourceId)
+		if err != nil {
+			logx.Error("新增评论失败：", err)
+			return nil, err
+		}
+		// 导入的评论和已有评论的 (shop_id, source, source_id) 相同，没有写入
+		if affected, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if affected == 0 {
+			return nil, ErrReviewDuplicate
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return nil, err
+		}
+		for _, url := range imageUrls {
+			job := SailProductImageJob{ShopId: data.ShopId, ProductId: data.ProductId, Url: url, CommentId: id}
+			if _, err := StmtInsertImageJob(session, job); err != nil {
+				return nil, err
+			}
+		}
+		return reviewChanges(nil, &data), nil
+	})
+	return id, err
+}
+
+func (m *defaultSailProductReviewModel) FindOne(shopId, id int64) (*SailProductReview, error) {
+	var resp SailProductReview
+	query := fmt.Sprintf("select %s from %s where `shop_id` = ? and `id` = ? and `is_del` = 0 limit 1", sailProductReviewRows, m.table)
+	err := m.QueryRowNoCache(&resp, query, shopId, id)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// FindList 置顶的排在前面，其余按评论时间倒序
+func (m *defaultSailProductReviewModel) FindList(shopId int64, filter ReviewFilter, limit, page int64) (*[]SailProductReview, error) {
+	var resp []SailProductReview
+	where, args := reviewWhere(shopId, filter)
+	if page < 1 {
+		page = 1
+	}
+	args = append(args, limit, (page-1)*limit)
+	query := fmt.Sprintf("select %s from %s where %s order by `is_top` desc, `sort` desc, `comment_at` desc, `id` desc limit ? offset ? ", sailProductReviewRows, m.table, where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultSailProductReviewModel) Count(shopId int64, filter ReviewFilter) (int64, error) {
+	var count int64
+	where, args := reviewWhere(shopId, filter)
+	query := fmt.Sprintf("select count(*) from %s where %s ", m.table, where)
+	err := m.QueryRowNoCache(&count, query, args...)
+	return count, err
+}
+
+// Update 修改评论内容，评分变化会同步到产品的计数
+func (m *defaultSailProductReviewModel) Update(data SailProductReview) error {
+	return m.transact(data.ShopId, func(session sqlx.Session) ([]ratingChange, error) {
+		old, err := m.lockReview(session, data.ShopId, data.Id)
+		if err != nil {
+			return nil, err
+		}
+		query := fmt.Sprintf("update %s set `observer` = ?, `observer_icon` = ?, `country` = ?, `score` = ?, `image_id` = ?, `images_id` = ?, `comment` = ?, `email` = ?, `comment_at` = ? where `id` = ? ", m.table)
+		err = stmtExec(session, query, data.Observer, data.ObserverIcon, data.Country, data.Score, data.ImageId, data.ImagesId, data.Comment, data.Email, data.CommentAt, data.Id)
+		updated := *old
+		updated.Score = data.Score
+		return reviewChanges(old, &updated), err
+	})
+}
+
+func (m *defaultSailProductReviewModel) Delete(shopId, id int64) error {
+	return m.setColumn(shopId, id, "is_del", 1, func(r *SailProductReview) { r.IsDel = 1 })
+}
+
+// SetStatus 批量审核，不允许的状态变更跳过，返回实际修改的评论
+func (m *defaultSailProductReviewModel) SetStatus(shopId int64, ids []int64, status int64) ([]int64, error) {
+	var updated []int64
+	err := m.transact(shopId, func(session sqlx.Session) ([]ratingChange, error) {
+		updated = updated[:0]
+		args := []interface{}{shopId}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		var rows []SailProductReview
+		query := fmt.Sprintf("select %s from %s where `shop_id` = ? and `id` in (%s) and `is_del` = 0 order by `id` for update", sailProductReviewRows, m.table, placeholders(len(ids)))
+		if err := stmtQueryRows(session, &rows, query, args...); err != nil && err != sqlc.ErrNotFound {
+			return nil, err
+		}
+		var changes []ratingChange
+		for i := range rows {
+			row := rows[i]
+			if !reviewTransitionAllowed(row.Status, status) {
+				continue
+			}
+			if err := stmtExec(session, fmt.Sprintf("update %s set `status` = ? where `id` = ? ", m.table), status, row.Id); err != nil {
+				return nil, err
+			}
+			updated = append(updated, row.Id)
+			moderated := row
+			moderated.Status = status
+			changes = append(changes, reviewChanges(&row, &moderated)...)
+		}
+		return changes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (m *defaultSailProductReviewModel) SetTop(shopId, id int64, isTop bool) error {
+	return m.setColumn(shopId, id, "is_top", boolInt(isTop), func(r *SailProductReview) { r.IsTop = boolInt(isTop) })
+}
+
+func (m *defaultSailProductReviewModel) SetShow(shopId, id int64, isShow bool) error {
+	return m.setColumn(shopId, id, "is_show", boolInt(isShow), func(r *SailProductReview) { r.IsShow = boolInt(isShow) })
+}
+
+// Reply 保存商家回复，reply 为空时删除回复
+func (m *defaultSailProductReviewModel) Reply(shopId, id int64, reply, adminUser string) error {
+	query := fmt.Sprintf("update %s set `reply` = ?, `replied_at` = ?, `admin_user` = ? where `shop_id` = ? and `id` = ? and `is_del` = 0 ", m.table)
+	args := []interface{}{reply, time.Now(), adminUser, shopId, id}
+	if reply == "" {
+		args[0], args[1] = nil, nil
+	}
+	result, err := m.ExecNoCache(query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Vote 记录有用投票，同一投票人只计一次，返回本次是否计入和当前票数
+func (m *defaultSailProductReviewModel) Vote(shopId, id int64, voter string) (bool, int64, error) {
+	var voted bool
+	var helpful int64
+	err := m.Transact(func(session sqlx.Session) error {
+		err := stmtQueryRow(session, &helpful, fmt.Sprintf("select `helpful` from %s where `shop_id` = ? and `id` = ? and `is_del` = 0 for update", m.table), shopId, id)
+		switch err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return ErrNotFound
+		default:
+			return err
+		}
+		stmt, err := session.Prepare("insert ignore into `sail_product_comment_vote` (`shop_id`, `comment_id`, `voter`) values (?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err := stmt.Exec(shopId, id, voter)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+		voted = true
+		helpful++
+		return stmtExec(session, fmt.Sprintf("update %s set `helpful` = `helpful` + 1 where `id` = ? ", m.table), id)
+	})
+	return voted, helpful, err
+}
+
+// FindSourceIds 返回已导入过的来源评论id
+func (m *defaultSailProductReviewModel) FindSourceIds(shopId, source int64, sourceIds []int64) ([]int64, error) {
+	var resp []int64
+	if len(sourceIds) == 0 {
+		return resp, nil
+	}
+	args := []interface{}{shopId, source}
+	for _, id := range sourceIds {
+		args = append(args, id)
+	}
+	query := fmt.Sprintf("select `source_id` from %s where `shop_id` = ? and `source` = ? and `source_id` in (%s) and `is_del` = 0 ", m.table, placeholders(len(sourceIds)))
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return resp, nil
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultSailProductReviewModel) FindProductIdBySku(shopId int64, sku string) (int64, error) {
+	var productId int64
+	query := "select `product_id` from `sail_shop_product_variant` where `shop_id` = ? and `sku_code` = ? and `is_del` = 0 order by `id` limit 1"
+	err := m.QueryRowNoCache(&productId, query, shopId, sku)
+	switch err {
+	case nil:
+		return productId, nil
+	case sqlc.ErrNotFound:
+		return 0, ErrNotFound
+	default:
+		return 0, err
+	}
+}
+
+// AppendImage 把转存后的图片追加到评论图片，已存在时不重复追加
+func (m *defaultSailProductReviewModel) AppendImage(shopId, id, imageId int64) error {
+	return m.Transact(func(session sqlx.Session) error {
+		var imagesId string
+		err := stmtQueryRow(session, &imagesId, fmt.Sprintf("select `images_id` from %s where `shop_id` = ? and `id` = ? for update", m.table), shopId, id)
+		switch err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return ErrNotFound
+		default:
+			return err
+		}
+		ids := ParseVariantIds(imagesId)
+		for _, existing := range ids {
+			if existing == imageId {
+				return nil
+			}
+		}
+		ids = append(ids, imageId)
+		set := make([]string, 0, len(ids))
+		for _, item := range ids {
+			set = append(set, strconv.FormatInt(item, 10))
+		}
+		return stmtExec(session, fmt.Sprintf("update %s set `images_id` = ?, `image_id` = ? where `id` = ? ", m.table), strings.Join(set, ","), ids[0], id)
+	})
+}
+
+func (m *defaultSailProductReviewModel) setColumn(shopId, id int64, column string, value int64, apply func(r *SailProductReview)) error {
+	return m.transact(shopId, func(session sqlx.Session) ([]ratingChange, error) {
+		old, err := m.lockReview(session, shopId, id)
+		if err != nil {
+			return nil, err
+		}
+		err = stmtExec(session, fmt.Sprintf("update %s set `%s` = ? where `id` = ? ", m.table, column), value, id)
+		updated := *old
+		apply(&updated)
+		return reviewChanges(old, &updated), err
+	})
+}
+
+func (m *defaultSailProductReviewModel) lockReview(session sqlx.Session, shopId, id int64) (*SailProductReview, error) {
+	var resp SailProductReview
+	err := stmtQueryRow(session, &resp, fmt.Sprintf("select %s from %s where `shop_id` = ? and `id` = ? and `is_del` = 0 for update", sailProductReviewRows, m.table), shopId, id)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// transact fn 返回计数的变化，在同一事务里更新产品计数和评分聚合，计数有变化的产品记录变更事件
+func (m *defaultSailProductReviewModel) transact(shopId int64, fn func(session sqlx.Session) ([]ratingChange, error)) error {
+	var productIds []int64
+	err := m.Transact(func(session sqlx.Session) error {
+		changes, err := fn(session)
+		if err != nil {
+			return err
+		}
+		productIds, err = stmtApplyRatingChanges(session, shopId, changes)
+		if err != nil {
+			logx.Error("更新产品评分失败：", err)
+			return err
+		}
+		for _, productId := range productIds {
+			if err := StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, productId := range productIds {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	}
+	return nil
+}
+
+// reviewChanges 比较修改前后是否计入评分，old 为 nil 表示新增
+func reviewChanges(old, updated *SailProductReview) []ratingChange {
+	var changes []ratingChange
+	if old != nil && reviewCounted(old) {
+		changes = append(changes, ratingChange{ProductId: old.ProductId, Score: old.Score, Delta: -1})
+	}
+	if updated != nil && reviewCounted(updated) {
+		changes = append(changes, ratingChange{ProductId: updated.ProductId, Score: updated.Score, Delta: 1})
+	}
+	return changes
+}
+
+// reviewCounted 审核通过、显示且未删除的评论计入评分
+func reviewCounted(r *SailProductReview) bool {
+	return r.Status == REVIEW_STATUS_APPROVED && r.IsShow == 1 && r.IsDel == 0
+}
+
+func reviewWhere(shopId int64, filter ReviewFilter) (string, []interface{}) {
+	conditions := []string{"`shop_id` = ?", "`is_del` = 0"}
+	args := []interface{}{shopId}
+	if filter.ProductId != 0 {
+		conditions = append(conditions, "`product_id` = ?")
+		args = append(args, filter.ProductId)
+	}
+	if filter.Score != 0 {
+		conditions = append(conditions, "`score` = ?")
+		args = append(args, filter.Score)
+	}
+	if filter.Status != 0 {
+		conditions = append(conditions, "`status` = ?")
+		args = append(args, filter.Status)
+	}
+	switch filter.HasImage {
+	case REVIEW_HAS_IMAGE_YES:
+		conditions = append(conditions, "`images_id` != ''")
+	case REVIEW_HAS_IMAGE_NO:
+		conditions = append(conditions, "`images_id` = ''")
+	}
+	switch filter.IsShow {
+	case 1:
+		conditions = append(conditions, "`is_show` = 1")
+	case 2:
+		conditions = append(conditions, "`is_show` = 0")
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+func reviewTransitionAllowed(from, to int64) bool {
+	for _, status := range reviewTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
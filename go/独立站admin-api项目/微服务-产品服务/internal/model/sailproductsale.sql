@@ -0,0 +1,39 @@
+CREATE TABLE `sail_product_sale` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `title` varchar(255) NOT NULL DEFAULT '' COMMENT '活动名称',
+  `product_ids` text COMMENT '参与活动的产品id，按逗号拼接，产品下全部子商品参与',
+  `variant_ids` text COMMENT '参与活动的子商品id，按逗号拼接',
+  `filter_vendor` varchar(255) NOT NULL DEFAULT '' COMMENT '按供应商筛选参与活动的产品',
+  `filter_title` varchar(255) NOT NULL DEFAULT '' COMMENT '按标题关键词筛选参与活动的产品',
+  `discount_type` varchar(16) NOT NULL DEFAULT '' COMMENT '优惠方式(percent:百分比;fixed:减固定金额;price:一口价)',
+  `discount_value` decimal(12,2) NOT NULL DEFAULT '0.00' COMMENT '优惠值',
+  `starts_at` datetime NOT NULL COMMENT '开始时间',
+  `ends_at` datetime NOT NULL COMMENT '结束时间',
+  `status` tinyint(3) NOT NULL DEFAULT '1' COMMENT '状态(1:未开始;2:进行中;3:已结束)',
+  `applied_at` datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '改价完成时间',
+  `ended_at` datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '恢复原价完成时间',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_shop_status` (`shop_id`,`status`),
+  KEY `idx_status_starts` (`status`,`starts_at`),
+  KEY `idx_status_ends` (`status`,`ends_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='定时促销活动';
+
+CREATE TABLE `sail_product_sale_item` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `sale_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '活动ID',
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `product_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '产品ID',
+  `variant_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '子商品ID，0表示产品本身的价格',
+  `original_price` decimal(12,2) NOT NULL DEFAULT '0.00' COMMENT '活动前价格',
+  `original_compare_at_price` decimal(12,2) NOT NULL DEFAULT '0.00' COMMENT '活动前对比价格',
+  `sale_price` decimal(12,2) NOT NULL DEFAULT '0.00' COMMENT '活动价',
+  `status` tinyint(3) NOT NULL DEFAULT '1' COMMENT '状态(1:已改价;2:已恢复)',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_sale_product_variant` (`sale_id`,`product_id`,`variant_id`),
+  KEY `idx_product_status` (`product_id`,`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='促销活动改价记录，用于恢复原价';
@@ -0,0 +1,500 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+)
+
+var (
+	sailProductSaleFieldNames     = builderx.RawFieldNames(&SailProductSale{})
+	sailProductSaleRows           = strings.Join(sailProductSaleFieldNames, ",")
+	sailProductSaleItemFieldNames = builderx.RawFieldNames(&SailProductSaleItem{})
+	sailProductSaleItemRows       = strings.Join(sailProductSaleItemFieldNames, ",")
+)
+
+type (
+	// SailProductSaleModel 定时促销活动。开始时把原价移到对比价格并改为活动价，结束时按改价记录恢复；
+	// 每个产品在单独的事务里改价，改价记录的唯一索引保证重复执行不会重复改价
+	SailProductSaleModel interface {
+		Insert(data SailProductSale) (sql.Result, error)
+		FindOne(shopId, id int64) (*SailProductSale, error)
+		FindList(shopId, status, limit, page int64) (*[]SailProductSale, error)
+		Count(shopId, status int64) (int64, error)
+		Cancel(shopId, id int64) error
+		FindDue(now time.Time, limit int64) (*[]SailProductSale, error)
+		SetStatus(id, from, to int64) (bool, error)
+		FindTargetProducts(sale SailProductSale, afterId, limit int64) ([]int64, error)
+		FindAppliedProducts(saleId, afterId, limit int64) ([]int64, error)
+		FindAppliedItems(productIds []int64) (*[]SailProductSaleItem, error)
+		ApplyProduct(sale SailProductSale, productId int64, salePrice SalePriceFunc) (int64, error)
+		RevertProduct(sale SailProductSale, productId int64) (int64, error)
+	}
+
+	defaultSailProductSaleModel struct {
+		sqlc.CachedConn
+		table     string
+		itemTable string
+	}
+
+	SailProductSale struct {
+		Id            int64          `db:"id"`
+		ShopId        int64          `db:"shop_id"`        // 商店唯一ID
+		Title         string         `db:"title"`          // 活动名称
+		ProductIds    sql.NullString `db:"product_ids"`    // 参与活动的产品id，按逗号拼接，产品下全部子商品参与
+		VariantIds    sql.NullString `db:"variant_ids"`    // 参与活动的子商品id，按逗号拼接
+		FilterVendor  string         `db:"filter_vendor"`  // 按供应商筛选参与活动的产品
+		FilterTitle   string         `db:"filter_title"`   // 按标题关键词筛选参与活动的产品
+		DiscountType  string         `db:"discount_type"`  // 优惠方式(percent:百分比;fixed:减固定金额;price:一口价)
+		DiscountValue float64        `db:"discount_value"` // 优惠值
+		StartsAt      time.Time      `db:"starts_at"`      // 开始时间
+		EndsAt        time.Time      `db:"ends_at"`        // 结束时间
+		Status        int64          `db:"status"`         // 状态(1:未开始;2:进行中;3:已结束)
+		AppliedAt     time.Time      `db:"applied_at"`     // 改价完成时间
+		EndedAt       time.Time      `db:"ended_at"`       // 恢复原价完成时间
+		CreatedAt     time.Time      `db:"created_at"`
+		UpdatedAt     time.Time      `db:"updated_at"`
+	}
+
+	SailProductSaleItem struct {
+		Id                     int64     `db:"id"`
+		SaleId                 int64     `db:"sale_id"`                   // 活动ID
+		ShopId                 int64     `db:"shop_id"`                   // 商店唯一ID
+		ProductId              int64     `db:"product_id"`                // 产品ID
+		VariantId              int64     `db:"variant_id"`                // 子商品ID，0表示产品本身的价格
+		OriginalPrice          float64   `db:"original_price"`            // 活动前价格
+		OriginalCompareAtPrice float64   `db:"original_compare_at_price"` // 活动前对比价格
+		SalePrice              float64   `db:"sale_price"`                // 活动价
+		Status                 int64     `db:"status"`                    // 状态(1:已改价;2:已恢复)
+		CreatedAt              time.Time `db:"created_at"`
+		UpdatedAt              time.Time `db:"updated_at"`
+	}
+
+	// SalePriceFunc 按活动优惠计算活动价，返回 false 表示该价格不参与活动
+	SalePriceFunc func(price float64) (float64, bool)
+
+	saleProductRow struct {
+		Price          float64 `db:"price"`
+		CompareAtPrice float64 `db:"compare_at_price"`
+		Vendor         string  `db:"vendor"`
+		Title          string  `db:"title"`
+	}
+
+	saleVariantRow struct {
+		Id             int64   `db:"id"`
+		Price          float64 `db:"price"`
+		CompareAtPrice float64 `db:"compare_at_price"`
+	}
+)
+
+func NewSailProductSaleModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductSaleModel {
+	return &defaultSailProductSaleModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_sale`",
+		itemTable:  "`sail_product_sale_item`",
+	}
+}
+
+func (m *defaultSailProductSaleModel) Insert(data SailProductSale) (sql.Result, error) {
+	query := fmt.Sprintf("insert into %s (`shop_id`, `title`, `product_ids`, `variant_ids`, `filter_vendor`, `filter_title`, `discount_type`, `discount_value`, `starts_at`, `ends_at`, `status`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", m.table)
+	return m.ExecNoCache(query, data.ShopId, data.Title, data.ProductIds, data.VariantIds, data.FilterVendor, data.FilterTitle,
+		data.DiscountType, data.DiscountValue, data.StartsAt, data.EndsAt, SALE_STATUS_SCHEDULED)
+}
+
+func (m *defaultSailProductSaleModel) FindOne(shopId, id int64) (*SailProductSale, error) {
+	var resp SailProductSale
+	query := fmt.Sprintf("select %s from %s where `shop_id` = ? and `id` = ? limit 1", sailProductSaleRows, m.table)
+	err := m.QueryRowNoCache(&resp, query, shopId, id)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// FindList status 为0时不限制状态
+func (m *defaultSailProductSaleModel) FindList(shopId, status, limit, page int64) (*[]SailProductSale, error) {
+	var resp []SailProductSale
+	where, args := m.listWhere(shopId, status)
+	if page < 1 {
+		page = 1
+	}
+	args = append(args, limit, (page-1)*limit)
+	query := fmt.Sprintf("select %s from %s where %s order by `id` desc limit ? offset ? ", sailProductSaleRows, m.table, where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultSailProductSaleModel) Count(shopId, status int64) (int64, error) {
+	var count int64
+	where, args := m.listWhere(shopId, status)
+	query := fmt.Sprintf("select count(*) from %s where %s ", m.table, where)
+	err := m.QueryRowNoCache(&count, query, args...)
+	return count, err
+}
+
+func (m *defaultSailProductSaleModel) listWhere(shopId, status int64) (string, []interface{}) {
+	where := "`shop_id` = ?"
+	args := []interface{}{shopId}
+	if status != 0 {
+		where += " and `status` = ?"
+		args = append(args, status)
+	}
+	return where, args
+}
+
+// Cancel 把未结束活动的结束时间提前到现在，由定时任务恢复原价
+func (m *defaultSailProductSaleModel) Cancel(shopId, id int64) error {
+	now := time.Now()
+	query := fmt.Sprintf("update %s set `ends_at` = ?, `starts_at` = least(`starts_at`, ?) where `shop_id` = ? and `id` = ? and `status` in (?, ?) and `ends_at` > ? ", m.table)
+	result, err := m.ExecNoCache(query, now, now, shopId, id, SALE_STATUS_SCHEDULED, SALE_STATUS_ACTIVE, now)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FindDue 查询到了开始时间还未改价、或到了结束时间还未恢复的活动
+func (m *defaultSailProductSaleModel) FindDue(now time.Time, limit int64) (*[]SailProductSale, error) {
+	var resp []SailProductSale
+	query := fmt.Sprintf("select %s from %s where (`status` = ? and `starts_at` <= ?) or (`status` = ? and `ends_at` <= ?) order by `id` limit ? ", sailProductSaleRows, m.table)
+	err := m.QueryRowsNoCache(&resp, query, SALE_STATUS_SCHEDULED, now, SALE_STATUS_ACTIVE, now, limit)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// SetStatus 状态为 from 时才修改，返回是否修改成功
+func (m *defaultSailProductSaleModel) SetStatus(id, from, to int64) (bool, error) {
+	column := "`applied_at`"
+	if to == SALE_STATUS_ENDED {
+		column = "`ended_at`"
+	}
+	query := fmt.Sprintf("update %s set `status` = ?, %s = ? where `id` = ? and `status` = ? ", m.table, column)
+	result, err := m.ExecNoCache(query, to, time.Now(), id, from)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FindTargetProducts 按id顺序返回活动覆盖的产品，筛选条件在执行时才计算，活动期间新增的产品不参与
+func (m *defaultSailProductSaleModel) FindTargetProducts(sale SailProductSale, afterId, limit int64) ([]int64, error) {
+	targets := make([]string, 0)
+	args := []interface{}{sale.ShopId, afterId}
+	if productIds := ParseVariantIds(sale.ProductIds.String); len(productIds) != 0 {
+		targets = append(targets, fmt.Sprintf("`id` in (%s)", placeholders(len(productIds))))
+		for _, id := range productIds {
+			args = append(args, id)
+		}
+	}
+	if variantIds := ParseVariantIds(sale.VariantIds.String); len(variantIds) != 0 {
+		targets = append(targets, fmt.Sprintf("`id` in (select `product_id` from `sail_shop_product_variant` where `shop_id` = ? and `id` in (%s) and `is_del` = 0)", placeholders(len(variantIds))))
+		args = append(args, sale.ShopId)
+		for _, id := range variantIds {
+			args = append(args, id)
+		}
+	}
+	if sale.HasFilter() {
+		filter := make([]string, 0, 2)
+		if sale.FilterVendor != "" {
+			filter = append(filter, "`vendor` = ?")
+			args = append(args, sale.FilterVendor)
+		}
+		if sale.FilterTitle != "" {
+			filter = append(filter, "`title` like ?")
+			args = append(args, "%"+sale.FilterTitle+"%")
+		}
+		targets = append(targets, "("+strings.Join(filter, " and ")+")")
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	args = append(args, limit)
+
+	var ids []int64
+	query := fmt.Sprintf("select `id` from `sail_shop_product` where `shop_id` = ? and `is_del` = 0 and `id` > ? and (%s) order by `id` limit ? ", strings.Join(targets, " or "))
+	err := m.QueryRowsNoCache(&ids, query, args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return ids, nil
+	default:
+		return nil, err
+	}
+}
+
+// FindAppliedProducts 按id顺序返回活动中还未恢复原价的产品
+func (m *defaultSailProductSaleModel) FindAppliedProducts(saleId, afterId, limit int64) ([]int64, error) {
+	var ids []int64
+	query := fmt.Sprintf("select distinct `product_id` from %s where `sale_id` = ? and `status` = ? and `product_id` > ? order by `product_id` limit ? ", m.itemTable)
+	err := m.QueryRowsNoCache(&ids, query, saleId, SALE_ITEM_STATUS_APPLIED, afterId, limit)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return ids, nil
+	default:
+		return nil, err
+	}
+}
+
+// FindAppliedItems 查询这些产品当前生效中的改价记录
+func (m *defaultSailProductSaleModel) FindAppliedItems(productIds []int64) (*[]SailProductSaleItem, error) {
+	resp := make([]SailProductSaleItem, 0)
+	if len(productIds) == 0 {
+		return &resp, nil
+	}
+	args := []interface{}{SALE_ITEM_STATUS_APPLIED}
+	for _, id := range productIds {
+		args = append(args, id)
+	}
+	query := fmt.Sprintf("select %s from %s where `status` = ? and `product_id` in (%s) ", sailProductSaleItemRows, m.itemTable, placeholders(len(productIds)))
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return &resp, nil
+	default:
+		return nil, err
+	}
+}
+
+// ApplyProduct 给一个产品改价，返回改价条数。锁定活动行后确认活动仍未开始改价完成且未结束，
+// 已有改价记录的子商品跳过；正在参加其他活动的子商品不参与，避免恢复时互相覆盖
+func (m *defaultSailProductSaleModel) ApplyProduct(sale SailProductSale, productId int64, salePrice SalePriceFunc) (int64, error) {
+	var applied int64
+	err := m.Transact(func(session sqlx.Session) error {
+		var current SailProductSale
+		err := stmtQueryRow(session, &current, fmt.Sprintf("select %s from %s where `id` = ? for update", sailProductSaleRows, m.table), sale.Id)
+		if err != nil {
+			return err
+		}
+		if current.Status != SALE_STATUS_SCHEDULED || !current.EndsAt.After(time.Now()) {
+			return nil
+		}
+
+		var product saleProductRow
+		err = stmtQueryRow(session, &product, "select `price`, `compare_at_price`, `vendor`, `title` from `sail_shop_product` where `shop_id` = ? and `id` = ? and `is_del` = 0 for update", sale.ShopId, productId)
+		switch err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return nil
+		default:
+			return err
+		}
+		var variants []saleVariantRow
+		err = stmtQueryRows(session, &variants, "select `id`, `price`, `compare_at_price` from `sail_shop_product_variant` where `shop_id` = ? and `product_id` = ? and `is_del` = 0 for update", sale.ShopId, productId)
+		if err != nil && err != sqlc.ErrNotFound {
+			return err
+		}
+		var items []SailProductSaleItem
+		err = stmtQueryRows(session, &items, fmt.Sprintf("select %s from %s where `product_id` = ? and (`sale_id` = ? or `status` = ?) ", sailProductSaleItemRows, m.itemTable), productId, sale.Id, SALE_ITEM_STATUS_APPLIED)
+		if err != nil && err != sqlc.ErrNotFound {
+			return err
+		}
+		skip := make(map[int64]bool, len(items))
+		for _, item := range items {
+			skip[item.VariantId] = true
+		}
+
+		wholeProduct := sale.CoversProduct(productId, product.Vendor, product.Title)
+		variantIds := make(map[int64]bool)
+		for _, id := range ParseVariantIds(sale.VariantIds.String) {
+			variantIds[id] = true
+		}
+		targets := make([]saleVariantRow, 0, len(variants)+1)
+		if wholeProduct {
+			targets = append(targets, saleVariantRow{Price: product.Price, CompareAtPrice: product.CompareAtPrice})
+		}
+		for _, variant := range variants {
+			if wholeProduct || variantIds[variant.Id] {
+				targets = append(targets, variant)
+			}
+		}
+
+		for _, target := range targets {
+			if skip[target.Id] {
+				continue
+			}
+			price, ok := salePrice(target.Price)
+			if !ok {
+				continue
+			}
+			fields := []string{"`sale_id`", "`shop_id`", "`product_id`", "`variant_id`", "`original_price`", "`original_compare_at_price`", "`sale_price`", "`status`"}
+			args := []interface{}{sale.Id, sale.ShopId, productId, target.Id, target.Price, target.CompareAtPrice, price, SALE_ITEM_STATUS_APPLIED}
+			if _, err = StmtInsert(session, m.itemTable, fields, args); err != nil {
+				logx.Error("记录活动改价失败：", err)
+				return err
+			}
+			if err = stmtUpdatePrice(session, sale.ShopId, productId, target.Id, price, target.Price); err != nil {
+				return err
+			}
+			applied++
+		}
+		if applied == 0 {
+			return nil
+		}
+		return StmtInsertProductEvent(session, sale.ShopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if applied != 0 {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	}
+	return applied, nil
+}
+
+// RevertProduct 恢复一个产品的原价，返回恢复条数。活动期间被手动改过价格的不再恢复，保留商家的修改
+func (m *defaultSailProductSaleModel) RevertProduct(sale SailProductSale, productId int64) (int64, error) {
+	var reverted int64
+	err := m.Transact(func(session sqlx.Session) error {
+		var items []SailProductSaleItem
+		err := stmtQueryRows(session, &items, fmt.Sprintf("select %s from %s where `sale_id` = ? and `product_id` = ? and `status` = ? for update", sailProductSaleItemRows, m.itemTable), sale.Id, productId, SALE_ITEM_STATUS_APPLIED)
+		switch err {
+		case nil:
+		case sqlc.ErrNotFound:
+			return nil
+		default:
+			return err
+		}
+		for _, item := range items {
+			var current saleVariantRow
+			if item.VariantId == 0 {
+				err = stmtQueryRow(session, &current, "select `id`, `price`, `compare_at_price` from `sail_shop_product` where `shop_id` = ? and `id` = ? and `is_del` = 0 for update", sale.ShopId, productId)
+			} else {
+				err = stmtQueryRow(session, &current, "select `id`, `price`, `compare_at_price` from `sail_shop_product_variant` where `shop_id` = ? and `product_id` = ? and `id` = ? and `is_del` = 0 for update", sale.ShopId, productId, item.VariantId)
+			}
+			switch err {
+			case nil:
+				if current.Price == item.SalePrice && current.CompareAtPrice == item.OriginalPrice {
+					if err = stmtUpdatePrice(session, sale.ShopId, productId, item.VariantId, item.OriginalPrice, item.OriginalCompareAtPrice); err != nil {
+						return err
+					}
+					reverted++
+				} else {
+					logx.Infof("活动期间价格已被修改，不恢复原价 sale_id:%d product_id:%d variant_id:%d", sale.Id, productId, item.VariantId)
+				}
+			case sqlc.ErrNotFound:
+			default:
+				return err
+			}
+			if err = stmtExec(session, fmt.Sprintf("update %s set `status` = ? where `id` = ? ", m.itemTable), SALE_ITEM_STATUS_REVERTED, item.Id); err != nil {
+				return err
+			}
+		}
+		if reverted == 0 {
+			return nil
+		}
+		return StmtInsertProductEvent(session, sale.ShopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if reverted != 0 {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	}
+	return reverted, nil
+}
+
+func (s SailProductSale) HasFilter() bool {
+	return s.FilterVendor != "" || s.FilterTitle != ""
+}
+
+// CoversProduct 产品本身及其全部子商品是否参与活动；只指定了子商品的产品返回 false
+func (s SailProductSale) CoversProduct(productId int64, vendor, title string) bool {
+	for _, id := range ParseVariantIds(s.ProductIds.String) {
+		if id == productId {
+			return true
+		}
+	}
+	if !s.HasFilter() {
+		return false
+	}
+	if s.FilterVendor != "" && !strings.EqualFold(s.FilterVendor, vendor) {
+		return false
+	}
+	return s.FilterTitle == "" || strings.Contains(strings.ToLower(title), strings.ToLower(s.FilterTitle))
+}
+
+// stmtUpdatePrice variantId 为0时修改产品本身的价格，版本号同时加一
+func stmtUpdatePrice(session sqlx.Session, shopId, productId, variantId int64, price, compareAtPrice float64) error {
+	var err error
+	if variantId == 0 {
+		err = stmtExec(session, "update `sail_shop_product` set `price` = ?, `compare_at_price` = ?, `version` = `version` + 1 where `shop_id` = ? and `id` = ? ", price, compareAtPrice, shopId, productId)
+	} else {
+		err = stmtExec(session, "update `sail_shop_product_variant` set `price` = ?, `compare_at_price` = ?, `version` = `version` + 1 where `shop_id` = ? and `product_id` = ? and `id` = ? ", price, compareAtPrice, shopId, productId, variantId)
+	}
+	if err != nil {
+		logx.Error("修改活动价格失败：", err)
+	}
+	return err
+}
+
+func stmtExec(session sqlx.Session, query string, args ...interface{}) error {
+	stmt, err := session.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(args...)
+	return err
+}
+
+func stmtQueryRow(session sqlx.Session, v interface{}, query string, args ...interface{}) error {
+	stmt, err := session.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.QueryRow(v, args...)
+}
+
+func stmtQueryRows(session sqlx.Session, v interface{}, query string, args ...interface{}) error {
+	stmt, err := session.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.QueryRows(v, args...)
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+const (
+	SALE_STATUS_SCHEDULED = 1
+	SALE_STATUS_ACTIVE    = 2
+	SALE_STATUS_ENDED     = 3
+
+	SALE_ITEM_STATUS_APPLIED  = 1
+	SALE_ITEM_STATUS_REVERTED = 2
+)
@@ -0,0 +1,16 @@
+CREATE TABLE `sail_product_sitemap` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `kind` varchar(32) NOT NULL DEFAULT '' COMMENT '文件类型(product;category)',
+  `seq` int(11) NOT NULL DEFAULT '1' COMMENT '同类型文件序号，从1开始',
+  `first_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '文件包含的最小记录id',
+  `last_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '文件包含的最大记录id，0表示最后一个文件，不设上限',
+  `url_count` int(11) NOT NULL DEFAULT '0' COMMENT '文件中的链接数',
+  `dirty` int(11) NOT NULL DEFAULT '1' COMMENT '生成之后收到的变更次数，大于0时需要重新生成',
+  `lastmod` datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '文件中记录的最近更新时间',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_shop_kind_seq` (`shop_id`,`kind`,`seq`),
+  KEY `idx_shop_kind_range` (`shop_id`,`kind`,`first_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='店铺sitemap文件划分';
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+)
+
+var (
+	sailProductSitemapFieldNames = builderx.RawFieldNames(&SailProductSitemap{})
+	sailProductSitemapRows       = strings.Join(sailProductSitemapFieldNames, ",")
+)
+
+type (
+	// SailProductSitemapModel 记录每个店铺的sitemap子文件按记录id划分的范围。
+	// 产品id全局递增，新产品只会落在最后一个文件里，已有文件的范围不会变化，
+	// 收到变更事件时只需要把对应范围的文件标记为待重新生成
+	SailProductSitemapModel interface {
+		FindList(shopId int64) (*[]SailProductSitemap, error)
+		Reset(shopId int64, kind string) error
+		MarkDirty(shopId, recordId int64, kind string) error
+		MarkGenerated(id, dirty, urlCount int64, lastmod time.Time) error
+		Split(data SailProductSitemap, lastId int64) error
+		FindCategories(shopId, afterId, lastId, limit int64) (*[]SitemapCategory, error)
+	}
+
+	defaultSailProductSitemapModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	SailProductSitemap struct {
+		Id        int64     `db:"id"`
+		ShopId    int64     `db:"shop_id"`   // 商店唯一ID
+		Kind      string    `db:"kind"`      // 文件类型(product;category)
+		Seq       int64     `db:"seq"`       // 同类型文件序号，从1开始
+		FirstId   int64     `db:"first_id"`  // 文件包含的最小记录id
+		LastId    int64     `db:"last_id"`   // 文件包含的最大记录id，0表示最后一个文件，不设上限
+		UrlCount  int64     `db:"url_count"` // 文件中的链接数
+		Dirty     int64     `db:"dirty"`     // 生成之后收到的变更次数，大于0时需要重新生成
+		Lastmod   time.Time `db:"lastmod"`   // 文件中记录的最近更新时间
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+
+	SitemapCategory struct {
+		Id        int64     `db:"id"`
+		Handler   string    `db:"handler"`
+		ImageId   int64     `db:"image_id"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+)
+
+func NewSailProductSitemapModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductSitemapModel {
+	return &defaultSailProductSitemapModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_sitemap`",
+	}
+}
+
+func (m *defaultSailProductSitemapModel) FindList(shopId int64) (*[]SailProductSitemap, error) {
+	var resp []SailProductSitemap
+	query := fmt.Sprintf("select %s from %s where `shop_id` = ? order by `kind` desc, `seq` ", sailProductSitemapRows, m.table)
+	err := m.QueryRowsNoCache(&resp, query, shopId)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+// Reset 清空划分，只保留一个从头开始、不设上限的待生成文件，生成时超过单文件上限再拆分
+func (m *defaultSailProductSitemapModel) Reset(shopId int64, kind string) error {
+	return m.Transact(func(session sqlx.Session) error {
+		if _, err := session.Exec(fmt.Sprintf("delete from %s where `shop_id` = ? and `kind` = ? ", m.table), shopId, kind); err != nil {
+			return err
+		}
+		_, err := StmtInsert(session, m.table, []string{"`shop_id`", "`kind`", "`seq`", "`first_id`", "`last_id`", "`dirty`"}, []interface{}{shopId, kind, 1, 0, 0, 1})
+		return err
+	})
+}
+
+func (m *defaultSailProductSitemapModel) MarkDirty(shopId, recordId int64, kind string) error {
+	query := fmt.Sprintf("update %s set `dirty` = `dirty` + 1 where `shop_id` = ? and `kind` = ? and `first_id` <= ? and (`last_id` >= ? or `last_id` = 0) ", m.table)
+	_, err := m.ExecNoCache(query, shopId, kind, recordId, recordId)
+	return err
+}
+
+// MarkGenerated 生成完成后减去开始生成时读到的变更次数，生成期间新收到的变更会保留下来
+func (m *defaultSailProductSitemapModel) MarkGenerated(id, dirty, urlCount int64, lastmod time.Time) error {
+	query := fmt.Sprintf("update %s set `dirty` = greatest(`dirty` - ?, 0), `url_count` = ?, `lastmod` = ? where `id` = ? ", m.table)
+	_, err := m.ExecNoCache(query, dirty, urlCount, lastmod, id)
+	return err
+}
+
+// Split 最后一个文件写满后在 lastId 处截止，之后的记录放到新的最后一个文件
+func (m *defaultSailProductSitemapModel) Split(data SailProductSitemap, lastId int64) error {
+	return m.Transact(func(session sqlx.Session) error {
+		query := fmt.Sprintf("update %s set `last_id` = ? where `id` = ? and `last_id` = 0 ", m.table)
+		if _, err := session.Exec(query, lastId, data.Id); err != nil {
+			return err
+		}
+		fields := []string{"`shop_id`", "`kind`", "`seq`", "`first_id`", "`last_id`", "`dirty`"}
+		args := []interface{}{data.ShopId, data.Kind, data.Seq + 1, lastId + 1, 0, 1}
+		_, err := StmtInsert(session, m.table, fields, args)
+		return err
+	})
+}
+
+// FindCategories 按id顺序读取 (afterId, lastId] 之间的分类，lastId 为0时不限制上界
+func (m *defaultSailProductSitemapModel) FindCategories(shopId, afterId, lastId, limit int64) (*[]SitemapCategory, error) {
+	var resp []SitemapCategory
+	where := "`shop_id` = ? and `is_del` = 0 and `id` > ?"
+	args := []interface{}{shopId, afterId}
+	if lastId != 0 {
+		where += " and `id` <= ?"
+		args = append(args, lastId)
+	}
+	args = append(args, limit)
+	query := fmt.Sprintf("select `id`, `handler`, `image_id`, `updated_at` from `sail_shop_category` where %s order by `id` limit ? ", where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+const (
+	SITEMAP_KIND_PRODUCT  = "product"
+	SITEMAP_KIND_CATEGORY = "category"
+	SITEMAP_MAX_URLS      = 50000
+)
@@ -0,0 +1,14 @@
+CREATE TABLE `sail_product_translation` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `shop_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '商店唯一ID',
+  `owner_type` varchar(16) NOT NULL DEFAULT '' COMMENT '所属对象类型(product;variant;category)',
+  `owner_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '所属对象ID',
+  `locale` varchar(16) NOT NULL DEFAULT '' COMMENT '语言，例如 en、pt-BR',
+  `field` varchar(32) NOT NULL DEFAULT '' COMMENT '翻译的字段',
+  `value` mediumtext NOT NULL COMMENT '翻译内容',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_owner_locale_field` (`shop_id`,`owner_type`,`owner_id`,`locale`,`field`),
+  KEY `idx_shop_locale` (`shop_id`,`locale`,`owner_type`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='产品、子商品、分类的多语言翻译';
@@ -0,0 +1,289 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/cache"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/stores/sqlx"
+	"github.com/tal-tech/go-zero/tools/goctl/model/sql/builderx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/translation"
+)
+
+var (
+	sailProductTranslationFieldNames = builderx.RawFieldNames(&SailProductTranslation{})
+	sailProductTranslationRows       = strings.Join(sailProductTranslationFieldNames, ",")
+)
+
+type (
+	SailProductTranslationModel interface {
+		FindList(shopId int64, ownerType string, ownerIds []int64, locale string) (*[]SailProductTranslation, error)
+		FindValues(shopId int64, ownerType string, ownerIds []int64, locale string) (map[int64]translation.Values, error)
+		Set(shopId int64, ownerType string, ownerId int64, locale string, data []SailProductTranslation) error
+		Delete(shopId int64, ownerType string, ownerId int64, locale string, fields []string) (int64, error)
+		FindMissing(shopId int64, locale string, sinceId, limit int64) (*[]TranslationMissing, int64, error)
+		OwnerExists(shopId int64, ownerType string, ownerId int64) (bool, error)
+	}
+
+	defaultSailProductTranslationModel struct {
+		sqlc.CachedConn
+		table string
+	}
+
+	SailProductTranslation struct {
+		Id        int64     `db:"id"`
+		ShopId    int64     `db:"shop_id"`    // 商店唯一ID
+		OwnerType string    `db:"owner_type"` // 所属对象类型(product;variant;category)
+		OwnerId   int64     `db:"owner_id"`   // 所属对象ID
+		Locale    string    `db:"locale"`     // 语言
+		Field     string    `db:"field"`      // 翻译的字段
+		Value     string    `db:"value"`      // 翻译内容
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+
+	TranslationMissing struct {
+		ProductId       int64
+		Title           string
+		MissingFields   []string
+		MissingVariants int64
+	}
+
+	translationProductRow struct {
+		Id       int64          `db:"id"`
+		Title    string         `db:"title"`
+		SubTitle string         `db:"sub_title"`
+		BodyHtml sql.NullString `db:"body_html"`
+		SeoTitle string         `db:"seo_title"`
+		SeoDesc  string         `db:"seo_desc"`
+	}
+
+	translationVariantRow struct {
+		Id        int64          `db:"id"`
+		ProductId int64          `db:"product_id"`
+		Title     string         `db:"title"`
+		Spec      sql.NullString `db:"spec"`
+	}
+)
+
+func NewSailProductTranslationModel(conn sqlx.SqlConn, c cache.CacheConf) SailProductTranslationModel {
+	return &defaultSailProductTranslationModel{
+		CachedConn: sqlc.NewConn(conn, c),
+		table:      "`sail_product_translation`",
+	}
+}
+
+// FindList 批量查询多个对象的翻译，locale 为空时返回所有语言
+func (m *defaultSailProductTranslationModel) FindList(shopId int64, ownerType string, ownerIds []int64, locale string) (*[]SailProductTranslation, error) {
+	resp := make([]SailProductTranslation, 0)
+	if len(ownerIds) == 0 {
+		return &resp, nil
+	}
+	args := []interface{}{shopId, ownerType}
+	for _, id := range ownerIds {
+		args = append(args, id)
+	}
+	where := fmt.Sprintf("`shop_id` = ? and `owner_type` = ? and `owner_id` in (%s)", placeholders(len(ownerIds)))
+	if locale != "" {
+		where += " and `locale` = ?"
+		args = append(args, locale)
+	}
+	query := fmt.Sprintf("select %s from %s where %s order by `owner_id`, `locale`, `field` ", sailProductTranslationRows, m.table, where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+		return &resp, nil
+	default:
+		return nil, err
+	}
+}
+
+// FindValues 按对象分组返回某语言的翻译，读取接口用它替换原值
+func (m *defaultSailProductTranslationModel) FindValues(shopId int64, ownerType string, ownerIds []int64, locale string) (map[int64]translation.Values, error) {
+	resp, err := m.FindList(shopId, ownerType, ownerIds, locale)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int64]translation.Values)
+	for _, item := range *resp {
+		if result[item.OwnerId] == nil {
+			result[item.OwnerId] = make(translation.Values)
+		}
+		result[item.OwnerId][item.Field] = item.Value
+	}
+	return result, nil
+}
+
+// Set 按 locale+field 新增或覆盖一个对象的翻译，产品和子商品的修改会记录变更事件
+func (m *defaultSailProductTranslationModel) Set(shopId int64, ownerType string, ownerId int64, locale string, data []SailProductTranslation) error {
+	query := fmt.Sprintf("insert into %s (`shop_id`, `owner_type`, `owner_id`, `locale`, `field`, `value`) values (?, ?, ?, ?, ?, ?) "+
+		"on duplicate key update `value` = values(`value`)", m.table)
+	var productId int64
+	err := m.Transact(func(session sqlx.Session) error {
+		for _, item := range data {
+			if err := stmtExec(session, query, shopId, ownerType, ownerId, locale, item.Field, item.Value); err != nil {
+				logx.Error("保存翻译失败：", err)
+				return err
+			}
+		}
+		var err error
+		productId, err = stmtOwnerProductId(session, shopId, ownerType, ownerId)
+		if err != nil || productId == 0 {
+			return err
+		}
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return err
+	}
+	if productId != 0 {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	}
+	return nil
+}
+
+// Delete 删除一个对象某语言的翻译，fields 为空时删除该语言的全部字段，返回删除的条数
+func (m *defaultSailProductTranslationModel) Delete(shopId int64, ownerType string, ownerId int64, locale string, fields []string) (int64, error) {
+	var deleted, productId int64
+	err := m.Transact(func(session sqlx.Session) error {
+		query := fmt.Sprintf("delete from %s where `shop_id` = ? and `owner_type` = ? and `owner_id` = ? and `locale` = ?", m.table)
+		args := []interface{}{shopId, ownerType, ownerId, locale}
+		if len(fields) != 0 {
+			query += fmt.Sprintf(" and `field` in (%s)", placeholders(len(fields)))
+			for _, field := range fields {
+				args = append(args, field)
+			}
+		}
+		stmt, err := session.Prepare(query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err := stmt.Exec(args...)
+		if err != nil {
+			logx.Error("删除翻译失败：", err)
+			return err
+		}
+		if deleted, err = result.RowsAffected(); err != nil || deleted == 0 {
+			return err
+		}
+		productId, err = stmtOwnerProductId(session, shopId, ownerType, ownerId)
+		if err != nil || productId == 0 {
+			return err
+		}
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if productId != 0 {
+		m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	}
+	return deleted, nil
+}
+
+// FindMissing 按id检查 sinceId 之后的 limit 个产品，返回缺少翻译的产品和下一页的 sinceId，检查完时为0。
+// 原值为空的字段不需要翻译
+func (m *defaultSailProductTranslationModel) FindMissing(shopId int64, locale string, sinceId, limit int64) (*[]TranslationMissing, int64, error) {
+	resp := make([]TranslationMissing, 0)
+	var products []translationProductRow
+	query := "select `id`, `title`, `sub_title`, `body_html`, `seo_title`, `seo_desc` from `sail_shop_product` where `shop_id` = ? and `id` > ? and `is_del` = 0 order by `id` limit ?"
+	err := m.QueryRowsNoCache(&products, query, shopId, sinceId, limit)
+	if err != nil && err != sqlc.ErrNotFound {
+		return nil, 0, err
+	}
+	if len(products) == 0 {
+		return &resp, 0, nil
+	}
+	productIds := make([]int64, 0, len(products))
+	args := []interface{}{shopId}
+	for _, p := range products {
+		productIds = append(productIds, p.Id)
+		args = append(args, p.Id)
+	}
+	var variants []translationVariantRow
+	query = fmt.Sprintf("select `id`, `product_id`, `title`, `spec` from `sail_shop_product_variant` where `shop_id` = ? and `product_id` in (%s) and `is_del` = 0", placeholders(len(productIds)))
+	err = m.QueryRowsNoCache(&variants, query, args...)
+	if err != nil && err != sqlc.ErrNotFound {
+		return nil, 0, err
+	}
+	variantIds := make([]int64, 0, len(variants))
+	for _, v := range variants {
+		variantIds = append(variantIds, v.Id)
+	}
+
+	productValues, err := m.FindValues(shopId, metafield.OwnerProduct, productIds, locale)
+	if err != nil {
+		return nil, 0, err
+	}
+	variantValues, err := m.FindValues(shopId, metafield.OwnerVariant, variantIds, locale)
+	if err != nil {
+		return nil, 0, err
+	}
+	missingVariants := make(map[int64]int64)
+	for _, v := range variants {
+		if len(missingFields(variantValues[v.Id], map[string]string{
+			translation.FieldTitle: v.Title,
+			translation.FieldSpec:  strings.TrimSpace(strings.Trim(v.Spec.String, "[]")),
+		})) != 0 {
+			missingVariants[v.ProductId]++
+		}
+	}
+	for _, p := range products {
+		fields := missingFields(productValues[p.Id], map[string]string{
+			translation.FieldTitle:    p.Title,
+			translation.FieldSubTitle: p.SubTitle,
+			translation.FieldBodyHtml: p.BodyHtml.String,
+			translation.FieldSeoTitle: p.SeoTitle,
+			translation.FieldSeoDesc:  p.SeoDesc,
+		})
+		if len(fields) == 0 && missingVariants[p.Id] == 0 {
+			continue
+		}
+		resp = append(resp, TranslationMissing{
+			ProductId:       p.Id,
+			Title:           p.Title,
+			MissingFields:   fields,
+			MissingVariants: missingVariants[p.Id],
+		})
+	}
+	nextSinceId := products[len(products)-1].Id
+	if int64(len(products)) < limit {
+		nextSinceId = 0
+	}
+	return &resp, nextSinceId, nil
+}
+
+func (m *defaultSailProductTranslationModel) OwnerExists(shopId int64, ownerType string, ownerId int64) (bool, error) {
+	return ownerExists(m.CachedConn, shopId, ownerType, ownerId)
+}
+
+// missingFields 返回原值不为空但没有翻译的字段
+func missingFields(values translation.Values, base map[string]string) []string {
+	var result []string
+	for _, field := range []string{translation.FieldTitle, translation.FieldSubTitle, translation.FieldBodyHtml, translation.FieldSeoTitle, translation.FieldSeoDesc, translation.FieldSpec} {
+		if strings.TrimSpace(base[field]) == "" {
+			continue
+		}
+		if values[field] == "" {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// StmtDeleteProductTranslations 删除产品及其子商品的翻译
+func StmtDeleteProductTranslations(session sqlx.Session, shopId, productId int64) error {
+	query := "delete from `sail_product_translation` where `shop_id` = ? and ((`owner_type` = ? and `owner_id` = ?) or " +
+		"(`owner_type` = ? and `owner_id` in (select `id` from `sail_shop_product_variant` where `shop_id` = ? and `product_id` = ?)))"
+	err := stmtExec(session, query, shopId, metafield.OwnerProduct, productId, metafield.OwnerVariant, shopId, productId)
+	if err != nil {
+		logx.Error("删除产品翻译失败：", err)
+	}
+	return err
+}
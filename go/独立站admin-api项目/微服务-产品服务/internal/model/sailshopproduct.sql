@@ -37,6 +37,8 @@ CREATE TABLE `sail_shop_product` (
   `youtube_video_url` varchar(255) NOT NULL DEFAULT '' COMMENT 'youtube视频地址',
   `youtube_video_pos` char(100) NOT NULL DEFAULT '' COMMENT 'youtube视频所在轮播图中位置',
   `sub_title` varchar(400) NOT NULL DEFAULT '' COMMENT '副标题',
+  `vendor` varchar(255) NOT NULL DEFAULT '' COMMENT '供应商/品牌',
+  `version` bigint(20) NOT NULL DEFAULT '1' COMMENT '乐观锁版本号，每次修改加一',
   PRIMARY KEY (`id`),
   UNIQUE KEY `unique_product_handler` (`shop_id`,`handler`),
   KEY `index_shop_id_handler` (`shop_id`,`handler`),
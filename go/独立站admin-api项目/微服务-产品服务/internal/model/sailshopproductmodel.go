@@ -1,18 +1,23 @@
 package model
 
 import (
+	"crypto/md5"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/tal-tech/go-zero/core/logx"
 	"github.com/tal-tech/go-zero/core/mr"
 	"github.com/tal-tech/go-zero/core/stores/redis"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/slug"
 	"math"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/tal-tech/go-zero/core/stores/cache"
 	"github.com/tal-tech/go-zero/core/stores/sqlc"
@@ -26,11 +31,23 @@ import (
 var (
 	sailShopProductFieldNames          = builderx.RawFieldNames(&SailShopProduct{})
 	sailShopProductRows                = strings.Join(sailShopProductFieldNames, ",")
-	sailShopProductRowsExpectAutoSet   = strings.Join(stringx.Remove(sailShopProductFieldNames, "`id`", "`create_time`", "`update_time`"), ",")
-	sailShopProductRowsWithPlaceHolder = strings.Join(stringx.Remove(sailShopProductFieldNames, "`id`", "`create_time`", "`update_time`"), "=?,") + "=?"
+	sailShopProductRowsExpectAutoSet   = strings.Join(stringx.Remove(sailShopProductFieldNames, "`id`", "`create_time`", "`update_time`", "`version`"), ",")
+	sailShopProductRowsWithPlaceHolder = strings.Join(stringx.Remove(sailShopProductFieldNames, "`id`", "`create_time`", "`update_time`", "`version`"), "=?,") + "=?"
 
 	cacheSailShopProductIdPrefix            = "cache#sailShopProduct#id#"
 	cacheSailShopProductShopIdHandlerPrefix = "cache#sailShopProduct#shopId#handler#"
+
+	ErrHandleInvalid            = errors.New("handle may only contain lowercase letters, numbers, '-' and '_'")
+	ErrHandleTaken              = errors.New("handle is already taken by another product")
+	ErrIdsInvalid               = errors.New("field ids in wrong format")
+	ErrOnlyUnpublishedDeletable = errors.New("only unpublished product can be deleted")
+	ErrTitleBusy                = errors.New("product title repeated, too many request")
+	ErrHandleBusy               = errors.New("product handle repeated, too many request")
+
+	handleRegexp = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}]+(?:[-_][\p{Ll}\p{Lo}\p{N}]+)*$`)
+
+	// UnpublishedAt 未发布产品的 published_at，和建表的默认值一致
+	UnpublishedAt = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local)
 )
 
 type (
@@ -38,12 +55,23 @@ type (
 		Insert(data SailShopProduct) (sql.Result, error)
 		InsertProduct(data InsertProductData, redis *redis.Redis) (sql.Result, *RespImageData, error)
 		FindOne(shopId int64, filter ...HandlerOption) (*SailShopProduct, error)
+		FindOneById(productId int64) (*SailShopProduct, error)
 		FindList(options ListOptions, shopId int64) (*[]SailShopProduct, error)
 		Count(shopId int64, options ListOptions) (int64, error)
 		FindOneByShopIdHandler(shopId int64, handler string) (*SailShopProduct, error)
 		Delete(shopId, productId int64, redis2 *redis.Redis) error
+		DeleteWithVersion(shopId, productId, expectedVersion int64, redis2 *redis.Redis) error
+		FindVariantVersion(shopId, variantId int64) (int64, error)
+		FindVersion(shopId, productId int64) (int64, error)
+		Patch(shopId, productId int64, data ProductPatchData) error
+		PatchVariant(shopId, productId, variantId int64, data VariantPatchData) error
 		Update(data InsertProductData, productId int64, redis2 *redis.Redis) (*RespImageData, error)
 		UpdateDefaultImage(imageId int64, productId int64) error
+		InsertVariant(shopId, productId int64, data VariantData, redis2 *redis.Redis) (sql.Result, error)
+		UpdateVariant(data VariantData, shopId, productId, variantId int64) error
+		UpdateVariantImage(imageId, productId, variantId int64) error
+		FindPublishedAfter(shopId, afterId, lastId, limit int64) (*[]SailShopProduct, error)
+		FindCachedByIds(shopId int64, ids []int64) (map[int64]SailShopProduct, error)
 	}
 
 	defaultSailShopProductModel struct {
@@ -90,6 +118,8 @@ type (
 		WeightUnit         string         `db:"weight_unit"`       // 产品重量单位
 		PublishedAt        time.Time      `db:"published_at"`      // 发布时间
 		IsRead             int64          `db:"is_read"`           // 产品导入 先判定是否已经读取 1读取 2未读取
+		Vendor             string         `db:"vendor"`            // 供应商/品牌
+		Version            int64          `db:"version"`           // 乐观锁版本号，每次修改加一
 	}
 
 	ListOptions struct {
@@ -109,6 +139,11 @@ type (
 		Title           string
 		Handlers        []string
 		IsNewVersion    bool
+		Metafield       MetafieldFilter
+		// SortBy 为 LIST_SORT_RATING 时按贝叶斯加权分倒序，需同时传入先验平均分和权重
+		SortBy            string
+		RatingPriorMean   float64
+		RatingPriorWeight float64
 	}
 
 	InsertProductData struct {
@@ -133,10 +168,12 @@ type (
 		OriginHandler     string
 		Tags              []string
 		Attribute         string
+		Vendor            string
 		InventoryQuantity int64
 		DefaultImage      ImageData
 		Images            *[]ImageData
 		Variants          *[]VariantData
+		ExpectedVersion   int64 // 期望的产品版本，为 0 时不校验
 	}
 
 	ImageData struct {
@@ -160,6 +197,7 @@ type (
 		Title             string
 		Options           string
 		IsChecked         int64
+		ExpectedVersion   int64 // 期望的子商品版本，为 0 时不校验
 	}
 
 	RespImageData struct {
@@ -203,6 +241,30 @@ type (
 		Id int64
 		VariantItem
 	}
+
+	// PatchColumn 部分更新写入的列，值原样写入，包括 0 和空字符串
+	PatchColumn struct {
+		Name  string
+		Value interface{}
+	}
+
+	ProductPatchData struct {
+		Columns          []PatchColumn
+		BodyHtml         *string // 不为 nil 时写入描述
+		DefaultVariantId int64   // 单规格产品隐藏的默认子商品，不为 0 时同时写入 VariantColumns
+		VariantColumns   []PatchColumn
+		ExpectedVersion  int64
+	}
+
+	VariantPatchData struct {
+		Columns         []PatchColumn
+		ExpectedVersion int64
+	}
+
+	// VersionConflictError 写入时期望的版本和当前版本不一致
+	VersionConflictError struct {
+		Current int64
+	}
 )
 
 func NewSailShopProductModel(conn sqlx.SqlConn, c cache.CacheConf) SailShopProductModel {
@@ -224,8 +286,8 @@ func (m *defaultSailShopProductModel) Insert(data SailShopProduct) (sql.Result,
 	data.Handler = data.Title
 	data.HandlerOrigin = data.Title
 	ret, err := m.Exec(func(conn sqlx.SqlConn) (result sql.Result, err error) {
-		query := fmt.Sprintf("insert into %s (%s) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", m.table, sailShopProductRowsExpectAutoSet)
-		return conn.Exec(query, data.ImageTmpUrls, data.Scores, data.CountSales, data.YoutubeVideoPos, data.DefaultImageId, data.IsDel, data.Status, data.ProductStock, data.Sort, data.Handler, data.ShopId, data.DefaultSkuCode, data.CountSkus, data.IsUseStock, data.SoldoutPolicy, data.Source, data.CreatedAt, data.HandlerOrigin, data.Price, data.IsShowComment, data.SeoTitle, data.UpdatedAt, data.BodyHtml, data.Weight, data.Comments, data.SeoDesc, data.Attribute, data.IsLogistics, data.SubTitle, data.Title, data.ImageIds, data.DefaultImageTmpUrl, data.YoutubeVideoUrl, data.CompareAtPrice, data.WeightUnit, data.PublishedAt, data.IsRead)
+		query := fmt.Sprintf("insert into %s (%s) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", m.table, sailShopProductRowsExpectAutoSet)
+		return conn.Exec(query, data.ImageTmpUrls, data.Scores, data.CountSales, data.YoutubeVideoPos, data.DefaultImageId, data.IsDel, data.Status, data.ProductStock, data.Sort, data.Handler, data.ShopId, data.DefaultSkuCode, data.CountSkus, data.IsUseStock, data.SoldoutPolicy, data.Source, data.CreatedAt, data.HandlerOrigin, data.Price, data.IsShowComment, data.SeoTitle, data.UpdatedAt, data.BodyHtml, data.Weight, data.Comments, data.SeoDesc, data.Attribute, data.IsLogistics, data.SubTitle, data.Title, data.ImageIds, data.DefaultImageTmpUrl, data.YoutubeVideoUrl, data.CompareAtPrice, data.WeightUnit, data.PublishedAt, data.IsRead, data.Vendor)
 	}, sailShopProductShopIdHandlerKey)
 	return ret, err
 }
@@ -238,7 +300,7 @@ func (m *defaultSailShopProductModel) InsertProduct(data InsertProductData, redi
 	redisLock := redis.NewRedisLock(redis2, lockKey)
 	redisLock.SetExpire(5)
 	if ok, err := redisLock.Acquire(); !ok || err != nil {
-		return nil, nil, errors.New(" product title repeated, too many request ")
+		return nil, nil, ErrTitleBusy
 	}
 	defer func() {
 		recover()
@@ -255,8 +317,17 @@ func (m *defaultSailShopProductModel) InsertProduct(data InsertProductData, redi
 	if data.SeoDesc == "" {
 		data.SeoDesc = m.GetDefaultSeoDesc(data.BodyHtml)
 	}
-	data.Handler = m.generateHandler(data.Title, data.ShopId)
-	data.OriginHandler = m.generateOriginHandler(data.Title)
+	autoHandle := data.Handler == ""
+	if autoHandle {
+		data.OriginHandler = ProductHandleOwner.Base(data.Title)
+	} else {
+		handleLock, err := lockHandle(redis2, data.ShopId, data.Handler)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer handleLock.Release()
+		data.OriginHandler = data.Handler
+	}
 
 	if data.ShopId == 0 {
 		logx.Error("缺少shop_id参数")
@@ -268,7 +339,23 @@ func (m *defaultSailShopProductModel) InsertProduct(data InsertProductData, redi
 	}
 	logx.Error("variants", data.Variants)
 
-	err = m.Transact(func(session sqlx.Session) error {
+	insertTx := func(session sqlx.Session) error {
+		respImageData.Images, respImageData.Variants = nil, nil
+		if autoHandle {
+			handler, err := StmtPickHandle(session, ProductHandleOwner, data.ShopId, data.OriginHandler)
+			if err != nil {
+				return err
+			}
+			data.Handler = handler
+		} else {
+			if err := StmtCheckHandle(session, data.ShopId, data.Id, data.Handler); err != nil {
+				return err
+			}
+			// 商户指定的链接优先于其他产品的旧链接跳转；自动生成的链接不会和跳转重复
+			if err := StmtReleaseRedirectHandle(session, data.ShopId, data.Handler); err != nil {
+				return err
+			}
+		}
 		resultProduct, err := StmtInsertProduct(session, data)
 		if err != nil {
 			logx.Error(err)
@@ -386,18 +473,26 @@ func (m *defaultSailShopProductModel) InsertProduct(data InsertProductData, redi
 				}
 			}
 		}
+		err = StmtInsertImageJobs(session, BuildImageJobs(data.ShopId, lastProId, &respImageData))
+		if err != nil {
+			logx.Error(err)
+			return err
+		}
+		err = StmtInsertProductEvent(session, data.ShopId, lastProId, ES_SYNC_EVENT_ADD)
+		if err != nil {
+			return err
+		}
 
 		return nil
 
+	}
+	err = ProductHandleOwner.Retry(autoHandle, func() error {
+		return m.Transact(insertTx)
 	})
 	if err != nil {
 		logx.Error(err)
 		return nil, nil, err
 	}
-	err = m.AfterSave(data.ShopId, lastProId, ES_SYNC_EVENT_ADD, redis2)
-	if err != nil {
-		logx.Error(err)
-	}
 	return result, &respImageData, nil
 }
 
@@ -408,9 +503,200 @@ func (m *defaultSailShopProductModel) UpdateDefaultImage(imageId int64, productI
 	if productId == 0 {
 		return errors.New("product id is not set")
 	}
-	query := fmt.Sprintf("update %s set  `default_image_id` = ?  where `id` = ? and `is_del` = 0 ", m.table)
-	_, err := m.ExecNoCache(query, imageId, productId)
-	return err
+	productInfo, err := m.FindOneById(productId)
+	if err != nil {
+		return err
+	}
+	err = m.Transact(func(session sqlx.Session) error {
+		query := fmt.Sprintf("update %s set `default_image_id` = ?, `version` = `version` + 1 where `id` = ? and `is_del` = 0 ", m.table)
+		stmt, err := session.Prepare(query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if _, err = stmt.Exec(imageId, productId); err != nil {
+			logx.Error("设置商品主图出错：", err)
+			return err
+		}
+		return StmtInsertProductEvent(session, productInfo.ShopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return err
+	}
+	m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	return nil
+}
+
+// InsertVariant 单独新增子商品，和变更事件在同一个事务里写入
+func (m *defaultSailShopProductModel) InsertVariant(shopId, productId int64, data VariantData, redis2 *redis.Redis) (sql.Result, error) {
+	var result sql.Result
+	err := m.Transact(func(session sqlx.Session) error {
+		resultVariant, err := StmtInsertVariant(session, data, productId, shopId, redis2)
+		if err != nil {
+			return err
+		}
+		result = resultVariant
+		variantId, err := resultVariant.LastInsertId()
+		if err != nil {
+			logx.Error("新增子商品出错：", err)
+			return err
+		}
+		countQuery := fmt.Sprintf("update %s set `count_skus` = `count_skus` + 1, `version` = `version` + 1 where `id` = ? and `shop_id` = ? ", m.table)
+		stmt, err := session.Prepare(countQuery)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if _, err = stmt.Exec(productId, shopId); err != nil {
+			logx.Error("更新sku数量出错：", err)
+			return err
+		}
+		if data.Image.FileKey != "" {
+			if err = StmtInsertVariantImage(session, shopId, productId, variantId, data.Image); err != nil {
+				return err
+			}
+		}
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	return result, nil
+}
+
+func (m *defaultSailShopProductModel) UpdateVariant(data VariantData, shopId, productId, variantId int64) error {
+	data.Id = variantId
+	err := m.Transact(func(session sqlx.Session) error {
+		if err := StmtCheckVersion(session, "`sail_shop_product_variant`", shopId, variantId, data.ExpectedVersion); err != nil {
+			return err
+		}
+		oldStock, err := StmtQueryStock(session, shopId, productId)
+		if err != nil {
+			return err
+		}
+		err = StmtUpdateVariant(session, data, productId, shopId)
+		if err != nil {
+			return err
+		}
+		if data.Image.FileKey != "" {
+			if err = StmtInsertVariantImage(session, shopId, productId, variantId, data.Image); err != nil {
+				return err
+			}
+		}
+		newStock, err := StmtQueryStock(session, shopId, productId)
+		if err != nil {
+			return err
+		}
+		if newStock.Differs(oldStock) {
+			if err = StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_INVENTORY); err != nil {
+				return err
+			}
+		}
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return err
+	}
+	m.DelCache(fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId))
+	return nil
+}
+
+// Patch 只写入 data 中列出的列，和 Update 不同，0 和空字符串也会写入
+func (m *defaultSailShopProductModel) Patch(shopId, productId int64, data ProductPatchData) error {
+	err := m.Transact(func(session sqlx.Session) error {
+		if err := StmtCheckVersion(session, m.table, shopId, productId, data.ExpectedVersion); err != nil {
+			return err
+		}
+		if err := stmtPatch(session, m.table, data.Columns, "`shop_id` = ? and `id` = ? and `is_del` = 0", shopId, productId); err != nil {
+			return err
+		}
+		if data.DefaultVariantId != 0 && len(data.VariantColumns) != 0 {
+			err := stmtPatch(session, "`sail_shop_product_variant`", data.VariantColumns, "`shop_id` = ? and `product_id` = ? and `id` = ? and `is_del` = 0", shopId, productId, data.DefaultVariantId)
+			if err != nil {
+				return err
+			}
+		}
+		if data.BodyHtml != nil {
+			detailModel := defaultSailShopProductDetailModel{
+				CachedConn: m.CachedConn,
+				table:      "sail_shop_product_detail",
+			}
+			if err := detailModel.Update(SailShopProductDetail{ProductId: productId, BodyHtml: *data.BodyHtml}, session); err != nil {
+				logx.Error("body_html修改失败:", err)
+				return err
+			}
+		}
+		if hasPatchColumn(data.Columns, "product_stock") {
+			if err := StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_INVENTORY); err != nil {
+				return err
+			}
+		}
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return err
+	}
+	m.delProductCache(shopId, productId)
+	return nil
+}
+
+func (m *defaultSailShopProductModel) PatchVariant(shopId, productId, variantId int64, data VariantPatchData) error {
+	err := m.Transact(func(session sqlx.Session) error {
+		if err := StmtCheckVersion(session, "`sail_shop_product_variant`", shopId, variantId, data.ExpectedVersion); err != nil {
+			return err
+		}
+		err := stmtPatch(session, "`sail_shop_product_variant`", data.Columns, "`shop_id` = ? and `product_id` = ? and `id` = ? and `is_del` = 0", shopId, productId, variantId)
+		if err != nil {
+			return err
+		}
+		if hasPatchColumn(data.Columns, "inventory_quantity") {
+			if err := StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_INVENTORY); err != nil {
+				return err
+			}
+		}
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
+	if err != nil {
+		return err
+	}
+	m.delProductCache(shopId, productId)
+	return nil
+}
+
+// stmtPatch 写入列出的列并把版本号加一，只修改描述时也会更新版本号
+func stmtPatch(session sqlx.Session, table string, columns []PatchColumn, where string, args ...interface{}) error {
+	sets := make([]string, 0, len(columns)+1)
+	values := make([]interface{}, 0, len(columns)+len(args))
+	for _, column := range columns {
+		sets = append(sets, fmt.Sprintf("`%s` = ?", column.Name))
+		values = append(values, column.Value)
+	}
+	sets = append(sets, "`version` = `version` + 1")
+	query := fmt.Sprintf("update %s set %s where %s", table, strings.Join(sets, ", "), where)
+	return stmtExec(session, query, append(values, args...)...)
+}
+
+func hasPatchColumn(columns []PatchColumn, name string) bool {
+	for _, column := range columns {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *defaultSailShopProductModel) UpdateVariantImage(imageId, productId, variantId int64) error {
+	productInfo, err := m.FindOneById(productId)
+	if err != nil {
+		return err
+	}
+	return m.Transact(func(session sqlx.Session) error {
+		if err := StmtUpdateVariantImageId(session, imageId, variantId); err != nil {
+			return err
+		}
+		return StmtInsertProductEvent(session, productInfo.ShopId, productId, ES_SYNC_EVENT_UPDATE)
+	})
 }
 
 func (m *defaultSailShopProductModel) FindOne(shopId int64, filter ...HandlerOption) (*SailShopProduct, error) {
@@ -437,6 +723,94 @@ func (m *defaultSailShopProductModel) FindOne(shopId int64, filter ...HandlerOpt
 	}
 }
 
+// FindCachedByIds 按id读取产品，优先读产品id缓存；不存在、已删除或不属于该店铺的产品不返回
+func (m *defaultSailShopProductModel) FindCachedByIds(shopId int64, ids []int64) (map[int64]SailShopProduct, error) {
+	resp := make(map[int64]SailShopProduct, len(ids))
+	if len(ids) == 0 {
+		return resp, nil
+	}
+	r, err := mr.MapReduce(func(source chan<- interface{}) {
+		for _, id := range ids {
+			source <- id
+		}
+	}, func(item interface{}, writer mr.Writer, cancel func(error)) {
+		id := item.(int64)
+		var row SailShopProduct
+		err := m.QueryRow(&row, fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, id), func(conn sqlx.SqlConn, v interface{}) error {
+			return m.queryPrimary(conn, v, id)
+		})
+		switch err {
+		case nil:
+			if row.ShopId == shopId && row.IsDel == 0 {
+				writer.Write(row)
+			}
+		case sqlc.ErrNotFound:
+		default:
+			cancel(err)
+		}
+	}, func(pipe <-chan interface{}, writer mr.Writer, cancel func(error)) {
+		for p := range pipe {
+			row := p.(SailShopProduct)
+			resp[row.Id] = row
+		}
+		writer.Write(resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.(map[int64]SailShopProduct), nil
+}
+
+// FindPublishedAfter 按id顺序读取 (afterId, lastId] 之间已上架的产品，lastId 为0时不限制上界
+func (m *defaultSailShopProductModel) FindPublishedAfter(shopId, afterId, lastId, limit int64) (*[]SailShopProduct, error) {
+	var resp []SailShopProduct
+	where := "`shop_id` = ? and `status` = 1 and `is_del` = 0 and `id` > ?"
+	args := []interface{}{shopId, afterId}
+	if lastId != 0 {
+		where += " and `id` <= ?"
+		args = append(args, lastId)
+	}
+	args = append(args, limit)
+	query := fmt.Sprintf("select %s from %s where %s order by `id` limit ? ", sailShopProductRows, m.table, where)
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *defaultSailShopProductModel) FindVariantVersion(shopId, variantId int64) (int64, error) {
+	var version int64
+	query := "select `version` from `sail_shop_product_variant` where `shop_id` = ? and `id` = ? and `is_del` = 0 limit 1"
+	err := m.QueryRowNoCache(&version, query, shopId, variantId)
+	return version, err
+}
+
+func (m *defaultSailShopProductModel) FindVersion(shopId, productId int64) (int64, error) {
+	var version int64
+	query := fmt.Sprintf("select `version` from %s where `shop_id` = ? and `id` = ? and `is_del` = 0 limit 1", m.table)
+	err := m.QueryRowNoCache(&version, query, shopId, productId)
+	return version, err
+}
+
+func (m *defaultSailShopProductModel) FindOneById(productId int64) (*SailShopProduct, error) {
+	var resp SailShopProduct
+	query := fmt.Sprintf("select %s from %s where `id` = ? and is_del = 0 limit 1", sailShopProductRows, m.table)
+	err := m.QueryRowNoCache(&resp, query, productId)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
 func (m *defaultSailShopProductModel) Count(shopId int64, options ListOptions) (int64, error) {
 	var resp int64
 	args := make([]interface{}, 0)
@@ -456,8 +830,8 @@ func (m *defaultSailShopProductModel) Count(shopId int64, options ListOptions) (
 		if len(idSet) == 1 {
 			idNum, err := strconv.Atoi(idSet[0])
 			if err != nil {
-				logx.Error("err:", errors.New(" field ids in wrong format "))
-				return 0, errors.New(" field ids in wrong format ")
+				logx.Error("err:", ErrIdsInvalid)
+				return 0, ErrIdsInvalid
 			}
 			args = append(args, idNum)
 			query += " and id in (?) "
@@ -466,8 +840,8 @@ func (m *defaultSailShopProductModel) Count(shopId int64, options ListOptions) (
 			for k, id := range idSet {
 				idNum, err := strconv.Atoi(id)
 				if err != nil {
-					logx.Error("err:", errors.New(" field ids in wrong format "))
-					return 0, errors.New(" field ids in wrong format ")
+					logx.Error("err:", ErrIdsInvalid)
+					return 0, ErrIdsInvalid
 				}
 				if k == 0 {
 					args = append(args, idNum)
@@ -547,6 +921,10 @@ func (m *defaultSailShopProductModel) Count(shopId int64, options ListOptions) (
 		}
 
 	}
+	if options.Metafield.Key != "" {
+		args = append(args, shopId, metafield.OwnerProduct, options.Metafield.Namespace, options.Metafield.Key, options.Metafield.Value)
+		query += metafieldFilterQuery
+	}
 	err := m.QueryRowNoCache(&resp, query, args...)
 	switch err {
 	case nil:
@@ -581,8 +959,8 @@ func (m *defaultSailShopProductModel) FindList(options ListOptions, shopId int64
 		if len(idSet) == 1 {
 			idNum, err := strconv.Atoi(idSet[0])
 			if err != nil {
-				logx.Error("err:", errors.New(" field ids in wrong format "))
-				return nil, errors.New(" field ids in wrong format ")
+				logx.Error("err:", ErrIdsInvalid)
+				return nil, ErrIdsInvalid
 			}
 			queryCount += " and id in (?) "
 			args = append(args, idNum)
@@ -593,8 +971,8 @@ func (m *defaultSailShopProductModel) FindList(options ListOptions, shopId int64
 			for k, id := range idSet {
 				idNum, err := strconv.Atoi(id)
 				if err != nil {
-					logx.Error("err:", errors.New(" field ids in wrong format "))
-					return nil, errors.New(" field ids in wrong format ")
+					logx.Error("err:", ErrIdsInvalid)
+					return nil, ErrIdsInvalid
 				}
 				if k == 0 {
 					queryCount += " and id in ( ? "
@@ -703,8 +1081,19 @@ func (m *defaultSailShopProductModel) FindList(options ListOptions, shopId int64
 		}
 
 	}
+	if options.Metafield.Key != "" {
+		queryCount += metafieldFilterQuery
+		args = append(args, shopId, metafield.OwnerProduct, options.Metafield.Namespace, options.Metafield.Key, options.Metafield.Value)
+		query += metafieldFilterQuery
+		argsQuery = append(argsQuery, shopId, metafield.OwnerProduct, options.Metafield.Namespace, options.Metafield.Key, options.Metafield.Value)
+	}
 
-	query += " order by created_at desc"
+	if options.SortBy == LIST_SORT_RATING {
+		query += ratingOrderQuery
+		argsQuery = append(argsQuery, options.RatingPriorWeight*options.RatingPriorMean, options.RatingPriorWeight)
+	} else {
+		query += " order by created_at desc"
+	}
 
 	if options.Limit > 0 {
 		if options.IsNewVersion == true {
@@ -792,18 +1181,45 @@ func (m *defaultSailShopProductModel) Update(data InsertProductData, productId i
 		redisLock := redis.NewRedisLock(redis2, lockKey)
 		redisLock.SetExpire(5)
 		if ok, err := redisLock.Acquire(); !ok || err != nil {
-			return nil, errors.New(" product title repeated, too many request ")
+			return nil, ErrTitleBusy
 		}
 		defer func() {
 			recover()
 			redisLock.Release()
 		}()
 	}
+	if data.Handler != "" {
+		handleLock, err := lockHandle(redis2, data.ShopId, data.Handler)
+		if err != nil {
+			return nil, err
+		}
+		defer handleLock.Release()
+	}
 	var respImageData RespImageData
 	respImageData.DefaultImageUrl = data.DefaultImage.FileKey
-	handlerOrigin := m.generateOriginHandler(data.Title)
-	data.OriginHandler = m.generateHandler(data.Title, data.ShopId)
+	changedHandles := make([]string, 0, 2)
 	err := m.Transact(func(session sqlx.Session) error {
+		if err := StmtCheckVersion(session, "`sail_shop_product`", data.ShopId, productId, data.ExpectedVersion); err != nil {
+			return err
+		}
+		if data.Handler != "" {
+			oldHandle, err := StmtQueryProductHandle(session, data.ShopId, productId)
+			if err != nil {
+				return err
+			}
+			if oldHandle == data.Handler {
+				data.Handler = ""
+			} else {
+				if err := StmtCheckHandle(session, data.ShopId, productId, data.Handler); err != nil {
+					return err
+				}
+				if err := StmtChangeProductHandle(session, data.ShopId, productId, oldHandle, data.Handler); err != nil {
+					return err
+				}
+				data.OriginHandler = data.Handler
+				changedHandles = append(changedHandles, oldHandle, data.Handler)
+			}
+		}
 		respVariants, err := StmtQueryVariants(session, data.ShopId, productId)
 		switch err {
 		case nil:
@@ -836,7 +1252,12 @@ func (m *defaultSailShopProductModel) Update(data InsertProductData, productId i
 			return err
 		}
 
-		err = StmtUpdateProduct(session, data, productId, handlerOrigin, 0)
+		// 商品库存或子商品库存有变化时需要额外发出库存事件，写入前后各取一次库存比较
+		oldStock, err := StmtQueryStock(session, data.ShopId, productId)
+		if err != nil {
+			return err
+		}
+		err = StmtUpdateProduct(session, data, productId, data.OriginHandler, 0)
 		if err != nil {
 			logx.Error(err)
 			return err
@@ -959,31 +1380,53 @@ func (m *defaultSailShopProductModel) Update(data InsertProductData, productId i
 			}
 
 		}
+		err = StmtInsertImageJobs(session, BuildImageJobs(data.ShopId, productId, &respImageData))
+		if err != nil {
+			logx.Error(err)
+			return err
+		}
+		newStock, err := StmtQueryStock(session, data.ShopId, productId)
+		if err != nil {
+			return err
+		}
+		if newStock.Differs(oldStock) {
+			if err = StmtInsertProductEvent(session, data.ShopId, productId, ES_SYNC_EVENT_INVENTORY); err != nil {
+				return err
+			}
+		}
+		err = StmtInsertProductEvent(session, data.ShopId, productId, ES_SYNC_EVENT_UPDATE)
+		if err != nil {
+			return err
+		}
 
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-
-	err = m.AfterSave(data.ShopId, productId, ES_SYNC_EVENT_UPDATE, redis2)
-	if err != nil {
-		logx.Error(err)
-	}
+	m.delProductCache(data.ShopId, productId, changedHandles...)
 	return &respImageData, nil
 }
 
 func (m *defaultSailShopProductModel) Delete(shopId, productId int64, redis2 *redis.Redis) error {
+	return m.DeleteWithVersion(shopId, productId, 0, redis2)
+}
+
+// DeleteWithVersion expectedVersion 不为 0 时和当前版本不一致返回 *VersionConflictError
+func (m *defaultSailShopProductModel) DeleteWithVersion(shopId, productId, expectedVersion int64, redis2 *redis.Redis) error {
 	productInfo, err := m.FindOne(shopId, WithId(productId))
 	if err != nil {
 		return err
 	}
 
 	if productInfo.Status == 1 {
-		logx.Error("only unpublished product can be deleted")
-		return errors.New("only unpublished product can be deleted")
+		logx.Error(ErrOnlyUnpublishedDeletable)
+		return ErrOnlyUnpublishedDeletable
 	}
 	err = m.Transact(func(session sqlx.Session) error {
+		if err := StmtCheckVersion(session, m.table, shopId, productId, expectedVersion); err != nil {
+			return err
+		}
 		err := m.StmtDeleteProductItems(shopId, productId, m.table, session)
 		if err != nil {
 
@@ -1028,16 +1471,37 @@ func (m *defaultSailShopProductModel) Delete(shopId, productId int64, redis2 *re
 		default:
 
 		}
+		if err := StmtDeleteProductRedirects(session, shopId, productId); err != nil {
+			return err
+		}
+		if err := StmtDeleteProductMetafields(session, shopId, productId); err != nil {
+			return err
+		}
+		if err := StmtDeleteProductTranslations(session, shopId, productId); err != nil {
+			return err
+		}
 
-		return nil
+		return StmtInsertProductEvent(session, shopId, productId, ES_SYNC_EVENT_DELETE)
 	})
 	if err != nil {
 		logx.Error(err)
 		return err
 	}
+	m.delProductCache(shopId, productId, productInfo.Handler)
 	return nil
 }
 
+// delProductCache 删除产品id缓存，链接有变化时同时删除新旧链接的缓存
+func (m *defaultSailShopProductModel) delProductCache(shopId, productId int64, handles ...string) {
+	keys := []string{fmt.Sprintf("%s%v", cacheSailShopProductIdPrefix, productId)}
+	for _, handle := range handles {
+		keys = append(keys, fmt.Sprintf("%s%v%v", cacheSailShopProductShopIdHandlerPrefix, shopId, handle))
+	}
+	if err := m.DelCache(keys...); err != nil {
+		logx.Error("删除产品缓存失败：", err)
+	}
+}
+
 func (m *defaultSailShopProductModel) StmtDeleteProductItems(shopId, productId int64, tableName string, session sqlx.Session) error {
 	args := make([]interface{}, 0)
 	args = append(args, shopId, productId)
@@ -1070,67 +1534,28 @@ func (m *defaultSailShopProductModel) queryPrimary(conn sqlx.SqlConn, v, primary
 	return conn.QueryRow(v, query, primary)
 }
 
-func (m *defaultSailShopProductModel) generateOriginHandler(title string) string {
-	title = strings.ToLower(title)
-	filters := []string{",", "!", "/", " ", "?", "%", "#", "&", "=", "$", "*", "+", "[", "]", "(", ")", "{", "}", `"`, "'", ",", "，"}
-	replace := "-"
-	for _, filter := range filters {
-		title = strings.Replace(title, filter, replace, -1)
+// NormalizeHandle 校验商家自定义的产品链接，返回统一转为小写后的值；
+// 只允许字母、数字和单个 '-' 或 '_' 分隔，不能以分隔符开头或结尾
+func NormalizeHandle(handle string) (string, error) {
+	handle = strings.ToLower(strings.TrimSpace(handle))
+	if handle == "" || utf8.RuneCountInString(handle) > HANDLE_MAX_LENGTH {
+		return "", ErrHandleInvalid
 	}
-	reg := regexp.MustCompile(`/\s+/`)
-	title = reg.ReplaceAllString(title, replace)
-	return title
+	if !handleRegexp.MatchString(handle) {
+		return "", ErrHandleInvalid
+	}
+	return handle, nil
 }
 
-func (m *defaultSailShopProductModel) generateHandler(title string, shopId int64) string {
-	origin := m.generateOriginHandler(title)
-	handler := origin
-	var count int64
-	var productResp SailShopProduct
-	query := fmt.Sprintf("select count(*) from %s where `shop_id` = ? and handler_origin = ? ", m.table)
-	err := m.QueryRowNoCache(&count, query, shopId, origin)
-	switch err {
-	case nil:
-		if count > 0 {
-			handler = origin + "-" + strconv.Itoa(int(count))
-		}
-		productQuery := fmt.Sprintf("select %s from %s where `shop_id` = ? and handler = ? and is_del = 0 order by id desc limit 1 ", sailShopProductRows, m.table)
-		err := m.QueryRowNoCache(&productResp, productQuery, shopId, handler)
-		switch err {
-		case nil:
-			sourceStringSet := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10",
-				"a", "b", "c", "d", "e", "f",
-				"g", "h", "i", "j", "k", "l",
-				"m", "n", "o", "p", "q", "r",
-				"s", "t", "u", "v", "w", "x",
-				"y", "z"}
-			num := productResp.Id
-			code := ""
-			for i := num; i > 0; i = int64(math.Floor(float64(i) / float64(36))) {
-				index := i % 36
-				code = sourceStringSet[index] + code
-			}
-			if len(code) < 5 {
-				for k := 5 - len(code); k > 0; k-- {
-					code = "0" + code
-				}
-			}
-			handler = origin + "-" + code
-
-			return handler
-		case sqlc.ErrNotFound:
-			return handler
-		default:
-			logx.Error("查询商品信息失败：", err)
-			return handler
-		}
-
-	case sqlc.ErrNotFound:
-		return handler
-	default:
-		logx.Error("查询商品数量信息失败：", err)
-		return handler
+// lockHandle 同一店铺同一链接的写入串行执行，唯一索引兜底
+func lockHandle(redis2 *redis.Redis, shopId int64, handle string) (*redis.RedisLock, error) {
+	lockKey := fmt.Sprintf("adminapi_lock_product_handle_%d_%s", shopId, handle)
+	redisLock := redis.NewRedisLock(redis2, lockKey)
+	redisLock.SetExpire(5)
+	if ok, err := redisLock.Acquire(); !ok || err != nil {
+		return nil, ErrHandleBusy
 	}
+	return redisLock, nil
 }
 
 func (m *defaultSailShopProductModel) FilterSeoDesc(seoDesc string) string {
@@ -1205,8 +1630,8 @@ func StmtInsertProduct(session sqlx.Session, data InsertProductData) (sql.Result
 	//h.Write([]byte(data.BodyHtml))
 	//strMd5 := hex.EncodeToString(h.Sum(nil))
 
-	fields := " id, shop_id, title,weight, weight_unit, price,compare_at_price, status, default_sku_code, seo_title, seo_desc, is_logistics, is_use_stock, handler, handler_origin, default_image_id, attribute, source, youtube_video_url, youtube_video_pos, product_stock"
-	values := " ?, ?, ?, ?, ?, ?, ?, ?, ?, ? , ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
+	fields := " id, shop_id, title,weight, weight_unit, price,compare_at_price, status, default_sku_code, seo_title, seo_desc, is_logistics, is_use_stock, handler, handler_origin, default_image_id, attribute, source, youtube_video_url, youtube_video_pos, product_stock, vendor"
+	values := " ?, ?, ?, ?, ?, ?, ?, ?, ?, ? , ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
 	if data.SoldOutPolicy == "Y" || data.SoldOutPolicy == "N" {
 		fields += " , soldout_policy"
 		values += " , ? "
@@ -1233,7 +1658,7 @@ func StmtInsertProduct(session sqlx.Session, data InsertProductData) (sql.Result
 	args := make([]interface{}, 0)
 	//" shop_id, title,weight, weight_unit, price,compare_at_price,soldout_policy, status, default_sku_code, seo_title, seo_desc, requires_shipping, is_use_stock, handler, handler_origin "
 
-	args = append(args, data.Id, data.ShopId, data.Title, data.Weight, data.WeightUnit, data.Price, data.ComparePrice, status, data.Sku, data.SeoTitle, data.SeoDesc, requiresShipping, isUseStock, data.Handler, data.OriginHandler, 0, data.Attribute, "adminapi", data.YoutubeVideoUrl, data.YoutubeVideoPos, data.InventoryQuantity)
+	args = append(args, data.Id, data.ShopId, data.Title, data.Weight, data.WeightUnit, data.Price, data.ComparePrice, status, data.Sku, data.SeoTitle, data.SeoDesc, requiresShipping, isUseStock, data.Handler, data.OriginHandler, 0, data.Attribute, "adminapi", data.YoutubeVideoUrl, data.YoutubeVideoPos, data.InventoryQuantity, data.Vendor)
 	if data.SoldOutPolicy == "Y" || data.SoldOutPolicy == "N" {
 		args = append(args, data.SoldOutPolicy)
 	}
@@ -1248,6 +1673,172 @@ func StmtInsertProduct(session sqlx.Session, data InsertProductData) (sql.Result
 	return result, err
 }
 
+// HandleOwner 拥有链接的对象。产品、分类和活动页的链接都通过它生成，共用同一套规范化和冲突规则
+type HandleOwner struct {
+	Table     string // 需要有 `shop_id` 和 `handler` 字段
+	UniqueKey string // (shop_id, handler) 唯一索引名，为空时任意唯一索引冲突都按链接冲突处理
+	Fallback  string // 标题生成的链接为空时使用
+	Redirects bool   // 旧链接还在跳转，也算作已占用
+}
+
+var (
+	ProductHandleOwner  = HandleOwner{Table: "`sail_shop_product`", UniqueKey: PRODUCT_HANDLE_UNIQUE_KEY, Fallback: PRODUCT_HANDLE_FALLBACK, Redirects: true}
+	CategoryHandleOwner = HandleOwner{Table: "`sail_shop_category`", Fallback: CATEGORY_HANDLE_FALLBACK}
+)
+
+// Base 由标题生成链接，结果为空时使用默认链接
+func (o HandleOwner) Base(title string) string {
+	if base := slug.Make(title); base != "" {
+		return base
+	}
+	return o.Fallback
+}
+
+// Retry 执行写入事务，同时写入的记录可能选中同一个链接，由唯一索引拦下后重新执行整个事务。
+// 链接由商户指定（auto 为 false）时冲突返回 ErrHandleTaken，重试次数用完返回 ErrHandleBusy
+func (o HandleOwner) Retry(auto bool, tx func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := tx()
+		if !slug.IsDuplicate(err, o.UniqueKey) {
+			return err
+		}
+		if !auto {
+			return ErrHandleTaken
+		}
+		if attempt >= slug.MaxAttempts {
+			return ErrHandleBusy
+		}
+		logx.Infof("链接冲突，重新生成 table:%s attempt:%d", o.Table, attempt)
+	}
+}
+
+// StmtPickHandle 在事务里为 base 选出店铺内未被占用的链接，已删除的记录仍占用唯一索引，一并计算；
+// owner.Redirects 为真时旧链接跳转也算作已占用。并发事务仍可能选中同一个值，由 owner.Retry 在唯一索引冲突时重试
+func StmtPickHandle(session sqlx.Session, owner HandleOwner, shopId int64, base string) (string, error) {
+	queries := []string{fmt.Sprintf("select `handler` from %s where `shop_id` = ? and (`handler` = ? or `handler` like ?) ", owner.Table)}
+	if owner.Redirects {
+		queries = append(queries, "select `old_handle` from `sail_product_redirect` where `shop_id` = ? and (`old_handle` = ? or `old_handle` like ?) ")
+	}
+	taken := make([]string, 0)
+	for _, query := range queries {
+		var handles []string
+		stmt, err := session.Prepare(query)
+		if err != nil {
+			logx.Error("查询链接失败：", err)
+			return "", err
+		}
+		err = stmt.QueryRows(&handles, shopId, base, slug.Pattern(base))
+		stmt.Close()
+		switch err {
+		case nil, sqlc.ErrNotFound:
+		default:
+			logx.Error("查询链接失败：", err)
+			return "", err
+		}
+		taken = append(taken, handles...)
+	}
+	return slug.Pick(base, taken), nil
+}
+
+// StmtCheckHandle 检查链接是否已被店铺内的其他产品占用，已删除的产品仍占用唯一索引，一并计算
+func StmtCheckHandle(session sqlx.Session, shopId, productId int64, handle string) error {
+	var count int64
+	stmt, err := session.Prepare("select count(*) from `sail_shop_product` where `shop_id` = ? and `handler` = ? and `id` != ? ")
+	if err != nil {
+		logx.Error("查询产品链接失败：", err)
+		return err
+	}
+	defer stmt.Close()
+	if err = stmt.QueryRow(&count, shopId, handle, productId); err != nil {
+		logx.Error("查询产品链接失败：", err)
+		return err
+	}
+	if count > 0 {
+		return ErrHandleTaken
+	}
+	return nil
+}
+
+// Error 返回冲突时数据库中的当前版本
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict, current version is %d", e.Current)
+}
+
+// StmtCheckVersion 锁定记录并校验版本，expectedVersion 为 0 时不校验
+func StmtCheckVersion(session sqlx.Session, table string, shopId, id, expectedVersion int64) error {
+	if expectedVersion == 0 {
+		return nil
+	}
+	var version int64
+	query := fmt.Sprintf("select `version` from %s where `shop_id` = ? and `id` = ? and `is_del` = 0 for update", table)
+	if err := stmtQueryRow(session, &version, query, shopId, id); err != nil {
+		return err
+	}
+	if version != expectedVersion {
+		return &VersionConflictError{Current: version}
+	}
+	return nil
+}
+
+// StmtQueryProductHandle 锁定产品行并返回当前链接
+func StmtQueryProductHandle(session sqlx.Session, shopId, productId int64) (string, error) {
+	var handle string
+	stmt, err := session.Prepare("select `handler` from `sail_shop_product` where `shop_id` = ? and `id` = ? and `is_del` = 0 for update")
+	if err != nil {
+		logx.Error("查询产品链接失败：", err)
+		return "", err
+	}
+	defer stmt.Close()
+	if err = stmt.QueryRow(&handle, shopId, productId); err != nil {
+		logx.Error("查询产品链接失败：", err)
+		return "", err
+	}
+	return handle, nil
+}
+
+// StockSnapshot 产品库存和各子商品库存，key 为0表示产品本身
+type StockSnapshot map[int64]int64
+
+// Differs 比较两次库存，新增或删除的子商品库存按0计算
+func (s StockSnapshot) Differs(other StockSnapshot) bool {
+	for id, stock := range s {
+		if other[id] != stock {
+			return true
+		}
+	}
+	for id, stock := range other {
+		if s[id] != stock {
+			return true
+		}
+	}
+	return false
+}
+
+// StmtQueryStock 查询并锁定产品和子商品的库存，用于判断更新后库存是否变化
+func StmtQueryStock(session sqlx.Session, shopId, productId int64) (StockSnapshot, error) {
+	var productStock int64
+	if err := stmtQueryRow(session, &productStock, "select `product_stock` from `sail_shop_product` where `shop_id` = ? and `id` = ? and `is_del` = 0 for update", shopId, productId); err != nil {
+		logx.Error("查询商品库存失败：", err)
+		return nil, err
+	}
+	var variants []struct {
+		Id                int64 `db:"id"`
+		InventoryQuantity int64 `db:"inventory_quantity"`
+	}
+	err := stmtQueryRows(session, &variants, "select `id`, `inventory_quantity` from `sail_shop_product_variant` where `shop_id` = ? and `product_id` = ? and `is_del` = 0 for update", shopId, productId)
+	switch err {
+	case nil, sqlc.ErrNotFound:
+	default:
+		logx.Error("查询子商品库存失败：", err)
+		return nil, err
+	}
+	snapshot := StockSnapshot{0: productStock}
+	for _, variant := range variants {
+		snapshot[variant.Id] = variant.InventoryQuantity
+	}
+	return snapshot, nil
+}
+
 func StmtUpdateProduct(session sqlx.Session, data InsertProductData, productId int64, handlerOrigin string, defaultImageId int64) error {
 	if data.ShopId == 0 {
 		logx.Error("缺少shop_id参数")
@@ -1289,7 +1880,7 @@ func StmtUpdateProduct(session sqlx.Session, data InsertProductData, productId i
 			updateDataArr = append(updateDataArr, "status = ?")
 			args = append(args, 2)
 			updateDataArr = append(updateDataArr, "published_at = ?")
-			args = append(args, time.Time{})
+			args = append(args, UnpublishedAt)
 		}
 	}
 	if data.Sku != "" {
@@ -1341,20 +1932,27 @@ func StmtUpdateProduct(session sqlx.Session, data InsertProductData, productId i
 		updateDataArr = append(updateDataArr, "youtube_video_url = ?")
 		args = append(args, data.YoutubeVideoUrl)
 	}
-	updateDataArr = append(updateDataArr, "handler_origin = ?")
-	args = append(args, handlerOrigin)
+	if handlerOrigin != "" {
+		updateDataArr = append(updateDataArr, "handler_origin = ?")
+		args = append(args, handlerOrigin)
+	}
 	updateDataArr = append(updateDataArr, "default_image_id = ?")
 	args = append(args, defaultImageId)
 	if data.Attribute != "" {
 		updateDataArr = append(updateDataArr, "attribute = ?")
 		args = append(args, data.Attribute)
 	}
+	if data.Vendor != "" {
+		updateDataArr = append(updateDataArr, "vendor = ?")
+		args = append(args, data.Vendor)
+	}
 	updateDataArr = append(updateDataArr, "source = ?")
 	args = append(args, "adminapi")
 	if data.SoldOutPolicy == "Y" || data.SoldOutPolicy == "N" {
 		updateDataArr = append(updateDataArr, "soldout_policy = ?")
 		args = append(args, data.SoldOutPolicy)
 	}
+	updateDataArr = append(updateDataArr, "version = version + 1")
 
 	args = append(args, data.ShopId, productId)
 
@@ -1472,6 +2070,7 @@ func StmtUpdateVariant(session sqlx.Session, data VariantData, productId, shopId
 		updateDataArr = append(updateDataArr, "options = ?")
 		args = append(args, data.Options)
 	}
+	updateDataArr = append(updateDataArr, "version = version + 1")
 	args = append(args, shopId, productId, data.Id)
 	updateDataStr := strings.Join(updateDataArr, ",")
 
@@ -1494,6 +2093,49 @@ func StmtUpdateVariant(session sqlx.Session, data VariantData, productId, shopId
 	return nil
 }
 
+// StmtInsertVariantImage 记录已上传的子商品图片，追加到商品图集并关联到子商品
+func StmtInsertVariantImage(session sqlx.Session, shopId, productId, variantId int64, image ImageData) error {
+	h := md5.New()
+	h.Write([]byte(image.FileKey))
+	now := time.Now()
+	fields := []string{"`shop_id`", "`file_key`", "`file_key1`", "`file_key2`", "`file_key3`", "`file_md5`", "`image_width`", "`is_del`", "`created_at`", "`updated_at`"}
+	args := []interface{}{shopId, image.FileKey, "", "", "", hex.EncodeToString(h.Sum(nil)), image.ImageWidth, 0, now, now}
+	result, err := StmtInsert(session, "`sail_upload`", fields, args)
+	if err != nil {
+		logx.Error("新增子商品图片出错：", err)
+		return err
+	}
+	imageId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	imageQuery := "update `sail_shop_product` set `image_ids` = if(`image_ids` = '', ?, concat(`image_ids`, ',', ?)), `version` = `version` + 1 where `id` = ? and `shop_id` = ? "
+	stmt, err := session.Prepare(imageQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if _, err = stmt.Exec(imageId, imageId, productId, shopId); err != nil {
+		logx.Error("新增子商品图片出错：", err)
+		return err
+	}
+	return StmtUpdateVariantImageId(session, imageId, variantId)
+}
+
+func StmtUpdateVariantImageId(session sqlx.Session, imageId, variantId int64) error {
+	stmt, err := session.Prepare("update `sail_shop_product_variant` set `image_id` = ?, `is_set_default_img` = 1, `version` = `version` + 1 where `id` = ? ")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if _, err = stmt.Exec(imageId, variantId); err != nil {
+		logx.Error("关联子商品图片出错：", err)
+		return err
+	}
+	return nil
+}
+
 func StmtUpdateTags(session sqlx.Session, shopId int64, tag string) (sql.Result, error) {
 	fields := []string{"`shop_id`", "`name`"}
 	args := make([]interface{}, 0)
@@ -1524,7 +2166,7 @@ func StmtUpdateProductImages(session sqlx.Session, shopId, productId int64, imag
 		imageIdsSet = append(imageIdsSet, strconv.Itoa(int(id)))
 	}
 	imageIdsStr := strings.Join(imageIdsSet, ",")
-	query := fmt.Sprintf("update %s set `image_ids` = ? where `shop_id` = ? and `id` = ? and is_del = 0 ", "sail_shop_product")
+	query := fmt.Sprintf("update %s set `image_ids` = ?, `version` = `version` + 1 where `shop_id` = ? and `id` = ? and is_del = 0 ", "sail_shop_product")
 	args := make([]interface{}, 0)
 	args = append(args, imageIdsStr, shopId, productId)
 	stmt1, err := session.Prepare(query)
@@ -1668,34 +2310,34 @@ func StmtQueryVariants(session sqlx.Session, shopId, productId int64) (*[]SailSh
 	}
 }
 
-func (m *defaultSailShopProductModel) AfterSave(shopId, productId int64, event string, redis2 *redis.Redis) error {
-	temp := EsQueue{
-		ShopId:    shopId,
-		ProductId: productId,
-		Event:     event,
-	}
-	tempByte, err := json.Marshal(temp)
-	if err != nil {
-		logx.Error("添加到es队列失败:", err)
-		return errors.New("internal server error")
-	}
-	_, err = redis2.Lpush(PRODUCT_ES_SYNC_QUEUE, string(tempByte))
-	if err != nil {
-		logx.Error("添加到es队列失败:", err)
-		return errors.New("internal server error")
-	}
-	return nil
-}
-
 type EsQueue struct {
 	ShopId    int64  `json:"shop_id"`
 	ProductId int64  `json:"product_id"`
 	Event     string `json:"event"`
+	Version   int64  `json:"version"`  // 同一产品单调递增，消费方据此丢弃重复或过期的事件
+	EventId   int64  `json:"event_id"` // sail_product_outbox.id
 }
 
+// metafieldFilterQuery 按产品自定义字段的值筛选，值需先按字段类型统一格式
+const metafieldFilterQuery = " and id in (select `owner_id` from `sail_product_metafield` where `shop_id` = ? and `owner_type` = ? and `namespace` = ? and `key` = ? and `value` = ?) "
+
+const LIST_SORT_RATING = "rating"
+
+// ratingOrderQuery 按贝叶斯加权分 (C*m + sum) / (C + count) 倒序，参数依次为 C*m、C
+const ratingOrderQuery = " order by (? + coalesce((select r.`score_sum` from `sail_product_rating` r where r.`shop_id` = `sail_shop_product`.`shop_id` and r.`product_id` = `sail_shop_product`.`id`), 0)) / " +
+	"(? + coalesce((select r.`review_count` from `sail_product_rating` r where r.`shop_id` = `sail_shop_product`.`shop_id` and r.`product_id` = `sail_shop_product`.`id`), 0)) desc, created_at desc"
+
 const PRODUCT_ES_SYNC_QUEUE = "es:sync:product:queue"
 const CLEAR_TAGS_QUEUE = "sail:shop:product:tags:clear"
 
 const ES_SYNC_EVENT_ADD = "add"
 const ES_SYNC_EVENT_UPDATE = "update"
+const ES_SYNC_EVENT_DELETE = "delete"
+
+// ES_SYNC_EVENT_INVENTORY 只用于变更订阅，投递到es队列时按update处理
+const ES_SYNC_EVENT_INVENTORY = "inventory"
 const AUTO_INCREMENT_KEY = "jh_auto_increment"
+const HANDLE_MAX_LENGTH = 255
+const PRODUCT_HANDLE_FALLBACK = "product"
+const PRODUCT_HANDLE_UNIQUE_KEY = "unique_product_handler"
+const CATEGORY_HANDLE_FALLBACK = "category"
@@ -0,0 +1,3 @@
+-- 产品和子商品的乐观锁版本号，已有数据从 1 开始
+ALTER TABLE `sail_shop_product` ADD COLUMN `version` bigint(20) NOT NULL DEFAULT '1' COMMENT '乐观锁版本号，每次修改加一';
+ALTER TABLE `sail_shop_product_variant` ADD COLUMN `version` bigint(20) NOT NULL DEFAULT '1' COMMENT '乐观锁版本号，每次修改加一';
@@ -30,9 +30,11 @@ type (
 		InsertProductImage(data SailUpload, productId int64) (sql.Result, error)
 		InsertDefaultImg(data SailUpload, productId int64) (sql.Result, error)
 		InsertVariantImg(data SailUpload, productId int64, variantId int64) (sql.Result, error)
+		InsertImage(data SailUpload) (sql.Result, error)
 		FindOne(id int64) (*SailUpload, error)
 		Count(shopId, productId int64) (int64, error)
 		FindList(shopId int64) (*[]SailUpload, error)
+		FindByIds(ids []int64) (*[]SailUpload, error)
 		Update(data SailUpload) error
 		Delete(id int64) error
 	}
@@ -122,7 +124,7 @@ func (m *defaultSailUploadModel) Insert(data SailUpload, productId int64) (sql.R
 			} else {
 				finalIds = productResp.ImageIds + "," + idStr
 			}
-			var updateSql = "update sail_shop_product set image_ids = ? where id = ?"
+			var updateSql = "update sail_shop_product set image_ids = ?, version = version + 1 where id = ?"
 			stmt2, err := session.Prepare(updateSql)
 			if err != nil {
 				return err
@@ -202,7 +204,7 @@ func (m *defaultSailUploadModel) InsertProductImage(data SailUpload, productId i
 			} else {
 				finalIds = productResp.ImageIds + "," + idStr
 			}
-			var updateSql = "update sail_shop_product set image_ids = ? where id = ?"
+			var updateSql = "update sail_shop_product set image_ids = ?, version = version + 1 where id = ?"
 			stmt2, err := session.Prepare(updateSql)
 			if err != nil {
 				return err
@@ -221,7 +223,7 @@ func (m *defaultSailUploadModel) InsertProductImage(data SailUpload, productId i
 			}
 		}
 
-		return nil
+		return StmtInsertProductEvent(session, data.ShopId, productId, ES_SYNC_EVENT_UPDATE)
 	})
 	return res, err
 }
@@ -283,7 +285,7 @@ func (m *defaultSailUploadModel) InsertDefaultImg(data SailUpload, productId int
 				finalIds = productResp.ImageIds + "," + idStr
 			}
 
-			var updateSql = "update sail_shop_product set image_ids = ?, default_image_id = ? where id = ?"
+			var updateSql = "update sail_shop_product set image_ids = ?, default_image_id = ?, version = version + 1 where id = ?"
 			stmt2, err := session.Prepare(updateSql)
 			if err != nil {
 				return err
@@ -302,7 +304,7 @@ func (m *defaultSailUploadModel) InsertDefaultImg(data SailUpload, productId int
 			}
 		}
 
-		return nil
+		return StmtInsertProductEvent(session, data.ShopId, productId, ES_SYNC_EVENT_UPDATE)
 	})
 	return res, err
 }
@@ -363,7 +365,7 @@ func (m *defaultSailUploadModel) InsertVariantImg(data SailUpload, productId int
 			} else {
 				finalIds = productResp.ImageIds + "," + idStr
 			}
-			var updateSql = "update sail_shop_product set image_ids = ? where id = ?"
+			var updateSql = "update sail_shop_product set image_ids = ?, version = version + 1 where id = ?"
 			stmt2, err := session.Prepare(updateSql)
 			if err != nil {
 				return err
@@ -382,7 +384,7 @@ func (m *defaultSailUploadModel) InsertVariantImg(data SailUpload, productId int
 			}
 		}
 
-		var updateVariantSql = "update sail_shop_product_variant set image_id = ?, is_set_default_img = 1 where id = ?"
+		var updateVariantSql = "update sail_shop_product_variant set image_id = ?, is_set_default_img = 1, version = version + 1 where id = ?"
 		stmt3, err := session.Prepare(updateVariantSql)
 		if err != nil {
 			logx.Error(err)
@@ -395,11 +397,22 @@ func (m *defaultSailUploadModel) InsertVariantImg(data SailUpload, productId int
 			return err
 		}
 
-		return nil
+		return StmtInsertProductEvent(session, data.ShopId, productId, ES_SYNC_EVENT_UPDATE)
 	})
 	return res, err
 }
 
+// InsertImage 只保存图片，不关联到产品，例如评论图片
+func (m *defaultSailUploadModel) InsertImage(data SailUpload) (sql.Result, error) {
+	if data.ShopId == 0 {
+		return nil, errors.New("缺少shop_id参数")
+	}
+	now := time.Now()
+	data.UpdatedAt, data.CreatedAt = now, now
+	query := fmt.Sprintf("insert into %s (%s) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", m.table, sailUploadRowsExpectAutoSet)
+	return m.ExecNoCache(query, data.ShopId, data.FileKey2, data.IsDel, data.UpdatedAt, data.FileMd5, data.FileKey, data.FileKey1, data.FileKey3, data.ImageWidth, data.CreatedAt)
+}
+
 func (m *defaultSailUploadModel) FindOne(id int64) (*SailUpload, error) {
 	//sailUploadIdKey := fmt.Sprintf("%s%v", cacheSailUploadIdPrefix, id)
 	var resp SailUpload
@@ -483,3 +496,26 @@ func (m *defaultSailUploadModel) queryPrimary(conn sqlx.SqlConn, v, primary inte
 	query := fmt.Sprintf("select %s from %s where `id` = ? limit 1", sailUploadRows, m.table)
 	return conn.QueryRow(v, query, primary)
 }
+
+func (m *defaultSailUploadModel) FindByIds(ids []int64) (*[]SailUpload, error) {
+	var resp []SailUpload
+	if len(ids) == 0 {
+		return &resp, nil
+	}
+	placeholders := make([]string, 0, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+	query := fmt.Sprintf("select %s from %s where `id` in (%s) and `is_del` = 0 ", sailUploadRows, m.table, strings.Join(placeholders, ","))
+	err := m.QueryRowsNoCache(&resp, query, args...)
+	switch err {
+	case nil:
+		return &resp, nil
+	case sqlc.ErrNotFound:
+		return &resp, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,75 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	FieldPrice          = "price"
+	FieldCompareAtPrice = "compare_at_price"
+
+	OpPercent  = "percent"  // 基准价格按百分比增减，value 为 8 表示 +8%
+	OpDelta    = "delta"    // 基准价格加减固定金额
+	OpMultiply = "multiply" // 基准价格乘以 value，例如对比价格 = 价格 × 1.3
+	OpAbsolute = "absolute" // 直接设置为 value
+)
+
+// Expression 批量改价表达式：target = op(base, value)，结果按 Rounding 取整，base 为空时与 target 相同
+type Expression struct {
+	Target   string
+	Base     string
+	Op       string
+	Value    float64
+	Rounding string
+}
+
+func (e Expression) Validate() error {
+	if e.Target != FieldPrice && e.Target != FieldCompareAtPrice {
+		return errors.New("target must be price or compare_at_price")
+	}
+	if e.Base != "" && e.Base != FieldPrice && e.Base != FieldCompareAtPrice {
+		return errors.New("base must be price or compare_at_price")
+	}
+	switch e.Op {
+	case OpPercent:
+		if e.Value <= -100 {
+			return errors.New("percent must be greater than -100")
+		}
+	case OpDelta:
+	case OpMultiply, OpAbsolute:
+		if e.Value < 0 {
+			return errors.New("value must not be negative")
+		}
+	default:
+		return errors.New("operation is invalid")
+	}
+	if !ValidRounding(e.Rounding) {
+		return errors.New("rounding is invalid")
+	}
+	return nil
+}
+
+// Eval 按当前价格计算新的 target 值，结果不大于0时返回 false
+func (e Expression) Eval(price, compareAtPrice float64) (float64, bool) {
+	base := compareAtPrice
+	if e.Base == FieldPrice || (e.Base == "" && e.Target == FieldPrice) {
+		base = price
+	}
+	var value float64
+	switch e.Op {
+	case OpPercent:
+		value = base * (100 + e.Value) / 100
+	case OpDelta:
+		value = base + e.Value
+	case OpMultiply:
+		value = base * e.Value
+	case OpAbsolute:
+		value = e.Value
+	}
+	if value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	value = Convert(value, 1, e.Rounding)
+	return value, value > 0
+}
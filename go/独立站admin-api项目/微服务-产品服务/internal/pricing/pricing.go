@@ -0,0 +1,133 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+)
+
+const (
+	RoundingNone    = ""
+	RoundingEnd99   = "0.99"
+	RoundingEnd95   = "0.95"
+	RoundingInteger = "0.00"
+)
+
+var ErrPriceListNotFound = errors.New("price list not found")
+
+// 尾数规则对应的分值
+var roundingEndings = map[string]int64{
+	RoundingEnd99:   99,
+	RoundingEnd95:   95,
+	RoundingInteger: 0,
+}
+
+type (
+	// Resolver 按一个价格表解析价格：有固定价格用固定价格，否则按汇率换算后取整
+	Resolver struct {
+		list  model.SailProductPriceList
+		items map[itemKey]model.SailProductPriceListItem
+	}
+
+	itemKey struct {
+		productId int64
+		variantId int64
+	}
+)
+
+func ValidRounding(rounding string) bool {
+	if rounding == RoundingNone {
+		return true
+	}
+	_, ok := roundingEndings[rounding]
+	return ok
+}
+
+// Convert 按汇率换算并取整。尾数规则总是向上取，换算后的价格不会低于按汇率算出的价格
+func Convert(price, rate float64, rounding string) float64 {
+	if price <= 0 {
+		return 0
+	}
+	cents := int64(math.Round(price * rate * 100))
+	ending, ok := roundingEndings[rounding]
+	if !ok {
+		return float64(cents) / 100
+	}
+	yuan := int64(math.Ceil(float64(cents-ending) / 100))
+	if yuan < 0 {
+		yuan = 0
+	}
+	return float64(yuan*100+ending) / 100
+}
+
+// Load 读取 market 对应的价格表，没有 market 时读取 currency 对应的价格表，
+// 两个都为空返回 nil，表示使用店铺币种价格
+func Load(m model.SailProductPriceListModel, shopId int64, market, currency string, productIds []int64) (*Resolver, error) {
+	var list *model.SailProductPriceList
+	var err error
+	switch {
+	case market != "":
+		list, err = m.FindByMarket(shopId, market)
+	case currency != "":
+		list, err = m.FindByCurrency(shopId, strings.ToUpper(currency))
+	default:
+		return nil, nil
+	}
+	switch err {
+	case nil:
+	case model.ErrNotFound:
+		return nil, ErrPriceListNotFound
+	default:
+		return nil, err
+	}
+	r := &Resolver{list: *list, items: make(map[itemKey]model.SailProductPriceListItem)}
+	// productIds 为空时 FindItems 不加产品条件，会读出整张价格表
+	if len(productIds) == 0 {
+		return r, nil
+	}
+	items, err := m.FindItems(list.Id, productIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range *items {
+		r.items[itemKey{item.ProductId, item.VariantId}] = item
+	}
+	return r, nil
+}
+
+func (r *Resolver) Currency() string {
+	return r.list.Currency
+}
+
+// Price 返回价格表中的价格和对比价格。variantId 为0表示产品本身；
+// 对比价格不高于价格时返回0，避免换算取整后出现“原价低于现价”
+func (r *Resolver) Price(productId, variantId int64, price, compareAtPrice float64) (float64, float64) {
+	item, ok := r.items[itemKey{productId, variantId}]
+	if ok {
+		price = item.Price
+		if item.CompareAtPrice > 0 {
+			compareAtPrice = item.CompareAtPrice
+		} else {
+			compareAtPrice = Convert(compareAtPrice, r.list.Rate, r.list.Rounding)
+		}
+	} else {
+		price = Convert(price, r.list.Rate, r.list.Rounding)
+		compareAtPrice = Convert(compareAtPrice, r.list.Rate, r.list.Rounding)
+	}
+	if compareAtPrice <= price {
+		compareAtPrice = 0
+	}
+	return price, compareAtPrice
+}
+
+// Apply 把产品和子商品的价格替换为价格表中的价格
+func (r *Resolver) Apply(p *product.Product) {
+	p.Currency = r.list.Currency
+	p.Price, p.CompareAtPrice = r.Price(p.ProductId, 0, p.Price, p.CompareAtPrice)
+	for _, variant := range p.Variants {
+		variant.Price, variant.ComparePrice = r.Price(p.ProductId, variant.Id, variant.Price, variant.ComparePrice)
+	}
+}
@@ -0,0 +1,40 @@
+package pricing
+
+import "math"
+
+const (
+	DiscountPercent = "percent" // 按百分比减，value 为 0~100
+	DiscountFixed   = "fixed"   // 减固定金额
+	DiscountPrice   = "price"   // 一口价
+)
+
+func ValidDiscount(discountType string, value float64) bool {
+	switch discountType {
+	case DiscountPercent:
+		return value > 0 && value < 100
+	case DiscountFixed, DiscountPrice:
+		return value > 0
+	default:
+		return false
+	}
+}
+
+// SalePrice 计算活动价，活动价不低于0.01且必须低于原价，否则返回 false 表示不参与活动
+func SalePrice(discountType string, value, price float64) (float64, bool) {
+	cents := int64(math.Round(price * 100))
+	var sale int64
+	switch discountType {
+	case DiscountPercent:
+		sale = int64(math.Round(float64(cents) * (100 - value) / 100))
+	case DiscountFixed:
+		sale = cents - int64(math.Round(value*100))
+	case DiscountPrice:
+		sale = int64(math.Round(value * 100))
+	default:
+		return 0, false
+	}
+	if sale <= 0 || sale >= cents {
+		return 0, false
+	}
+	return float64(sale) / 100, true
+}
@@ -0,0 +1,280 @@
+package productcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/metric"
+	"github.com/tal-tech/go-zero/core/mr"
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/syncx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+)
+
+const (
+	aggregateKeyPrefix = "product:aggregate:id:"
+	handleKeyPrefix    = "product:aggregate:handle:"
+	versionKeyPrefix   = "product:aggregate:version:"
+	notFoundValue      = "*"
+)
+
+var cacheRequests = metric.NewCounterVec(&metric.CounterVecOpts{
+	Namespace: "product",
+	Subsystem: "aggregate_cache",
+	Name:      "requests_total",
+	Help:      "product aggregate cache requests by result",
+	Labels:    []string{"result"},
+})
+
+type (
+	// Aggregate 产品详情需要的产品行、子商品、图片和描述，整体缓存，任一部分变化都删除整条缓存。
+	// Product.Version 是读取时的产品版本，用来判断缓存是否比最近一次写入旧
+	Aggregate struct {
+		Product         model.SailShopProduct
+		Variants        []model.SailShopProductVariant
+		VariantVersions map[int64]int64
+		Images          map[int64]model.SailUpload
+		BodyHtml        string
+	}
+
+	// Source 读取聚合数据用到的模型，从库和主库各一套
+	Source struct {
+		ProductModel model.SailShopProductModel
+		BatchModel   model.SailProductBatchModel
+		ImageModel   model.SailUploadModel
+	}
+
+	// Cache 读穿透的产品聚合缓存。按产品id缓存聚合数据，按链接只缓存到产品id的映射，
+	// 读到的聚合数据链接和请求不一致时当作未命中，所以链接映射不需要精确失效。
+	// 失效时在主库读取产品版本记为最低版本，低于它的缓存当作未命中，从库读到的旧数据改从主库读取，
+	// 这样从库延迟或失效事件晚到都不会把旧数据缓存下来。
+	// 同一个key并发未命中时只有一个请求查询数据库
+	Cache struct {
+		redis   *redis.Redis
+		conf    config.ProductCache
+		barrier syncx.SharedCalls
+		replica Source
+		primary Source
+	}
+)
+
+func NewCache(redis *redis.Redis, conf config.ProductCache, replica, primary Source) *Cache {
+	return &Cache{
+		redis:   redis,
+		conf:    conf,
+		barrier: syncx.NewSharedCalls(),
+		replica: replica,
+		primary: primary,
+	}
+}
+
+// Get 按产品id读取，产品不存在时返回 sqlc.ErrNotFound
+func (c *Cache) Get(shopId, productId int64) (*Aggregate, error) {
+	key := aggregateKey(shopId, productId)
+	aggregate, ok, minVersion := c.get(shopId, productId)
+	if ok {
+		cacheRequests.Inc("hit")
+		if aggregate == nil {
+			return nil, sqlc.ErrNotFound
+		}
+		return aggregate, nil
+	}
+
+	cacheRequests.Inc("miss")
+	val, err := c.barrier.Do(key, func() (interface{}, error) {
+		aggregate, err := c.load(shopId, productId, minVersion)
+		switch err {
+		case nil:
+			c.set(key, aggregate, c.conf.Expiry)
+		case sqlc.ErrNotFound:
+			c.set(key, nil, c.conf.NotFoundExpiry)
+		}
+		return aggregate, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*Aggregate), nil
+}
+
+// GetByHandle 按产品链接读取，链接不存在时返回 sqlc.ErrNotFound，不缓存不存在的链接
+func (c *Cache) GetByHandle(shopId int64, handle string) (*Aggregate, error) {
+	handle = strings.ToLower(handle)
+	key := handleKey(shopId, handle)
+	idStr, err := c.redis.Get(key)
+	if err != nil {
+		logx.Error("读取产品链接缓存失败：", err)
+	}
+	if productId, _ := strconv.ParseInt(idStr, 10, 64); productId != 0 {
+		aggregate, err := c.Get(shopId, productId)
+		switch {
+		case err == nil && strings.EqualFold(aggregate.Product.Handler, handle):
+			return aggregate, nil
+		case err != nil && err != sqlc.ErrNotFound:
+			return nil, err
+		}
+	}
+
+	val, err := c.barrier.Do(key, func() (interface{}, error) {
+		row, err := c.replica.ProductModel.FindOne(shopId, model.WithHandler(handle))
+		if err != nil {
+			return int64(0), err
+		}
+		if err := c.redis.Setex(key, strconv.FormatInt(row.Id, 10), c.conf.Expiry); err != nil {
+			logx.Error("保存产品链接缓存失败：", err)
+		}
+		return row.Id, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	aggregate, err := c.Get(shopId, val.(int64))
+	if err != nil {
+		return nil, err
+	}
+	// 查询链接和读取聚合数据之间链接被修改
+	if !strings.EqualFold(aggregate.Product.Handler, handle) {
+		return nil, sqlc.ErrNotFound
+	}
+	return aggregate, nil
+}
+
+// Invalidate 删除产品的聚合缓存和当前缓存中记录的链接映射，并记录主库中的产品版本，
+// 在写入完成后和收到产品变更事件时调用
+func (c *Cache) Invalidate(shopId, productId int64) {
+	version, err := c.primary.ProductModel.FindVersion(shopId, productId)
+	switch err {
+	case nil:
+		// 最低版本比聚合缓存多保留一个过期时间，失效前开始读取的旧数据晚于失效写入缓存时也能识别
+		if err := c.redis.Setex(versionKey(shopId, productId), strconv.FormatInt(version, 10), c.conf.Expiry*2); err != nil {
+			logx.Errorf("保存产品缓存版本失败 shop_id:%d product_id:%d err:%s", shopId, productId, err)
+		}
+	case sqlc.ErrNotFound:
+	default:
+		logx.Errorf("查询产品版本失败 shop_id:%d product_id:%d err:%s", shopId, productId, err)
+	}
+
+	keys := []string{aggregateKey(shopId, productId)}
+	if aggregate, ok, _ := c.get(shopId, productId); ok && aggregate != nil {
+		keys = append(keys, handleKey(shopId, strings.ToLower(aggregate.Product.Handler)))
+	}
+	if _, err := c.redis.Del(keys...); err != nil {
+		logx.Errorf("删除产品缓存失败 shop_id:%d product_id:%d err:%s", shopId, productId, err)
+	}
+}
+
+// get 第二个返回值表示缓存存在，缓存的是不存在占位时聚合数据为 nil。
+// 第三个返回值是失效时记录的最低版本，版本低于它的缓存当作未命中。redis出错时当作未命中
+func (c *Cache) get(shopId, productId int64) (*Aggregate, bool, int64) {
+	key := aggregateKey(shopId, productId)
+	vals, err := c.redis.Mget(key, versionKey(shopId, productId))
+	if err != nil {
+		logx.Error("读取产品缓存失败：", err)
+		return nil, false, 0
+	}
+	minVersion, _ := strconv.ParseInt(vals[1], 10, 64)
+	switch vals[0] {
+	case "":
+		return nil, false, minVersion
+	case notFoundValue:
+		return nil, true, minVersion
+	}
+	var aggregate Aggregate
+	if err := json.Unmarshal([]byte(vals[0]), &aggregate); err != nil {
+		logx.Errorf("产品缓存内容不合法 key:%s err:%s", key, err)
+		return nil, false, minVersion
+	}
+	if aggregate.Product.Version < minVersion {
+		return nil, false, minVersion
+	}
+	return &aggregate, true, minVersion
+}
+
+func (c *Cache) set(key string, aggregate *Aggregate, expiry int) {
+	val := notFoundValue
+	if aggregate != nil {
+		data, err := json.Marshal(aggregate)
+		if err != nil {
+			logx.Error(err)
+			return
+		}
+		val = string(data)
+	}
+	if err := c.redis.Setex(key, val, expiry); err != nil {
+		logx.Error("保存产品缓存失败：", err)
+	}
+}
+
+// load 先从从库读取，从库的产品版本低于 minVersion 时说明从库还没同步到最近一次写入，整体改从主库读取
+func (c *Cache) load(shopId, productId, minVersion int64) (*Aggregate, error) {
+	source := c.replica
+	row, err := source.ProductModel.FindOne(shopId, model.WithId(productId))
+	if (err == nil && row.Version < minVersion) || (err == sqlc.ErrNotFound && minVersion > 0) {
+		source = c.primary
+		row, err = source.ProductModel.FindOne(shopId, model.WithId(productId))
+	}
+	if err != nil {
+		return nil, err
+	}
+	aggregate := &Aggregate{Product: *row, Images: map[int64]model.SailUpload{}}
+	err = mr.Finish(func() error {
+		bodyHtml, err := source.BatchModel.FindBodyHtml([]int64{productId})
+		if err != nil {
+			return err
+		}
+		aggregate.BodyHtml = bodyHtml[productId]
+		return nil
+	}, func() error {
+		variants, err := source.BatchModel.FindVariants(shopId, []int64{productId})
+		if err != nil {
+			return err
+		}
+		aggregate.Variants = variants[productId]
+		return nil
+	}, func() error {
+		versions, err := source.BatchModel.FindVariantVersions(shopId, []int64{productId})
+		if err != nil {
+			return err
+		}
+		aggregate.VariantVersions = versions
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	imageIds := model.ParseVariantIds(row.ImageIds)
+	if row.DefaultImageId != 0 {
+		imageIds = append(imageIds, row.DefaultImageId)
+	}
+	for _, variant := range aggregate.Variants {
+		if variant.ImageId != 0 {
+			imageIds = append(imageIds, variant.ImageId)
+		}
+	}
+	images, err := source.ImageModel.FindByIds(imageIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range *images {
+		aggregate.Images[image.Id] = image
+	}
+	return aggregate, nil
+}
+
+func aggregateKey(shopId, productId int64) string {
+	return fmt.Sprintf("%s%d:%d", aggregateKeyPrefix, shopId, productId)
+}
+
+func versionKey(shopId, productId int64) string {
+	return fmt.Sprintf("%s%d:%d", versionKeyPrefix, shopId, productId)
+}
+
+func handleKey(shopId int64, handle string) string {
+	return fmt.Sprintf("%s%d:%s", handleKeyPrefix, shopId, handle)
+}
@@ -0,0 +1,153 @@
+package productcache
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/tal-tech/go-zero/core/stores/redis/redistest"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+)
+
+// memoryProductModel 一个库里的产品行，从库和主库各一个，用来模拟从库延迟
+type memoryProductModel struct {
+	model.SailShopProductModel
+	mu    sync.Mutex
+	rows  map[int64]model.SailShopProduct
+	reads int
+}
+
+func (m *memoryProductModel) FindOne(shopId int64, filter ...model.HandlerOption) (*model.SailShopProduct, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reads++
+	row, ok := m.rows[model.SetOptions(filter...).Id]
+	if !ok || row.ShopId != shopId {
+		return nil, sqlc.ErrNotFound
+	}
+	return &row, nil
+}
+
+func (m *memoryProductModel) FindVersion(shopId, productId int64) (int64, error) {
+	row, err := m.FindOne(shopId, model.WithId(productId))
+	if err != nil {
+		return 0, err
+	}
+	return row.Version, nil
+}
+
+func (m *memoryProductModel) write(row model.SailShopProduct) {
+	m.mu.Lock()
+	m.rows[row.Id] = row
+	m.mu.Unlock()
+}
+
+type emptyBatchModel struct {
+	model.SailProductBatchModel
+}
+
+func (emptyBatchModel) FindBodyHtml(productIds []int64) (map[int64]string, error) {
+	return map[int64]string{}, nil
+}
+
+func (emptyBatchModel) FindVariants(shopId int64, productIds []int64) (map[int64][]model.SailShopProductVariant, error) {
+	return map[int64][]model.SailShopProductVariant{}, nil
+}
+
+func (emptyBatchModel) FindVariantVersions(shopId int64, productIds []int64) (map[int64]int64, error) {
+	return map[int64]int64{}, nil
+}
+
+type emptyImageModel struct {
+	model.SailUploadModel
+}
+
+func (emptyImageModel) FindByIds(ids []int64) (*[]model.SailUpload, error) {
+	return &[]model.SailUpload{}, nil
+}
+
+func newTestCache(t *testing.T) (*Cache, *memoryProductModel, *memoryProductModel) {
+	r, clean, err := redistest.CreateRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clean)
+	row := model.SailShopProduct{Id: 1, ShopId: 1, Title: "v1", Handler: "shirt", Version: 1}
+	replica := &memoryProductModel{rows: map[int64]model.SailShopProduct{1: row}}
+	primary := &memoryProductModel{rows: map[int64]model.SailShopProduct{1: row}}
+	cache := NewCache(r, config.ProductCache{Expiry: 3600, NotFoundExpiry: 60},
+		Source{ProductModel: replica, BatchModel: emptyBatchModel{}, ImageModel: emptyImageModel{}},
+		Source{ProductModel: primary, BatchModel: emptyBatchModel{}, ImageModel: emptyImageModel{}})
+	return cache, replica, primary
+}
+
+func assertVersion(t *testing.T, cache *Cache, want int64) {
+	t.Helper()
+	aggregate, err := cache.Get(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aggregate.Product.Version != want {
+		t.Fatalf("cached product version = %d, want %d", aggregate.Product.Version, want)
+	}
+}
+
+// 失效之后从库还没同步，未命中时改从主库读取，不会把从库的旧数据缓存一个小时
+func TestGetAfterInvalidateSkipsLaggingReplica(t *testing.T) {
+	cache, _, primary := newTestCache(t)
+	assertVersion(t, cache, 1)
+
+	primary.write(model.SailShopProduct{Id: 1, ShopId: 1, Title: "v2", Handler: "shirt", Version: 2})
+	cache.Invalidate(1, 1)
+	assertVersion(t, cache, 2)
+
+	reads := primary.reads
+	assertVersion(t, cache, 2)
+	if primary.reads != reads {
+		t.Fatalf("second read loaded from database again, want cache hit")
+	}
+}
+
+// 失效前开始的读取在失效之后才把旧数据写入缓存，读取时按最低版本识别为未命中
+func TestGetIgnoresStaleAggregateCachedAfterInvalidate(t *testing.T) {
+	cache, replica, primary := newTestCache(t)
+	stale, err := cache.load(1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary.write(model.SailShopProduct{Id: 1, ShopId: 1, Title: "v2", Handler: "shirt", Version: 2})
+	cache.Invalidate(1, 1)
+	cache.set(aggregateKey(1, 1), stale, cache.conf.Expiry)
+	assertVersion(t, cache, 2)
+
+	// 从库追上之后正常从从库读取
+	replica.write(model.SailShopProduct{Id: 1, ShopId: 1, Title: "v3", Handler: "shirt", Version: 3})
+	primary.write(model.SailShopProduct{Id: 1, ShopId: 1, Title: "v3", Handler: "shirt", Version: 3})
+	cache.Invalidate(1, 1)
+	reads := primary.reads
+	assertVersion(t, cache, 3)
+	if primary.reads != reads {
+		t.Fatalf("loaded from primary although the replica is up to date")
+	}
+}
+
+// 写入方没有同步失效，变更事件晚到时缓存里仍是旧数据；事件到达后即使从库仍然落后也读到新数据
+func TestLateEventInvalidationWithLaggingReplica(t *testing.T) {
+	cache, _, primary := newTestCache(t)
+	assertVersion(t, cache, 1)
+
+	primary.write(model.SailShopProduct{Id: 1, ShopId: 1, Title: "v2", Handler: "shirt", Version: 2})
+	assertVersion(t, cache, 1)
+
+	cache.Invalidate(1, 1)
+	assertVersion(t, cache, 2)
+	aggregate, err := cache.GetByHandle(1, "shirt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aggregate.Product.Title != "v2" {
+		t.Fatalf("GetByHandle title = %q, want v2", aggregate.Product.Title)
+	}
+}
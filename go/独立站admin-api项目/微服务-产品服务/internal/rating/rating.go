@@ -0,0 +1,24 @@
+package rating
+
+import "math"
+
+// Average 平均分，没有评论时为0
+func Average(count, sum int64) float64 {
+	if count <= 0 {
+		return 0
+	}
+	return round(float64(sum) / float64(count))
+}
+
+// Bayesian 贝叶斯加权分 (C*m + sum) / (C + count)。m 为店铺平均分，C 为先验权重，
+// 评论少的产品向店铺平均分靠拢，避免一两条五星评论排在前面
+func Bayesian(count, sum int64, priorMean, priorWeight float64) float64 {
+	if float64(count)+priorWeight <= 0 {
+		return 0
+	}
+	return round((priorWeight*priorMean + float64(sum)) / (priorWeight + float64(count)))
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -56,6 +56,11 @@ func (s *ProductRPCServer) ProductDelete(ctx context.Context, in *product.Produc
 	return l.ProductDelete(in)
 }
 
+func (s *ProductRPCServer) WatchProducts(in *product.WatchProductsRequest, stream product.ProductRPC_WatchProductsServer) error {
+	l := logic.NewWatchProductsLogic(stream.Context(), s.svcCtx)
+	return l.WatchProducts(in, stream)
+}
+
 func (s *ProductRPCServer) ProductImageList(ctx context.Context, in *product.ProductImageListRequest) (*product.ProductImageListResponse, error) {
 	l := logic.NewProductImageListLogic(ctx, s.svcCtx)
 	return l.ProductImageList(in)
@@ -76,6 +81,11 @@ func (s *ProductRPCServer) ProductImageCount(ctx context.Context, in *product.Pr
 	return l.ProductImageCount(in)
 }
 
+func (s *ProductRPCServer) ProductImageIngestStatus(ctx context.Context, in *product.ProductImageIngestStatusRequest) (*product.ProductImageIngestStatusResponse, error) {
+	l := logic.NewProductImageIngestStatusLogic(ctx, s.svcCtx)
+	return l.ProductImageIngestStatus(in)
+}
+
 func (s *ProductRPCServer) ProductVariantList(ctx context.Context, in *product.ProductVariantListRequest) (*product.ProductVariantListResponse, error) {
 	l := logic.NewProductVariantListLogic(ctx, s.svcCtx)
 	return l.ProductVariantList(in)
@@ -155,3 +165,213 @@ func (s *ProductRPCServer) CategoryProductDelete(ctx context.Context, in *produc
 	l := logic.NewCategoryProductDeleteLogic(ctx, s.svcCtx)
 	return l.CategoryProductDelete(in)
 }
+
+func (s *ProductRPCServer) WebhookCreate(ctx context.Context, in *product.WebhookCreateRequest) (*product.WebhookCreateResponse, error) {
+	l := logic.NewWebhookCreateLogic(ctx, s.svcCtx)
+	return l.WebhookCreate(in)
+}
+
+func (s *ProductRPCServer) WebhookList(ctx context.Context, in *product.WebhookListRequest) (*product.WebhookListResponse, error) {
+	l := logic.NewWebhookListLogic(ctx, s.svcCtx)
+	return l.WebhookList(in)
+}
+
+func (s *ProductRPCServer) WebhookUpdate(ctx context.Context, in *product.WebhookUpdateRequest) (*product.WebhookUpdateResponse, error) {
+	l := logic.NewWebhookUpdateLogic(ctx, s.svcCtx)
+	return l.WebhookUpdate(in)
+}
+
+func (s *ProductRPCServer) WebhookDelete(ctx context.Context, in *product.WebhookDeleteRequest) (*product.WebhookDeleteResponse, error) {
+	l := logic.NewWebhookDeleteLogic(ctx, s.svcCtx)
+	return l.WebhookDelete(in)
+}
+
+func (s *ProductRPCServer) WebhookDeliveryList(ctx context.Context, in *product.WebhookDeliveryListRequest) (*product.WebhookDeliveryListResponse, error) {
+	l := logic.NewWebhookDeliveryListLogic(ctx, s.svcCtx)
+	return l.WebhookDeliveryList(in)
+}
+
+func (s *ProductRPCServer) RedirectList(ctx context.Context, in *product.RedirectListRequest) (*product.RedirectListResponse, error) {
+	l := logic.NewRedirectListLogic(ctx, s.svcCtx)
+	return l.RedirectList(in)
+}
+
+func (s *ProductRPCServer) RedirectDelete(ctx context.Context, in *product.RedirectDeleteRequest) (*product.RedirectDeleteResponse, error) {
+	l := logic.NewRedirectDeleteLogic(ctx, s.svcCtx)
+	return l.RedirectDelete(in)
+}
+
+func (s *ProductRPCServer) SitemapGenerate(in *product.SitemapGenerateRequest, stream product.ProductRPC_SitemapGenerateServer) error {
+	l := logic.NewSitemapGenerateLogic(stream.Context(), s.svcCtx)
+	return l.SitemapGenerate(in, stream)
+}
+
+func (s *ProductRPCServer) ProductJsonLd(ctx context.Context, in *product.ProductJsonLdRequest) (*product.ProductJsonLdResponse, error) {
+	l := logic.NewProductJsonLdLogic(ctx, s.svcCtx)
+	return l.ProductJsonLd(in)
+}
+
+func (s *ProductRPCServer) PriceListCreate(ctx context.Context, in *product.PriceListCreateRequest) (*product.PriceListCreateResponse, error) {
+	l := logic.NewPriceListCreateLogic(ctx, s.svcCtx)
+	return l.PriceListCreate(in)
+}
+
+func (s *ProductRPCServer) PriceListUpdate(ctx context.Context, in *product.PriceListUpdateRequest) (*product.PriceListUpdateResponse, error) {
+	l := logic.NewPriceListUpdateLogic(ctx, s.svcCtx)
+	return l.PriceListUpdate(in)
+}
+
+func (s *ProductRPCServer) PriceListList(ctx context.Context, in *product.PriceListListRequest) (*product.PriceListListResponse, error) {
+	l := logic.NewPriceListListLogic(ctx, s.svcCtx)
+	return l.PriceListList(in)
+}
+
+func (s *ProductRPCServer) PriceListDelete(ctx context.Context, in *product.PriceListDeleteRequest) (*product.PriceListDeleteResponse, error) {
+	l := logic.NewPriceListDeleteLogic(ctx, s.svcCtx)
+	return l.PriceListDelete(in)
+}
+
+func (s *ProductRPCServer) PriceListItemSet(ctx context.Context, in *product.PriceListItemSetRequest) (*product.PriceListItemSetResponse, error) {
+	l := logic.NewPriceListItemSetLogic(ctx, s.svcCtx)
+	return l.PriceListItemSet(in)
+}
+
+func (s *ProductRPCServer) PriceListItemList(ctx context.Context, in *product.PriceListItemListRequest) (*product.PriceListItemListResponse, error) {
+	l := logic.NewPriceListItemListLogic(ctx, s.svcCtx)
+	return l.PriceListItemList(in)
+}
+
+func (s *ProductRPCServer) SaleCreate(ctx context.Context, in *product.SaleCreateRequest) (*product.SaleCreateResponse, error) {
+	l := logic.NewSaleCreateLogic(ctx, s.svcCtx)
+	return l.SaleCreate(in)
+}
+
+func (s *ProductRPCServer) SaleList(ctx context.Context, in *product.SaleListRequest) (*product.SaleListResponse, error) {
+	l := logic.NewSaleListLogic(ctx, s.svcCtx)
+	return l.SaleList(in)
+}
+
+func (s *ProductRPCServer) SaleCancel(ctx context.Context, in *product.SaleCancelRequest) (*product.SaleCancelResponse, error) {
+	l := logic.NewSaleCancelLogic(ctx, s.svcCtx)
+	return l.SaleCancel(in)
+}
+
+func (s *ProductRPCServer) SalePreview(ctx context.Context, in *product.SalePreviewRequest) (*product.SalePreviewResponse, error) {
+	l := logic.NewSalePreviewLogic(ctx, s.svcCtx)
+	return l.SalePreview(in)
+}
+
+func (s *ProductRPCServer) ProductBulkPriceUpdate(in *product.ProductBulkPriceUpdateRequest, stream product.ProductRPC_ProductBulkPriceUpdateServer) error {
+	l := logic.NewProductBulkPriceUpdateLogic(stream.Context(), s.svcCtx)
+	return l.ProductBulkPriceUpdate(in, stream)
+}
+
+func (s *ProductRPCServer) MetafieldSet(ctx context.Context, in *product.MetafieldSetRequest) (*product.MetafieldSetResponse, error) {
+	l := logic.NewMetafieldSetLogic(ctx, s.svcCtx)
+	return l.MetafieldSet(in)
+}
+
+func (s *ProductRPCServer) MetafieldList(ctx context.Context, in *product.MetafieldListRequest) (*product.MetafieldListResponse, error) {
+	l := logic.NewMetafieldListLogic(ctx, s.svcCtx)
+	return l.MetafieldList(in)
+}
+
+func (s *ProductRPCServer) MetafieldDelete(ctx context.Context, in *product.MetafieldDeleteRequest) (*product.MetafieldDeleteResponse, error) {
+	l := logic.NewMetafieldDeleteLogic(ctx, s.svcCtx)
+	return l.MetafieldDelete(in)
+}
+
+func (s *ProductRPCServer) TranslationSet(ctx context.Context, in *product.TranslationSetRequest) (*product.TranslationSetResponse, error) {
+	l := logic.NewTranslationSetLogic(ctx, s.svcCtx)
+	return l.TranslationSet(in)
+}
+
+func (s *ProductRPCServer) TranslationList(ctx context.Context, in *product.TranslationListRequest) (*product.TranslationListResponse, error) {
+	l := logic.NewTranslationListLogic(ctx, s.svcCtx)
+	return l.TranslationList(in)
+}
+
+func (s *ProductRPCServer) TranslationDelete(ctx context.Context, in *product.TranslationDeleteRequest) (*product.TranslationDeleteResponse, error) {
+	l := logic.NewTranslationDeleteLogic(ctx, s.svcCtx)
+	return l.TranslationDelete(in)
+}
+
+func (s *ProductRPCServer) TranslationMissing(ctx context.Context, in *product.TranslationMissingRequest) (*product.TranslationMissingResponse, error) {
+	l := logic.NewTranslationMissingLogic(ctx, s.svcCtx)
+	return l.TranslationMissing(in)
+}
+
+func (s *ProductRPCServer) ReviewCreate(ctx context.Context, in *product.ReviewCreateRequest) (*product.ReviewCreateResponse, error) {
+	l := logic.NewReviewCreateLogic(ctx, s.svcCtx)
+	return l.ReviewCreate(in)
+}
+
+func (s *ProductRPCServer) ReviewUpdate(ctx context.Context, in *product.ReviewUpdateRequest) (*product.ReviewUpdateResponse, error) {
+	l := logic.NewReviewUpdateLogic(ctx, s.svcCtx)
+	return l.ReviewUpdate(in)
+}
+
+func (s *ProductRPCServer) ReviewDelete(ctx context.Context, in *product.ReviewDeleteRequest) (*product.ReviewDeleteResponse, error) {
+	l := logic.NewReviewDeleteLogic(ctx, s.svcCtx)
+	return l.ReviewDelete(in)
+}
+
+func (s *ProductRPCServer) ReviewDetail(ctx context.Context, in *product.ReviewDetailRequest) (*product.ReviewDetailResponse, error) {
+	l := logic.NewReviewDetailLogic(ctx, s.svcCtx)
+	return l.ReviewDetail(in)
+}
+
+func (s *ProductRPCServer) ReviewList(ctx context.Context, in *product.ReviewListRequest) (*product.ReviewListResponse, error) {
+	l := logic.NewReviewListLogic(ctx, s.svcCtx)
+	return l.ReviewList(in)
+}
+
+func (s *ProductRPCServer) ReviewModerate(ctx context.Context, in *product.ReviewModerateRequest) (*product.ReviewModerateResponse, error) {
+	l := logic.NewReviewModerateLogic(ctx, s.svcCtx)
+	return l.ReviewModerate(in)
+}
+
+func (s *ProductRPCServer) ReviewPin(ctx context.Context, in *product.ReviewPinRequest) (*product.ReviewPinResponse, error) {
+	l := logic.NewReviewPinLogic(ctx, s.svcCtx)
+	return l.ReviewPin(in)
+}
+
+func (s *ProductRPCServer) ReviewShow(ctx context.Context, in *product.ReviewShowRequest) (*product.ReviewShowResponse, error) {
+	l := logic.NewReviewShowLogic(ctx, s.svcCtx)
+	return l.ReviewShow(in)
+}
+
+func (s *ProductRPCServer) ReviewReply(ctx context.Context, in *product.ReviewReplyRequest) (*product.ReviewReplyResponse, error) {
+	l := logic.NewReviewReplyLogic(ctx, s.svcCtx)
+	return l.ReviewReply(in)
+}
+
+func (s *ProductRPCServer) ReviewVote(ctx context.Context, in *product.ReviewVoteRequest) (*product.ReviewVoteResponse, error) {
+	l := logic.NewReviewVoteLogic(ctx, s.svcCtx)
+	return l.ReviewVote(in)
+}
+
+func (s *ProductRPCServer) ProductRatingList(ctx context.Context, in *product.ProductRatingListRequest) (*product.ProductRatingListResponse, error) {
+	l := logic.NewProductRatingListLogic(ctx, s.svcCtx)
+	return l.ProductRatingList(in)
+}
+
+func (s *ProductRPCServer) ReviewImport(stream product.ProductRPC_ReviewImportServer) error {
+	l := logic.NewReviewImportLogic(stream.Context(), s.svcCtx)
+	return l.ReviewImport(stream)
+}
+
+func (s *ProductRPCServer) ProductBatchGet(ctx context.Context, in *product.ProductBatchGetRequest) (*product.ProductBatchGetResponse, error) {
+	l := logic.NewProductBatchGetLogic(ctx, s.svcCtx)
+	return l.ProductBatchGet(in)
+}
+
+func (s *ProductRPCServer) ProductPatch(ctx context.Context, in *product.ProductPatchRequest) (*product.ProductPatchResponse, error) {
+	l := logic.NewProductPatchLogic(ctx, s.svcCtx)
+	return l.ProductPatch(in)
+}
+
+func (s *ProductRPCServer) ProductVariantPatch(ctx context.Context, in *product.ProductVariantPatchRequest) (*product.ProductVariantPatchResponse, error) {
+	l := logic.NewProductVariantPatchLogic(ctx, s.svcCtx)
+	return l.ProductVariantPatch(in)
+}
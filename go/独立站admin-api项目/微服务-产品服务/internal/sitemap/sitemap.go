@@ -0,0 +1,107 @@
+package sitemap
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	IndexFile = "sitemap.xml"
+
+	xmlHeader    = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+	urlsetOpen   = `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">` + "\n"
+	urlsetClose  = "</urlset>\n"
+	indexOpen    = `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	indexClose   = "</sitemapindex>\n"
+	lastmodFmt   = time.RFC3339
+	fileNameTmpl = "sitemap-%ss-%d.xml"
+)
+
+type (
+	Url struct {
+		Loc     string
+		Lastmod time.Time
+		Images  []string
+	}
+
+	IndexEntry struct {
+		Loc     string
+		Lastmod time.Time
+	}
+
+	// UrlSetWriter 逐条写出 <urlset>，不在内存里保留整个文件
+	UrlSetWriter struct {
+		w   *bufio.Writer
+		err error
+	}
+)
+
+// FileName 子文件名，例如 sitemap-products-1.xml
+func FileName(kind string, seq int64) string {
+	return fmt.Sprintf(fileNameTmpl, kind, seq)
+}
+
+func NewUrlSetWriter(w io.Writer) *UrlSetWriter {
+	u := &UrlSetWriter{w: bufio.NewWriter(w)}
+	u.writeString(xmlHeader)
+	u.writeString(urlsetOpen)
+	return u
+}
+
+func (u *UrlSetWriter) Write(url Url) error {
+	u.writeString("<url><loc>")
+	u.escape(url.Loc)
+	u.writeString("</loc>")
+	if !url.Lastmod.IsZero() {
+		u.writeString("<lastmod>" + url.Lastmod.Format(lastmodFmt) + "</lastmod>")
+	}
+	for _, image := range url.Images {
+		u.writeString("<image:image><image:loc>")
+		u.escape(image)
+		u.writeString("</image:loc></image:image>")
+	}
+	u.writeString("</url>\n")
+	return u.err
+}
+
+func (u *UrlSetWriter) Close() error {
+	u.writeString(urlsetClose)
+	if u.err != nil {
+		return u.err
+	}
+	return u.w.Flush()
+}
+
+func (u *UrlSetWriter) writeString(s string) {
+	if u.err == nil {
+		_, u.err = u.w.WriteString(s)
+	}
+}
+
+func (u *UrlSetWriter) escape(s string) {
+	if u.err == nil {
+		u.err = xml.EscapeText(u.w, []byte(s))
+	}
+}
+
+func WriteIndex(w io.Writer, entries []IndexEntry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xmlHeader)
+	bw.WriteString(indexOpen)
+	for _, entry := range entries {
+		bw.WriteString("<sitemap><loc>")
+		if err := xml.EscapeText(bw, []byte(entry.Loc)); err != nil {
+			return err
+		}
+		bw.WriteString("</loc>")
+		if !entry.Lastmod.IsZero() {
+			bw.WriteString("<lastmod>" + entry.Lastmod.Format(lastmodFmt) + "</lastmod>")
+		}
+		bw.WriteString("</sitemap>\n")
+	}
+	bw.WriteString(indexClose)
+	return bw.Flush()
+}
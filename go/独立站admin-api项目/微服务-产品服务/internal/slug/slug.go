@@ -0,0 +1,121 @@
+package slug
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mozillazg/go-pinyin"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxLength 生成的链接最大长度，包含冲突时追加的数字后缀
+	MaxLength = 100
+	// MaxAttempts 唯一索引冲突时整个事务最多执行的次数
+	MaxAttempts = 5
+	// maxSuffixLength Pattern 覆盖的最长后缀，"-" 加6位数字
+	maxSuffixLength = 7
+)
+
+var (
+	pinyinArgs = pinyin.NewArgs()
+
+	// 拆分重音符号之后仍不能转成ASCII的拉丁字母
+	latinReplacer = strings.NewReplacer(
+		"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d", "ð", "d", "þ", "th", "ł", "l", "ı", "i",
+	)
+)
+
+// Make 把标题转成只包含 [a-z0-9-] 的链接：汉字转拼音，带重音的拉丁字母去掉重音，
+// 其他字符作为分隔符，结果为空时由调用方决定默认值
+func Make(title string) string {
+	title = latinReplacer.Replace(strings.ToLower(title))
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), title)
+	if err == nil {
+		title = folded
+	}
+
+	words := make([]string, 0)
+	var word, han []rune
+	flush := func() {
+		if len(word) != 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+		if len(han) != 0 {
+			words = append(words, pinyin.LazyPinyin(string(han), pinyinArgs)...)
+			han = han[:0]
+		}
+	}
+	for _, r := range title {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if len(han) != 0 {
+				flush()
+			}
+			word = append(word, r)
+		case unicode.Is(unicode.Han, r):
+			if len(word) != 0 {
+				flush()
+			}
+			han = append(han, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return truncate(strings.Join(words, "-"), MaxLength)
+}
+
+// WithSuffix 返回第 n 个候选链接，n 小于2时就是 base 本身，否则追加 "-n"，总长度不超过 MaxLength
+func WithSuffix(base string, n int) string {
+	if n < 2 {
+		return base
+	}
+	suffix := "-" + strconv.Itoa(n)
+	return truncate(base, MaxLength-len(suffix)) + suffix
+}
+
+// Pattern 返回匹配 base 所有候选链接的 LIKE 条件。base 过长时候选链接会截短 base，
+// 这时按最短的截断结果匹配，查出的记录可能多于候选链接，由 Pick 精确比较
+func Pattern(base string) string {
+	if len(base)+maxSuffixLength <= MaxLength {
+		return base + "-%"
+	}
+	return truncate(base, MaxLength-maxSuffixLength) + "%"
+}
+
+// Pick 从 base、base-2、base-3... 中选出第一个没有被 taken 占用的链接
+func Pick(base string, taken []string) string {
+	used := make(map[string]struct{}, len(taken))
+	for _, handle := range taken {
+		used[handle] = struct{}{}
+	}
+	for n := 1; ; n++ {
+		candidate := WithSuffix(base, n)
+		if _, ok := used[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
+// IsDuplicate 判断错误是否为指定唯一索引的冲突
+func IsDuplicate(err error, key string) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, key)
+}
+
+func truncate(s string, length int) string {
+	if len(s) > length {
+		s = s[:length]
+	}
+	return strings.Trim(s, "-")
+}
@@ -0,0 +1,51 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+// findTaken 模拟 StmtPickHandle 的查询：`handler` = base or `handler` like Pattern(base)
+func findTaken(handles []string, base string) []string {
+	pattern := Pattern(base)
+	if strings.ContainsAny(strings.TrimSuffix(pattern, "%"), "%_") {
+		panic("unexpected wildcard in pattern " + pattern)
+	}
+	taken := make([]string, 0)
+	for _, handle := range handles {
+		if handle == base || strings.HasPrefix(handle, strings.TrimSuffix(pattern, "%")) {
+			taken = append(taken, handle)
+		}
+	}
+	return taken
+}
+
+func TestPickMaxLengthTitlesCollide(t *testing.T) {
+	title := strings.Repeat("long title ", 20)
+	base := Make(title)
+	if len(base) != MaxLength {
+		t.Fatalf("len(Make(title)) = %d, want %d", len(base), MaxLength)
+	}
+	handles := []string{"long-title", "other-product"}
+	used := make(map[string]bool)
+	for i := 0; i < 12; i++ {
+		handle := Pick(base, findTaken(handles, base))
+		if used[handle] {
+			t.Fatalf("product %d picked %q, which is already used", i+1, handle)
+		}
+		if len(handle) > MaxLength {
+			t.Fatalf("product %d picked %q, longer than %d", i+1, handle, MaxLength)
+		}
+		used[handle] = true
+		handles = append(handles, handle)
+	}
+}
+
+func TestPatternShortBase(t *testing.T) {
+	if got := Pattern("red-shirt"); got != "red-shirt-%" {
+		t.Fatalf("Pattern(red-shirt) = %q, want %q", got, "red-shirt-%")
+	}
+	if got := Pick("red-shirt", findTaken([]string{"red-shirt", "red-shirt-2", "red-shirts"}, "red-shirt")); got != "red-shirt-3" {
+		t.Fatalf("Pick = %q, want red-shirt-3", got)
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"github.com/tal-tech/go-zero/core/stores/redis"
 	"github.com/tal-tech/go-zero/core/stores/sqlx"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fetcher"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model/mongo/webhook"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/productcache"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/watch"
 	"os"
 )
 
@@ -24,6 +28,28 @@ type ServiceContext struct {
 	WriteProductDetailModel   model.SailShopProductDetailModel
 	ReadProductCommentsModel  model.SailProductCommentsModel
 	WriteProductCommentsModel model.SailProductCommentsModel
+	ImageJobModel             model.SailProductImageJobModel
+	OutboxModel               model.SailProductOutboxModel
+	ReadRedirectModel         model.SailProductRedirectModel
+	WriteRedirectModel        model.SailProductRedirectModel
+	SitemapModel              model.SailProductSitemapModel
+	ReadPriceListModel        model.SailProductPriceListModel
+	WritePriceListModel       model.SailProductPriceListModel
+	SaleModel                 model.SailProductSaleModel
+	BulkPriceModel            model.SailProductBulkPriceModel
+	ReadMetafieldModel        model.SailProductMetafieldModel
+	WriteMetafieldModel       model.SailProductMetafieldModel
+	ReadTranslationModel      model.SailProductTranslationModel
+	WriteTranslationModel     model.SailProductTranslationModel
+	ReadReviewModel           model.SailProductReviewModel
+	WriteReviewModel          model.SailProductReviewModel
+	RatingModel               model.SailProductRatingModel
+	ProductBatchModel         model.SailProductBatchModel
+	ProductCache              *productcache.Cache
+	WatchHub                  *watch.Hub
+	WebhookModel              webhook.WebhookModel
+	WebhookDeliveryModel      webhook.DeliveryModel
+	ImageFetcher              *fetcher.Fetcher
 	ImgCDN                    string
 	StaticStorage             config.StaticStorage
 	AliOss                    config.AliOss
@@ -41,12 +67,22 @@ func NewServiceContext(c config.Config) *ServiceContext {
 		saasPass = "Lp8^w#H$r43@"
 	}
 	redisClientSaas := redis.NewRedis(c.Cache[1].Host, c.Cache[1].Type, saasPass)
+	outboxModel := model.NewSailProductOutboxModel(sqlx.NewMysql(c.WriteDataSource), c.Cache)
+	readModel := model.NewSailShopProductModel(sqlx.NewMysql(c.ReadDataSource), c.Cache)
+	readImageModel := model.NewSailUploadModel(sqlx.NewMysql(c.ReadDataSource), c.Cache)
+	productBatchModel := model.NewSailProductBatchModel(sqlx.NewMysql(c.ReadDataSource), c.Cache)
+	writeModel := model.NewSailShopProductModel(sqlx.NewMysql(c.WriteDataSource), c.Cache)
+	writeImageModel := model.NewSailUploadModel(sqlx.NewMysql(c.WriteDataSource), c.Cache)
+	productCache := productcache.NewCache(redisClientSaas, c.ProductCache,
+		productcache.Source{ProductModel: readModel, BatchModel: productBatchModel, ImageModel: readImageModel},
+		productcache.Source{ProductModel: writeModel, BatchModel: model.NewSailProductBatchModel(sqlx.NewMysql(c.WriteDataSource), c.Cache), ImageModel: writeImageModel})
+	mongoUrl := c.MongoLink + "/" + c.MongoDBName
 	return &ServiceContext{
 		Config:                    c,
-		ReadModel:                 model.NewSailShopProductModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
-		WriteModel:                model.NewSailShopProductModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
-		ReadImageModel:            model.NewSailUploadModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
-		WriteImageModel:           model.NewSailUploadModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		ReadModel:                 readModel,
+		WriteModel:                writeModel,
+		ReadImageModel:            readImageModel,
+		WriteImageModel:           writeImageModel,
 		ReadVariantModel:          model.NewSailShopProductVariantModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
 		WriteVariantModel:         model.NewSailShopProductVariantModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
 		ReadCategoryModel:         model.NewSailShopCategoryModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
@@ -57,6 +93,28 @@ func NewServiceContext(c config.Config) *ServiceContext {
 		WriteProductDetailModel:   model.NewSailShopProductDetailModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
 		ReadProductCommentsModel:  model.NewSailProductCommentsModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
 		WriteProductCommentsModel: model.NewSailProductCommentsModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		ImageJobModel:             model.NewSailProductImageJobModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		OutboxModel:               outboxModel,
+		ReadRedirectModel:         model.NewSailProductRedirectModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
+		WriteRedirectModel:        model.NewSailProductRedirectModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		SitemapModel:              model.NewSailProductSitemapModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		ReadPriceListModel:        model.NewSailProductPriceListModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
+		WritePriceListModel:       model.NewSailProductPriceListModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		SaleModel:                 model.NewSailProductSaleModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		BulkPriceModel:            model.NewSailProductBulkPriceModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		ReadMetafieldModel:        model.NewSailProductMetafieldModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
+		WriteMetafieldModel:       model.NewSailProductMetafieldModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		ReadTranslationModel:      model.NewSailProductTranslationModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
+		WriteTranslationModel:     model.NewSailProductTranslationModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		ReadReviewModel:           model.NewSailProductReviewModel(sqlx.NewMysql(c.ReadDataSource), c.Cache),
+		WriteReviewModel:          model.NewSailProductReviewModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		RatingModel:               model.NewSailProductRatingModel(sqlx.NewMysql(c.WriteDataSource), c.Cache),
+		ProductBatchModel:         productBatchModel,
+		ProductCache:              productCache,
+		WatchHub:                  watch.NewHub(outboxModel, c.Watch),
+		WebhookModel:              webhook.NewWebhookModel(mongoUrl, "webhook", c.Cache),
+		WebhookDeliveryModel:      webhook.NewDeliveryModel(mongoUrl, "webhook_delivery", c.Cache),
+		ImageFetcher:              fetcher.NewFetcher(c.ImageFetch),
 		ImgCDN:                    c.ImgCDN,
 		StaticStorage:             c.StaticStorage,
 		AliOss:                    c.AliOss,
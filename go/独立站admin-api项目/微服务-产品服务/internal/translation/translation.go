@@ -0,0 +1,100 @@
+package translation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/metafield"
+)
+
+const (
+	FieldTitle    = "title"
+	FieldSubTitle = "sub_title"
+	FieldBodyHtml = "body_html"
+	FieldSeoTitle = "seo_title"
+	FieldSeoDesc  = "seo_desc"
+	FieldSpec     = "spec"
+)
+
+var (
+	ErrLocaleInvalid = errors.New("locale must look like en or pt-BR")
+
+	localeRegexp = regexp.MustCompile(`^([A-Za-z]{2,3})(?:[-_]([A-Za-z]{2}|[0-9]{3}|[A-Za-z]{4}))?$`)
+
+	// 与原表字段长度一致，body_html 不限制
+	fieldLength = map[string]int{
+		FieldTitle:    255,
+		FieldSubTitle: 400,
+		FieldSeoTitle: 255,
+		FieldSeoDesc:  1024,
+	}
+
+	ownerFields = map[string][]string{
+		metafield.OwnerProduct:  {FieldTitle, FieldSubTitle, FieldBodyHtml, FieldSeoTitle, FieldSeoDesc},
+		metafield.OwnerVariant:  {FieldTitle, FieldSpec},
+		metafield.OwnerCategory: {FieldTitle, FieldBodyHtml, FieldSeoTitle, FieldSeoDesc},
+	}
+)
+
+// NormalizeLocale 语言小写、地区大写，例如 pt_br 返回 pt-BR
+func NormalizeLocale(locale string) (string, error) {
+	match := localeRegexp.FindStringSubmatch(strings.TrimSpace(locale))
+	if match == nil {
+		return "", ErrLocaleInvalid
+	}
+	result := strings.ToLower(match[1])
+	switch len(match[2]) {
+	case 0:
+	case 4:
+		result += "-" + strings.ToUpper(match[2][:1]) + strings.ToLower(match[2][1:])
+	default:
+		result += "-" + strings.ToUpper(match[2])
+	}
+	return result, nil
+}
+
+// Fields 返回对象可翻译的字段
+func Fields(ownerType string) []string {
+	return ownerFields[ownerType]
+}
+
+func ValidField(ownerType, field string) bool {
+	for _, item := range ownerFields[ownerType] {
+		if item == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 校验翻译内容，spec 需与子商品规格格式相同
+func Validate(field, value string) error {
+	if max, ok := fieldLength[field]; ok && utf8.RuneCountInString(value) > max {
+		return fmt.Errorf("%s exceeds %d characters", field, max)
+	}
+	if field == FieldSpec {
+		var spec []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal([]byte(value), &spec); err != nil {
+			return errors.New("spec must be a json array of {name, value}")
+		}
+	}
+	return nil
+}
+
+// Values 一个对象在某语言下的翻译，field => value
+type Values map[string]string
+
+// Get 有翻译时返回翻译，否则返回原值；原值为空（未请求该字段）时保持为空
+func (v Values) Get(field, base string) string {
+	if value, ok := v[field]; ok && value != "" && base != "" {
+		return value
+	}
+	return base
+}
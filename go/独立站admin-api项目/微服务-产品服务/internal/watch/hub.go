@@ -0,0 +1,153 @@
+package watch
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+)
+
+type (
+	// Hub 在进程内给 sail_product_outbox 中新提交的事件分配顺序号，按顺序号跟踪尾部并分发给所有订阅者。
+	// 事件来自已提交的发件箱记录，写入方不会感知订阅者；分发时订阅者的缓冲区满了
+	// 直接把它标记为落后并移除，由订阅者自己回到数据库追赶，不会拖慢其他订阅者
+	Hub struct {
+		outboxModel model.SailProductOutboxModel
+		conf        config.Watch
+		lock        sync.RWMutex
+		head        int64
+		subscribers map[*Subscriber]struct{}
+		done        chan struct{}
+		exited      chan struct{}
+	}
+
+	Subscriber struct {
+		shopId int64
+		events chan model.SailProductOutbox
+		lagged chan struct{}
+	}
+)
+
+func NewHub(outboxModel model.SailProductOutboxModel, conf config.Watch) *Hub {
+	return &Hub{
+		outboxModel: outboxModel,
+		conf:        conf,
+		subscribers: map[*Subscriber]struct{}{},
+		done:        make(chan struct{}),
+		exited:      make(chan struct{}),
+	}
+}
+
+func (h *Hub) Start() {
+	defer close(h.exited)
+	for {
+		_, maxSeq, err := h.outboxModel.FindSeqRange()
+		if err == nil {
+			h.setHead(maxSeq)
+			break
+		}
+		logx.Error("初始化变更订阅位置失败：", err)
+		select {
+		case <-h.done:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+
+	ticker := time.NewTicker(time.Duration(h.conf.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+			h.poll()
+		}
+	}
+}
+
+func (h *Hub) Stop() {
+	close(h.done)
+	<-h.exited
+}
+
+// Head 返回已分发的最大顺序号，小于等于它的事件都已经确定，可以直接从数据库读取
+func (h *Hub) Head() int64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.head
+}
+
+// Subscribe 订阅之后才分发的事件，shopId 为0时订阅所有店铺
+func (h *Hub) Subscribe(shopId int64) *Subscriber {
+	sub := &Subscriber{
+		shopId: shopId,
+		events: make(chan model.SailProductOutbox, h.conf.Buffer),
+		lagged: make(chan struct{}),
+	}
+	h.lock.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.lock.Unlock()
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.lock.Lock()
+	delete(h.subscribers, sub)
+	h.lock.Unlock()
+}
+
+// poll 先给新提交的事件分配顺序号，再按顺序号推进。顺序号在事件提交之后才分配，
+// 提交较晚的长事务只会排在后面，不会被跳过
+func (h *Hub) poll() {
+	if _, err := h.outboxModel.Sequence(h.conf.BatchSize); err != nil {
+		logx.Error("分配产品变更事件顺序号失败：", err)
+	}
+	events, err := h.outboxModel.FindAfter(h.Head(), 0, 0, h.conf.BatchSize)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		return
+	default:
+		logx.Error("查询产品变更事件失败：", err)
+		return
+	}
+	for _, event := range *events {
+		h.dispatch(event, event.Seq)
+	}
+}
+
+func (h *Hub) dispatch(event model.SailProductOutbox, head int64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.head = head
+	for sub := range h.subscribers {
+		if sub.shopId != 0 && sub.shopId != event.ShopId {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			close(sub.lagged)
+			delete(h.subscribers, sub)
+		}
+	}
+}
+
+func (h *Hub) setHead(head int64) {
+	h.lock.Lock()
+	h.head = head
+	h.lock.Unlock()
+}
+
+func (s *Subscriber) Events() <-chan model.SailProductOutbox {
+	return s.events
+}
+
+// Lagged 在订阅者跟不上分发速度被移除时关闭
+func (s *Subscriber) Lagged() <-chan struct{} {
+	return s.lagged
+}
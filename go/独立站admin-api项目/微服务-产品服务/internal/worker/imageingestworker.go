@@ -0,0 +1,256 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/threading"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/logic"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/product"
+)
+
+const (
+	failedProImgsQueue       = "admin-api:failedProImgs"
+	failedDefaultProImgQueue = "admin-api:failedDefaultProImg"
+	failedVariantImgQueue    = "admin-api:failedVariantImg"
+)
+
+// errLegacyItemInvalid 旧队列中无法解析或产品已删除的数据，重试也不会成功，直接丢弃
+var errLegacyItemInvalid = errors.New("legacy image item is invalid")
+
+// ImageIngestWorker 消费 sail_product_image_job 中的图片上传任务，失败按指数退避重试，
+// 超过最大次数后标记为死信；启动时顺带把旧版本遗留在redis失败队列中的数据迁移成任务
+type ImageIngestWorker struct {
+	svcCtx *svc.ServiceContext
+	owner  string
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewImageIngestWorker(svcCtx *svc.ServiceContext) *ImageIngestWorker {
+	hostname, _ := os.Hostname()
+	return &ImageIngestWorker{
+		svcCtx: svcCtx,
+		owner:  fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		done:   make(chan struct{}),
+	}
+}
+
+func (w *ImageIngestWorker) Start() {
+	conf := w.svcCtx.Config.ImageIngest
+	jobs := make(chan model.SailProductImageJob)
+	for i := 0; i < conf.Workers; i++ {
+		w.wg.Add(1)
+		threading.GoSafe(func() {
+			defer w.wg.Done()
+			for job := range jobs {
+				w.process(job)
+			}
+		})
+	}
+
+	w.drainLegacyQueues()
+	ticker := time.NewTicker(time.Duration(conf.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			close(jobs)
+			return
+		case <-ticker.C:
+			claimed, err := w.svcCtx.ImageJobModel.Claim(w.owner, conf.BatchSize, time.Duration(conf.Lease)*time.Millisecond)
+			switch err {
+			case nil:
+			case sqlc.ErrNotFound:
+				continue
+			default:
+				logx.Error("领取图片任务失败：", err)
+				continue
+			}
+			for _, job := range *claimed {
+				jobs <- job
+			}
+		}
+	}
+}
+
+func (w *ImageIngestWorker) Stop() {
+	close(w.done)
+	w.wg.Wait()
+}
+
+func (w *ImageIngestWorker) process(job model.SailProductImageJob) {
+	err := w.ingest(&job)
+	if err == nil {
+		if err := w.svcCtx.ImageJobModel.MarkSucceeded(job.Id); err != nil {
+			logx.Error("更新图片任务状态失败：", err)
+		}
+		return
+	}
+
+	logx.Errorf("图片任务执行失败 job_id:%d product_id:%d attempts:%d err:%s", job.Id, job.ProductId, job.Attempts, err)
+	if job.Attempts >= w.svcCtx.Config.ImageIngest.MaxAttempts {
+		if err := w.svcCtx.ImageJobModel.MarkDead(job.Id, err.Error()); err != nil {
+			logx.Error("更新图片任务状态失败：", err)
+		}
+		return
+	}
+	if err := w.svcCtx.ImageJobModel.MarkFailed(job.Id, err.Error(), time.Now().Add(w.backoff(job.Attempts))); err != nil {
+		logx.Error("更新图片任务状态失败：", err)
+	}
+}
+
+// ingest 上传成功后先记下image_id，之后的主图、子商品关联失败重试时不会重复上传
+func (w *ImageIngestWorker) ingest(job *model.SailProductImageJob) error {
+	if job.CommentId != 0 {
+		return w.ingestReviewImage(job)
+	}
+	if job.ImageId == 0 {
+		imageAddLogic := logic.NewProductImageAddLogic(context.Background(), w.svcCtx)
+		respImage, err := imageAddLogic.ProductImageAdd(&product.ProductImageAddRequest{
+			ShopId:    job.ShopId,
+			ProductId: job.ProductId,
+			Url:       job.Url,
+		})
+		if err != nil {
+			return err
+		}
+		job.ImageId = respImage.Image.Id
+		if err := w.svcCtx.ImageJobModel.SetImageId(job.Id, job.ImageId); err != nil {
+			logx.Error("记录图片任务image_id失败：", err)
+		}
+	}
+	if job.IsDefault == 1 {
+		if err := w.svcCtx.WriteModel.UpdateDefaultImage(job.ImageId, job.ProductId); err != nil {
+			return err
+		}
+	}
+	for _, variantId := range model.ParseVariantIds(job.VariantIds) {
+		if err := w.svcCtx.WriteModel.UpdateVariantImage(job.ImageId, job.ProductId, variantId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ingestReviewImage 评论导入的图片转存后追加到评论，不关联产品主图和子商品
+func (w *ImageIngestWorker) ingestReviewImage(job *model.SailProductImageJob) error {
+	if job.ImageId == 0 {
+		imageId, err := logic.NewProductImageAddLogic(context.Background(), w.svcCtx).UploadReviewImage(job.ShopId, job.Url)
+		if err != nil {
+			return err
+		}
+		job.ImageId = imageId
+		if err := w.svcCtx.ImageJobModel.SetImageId(job.Id, job.ImageId); err != nil {
+			logx.Error("记录图片任务image_id失败：", err)
+		}
+	}
+	return w.svcCtx.WriteReviewModel.AppendImage(job.ShopId, job.CommentId, job.ImageId)
+}
+
+func (w *ImageIngestWorker) backoff(attempts int64) time.Duration {
+	conf := w.svcCtx.Config.ImageIngest
+	wait := conf.BaseBackoff
+	for i := int64(1); i < attempts && wait < conf.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > conf.MaxBackoff {
+		wait = conf.MaxBackoff
+	}
+	// 加上最多20%的随机抖动，避免大量任务在同一时刻重试
+	wait += rand.Int63n(wait/5 + 1)
+	return time.Duration(wait) * time.Millisecond
+}
+
+// drainLegacyQueues 旧版本在上传失败时把数据推到redis列表里但从未消费，
+// 这里逐条取出转成任务；旧数据没有shop_id，需要按product_id反查。
+// 查询或写入失败时把数据放回队列原来的位置，停止迁移该队列，下次启动再处理
+func (w *ImageIngestWorker) drainLegacyQueues() {
+	for _, queue := range []string{failedProImgsQueue, failedDefaultProImgQueue, failedVariantImgQueue} {
+		for {
+			item, err := w.svcCtx.RedisClient.Rpop(queue)
+			if err != nil || item == "" {
+				break
+			}
+			jobs, err := w.legacyJobs(queue, item)
+			if err == errLegacyItemInvalid {
+				continue
+			}
+			if err == nil {
+				err = w.svcCtx.ImageJobModel.InsertList(jobs)
+			}
+			if err == nil {
+				continue
+			}
+			logx.Errorf("迁移图片失败队列出错 queue:%s item:%s err:%s", queue, item, err)
+			if _, err := w.svcCtx.RedisClient.Rpush(queue, item); err != nil {
+				logx.Errorf("放回图片失败队列出错 queue:%s item:%s err:%s", queue, item, err)
+			}
+			break
+		}
+	}
+}
+
+func (w *ImageIngestWorker) legacyJobs(queue, item string) ([]model.SailProductImageJob, error) {
+	jobs := make([]model.SailProductImageJob, 0)
+	var productId int64
+	var urls []string
+	var isDefault, variantId int64
+	switch queue {
+	case failedDefaultProImgQueue:
+		var failed logic.FailedDefaultProImg
+		if err := json.Unmarshal([]byte(item), &failed); err != nil {
+			logx.Errorf("图片失败队列数据不合法 queue:%s item:%s err:%s", queue, item, err)
+			return nil, errLegacyItemInvalid
+		}
+		productId, urls, isDefault = failed.ProductId, []string{failed.Url}, 1
+	default:
+		var failed logic.FailedVariantImg
+		if err := json.Unmarshal([]byte(item), &failed); err == nil && failed.VariantId != 0 {
+			productId, urls, variantId = failed.ProductId, []string{failed.Url}, failed.VariantId
+			break
+		}
+		var failedPro logic.FailedProImg
+		if err := json.Unmarshal([]byte(item), &failedPro); err != nil {
+			logx.Errorf("图片失败队列数据不合法 queue:%s item:%s err:%s", queue, item, err)
+			return nil, errLegacyItemInvalid
+		}
+		productId, urls = failedPro.ProductId, failedPro.Urls
+	}
+
+	productInfo, err := w.svcCtx.ReadModel.FindOneById(productId)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		logx.Errorf("图片失败队列的产品不存在 queue:%s product_id:%d", queue, productId)
+		return nil, errLegacyItemInvalid
+	default:
+		return nil, err
+	}
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		job := model.SailProductImageJob{
+			ShopId:    productInfo.ShopId,
+			ProductId: productId,
+			Url:       url,
+			IsDefault: isDefault,
+		}
+		if variantId != 0 {
+			job.VariantIds = fmt.Sprintf("%d", variantId)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
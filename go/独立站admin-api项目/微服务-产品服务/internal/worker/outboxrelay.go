@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+)
+
+const (
+	outboxRelayLockKey = "product:outbox:relay:lock"
+	outboxPurgeEvery   = time.Minute
+	outboxPurgeLimit   = 1000
+)
+
+// OutboxRelay 把 sail_product_outbox 中的事件按写入顺序投递到es同步队列。
+// 同一时间只有拿到redis锁的实例在投递，投递失败时停在失败的事件上，下一轮从这里继续，
+// 因此同一产品的事件不会乱序；推送成功但标记失败时会重复投递，消费方按version去重
+type OutboxRelay struct {
+	svcCtx *svc.ServiceContext
+	lock   *redis.RedisLock
+	done   chan struct{}
+	exited chan struct{}
+}
+
+func NewOutboxRelay(svcCtx *svc.ServiceContext) *OutboxRelay {
+	lock := redis.NewRedisLock(svcCtx.RedisClientSaas, outboxRelayLockKey)
+	lock.SetExpire(svcCtx.Config.Outbox.LockExpire)
+	return &OutboxRelay{
+		svcCtx: svcCtx,
+		lock:   lock,
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+func (r *OutboxRelay) Start() {
+	defer close(r.exited)
+	conf := r.svcCtx.Config.Outbox
+	ticker := time.NewTicker(time.Duration(conf.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+	for {
+		select {
+		case <-r.done:
+			if _, err := r.lock.Release(); err != nil {
+				logx.Error("释放事件投递锁失败：", err)
+			}
+			return
+		case <-ticker.C:
+			// 同一个锁实例再次 Acquire 会续期
+			if ok, err := r.lock.Acquire(); !ok || err != nil {
+				continue
+			}
+			// 一批投递完成后如果还有积压，续期后立即继续下一批
+			for r.relay(conf.BatchSize) == conf.BatchSize && !r.stopped() {
+				if ok, err := r.lock.Acquire(); !ok || err != nil {
+					break
+				}
+			}
+			if time.Since(lastPurge) > outboxPurgeEvery {
+				lastPurge = time.Now()
+				r.purge()
+			}
+		}
+	}
+}
+
+func (r *OutboxRelay) Stop() {
+	close(r.done)
+	<-r.exited
+}
+
+func (r *OutboxRelay) stopped() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// relay 返回本批成功投递的事件数
+func (r *OutboxRelay) relay(limit int64) int64 {
+	events, err := r.svcCtx.OutboxModel.FindPending(limit)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		return 0
+	default:
+		logx.Error("查询待投递事件失败：", err)
+		return 0
+	}
+
+	published := make([]int64, 0, len(*events))
+	for _, event := range *events {
+		esEvent := event.Event
+		if esEvent == model.ES_SYNC_EVENT_INVENTORY {
+			esEvent = model.ES_SYNC_EVENT_UPDATE
+		}
+		payload, err := json.Marshal(model.EsQueue{
+			ShopId:    event.ShopId,
+			ProductId: event.ProductId,
+			Event:     esEvent,
+			Version:   event.Version,
+			EventId:   event.Id,
+		})
+		if err != nil {
+			logx.Error("添加到es队列失败:", err)
+			break
+		}
+		if _, err = r.svcCtx.RedisClientSaas.Lpush(model.PRODUCT_ES_SYNC_QUEUE, string(payload)); err != nil {
+			logx.Error("添加到es队列失败:", err)
+			break
+		}
+		published = append(published, event.Id)
+	}
+	if err := r.svcCtx.OutboxModel.MarkPublished(published); err != nil {
+		logx.Error("更新事件投递状态失败：", err)
+		return 0
+	}
+	return int64(len(published))
+}
+
+func (r *OutboxRelay) purge() {
+	before := time.Now().Add(-time.Duration(r.svcCtx.Config.Outbox.Retention) * time.Hour)
+	if _, err := r.svcCtx.OutboxModel.DeletePublishedBefore(before, outboxPurgeLimit); err != nil {
+		logx.Error("清理已投递事件失败：", err)
+	}
+}
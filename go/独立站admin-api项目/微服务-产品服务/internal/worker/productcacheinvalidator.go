@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+)
+
+const (
+	productCacheLockKey   = "product:aggregate:worker:lock"
+	productCacheCursorKey = "product:aggregate:cursor"
+)
+
+// ProductCacheInvalidator 从发件箱读取产品变更事件删除产品聚合缓存。写入接口返回前已经删除过一次，
+// 这里覆盖没有直接删除缓存的写入，也清掉写入后到从库同步前被读回的旧数据。读取位置保存在redis中
+type ProductCacheInvalidator struct {
+	svcCtx *svc.ServiceContext
+	lock   *redis.RedisLock
+	done   chan struct{}
+	exited chan struct{}
+}
+
+func NewProductCacheInvalidator(svcCtx *svc.ServiceContext) *ProductCacheInvalidator {
+	lock := redis.NewRedisLock(svcCtx.RedisClientSaas, productCacheLockKey)
+	lock.SetExpire(svcCtx.Config.ProductCache.LockExpire)
+	return &ProductCacheInvalidator{
+		svcCtx: svcCtx,
+		lock:   lock,
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+func (w *ProductCacheInvalidator) Start() {
+	defer close(w.exited)
+	ticker := time.NewTicker(time.Duration(w.svcCtx.Config.ProductCache.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			if _, err := w.lock.Release(); err != nil {
+				logx.Error("释放产品缓存失效锁失败：", err)
+			}
+			return
+		case <-ticker.C:
+			if ok, err := w.lock.Acquire(); !ok || err != nil {
+				continue
+			}
+			w.invalidate()
+		}
+	}
+}
+
+func (w *ProductCacheInvalidator) Stop() {
+	close(w.done)
+	<-w.exited
+}
+
+func (w *ProductCacheInvalidator) invalidate() {
+	head := w.svcCtx.WatchHub.Head()
+	if head == 0 {
+		return
+	}
+	cursorStr, err := w.svcCtx.RedisClientSaas.Get(productCacheCursorKey)
+	if err != nil {
+		logx.Error("读取产品缓存失效位置失败：", err)
+		return
+	}
+	cursor, err := strconv.ParseInt(cursorStr, 10, 64)
+	if cursorStr == "" || err != nil {
+		// 第一次运行时缓存里还没有数据，从当前位置开始
+		if err = w.svcCtx.RedisClientSaas.Set(productCacheCursorKey, strconv.FormatInt(head, 10)); err != nil {
+			logx.Error("保存产品缓存失效位置失败：", err)
+		}
+		return
+	}
+	if cursor >= head {
+		return
+	}
+
+	events, err := w.svcCtx.OutboxModel.FindAfter(cursor, head, 0, w.svcCtx.Config.ProductCache.BatchSize)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		return
+	default:
+		logx.Error("查询产品变更事件失败：", err)
+		return
+	}
+	for _, event := range *events {
+		w.svcCtx.ProductCache.Invalidate(event.ShopId, event.ProductId)
+		cursor = event.Seq
+	}
+	if err := w.svcCtx.RedisClientSaas.Set(productCacheCursorKey, strconv.FormatInt(cursor, 10)); err != nil {
+		logx.Error("保存产品缓存失效位置失败：", err)
+	}
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+)
+
+const ratingRebuildAttempts = 3
+
+// RebuildRatings 从评论表重新统计产品评分聚合和 comments、scores，shopId 为0时处理所有店铺。
+// 与评论修改并发时单批可能因死锁回滚，重试几次后仍失败则返回错误，已完成的批次不受影响
+func RebuildRatings(svcCtx *svc.ServiceContext, shopId int64) error {
+	var afterId int64
+	for {
+		var lastId int64
+		var err error
+		for attempt := 1; attempt <= ratingRebuildAttempts; attempt++ {
+			if lastId, err = svcCtx.RatingModel.Rebuild(shopId, afterId, svcCtx.Config.Rating.RebuildBatchSize); err == nil {
+				break
+			}
+			logx.Errorf("重建产品评分失败 after_id:%d attempt:%d err:%v", afterId, attempt, err)
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if err != nil {
+			return err
+		}
+		if lastId == 0 {
+			logx.Infof("产品评分重建完成 shop_id:%d", shopId)
+			return nil
+		}
+		afterId = lastId
+		logx.Infof("产品评分重建中 shop_id:%d after_id:%d", shopId, afterId)
+	}
+}
@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/pricing"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+)
+
+const saleSchedulerLockKey = "product:sale:scheduler:lock"
+
+// SaleScheduler 在活动开始时改价、结束时恢复原价。同一时间只有拿到redis锁的实例在执行；
+// 每个产品单独一个事务并留下改价记录，中途退出或锁过期被其他实例接手时从头重跑也只会处理剩下的产品
+type SaleScheduler struct {
+	svcCtx *svc.ServiceContext
+	lock   *redis.RedisLock
+	done   chan struct{}
+	exited chan struct{}
+}
+
+func NewSaleScheduler(svcCtx *svc.ServiceContext) *SaleScheduler {
+	lock := redis.NewRedisLock(svcCtx.RedisClientSaas, saleSchedulerLockKey)
+	lock.SetExpire(svcCtx.Config.Sale.LockExpire)
+	return &SaleScheduler{
+		svcCtx: svcCtx,
+		lock:   lock,
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+func (s *SaleScheduler) Start() {
+	defer close(s.exited)
+	ticker := time.NewTicker(time.Duration(s.svcCtx.Config.Sale.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			if _, err := s.lock.Release(); err != nil {
+				logx.Error("释放促销活动锁失败：", err)
+			}
+			return
+		case <-ticker.C:
+			if ok, err := s.lock.Acquire(); !ok || err != nil {
+				continue
+			}
+			s.run()
+		}
+	}
+}
+
+func (s *SaleScheduler) Stop() {
+	close(s.done)
+	<-s.exited
+}
+
+func (s *SaleScheduler) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *SaleScheduler) run() {
+	sales, err := s.svcCtx.SaleModel.FindDue(time.Now(), s.svcCtx.Config.Sale.BatchSize)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		return
+	default:
+		logx.Error("查询待执行的促销活动失败：", err)
+		return
+	}
+	for _, sale := range *sales {
+		if s.stopped() {
+			return
+		}
+		if sale.Status == model.SALE_STATUS_SCHEDULED && sale.EndsAt.After(time.Now()) {
+			s.apply(sale)
+		} else {
+			s.revert(sale)
+		}
+	}
+}
+
+func (s *SaleScheduler) apply(sale model.SailProductSale) {
+	salePrice := func(price float64) (float64, bool) {
+		return pricing.SalePrice(sale.DiscountType, sale.DiscountValue, price)
+	}
+	var afterId, applied int64
+	for {
+		ids, err := s.svcCtx.SaleModel.FindTargetProducts(sale, afterId, s.svcCtx.Config.Sale.BatchSize)
+		if err != nil {
+			logx.Error("查询促销活动产品失败：", err)
+			return
+		}
+		for _, id := range ids {
+			count, err := s.svcCtx.SaleModel.ApplyProduct(sale, id, salePrice)
+			if err != nil {
+				logx.Errorf("促销活动改价失败 sale_id:%d product_id:%d err:%s", sale.Id, id, err)
+				return
+			}
+			applied += count
+			afterId = id
+		}
+		if int64(len(ids)) < s.svcCtx.Config.Sale.BatchSize {
+			break
+		}
+		// 每批之后续期，拿不到锁说明已被其他实例接手
+		if ok, err := s.lock.Acquire(); !ok || err != nil || s.stopped() {
+			return
+		}
+	}
+	if _, err := s.svcCtx.SaleModel.SetStatus(sale.Id, model.SALE_STATUS_SCHEDULED, model.SALE_STATUS_ACTIVE); err != nil {
+		logx.Error("更新促销活动状态失败：", err)
+		return
+	}
+	logx.Infof("促销活动已开始 sale_id:%d 改价:%d", sale.Id, applied)
+}
+
+func (s *SaleScheduler) revert(sale model.SailProductSale) {
+	var afterId, reverted int64
+	for {
+		ids, err := s.svcCtx.SaleModel.FindAppliedProducts(sale.Id, afterId, s.svcCtx.Config.Sale.BatchSize)
+		if err != nil {
+			logx.Error("查询促销活动产品失败：", err)
+			return
+		}
+		for _, id := range ids {
+			count, err := s.svcCtx.SaleModel.RevertProduct(sale, id)
+			if err != nil {
+				logx.Errorf("促销活动恢复原价失败 sale_id:%d product_id:%d err:%s", sale.Id, id, err)
+				return
+			}
+			reverted += count
+			afterId = id
+		}
+		if int64(len(ids)) < s.svcCtx.Config.Sale.BatchSize {
+			break
+		}
+		if ok, err := s.lock.Acquire(); !ok || err != nil || s.stopped() {
+			return
+		}
+	}
+	if _, err := s.svcCtx.SaleModel.SetStatus(sale.Id, sale.Status, model.SALE_STATUS_ENDED); err != nil {
+		logx.Error("更新促销活动状态失败：", err)
+		return
+	}
+	logx.Infof("促销活动已结束 sale_id:%d 恢复原价:%d", sale.Id, reverted)
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+)
+
+const (
+	sitemapWorkerLockKey = "product:sitemap:worker:lock"
+	sitemapCursorKey     = "product:sitemap:cursor"
+)
+
+// SitemapWorker 从发件箱读取产品变更事件，把产品所在的sitemap子文件标记为待重新生成，
+// 下次调用 SitemapGenerate 时只重新生成这些文件。读取位置保存在redis中
+type SitemapWorker struct {
+	svcCtx *svc.ServiceContext
+	lock   *redis.RedisLock
+	done   chan struct{}
+	exited chan struct{}
+}
+
+func NewSitemapWorker(svcCtx *svc.ServiceContext) *SitemapWorker {
+	lock := redis.NewRedisLock(svcCtx.RedisClientSaas, sitemapWorkerLockKey)
+	lock.SetExpire(svcCtx.Config.Sitemap.LockExpire)
+	return &SitemapWorker{
+		svcCtx: svcCtx,
+		lock:   lock,
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+func (w *SitemapWorker) Start() {
+	defer close(w.exited)
+	ticker := time.NewTicker(time.Duration(w.svcCtx.Config.Sitemap.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			if _, err := w.lock.Release(); err != nil {
+				logx.Error("释放sitemap标记锁失败：", err)
+			}
+			return
+		case <-ticker.C:
+			if ok, err := w.lock.Acquire(); !ok || err != nil {
+				continue
+			}
+			w.mark()
+		}
+	}
+}
+
+func (w *SitemapWorker) Stop() {
+	close(w.done)
+	<-w.exited
+}
+
+func (w *SitemapWorker) mark() {
+	head := w.svcCtx.WatchHub.Head()
+	if head == 0 {
+		return
+	}
+	cursorStr, err := w.svcCtx.RedisClientSaas.Get(sitemapCursorKey)
+	if err != nil {
+		logx.Error("读取sitemap标记位置失败：", err)
+		return
+	}
+	if cursorStr == "" {
+		// 还没有生成过的店铺第一次调用时会全量生成，不需要补标历史事件
+		if err = w.svcCtx.RedisClientSaas.Set(sitemapCursorKey, strconv.FormatInt(head, 10)); err != nil {
+			logx.Error("保存sitemap标记位置失败：", err)
+		}
+		return
+	}
+	cursor, err := strconv.ParseInt(cursorStr, 10, 64)
+	if err != nil || cursor >= head {
+		return
+	}
+
+	events, err := w.svcCtx.OutboxModel.FindAfter(cursor, head, 0, w.svcCtx.Config.Sitemap.BatchSize)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		return
+	default:
+		logx.Error("查询产品变更事件失败：", err)
+		return
+	}
+	for _, event := range *events {
+		// 库存变化不影响sitemap内容
+		if event.Event != model.ES_SYNC_EVENT_INVENTORY {
+			if err := w.svcCtx.SitemapModel.MarkDirty(event.ShopId, event.ProductId, model.SITEMAP_KIND_PRODUCT); err != nil {
+				logx.Errorf("标记sitemap文件失败 event_id:%d err:%s", event.Id, err)
+				return
+			}
+		}
+		if err := w.svcCtx.RedisClientSaas.Set(sitemapCursorKey, strconv.FormatInt(event.Seq, 10)); err != nil {
+			logx.Error("保存sitemap标记位置失败：", err)
+			return
+		}
+	}
+}
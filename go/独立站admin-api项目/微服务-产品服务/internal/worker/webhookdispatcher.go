@@ -0,0 +1,385 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	"github.com/tal-tech/go-zero/core/stores/redis"
+	"github.com/tal-tech/go-zero/core/stores/sqlc"
+	"github.com/tal-tech/go-zero/core/threading"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fetcher"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model/mongo/webhook"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+)
+
+const (
+	webhookDispatcherLockKey = "product:webhook:dispatcher:lock"
+	webhookCursorKey         = "product:webhook:cursor"
+	webhookResponseLimit     = 1024
+)
+
+var webhookTopics = map[string]string{
+	model.ES_SYNC_EVENT_ADD:    webhook.TOPIC_PRODUCT_CREATE,
+	model.ES_SYNC_EVENT_UPDATE: webhook.TOPIC_PRODUCT_UPDATE,
+	model.ES_SYNC_EVENT_DELETE: webhook.TOPIC_PRODUCT_DELETE,
+}
+
+type (
+	// WebhookDispatcher 从发件箱读取产品变更事件，为订阅了对应topic的webhook生成发送记录，
+	// 再按记录逐条发送；发送失败按指数退避重试，连续失败过多的webhook会被自动停用。
+	// 读取位置保存在redis中，同一时间只有拿到锁的实例在工作；锁过期后可能有两个实例同时发送，
+	// 所以每条记录发送前还要先领取
+	WebhookDispatcher struct {
+		svcCtx *svc.ServiceContext
+		client *http.Client
+		owner  string
+		lock   *redis.RedisLock
+		done   chan struct{}
+		exited chan struct{}
+	}
+
+	webhookPayload struct {
+		XMLName        xml.Name `json:"-" xml:"product"`
+		Id             int64    `json:"id" xml:"id"`
+		ShopId         int64    `json:"shop_id" xml:"shop-id"`
+		Title          string   `json:"title,omitempty" xml:"title,omitempty"`
+		Handle         string   `json:"handle,omitempty" xml:"handle,omitempty"`
+		Status         int64    `json:"status,omitempty" xml:"status,omitempty"`
+		Price          float64  `json:"price,omitempty" xml:"price,omitempty"`
+		CompareAtPrice float64  `json:"compare_at_price,omitempty" xml:"compare-at-price,omitempty"`
+		Version        int64    `json:"version" xml:"version"`
+		CreatedAt      string   `json:"created_at,omitempty" xml:"created-at,omitempty"`
+		UpdatedAt      string   `json:"updated_at,omitempty" xml:"updated-at,omitempty"`
+	}
+)
+
+func NewWebhookDispatcher(svcCtx *svc.ServiceContext) *WebhookDispatcher {
+	conf := svcCtx.Config.Webhook
+	lock := redis.NewRedisLock(svcCtx.RedisClientSaas, webhookDispatcherLockKey)
+	lock.SetExpire(conf.LockExpire)
+	fetchConf := svcCtx.Config.ImageFetch
+	hostname, _ := os.Hostname()
+	return &WebhookDispatcher{
+		svcCtx: svcCtx,
+		// webhook地址由商户填写，同样需要拦截内网地址；不跟随重定向
+		client: fetcher.NewClient(config.ImageFetch{
+			Timeout:      conf.Timeout,
+			DialTimeout:  fetchConf.DialTimeout,
+			MaxRedirects: 0,
+			AllowPrivate: fetchConf.AllowPrivate,
+		}),
+		owner:  fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		lock:   lock,
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+func (d *WebhookDispatcher) Start() {
+	defer close(d.exited)
+	ticker := time.NewTicker(time.Duration(d.svcCtx.Config.Webhook.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			if _, err := d.lock.Release(); err != nil {
+				logx.Error("释放webhook分发锁失败：", err)
+			}
+			return
+		case <-ticker.C:
+			if ok, err := d.lock.Acquire(); !ok || err != nil {
+				continue
+			}
+			d.enqueue()
+			d.deliver()
+		}
+	}
+}
+
+func (d *WebhookDispatcher) Stop() {
+	close(d.done)
+	<-d.exited
+}
+
+// enqueue 把新的产品变更事件展开成发送记录，每处理完一条事件就推进读取位置，
+// 中途退出时最多重复生成最后一条事件的发送记录
+func (d *WebhookDispatcher) enqueue() {
+	head := d.svcCtx.WatchHub.Head()
+	if head == 0 {
+		return
+	}
+	cursorStr, err := d.svcCtx.RedisClientSaas.Get(webhookCursorKey)
+	if err != nil {
+		logx.Error("读取webhook分发位置失败：", err)
+		return
+	}
+	if cursorStr == "" {
+		// 第一次启动时从当前位置开始，不补发历史事件
+		if err = d.svcCtx.RedisClientSaas.Set(webhookCursorKey, strconv.FormatInt(head, 10)); err != nil {
+			logx.Error("保存webhook分发位置失败：", err)
+		}
+		return
+	}
+	cursor, err := strconv.ParseInt(cursorStr, 10, 64)
+	if err != nil || cursor >= head {
+		return
+	}
+
+	events, err := d.svcCtx.OutboxModel.FindAfter(cursor, head, 0, d.svcCtx.Config.Webhook.BatchSize)
+	switch err {
+	case nil:
+	case sqlc.ErrNotFound:
+		return
+	default:
+		logx.Error("查询产品变更事件失败：", err)
+		return
+	}
+	for _, event := range *events {
+		if err := d.expand(event); err != nil {
+			logx.Errorf("生成webhook发送记录失败 event_id:%d err:%s", event.Id, err)
+			return
+		}
+		if err := d.svcCtx.RedisClientSaas.Set(webhookCursorKey, strconv.FormatInt(event.Seq, 10)); err != nil {
+			logx.Error("保存webhook分发位置失败：", err)
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) expand(event model.SailProductOutbox) error {
+	topic, ok := webhookTopics[event.Event]
+	if !ok {
+		return nil
+	}
+	hooks, err := d.svcCtx.WebhookModel.FindEnabled(context.Background(), event.ShopId, topic)
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload := webhookPayload{
+		Id:      event.ProductId,
+		ShopId:  event.ShopId,
+		Version: event.Version,
+	}
+	if event.Event != model.ES_SYNC_EVENT_DELETE {
+		productInfo, err := d.svcCtx.WriteModel.FindOneById(event.ProductId)
+		switch err {
+		case nil:
+			payload.Title = productInfo.Title
+			payload.Handle = productInfo.Handler
+			payload.Status = productInfo.Status
+			payload.Price = productInfo.Price
+			payload.CompareAtPrice = productInfo.CompareAtPrice
+			payload.CreatedAt = productInfo.CreatedAt.Local().Format(time.RFC3339)
+			payload.UpdatedAt = productInfo.UpdatedAt.Local().Format(time.RFC3339)
+		case sqlc.ErrNotFound:
+		default:
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, hook := range hooks {
+		body, err := encodeWebhookPayload(hook.Format, payload)
+		if err != nil {
+			return err
+		}
+		delivery := &webhook.Delivery{
+			WebhookId: hook.ID,
+			ShopId:    event.ShopId,
+			Topic:     topic,
+			ProductId: event.ProductId,
+			EventId:   event.Id,
+			Address:   hook.Address,
+			Format:    hook.Format,
+			Payload:   string(body),
+			Status:    webhook.DELIVERY_STATUS_PENDING,
+			Attempts:  []webhook.Attempt{},
+			NextRunAt: now,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := d.svcCtx.WebhookDeliveryModel.Insert(context.Background(), delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) deliver() {
+	conf := d.svcCtx.Config.Webhook
+	deliveries, err := d.svcCtx.WebhookDeliveryModel.FindDue(context.Background(), int(conf.BatchSize))
+	if err != nil {
+		logx.Error("查询待发送webhook失败：", err)
+		return
+	}
+	sem := make(chan struct{}, conf.Concurrency)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		sem <- struct{}{}
+		wg.Add(1)
+		delivery := delivery
+		threading.GoSafe(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			d.send(delivery)
+		})
+	}
+	wg.Wait()
+}
+
+func (d *WebhookDispatcher) send(delivery *webhook.Delivery) {
+	ctx := context.Background()
+	leaseEnd := time.Now().Add(time.Duration(d.svcCtx.Config.Webhook.Lease) * time.Millisecond)
+	switch err := d.svcCtx.WebhookDeliveryModel.Claim(ctx, delivery.ID, d.owner, leaseEnd); err {
+	case nil:
+	case webhook.ErrNotFound:
+		// 已被其他实例领取
+		return
+	default:
+		logx.Error("领取webhook发送记录失败：", err)
+		return
+	}
+	hook, err := d.svcCtx.WebhookModel.FindOne(ctx, delivery.ShopId, delivery.WebhookId.Hex())
+	switch err {
+	case nil:
+	case webhook.ErrNotFound:
+		d.abandon(delivery, "webhook已删除")
+		return
+	default:
+		logx.Error("查询webhook失败：", err)
+		return
+	}
+	if hook.Status != webhook.STATUS_ENABLED {
+		d.abandon(delivery, "webhook已停用")
+		return
+	}
+
+	attempt := d.post(hook, delivery)
+	if attempt.StatusCode >= 200 && attempt.StatusCode < 300 {
+		if err := d.svcCtx.WebhookDeliveryModel.RecordAttempt(ctx, delivery.ID, d.owner, attempt, webhook.DELIVERY_STATUS_SUCCEEDED, delivery.NextRunAt); err != nil {
+			logx.Error("更新webhook发送记录失败：", err)
+		}
+		if hook.FailureCount != 0 {
+			if err := d.svcCtx.WebhookModel.RecordSuccess(ctx, hook.ID); err != nil {
+				logx.Error("更新webhook状态失败：", err)
+			}
+		}
+		return
+	}
+
+	logx.Errorf("webhook发送失败 webhook_id:%s delivery_id:%s status:%d err:%s", hook.ID.Hex(), delivery.ID.Hex(), attempt.StatusCode, attempt.Error)
+	status := webhook.DELIVERY_STATUS_PENDING
+	attempts := len(delivery.Attempts) + 1
+	if attempts >= d.svcCtx.Config.Webhook.MaxAttempts {
+		status = webhook.DELIVERY_STATUS_FAILED
+	}
+	if err := d.svcCtx.WebhookDeliveryModel.RecordAttempt(ctx, delivery.ID, d.owner, attempt, status, time.Now().Add(d.backoff(attempts))); err != nil {
+		logx.Error("更新webhook发送记录失败：", err)
+	}
+	updated, err := d.svcCtx.WebhookModel.RecordFailure(ctx, hook.ID)
+	if err != nil {
+		logx.Error("更新webhook状态失败：", err)
+		return
+	}
+	if updated.FailureCount >= d.svcCtx.Config.Webhook.DisableAfter {
+		reason := fmt.Sprintf("连续失败%d次，已自动停用", updated.FailureCount)
+		if err := d.svcCtx.WebhookModel.Disable(ctx, hook.ID, reason); err != nil {
+			logx.Error("停用webhook失败：", err)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) abandon(delivery *webhook.Delivery, reason string) {
+	attempt := webhook.Attempt{Error: reason, CreatedAt: time.Now()}
+	err := d.svcCtx.WebhookDeliveryModel.RecordAttempt(context.Background(), delivery.ID, d.owner, attempt, webhook.DELIVERY_STATUS_FAILED, delivery.NextRunAt)
+	if err != nil {
+		logx.Error("更新webhook发送记录失败：", err)
+	}
+}
+
+func (d *WebhookDispatcher) post(hook *webhook.Webhook, delivery *webhook.Delivery) webhook.Attempt {
+	start := time.Now()
+	attempt := webhook.Attempt{CreatedAt: start}
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.Address, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	contentType := "application/json"
+	if delivery.Format == webhook.FORMAT_XML {
+		contentType = "application/xml"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Webhook-Topic", delivery.Topic)
+	req.Header.Set("X-Webhook-Shop-Id", strconv.FormatInt(delivery.ShopId, 10))
+	req.Header.Set("X-Webhook-Id", delivery.ID.Hex())
+	req.Header.Set("X-Webhook-Event-Id", strconv.FormatInt(delivery.EventId, 10))
+	req.Header.Set("X-Webhook-Hmac-Sha256", SignWebhook(hook.Secret, body))
+
+	resp, err := d.client.Do(req)
+	attempt.Duration = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	attempt.StatusCode = resp.StatusCode
+	attempt.Response = string(respBody)
+	return attempt
+}
+
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	conf := d.svcCtx.Config.Webhook
+	wait := conf.BaseBackoff
+	for i := 1; i < attempts && wait < conf.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > conf.MaxBackoff {
+		wait = conf.MaxBackoff
+	}
+	wait += rand.Int63n(wait/5 + 1)
+	return time.Duration(wait) * time.Millisecond
+}
+
+// SignWebhook 用webhook的密钥对请求体做HMAC-SHA256签名，接收方用同一密钥校验 X-Webhook-Hmac-Sha256
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func encodeWebhookPayload(format string, payload webhookPayload) ([]byte, error) {
+	if format == webhook.FORMAT_XML {
+		body, err := xml.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), body...), nil
+	}
+	return json.Marshal(payload)
+}
@@ -0,0 +1,423 @@
+package worker
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/fetcher"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/model/mongo/webhook"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+)
+
+const testWebhookSecret = "test-secret"
+
+// memoryWebhookModel 内存中的webhook，只实现分发用到的方法
+type memoryWebhookModel struct {
+	webhook.WebhookModel
+	mu   sync.Mutex
+	hook webhook.Webhook
+}
+
+func (m *memoryWebhookModel) FindOne(ctx context.Context, shopId int64, id string) (*webhook.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hook.ShopId != shopId || m.hook.ID.Hex() != id {
+		return nil, webhook.ErrNotFound
+	}
+	hook := m.hook
+	return &hook, nil
+}
+
+func (m *memoryWebhookModel) RecordSuccess(ctx context.Context, id bson.ObjectId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hook.FailureCount = 0
+	return nil
+}
+
+func (m *memoryWebhookModel) RecordFailure(ctx context.Context, id bson.ObjectId) (*webhook.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hook.FailureCount++
+	hook := m.hook
+	return &hook, nil
+}
+
+func (m *memoryWebhookModel) Disable(ctx context.Context, id bson.ObjectId, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hook.Status = webhook.STATUS_DISABLED
+	m.hook.DisabledReason = reason
+	return nil
+}
+
+// memoryDeliveryModel 内存中的发送记录，领取和到期条件和mongo实现一致
+type memoryDeliveryModel struct {
+	webhook.DeliveryModel
+	mu         sync.Mutex
+	deliveries []*webhook.Delivery
+}
+
+func (m *memoryDeliveryModel) FindDue(ctx context.Context, limit int) ([]*webhook.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	resp := make([]*webhook.Delivery, 0)
+	for _, delivery := range m.deliveries {
+		if len(resp) < limit && isDue(delivery, now) {
+			copied := *delivery
+			copied.Attempts = append([]webhook.Attempt{}, delivery.Attempts...)
+			resp = append(resp, &copied)
+		}
+	}
+	return resp, nil
+}
+
+func (m *memoryDeliveryModel) Claim(ctx context.Context, id bson.ObjectId, owner string, leaseEnd time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.find(id)
+	if delivery == nil || !isDue(delivery, time.Now()) {
+		return webhook.ErrNotFound
+	}
+	delivery.Status = webhook.DELIVERY_STATUS_SENDING
+	delivery.Owner = owner
+	delivery.LeaseEnd = leaseEnd
+	return nil
+}
+
+func (m *memoryDeliveryModel) RecordAttempt(ctx context.Context, id bson.ObjectId, owner string, attempt webhook.Attempt, status string, nextRunAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.find(id)
+	if delivery == nil || delivery.Owner != owner {
+		return webhook.ErrNotFound
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.Status = status
+	delivery.NextRunAt = nextRunAt
+	delivery.Owner = ""
+	return nil
+}
+
+func (m *memoryDeliveryModel) find(id bson.ObjectId) *webhook.Delivery {
+	for _, delivery := range m.deliveries {
+		if delivery.ID == id {
+			return delivery
+		}
+	}
+	return nil
+}
+
+// get 返回记录的副本，rewind 为 true 时把下次发送时间提前到现在，跳过退避等待
+func (m *memoryDeliveryModel) get(id bson.ObjectId, rewind bool) webhook.Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.find(id)
+	if rewind {
+		delivery.NextRunAt = time.Now()
+	}
+	return *delivery
+}
+
+func isDue(delivery *webhook.Delivery, now time.Time) bool {
+	switch delivery.Status {
+	case webhook.DELIVERY_STATUS_PENDING:
+		return !delivery.NextRunAt.After(now)
+	case webhook.DELIVERY_STATUS_SENDING:
+		return !delivery.LeaseEnd.After(now)
+	}
+	return false
+}
+
+func newTestDispatcher(address string, conf config.Webhook) (*WebhookDispatcher, *memoryWebhookModel, *memoryDeliveryModel) {
+	hooks := &memoryWebhookModel{hook: webhook.Webhook{
+		ID:      bson.NewObjectId(),
+		ShopId:  1,
+		Topic:   webhook.TOPIC_PRODUCT_UPDATE,
+		Address: address,
+		Format:  webhook.FORMAT_JSON,
+		Secret:  testWebhookSecret,
+		Status:  webhook.STATUS_ENABLED,
+	}}
+	now := time.Now()
+	deliveries := &memoryDeliveryModel{deliveries: []*webhook.Delivery{{
+		ID:        bson.NewObjectId(),
+		WebhookId: hooks.hook.ID,
+		ShopId:    1,
+		Topic:     webhook.TOPIC_PRODUCT_UPDATE,
+		ProductId: 100,
+		EventId:   1,
+		Address:   address,
+		Format:    webhook.FORMAT_JSON,
+		Payload:   `{"id":100,"shop_id":1,"version":2}`,
+		Status:    webhook.DELIVERY_STATUS_PENDING,
+		Attempts:  []webhook.Attempt{},
+		NextRunAt: now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}}
+	return newDispatcherWith(conf, hooks, deliveries, "test-owner"), hooks, deliveries
+}
+
+func newDispatcherWith(conf config.Webhook, hooks webhook.WebhookModel, deliveries webhook.DeliveryModel, owner string) *WebhookDispatcher {
+	svcCtx := &svc.ServiceContext{
+		WebhookModel:         hooks,
+		WebhookDeliveryModel: deliveries,
+	}
+	svcCtx.Config.Webhook = conf
+	return &WebhookDispatcher{
+		svcCtx: svcCtx,
+		client: fetcher.NewClient(config.ImageFetch{
+			Timeout:      conf.Timeout,
+			DialTimeout:  conf.Timeout,
+			AllowPrivate: true,
+		}),
+		owner: owner,
+	}
+}
+
+func testWebhookConf() config.Webhook {
+	return config.Webhook{
+		BatchSize:    10,
+		Concurrency:  2,
+		Timeout:      2000,
+		MaxAttempts:  10,
+		BaseBackoff:  1000,
+		MaxBackoff:   4000,
+		DisableAfter: 3,
+		Lease:        60000,
+	}
+}
+
+func TestWebhookDispatcherSignature(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		if got, want := r.Header.Get("X-Webhook-Hmac-Sha256"), SignWebhook(testWebhookSecret, body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if got := r.Header.Get("X-Webhook-Topic"); got != webhook.TOPIC_PRODUCT_UPDATE {
+			t.Errorf("topic = %q", got)
+		}
+		if string(body) != `{"id":100,"shop_id":1,"version":2}` {
+			t.Errorf("body = %s", body)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	d, _, deliveries := newTestDispatcher(server.URL, testWebhookConf())
+	d.deliver()
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+	delivery := deliveries.get(deliveries.deliveries[0].ID, false)
+	if delivery.Status != webhook.DELIVERY_STATUS_SUCCEEDED {
+		t.Fatalf("status = %s, want %s", delivery.Status, webhook.DELIVERY_STATUS_SUCCEEDED)
+	}
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("attempts = %+v", delivery.Attempts)
+	}
+}
+
+func TestWebhookDispatcherRetryBackoff(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	conf := testWebhookConf()
+	conf.DisableAfter = 100
+	d, _, deliveries := newTestDispatcher(server.URL, conf)
+	id := deliveries.deliveries[0].ID
+
+	// 每次失败后等待时间翻倍，最长不超过 MaxBackoff，另外最多加 20% 的随机抖动
+	waits := []int64{1000, 2000, 4000, 4000}
+	for i, wait := range waits {
+		start := time.Now()
+		d.deliver()
+		delivery := deliveries.get(id, false)
+		if delivery.Status != webhook.DELIVERY_STATUS_PENDING {
+			t.Fatalf("attempt %d: status = %s, want %s", i+1, delivery.Status, webhook.DELIVERY_STATUS_PENDING)
+		}
+		if len(delivery.Attempts) != i+1 {
+			t.Fatalf("attempt %d: attempts = %d", i+1, len(delivery.Attempts))
+		}
+		min := start.Add(time.Duration(wait) * time.Millisecond)
+		max := time.Now().Add(time.Duration(wait+wait/5+1) * time.Millisecond)
+		if delivery.NextRunAt.Before(min) || delivery.NextRunAt.After(max) {
+			t.Fatalf("attempt %d: next_run_at = %s, want between %s and %s", i+1, delivery.NextRunAt, min, max)
+		}
+
+		// 退避时间未到时不会重发
+		d.deliver()
+		if n := atomic.LoadInt32(&requests); n != int32(i+1) {
+			t.Fatalf("attempt %d: requests = %d, want %d", i+1, n, i+1)
+		}
+		deliveries.get(id, true)
+	}
+}
+
+func TestWebhookDispatcherGiveUpAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	conf := testWebhookConf()
+	conf.MaxAttempts = 2
+	conf.DisableAfter = 100
+	d, _, deliveries := newTestDispatcher(server.URL, conf)
+	id := deliveries.deliveries[0].ID
+
+	d.deliver()
+	deliveries.get(id, true)
+	d.deliver()
+	delivery := deliveries.get(id, false)
+	if delivery.Status != webhook.DELIVERY_STATUS_FAILED {
+		t.Fatalf("status = %s, want %s", delivery.Status, webhook.DELIVERY_STATUS_FAILED)
+	}
+}
+
+func TestWebhookDispatcherAutoDisable(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	conf := testWebhookConf()
+	d, hooks, deliveries := newTestDispatcher(server.URL, conf)
+	id := deliveries.deliveries[0].ID
+
+	for i := int64(1); i <= conf.DisableAfter; i++ {
+		d.deliver()
+		deliveries.get(id, true)
+		hook, _ := hooks.FindOne(context.Background(), 1, hooks.hook.ID.Hex())
+		if hook.FailureCount != i {
+			t.Fatalf("failure_count = %d, want %d", hook.FailureCount, i)
+		}
+		wantStatus := webhook.STATUS_ENABLED
+		if i == conf.DisableAfter {
+			wantStatus = webhook.STATUS_DISABLED
+		}
+		if hook.Status != wantStatus {
+			t.Fatalf("after %d failures: status = %s, want %s", i, hook.Status, wantStatus)
+		}
+	}
+
+	// 停用后的webhook不再发送，发送记录直接标记失败
+	d.deliver()
+	if n := atomic.LoadInt32(&requests); n != int32(conf.DisableAfter) {
+		t.Fatalf("requests = %d, want %d", n, conf.DisableAfter)
+	}
+	delivery := deliveries.get(id, false)
+	if delivery.Status != webhook.DELIVERY_STATUS_FAILED {
+		t.Fatalf("status = %s, want %s", delivery.Status, webhook.DELIVERY_STATUS_FAILED)
+	}
+}
+
+func TestWebhookDispatcherSuccessResetsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d, hooks, _ := newTestDispatcher(server.URL, testWebhookConf())
+	hooks.hook.FailureCount = 2
+	d.deliver()
+	if hooks.hook.FailureCount != 0 {
+		t.Fatalf("failure_count = %d, want 0", hooks.hook.FailureCount)
+	}
+}
+
+func TestWebhookDispatcherClaimOnce(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// 两个实例读到同一条到期记录，只有领取成功的实例会发送
+	conf := testWebhookConf()
+	first, hooks, deliveries := newTestDispatcher(server.URL, conf)
+	second := newDispatcherWith(conf, hooks, deliveries, "other-owner")
+	id := deliveries.deliveries[0].ID
+
+	var wg sync.WaitGroup
+	for _, d := range []*WebhookDispatcher{first, second} {
+		wg.Add(1)
+		d := d
+		go func() {
+			defer wg.Done()
+			d.deliver()
+		}()
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&requests) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 发送中的记录不会被另一个实例再次取出
+	second.deliver()
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+	delivery := deliveries.get(id, false)
+	if delivery.Status != webhook.DELIVERY_STATUS_SUCCEEDED || len(delivery.Attempts) != 1 {
+		t.Fatalf("status = %s attempts = %d", delivery.Status, len(delivery.Attempts))
+	}
+}
+
+func TestWebhookDispatcherReclaimExpiredLease(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// 领取后实例退出，租约到期前不会重发，到期后由其他实例接手
+	d, _, deliveries := newTestDispatcher(server.URL, testWebhookConf())
+	id := deliveries.deliveries[0].ID
+	if err := deliveries.Claim(context.Background(), id, "crashed-owner", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	d.deliver()
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Fatalf("requests = %d, want 0", n)
+	}
+
+	deliveries.mu.Lock()
+	deliveries.deliveries[0].LeaseEnd = time.Now()
+	deliveries.mu.Unlock()
+	d.deliver()
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+	if delivery := deliveries.get(id, false); delivery.Status != webhook.DELIVERY_STATUS_SUCCEEDED {
+		t.Fatalf("status = %s, want %s", delivery.Status, webhook.DELIVERY_STATUS_SUCCEEDED)
+	}
+}
@@ -8,11 +8,15 @@ import (
 	"os"
 
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/config"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/errorx"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/idempotency"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/server"
 	"gitlab.jhongnet.com/mall/rpc-product-server/internal/svc"
+	"gitlab.jhongnet.com/mall/rpc-product-server/internal/worker"
 	"gitlab.jhongnet.com/mall/rpc-product-server/product"
 
 	"github.com/tal-tech/go-zero/core/conf"
+	"github.com/tal-tech/go-zero/core/service"
 	"github.com/tal-tech/go-zero/zrpc"
 	"google.golang.org/grpc"
 
@@ -20,6 +24,8 @@ import (
 )
 
 var configFile = flag.String("f", "", "the config file")
+var rebuildRatings = flag.Bool("rebuild-ratings", false, "rebuild product rating aggregates and exit")
+var rebuildShopId = flag.Int64("shop", 0, "only rebuild this shop when used with -rebuild-ratings, 0 for all shops")
 
 func main() {
 	flag.Parse()
@@ -52,6 +58,13 @@ func main() {
 	var c config.Config
 	conf.MustLoad(*configFile, &c)
 	ctx := svc.NewServiceContext(c)
+	if *rebuildRatings {
+		if err := worker.RebuildRatings(ctx, *rebuildShopId); err != nil {
+			fmt.Println("rebuild ratings failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
 	srv := server.NewProductRPCServer(ctx)
 
 	s := zrpc.MustNewServer(c.RpcServerConf, func(grpcServer *grpc.Server) {
@@ -60,8 +73,21 @@ func main() {
 			reflection.Register(grpcServer)
 		}
 	})
-	defer s.Stop()
+	// 错误码转换在最外层，幂等拦截器和逻辑层返回的错误都经过转换
+	s.AddUnaryInterceptors(errorx.UnaryServerInterceptor(), idempotency.UnaryServerInterceptor(ctx.RedisClientSaas, c.Idempotency))
+	s.AddStreamInterceptors(errorx.StreamServerInterceptor())
+
+	group := service.NewServiceGroup()
+	defer group.Stop()
+	group.Add(s)
+	group.Add(worker.NewImageIngestWorker(ctx))
+	group.Add(worker.NewOutboxRelay(ctx))
+	group.Add(ctx.WatchHub)
+	group.Add(worker.NewWebhookDispatcher(ctx))
+	group.Add(worker.NewSitemapWorker(ctx))
+	group.Add(worker.NewProductCacheInvalidator(ctx))
+	group.Add(worker.NewSaleScheduler(ctx))
 
 	fmt.Printf("Starting rpc server at %s...\n", c.ListenOn)
-	s.Start()
+	group.Start()
 }
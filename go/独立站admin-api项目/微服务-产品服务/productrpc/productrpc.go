@@ -14,74 +14,182 @@ import (
 )
 
 type (
-	ProductVariantDeleteResponse  = product.ProductVariantDeleteResponse
-	ProductVariant                = product.ProductVariant
-	CategoryListRequest           = product.CategoryListRequest
-	ProductVariantAddResponse     = product.ProductVariantAddResponse
-	ProductVariantUpdateRequest   = product.ProductVariantUpdateRequest
-	CategoryProduct               = product.CategoryProduct
-	CategoryProductDetailResponse = product.CategoryProductDetailResponse
-	CategoryProductCountRequest   = product.CategoryProductCountRequest
-	CategoryProductCountResponse  = product.CategoryProductCountResponse
-	ProductDetailResponse         = product.ProductDetailResponse
-	CategoryProductListRequest    = product.CategoryProductListRequest
-	ProductDeleteRequest          = product.ProductDeleteRequest
-	ProductImageUpdate            = product.ProductImageUpdate
-	ProductVariantListResponse    = product.ProductVariantListResponse
-	SpecItem                      = product.SpecItem
-	ProductVariantAdd             = product.ProductVariantAdd
-	ProductVariantCountResponse   = product.ProductVariantCountResponse
-	ProductVariantDeleteRequest   = product.ProductVariantDeleteRequest
-	ProductImageCountResponse     = product.ProductImageCountResponse
-	CategoryProductDeleteRequest  = product.CategoryProductDeleteRequest
-	PingRequest                   = product.PingRequest
-	ProductCommentScoreRequest    = product.ProductCommentScoreRequest
-	ProductAddResponse            = product.ProductAddResponse
-	ProductCountRequest           = product.ProductCountRequest
-	ProductVariantUpdateResponse  = product.ProductVariantUpdateResponse
-	CategoryAddResponse           = product.CategoryAddResponse
-	VariantAdd                    = product.VariantAdd
-	CategoryListResponse          = product.CategoryListResponse
-	CategoryDetailRequest         = product.CategoryDetailRequest
-	CategoryCountResponse         = product.CategoryCountResponse
-	ProductCommentSource          = product.ProductCommentSource
-	ProductListResponse           = product.ProductListResponse
-	ProductImageAddRequest        = product.ProductImageAddRequest
-	ProductImageAddResponse       = product.ProductImageAddResponse
-	ProductImageListRequest       = product.ProductImageListRequest
-	ProductImageListResponse      = product.ProductImageListResponse
-	CategoryProductListResponse   = product.CategoryProductListResponse
-	CategoryProductAddRequest     = product.CategoryProductAddRequest
-	ProductCommentScoreResponse   = product.ProductCommentScoreResponse
-	ProductComment                = product.ProductComment
-	ProductDetailRequest          = product.ProductDetailRequest
-	ProductImageDetailRequest     = product.ProductImageDetailRequest
-	ProductImageDetailResponse    = product.ProductImageDetailResponse
-	ProductImage                  = product.ProductImage
-	ProductDeleteResponse         = product.ProductDeleteResponse
-	ProductUpdateRequest          = product.ProductUpdateRequest
-	ProductVariantUpdate1         = product.ProductVariantUpdate1
-	ProductCountResponse          = product.ProductCountResponse
-	ProductVariantUpdate          = product.ProductVariantUpdate
-	Product                       = product.Product
-	ProductUpdateResponse         = product.ProductUpdateResponse
-	ProductVariantDetailResponse  = product.ProductVariantDetailResponse
-	Category                      = product.Category
-	ProductImageAdd               = product.ProductImageAdd
-	PingResponse                  = product.PingResponse
-	ProductListRequest            = product.ProductListRequest
-	ProductAddRequest             = product.ProductAddRequest
-	ProductVariantListRequest     = product.ProductVariantListRequest
-	ProductVariantCountRequest    = product.ProductVariantCountRequest
-	CategoryProductAddResponse    = product.CategoryProductAddResponse
-	ProductImageCountRequest      = product.ProductImageCountRequest
-	CategoryAddRequest            = product.CategoryAddRequest
-	CategoryDetailResponse        = product.CategoryDetailResponse
-	CategoryCountRequest          = product.CategoryCountRequest
-	CategoryProductDetailRequest  = product.CategoryProductDetailRequest
-	ProductVariantAddRequest      = product.ProductVariantAddRequest
-	ProductVariantDetailRequest   = product.ProductVariantDetailRequest
-	CategoryProductDeleteResponse = product.CategoryProductDeleteResponse
+	ProductVariantDeleteResponse     = product.ProductVariantDeleteResponse
+	ProductVariant                   = product.ProductVariant
+	CategoryListRequest              = product.CategoryListRequest
+	ProductVariantAddResponse        = product.ProductVariantAddResponse
+	ProductVariantUpdateRequest      = product.ProductVariantUpdateRequest
+	CategoryProduct                  = product.CategoryProduct
+	CategoryProductDetailResponse    = product.CategoryProductDetailResponse
+	CategoryProductCountRequest      = product.CategoryProductCountRequest
+	CategoryProductCountResponse     = product.CategoryProductCountResponse
+	ProductDetailResponse            = product.ProductDetailResponse
+	CategoryProductListRequest       = product.CategoryProductListRequest
+	ProductDeleteRequest             = product.ProductDeleteRequest
+	ProductImageUpdate               = product.ProductImageUpdate
+	ProductVariantListResponse       = product.ProductVariantListResponse
+	SpecItem                         = product.SpecItem
+	ProductVariantAdd                = product.ProductVariantAdd
+	ProductVariantCountResponse      = product.ProductVariantCountResponse
+	ProductVariantDeleteRequest      = product.ProductVariantDeleteRequest
+	ProductImageCountResponse        = product.ProductImageCountResponse
+	CategoryProductDeleteRequest     = product.CategoryProductDeleteRequest
+	PingRequest                      = product.PingRequest
+	ProductCommentScoreRequest       = product.ProductCommentScoreRequest
+	ProductAddResponse               = product.ProductAddResponse
+	ProductCountRequest              = product.ProductCountRequest
+	ProductVariantUpdateResponse     = product.ProductVariantUpdateResponse
+	CategoryAddResponse              = product.CategoryAddResponse
+	VariantAdd                       = product.VariantAdd
+	CategoryListResponse             = product.CategoryListResponse
+	CategoryDetailRequest            = product.CategoryDetailRequest
+	CategoryCountResponse            = product.CategoryCountResponse
+	ProductCommentSource             = product.ProductCommentSource
+	ProductListResponse              = product.ProductListResponse
+	ProductImageAddRequest           = product.ProductImageAddRequest
+	ProductImageAddResponse          = product.ProductImageAddResponse
+	ProductImageListRequest          = product.ProductImageListRequest
+	ProductImageListResponse         = product.ProductImageListResponse
+	CategoryProductListResponse      = product.CategoryProductListResponse
+	CategoryProductAddRequest        = product.CategoryProductAddRequest
+	ProductCommentScoreResponse      = product.ProductCommentScoreResponse
+	ProductComment                   = product.ProductComment
+	ProductDetailRequest             = product.ProductDetailRequest
+	ProductImageDetailRequest        = product.ProductImageDetailRequest
+	ProductImageDetailResponse       = product.ProductImageDetailResponse
+	ProductImage                     = product.ProductImage
+	ProductDeleteResponse            = product.ProductDeleteResponse
+	ProductUpdateRequest             = product.ProductUpdateRequest
+	ProductVariantUpdate1            = product.ProductVariantUpdate1
+	ProductCountResponse             = product.ProductCountResponse
+	ProductVariantUpdate             = product.ProductVariantUpdate
+	Product                          = product.Product
+	ProductUpdateResponse            = product.ProductUpdateResponse
+	ProductVariantDetailResponse     = product.ProductVariantDetailResponse
+	Category                         = product.Category
+	ProductImageAdd                  = product.ProductImageAdd
+	PingResponse                     = product.PingResponse
+	ProductListRequest               = product.ProductListRequest
+	ProductAddRequest                = product.ProductAddRequest
+	ProductVariantListRequest        = product.ProductVariantListRequest
+	ProductVariantCountRequest       = product.ProductVariantCountRequest
+	CategoryProductAddResponse       = product.CategoryProductAddResponse
+	ProductImageCountRequest         = product.ProductImageCountRequest
+	CategoryAddRequest               = product.CategoryAddRequest
+	CategoryDetailResponse           = product.CategoryDetailResponse
+	CategoryCountRequest             = product.CategoryCountRequest
+	CategoryProductDetailRequest     = product.CategoryProductDetailRequest
+	ProductVariantAddRequest         = product.ProductVariantAddRequest
+	ProductVariantDetailRequest      = product.ProductVariantDetailRequest
+	CategoryProductDeleteResponse    = product.CategoryProductDeleteResponse
+	ProductImageIngestStatusRequest  = product.ProductImageIngestStatusRequest
+	ProductImageIngestJob            = product.ProductImageIngestJob
+	ProductImageIngestStatusResponse = product.ProductImageIngestStatusResponse
+	WatchProductsRequest             = product.WatchProductsRequest
+	ProductChangeEvent               = product.ProductChangeEvent
+	Webhook                          = product.Webhook
+	WebhookDelivery                  = product.WebhookDelivery
+	WebhookDeliveryAttempt           = product.WebhookDeliveryAttempt
+	WebhookCreateRequest             = product.WebhookCreateRequest
+	WebhookCreateResponse            = product.WebhookCreateResponse
+	WebhookListRequest               = product.WebhookListRequest
+	WebhookListResponse              = product.WebhookListResponse
+	WebhookUpdateRequest             = product.WebhookUpdateRequest
+	WebhookUpdateResponse            = product.WebhookUpdateResponse
+	WebhookDeleteRequest             = product.WebhookDeleteRequest
+	WebhookDeleteResponse            = product.WebhookDeleteResponse
+	WebhookDeliveryListRequest       = product.WebhookDeliveryListRequest
+	WebhookDeliveryListResponse      = product.WebhookDeliveryListResponse
+	ProductRedirect                  = product.ProductRedirect
+	RedirectListRequest              = product.RedirectListRequest
+	RedirectListResponse             = product.RedirectListResponse
+	RedirectDeleteRequest            = product.RedirectDeleteRequest
+	RedirectDeleteResponse           = product.RedirectDeleteResponse
+	SitemapGenerateRequest           = product.SitemapGenerateRequest
+	SitemapChunk                     = product.SitemapChunk
+	ProductJsonLdRequest             = product.ProductJsonLdRequest
+	ProductJsonLdResponse            = product.ProductJsonLdResponse
+	PriceList                        = product.PriceList
+	PriceListItem                    = product.PriceListItem
+	PriceListCreateRequest           = product.PriceListCreateRequest
+	PriceListCreateResponse          = product.PriceListCreateResponse
+	PriceListUpdateRequest           = product.PriceListUpdateRequest
+	PriceListUpdateResponse          = product.PriceListUpdateResponse
+	PriceListListRequest             = product.PriceListListRequest
+	PriceListListResponse            = product.PriceListListResponse
+	PriceListDeleteRequest           = product.PriceListDeleteRequest
+	PriceListDeleteResponse          = product.PriceListDeleteResponse
+	PriceListItemSetRequest          = product.PriceListItemSetRequest
+	PriceListItemSetResponse         = product.PriceListItemSetResponse
+	PriceListItemListRequest         = product.PriceListItemListRequest
+	PriceListItemListResponse        = product.PriceListItemListResponse
+	Sale                             = product.Sale
+	SalePreviewItem                  = product.SalePreviewItem
+	SaleCreateRequest                = product.SaleCreateRequest
+	SaleCreateResponse               = product.SaleCreateResponse
+	SaleListRequest                  = product.SaleListRequest
+	SaleListResponse                 = product.SaleListResponse
+	SaleCancelRequest                = product.SaleCancelRequest
+	SaleCancelResponse               = product.SaleCancelResponse
+	SalePreviewRequest               = product.SalePreviewRequest
+	SalePreviewResponse              = product.SalePreviewResponse
+	ProductBulkPriceUpdateRequest    = product.ProductBulkPriceUpdateRequest
+	BulkPriceProgress                = product.BulkPriceProgress
+	BulkPriceChange                  = product.BulkPriceChange
+	Metafield                        = product.Metafield
+	MetafieldInput                   = product.MetafieldInput
+	MetafieldSetRequest              = product.MetafieldSetRequest
+	MetafieldSetResponse             = product.MetafieldSetResponse
+	MetafieldListRequest             = product.MetafieldListRequest
+	MetafieldListResponse            = product.MetafieldListResponse
+	MetafieldDeleteRequest           = product.MetafieldDeleteRequest
+	MetafieldDeleteResponse          = product.MetafieldDeleteResponse
+	Translation                      = product.Translation
+	TranslationInput                 = product.TranslationInput
+	TranslationMissingItem           = product.TranslationMissingItem
+	TranslationSetRequest            = product.TranslationSetRequest
+	TranslationSetResponse           = product.TranslationSetResponse
+	TranslationListRequest           = product.TranslationListRequest
+	TranslationListResponse          = product.TranslationListResponse
+	TranslationDeleteRequest         = product.TranslationDeleteRequest
+	TranslationDeleteResponse        = product.TranslationDeleteResponse
+	TranslationMissingRequest        = product.TranslationMissingRequest
+	TranslationMissingResponse       = product.TranslationMissingResponse
+	ReviewCreateRequest              = product.ReviewCreateRequest
+	ReviewCreateResponse             = product.ReviewCreateResponse
+	ReviewUpdateRequest              = product.ReviewUpdateRequest
+	ReviewUpdateResponse             = product.ReviewUpdateResponse
+	ReviewDeleteRequest              = product.ReviewDeleteRequest
+	ReviewDeleteResponse             = product.ReviewDeleteResponse
+	ReviewDetailRequest              = product.ReviewDetailRequest
+	ReviewDetailResponse             = product.ReviewDetailResponse
+	ReviewListRequest                = product.ReviewListRequest
+	ReviewListResponse               = product.ReviewListResponse
+	ReviewModerateRequest            = product.ReviewModerateRequest
+	ReviewModerateResponse           = product.ReviewModerateResponse
+	ReviewPinRequest                 = product.ReviewPinRequest
+	ReviewPinResponse                = product.ReviewPinResponse
+	ReviewShowRequest                = product.ReviewShowRequest
+	ReviewShowResponse               = product.ReviewShowResponse
+	ReviewReplyRequest               = product.ReviewReplyRequest
+	ReviewReplyResponse              = product.ReviewReplyResponse
+	ReviewVoteRequest                = product.ReviewVoteRequest
+	ReviewVoteResponse               = product.ReviewVoteResponse
+	ProductRating                    = product.ProductRating
+	ProductRatingListRequest         = product.ProductRatingListRequest
+	ProductRatingListResponse        = product.ProductRatingListResponse
+	ReviewImportRow                  = product.ReviewImportRow
+	ReviewImportRequest              = product.ReviewImportRequest
+	ReviewImportProgress             = product.ReviewImportProgress
+	ProductIdentifier                = product.ProductIdentifier
+	ProductBatchGetRequest           = product.ProductBatchGetRequest
+	ProductBatchGetResponse          = product.ProductBatchGetResponse
+	ProductPatch                     = product.ProductPatch
+	ProductVariantPatch              = product.ProductVariantPatch
+	ProductPatchRequest              = product.ProductPatchRequest
+	ProductPatchResponse             = product.ProductPatchResponse
+	ProductVariantPatchRequest       = product.ProductVariantPatchRequest
+	ProductVariantPatchResponse      = product.ProductVariantPatchResponse
 
 	ProductRPC interface {
 		Ping(ctx context.Context, in *PingRequest) (*PingResponse, error)
@@ -91,10 +199,12 @@ type (
 		ProductUpdate(ctx context.Context, in *ProductUpdateRequest) (*ProductUpdateResponse, error)
 		ProductCount(ctx context.Context, in *ProductCountRequest) (*ProductCountResponse, error)
 		ProductDelete(ctx context.Context, in *ProductDeleteRequest) (*ProductDeleteResponse, error)
+		WatchProducts(ctx context.Context, in *WatchProductsRequest) (product.ProductRPC_WatchProductsClient, error)
 		ProductImageList(ctx context.Context, in *ProductImageListRequest) (*ProductImageListResponse, error)
 		ProductImageDetail(ctx context.Context, in *ProductImageDetailRequest) (*ProductImageDetailResponse, error)
 		ProductImageAdd(ctx context.Context, in *ProductImageAddRequest) (*ProductImageAddResponse, error)
 		ProductImageCount(ctx context.Context, in *ProductImageCountRequest) (*ProductImageCountResponse, error)
+		ProductImageIngestStatus(ctx context.Context, in *ProductImageIngestStatusRequest) (*ProductImageIngestStatusResponse, error)
 		ProductVariantList(ctx context.Context, in *ProductVariantListRequest) (*ProductVariantListResponse, error)
 		ProductVariantDetail(ctx context.Context, in *ProductVariantDetailRequest) (*ProductVariantDetailResponse, error)
 		ProductVariantAdd(ctx context.Context, in *ProductVariantAddRequest) (*ProductVariantAddResponse, error)
@@ -111,6 +221,48 @@ type (
 		CategoryProductAdd(ctx context.Context, in *CategoryProductAddRequest) (*CategoryProductAddResponse, error)
 		CategoryProductCount(ctx context.Context, in *CategoryProductCountRequest) (*CategoryProductCountResponse, error)
 		CategoryProductDelete(ctx context.Context, in *CategoryProductDeleteRequest) (*CategoryProductDeleteResponse, error)
+		WebhookCreate(ctx context.Context, in *WebhookCreateRequest) (*WebhookCreateResponse, error)
+		WebhookList(ctx context.Context, in *WebhookListRequest) (*WebhookListResponse, error)
+		WebhookUpdate(ctx context.Context, in *WebhookUpdateRequest) (*WebhookUpdateResponse, error)
+		WebhookDelete(ctx context.Context, in *WebhookDeleteRequest) (*WebhookDeleteResponse, error)
+		WebhookDeliveryList(ctx context.Context, in *WebhookDeliveryListRequest) (*WebhookDeliveryListResponse, error)
+		RedirectList(ctx context.Context, in *RedirectListRequest) (*RedirectListResponse, error)
+		RedirectDelete(ctx context.Context, in *RedirectDeleteRequest) (*RedirectDeleteResponse, error)
+		SitemapGenerate(ctx context.Context, in *SitemapGenerateRequest) (product.ProductRPC_SitemapGenerateClient, error)
+		ProductJsonLd(ctx context.Context, in *ProductJsonLdRequest) (*ProductJsonLdResponse, error)
+		PriceListCreate(ctx context.Context, in *PriceListCreateRequest) (*PriceListCreateResponse, error)
+		PriceListUpdate(ctx context.Context, in *PriceListUpdateRequest) (*PriceListUpdateResponse, error)
+		PriceListList(ctx context.Context, in *PriceListListRequest) (*PriceListListResponse, error)
+		PriceListDelete(ctx context.Context, in *PriceListDeleteRequest) (*PriceListDeleteResponse, error)
+		PriceListItemSet(ctx context.Context, in *PriceListItemSetRequest) (*PriceListItemSetResponse, error)
+		PriceListItemList(ctx context.Context, in *PriceListItemListRequest) (*PriceListItemListResponse, error)
+		SaleCreate(ctx context.Context, in *SaleCreateRequest) (*SaleCreateResponse, error)
+		SaleList(ctx context.Context, in *SaleListRequest) (*SaleListResponse, error)
+		SaleCancel(ctx context.Context, in *SaleCancelRequest) (*SaleCancelResponse, error)
+		SalePreview(ctx context.Context, in *SalePreviewRequest) (*SalePreviewResponse, error)
+		ProductBulkPriceUpdate(ctx context.Context, in *ProductBulkPriceUpdateRequest) (product.ProductRPC_ProductBulkPriceUpdateClient, error)
+		MetafieldSet(ctx context.Context, in *MetafieldSetRequest) (*MetafieldSetResponse, error)
+		MetafieldList(ctx context.Context, in *MetafieldListRequest) (*MetafieldListResponse, error)
+		MetafieldDelete(ctx context.Context, in *MetafieldDeleteRequest) (*MetafieldDeleteResponse, error)
+		TranslationSet(ctx context.Context, in *TranslationSetRequest) (*TranslationSetResponse, error)
+		TranslationList(ctx context.Context, in *TranslationListRequest) (*TranslationListResponse, error)
+		TranslationDelete(ctx context.Context, in *TranslationDeleteRequest) (*TranslationDeleteResponse, error)
+		TranslationMissing(ctx context.Context, in *TranslationMissingRequest) (*TranslationMissingResponse, error)
+		ReviewCreate(ctx context.Context, in *ReviewCreateRequest) (*ReviewCreateResponse, error)
+		ReviewUpdate(ctx context.Context, in *ReviewUpdateRequest) (*ReviewUpdateResponse, error)
+		ReviewDelete(ctx context.Context, in *ReviewDeleteRequest) (*ReviewDeleteResponse, error)
+		ReviewDetail(ctx context.Context, in *ReviewDetailRequest) (*ReviewDetailResponse, error)
+		ReviewList(ctx context.Context, in *ReviewListRequest) (*ReviewListResponse, error)
+		ReviewModerate(ctx context.Context, in *ReviewModerateRequest) (*ReviewModerateResponse, error)
+		ReviewPin(ctx context.Context, in *ReviewPinRequest) (*ReviewPinResponse, error)
+		ReviewShow(ctx context.Context, in *ReviewShowRequest) (*ReviewShowResponse, error)
+		ReviewReply(ctx context.Context, in *ReviewReplyRequest) (*ReviewReplyResponse, error)
+		ReviewVote(ctx context.Context, in *ReviewVoteRequest) (*ReviewVoteResponse, error)
+		ProductRatingList(ctx context.Context, in *ProductRatingListRequest) (*ProductRatingListResponse, error)
+		ReviewImport(ctx context.Context) (product.ProductRPC_ReviewImportClient, error)
+		ProductBatchGet(ctx context.Context, in *ProductBatchGetRequest) (*ProductBatchGetResponse, error)
+		ProductPatch(ctx context.Context, in *ProductPatchRequest) (*ProductPatchResponse, error)
+		ProductVariantPatch(ctx context.Context, in *ProductVariantPatchRequest) (*ProductVariantPatchResponse, error)
 	}
 
 	defaultProductRPC struct {
@@ -159,6 +311,11 @@ func (m *defaultProductRPC) ProductDelete(ctx context.Context, in *ProductDelete
 	return client.ProductDelete(ctx, in)
 }
 
+func (m *defaultProductRPC) WatchProducts(ctx context.Context, in *WatchProductsRequest) (product.ProductRPC_WatchProductsClient, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.WatchProducts(ctx, in)
+}
+
 func (m *defaultProductRPC) ProductImageList(ctx context.Context, in *ProductImageListRequest) (*ProductImageListResponse, error) {
 	client := product.NewProductRPCClient(m.cli.Conn())
 	return client.ProductImageList(ctx, in)
@@ -179,6 +336,11 @@ func (m *defaultProductRPC) ProductImageCount(ctx context.Context, in *ProductIm
 	return client.ProductImageCount(ctx, in)
 }
 
+func (m *defaultProductRPC) ProductImageIngestStatus(ctx context.Context, in *ProductImageIngestStatusRequest) (*ProductImageIngestStatusResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ProductImageIngestStatus(ctx, in)
+}
+
 func (m *defaultProductRPC) ProductVariantList(ctx context.Context, in *ProductVariantListRequest) (*ProductVariantListResponse, error) {
 	client := product.NewProductRPCClient(m.cli.Conn())
 	return client.ProductVariantList(ctx, in)
@@ -258,3 +420,213 @@ func (m *defaultProductRPC) CategoryProductDelete(ctx context.Context, in *Categ
 	client := product.NewProductRPCClient(m.cli.Conn())
 	return client.CategoryProductDelete(ctx, in)
 }
+
+func (m *defaultProductRPC) WebhookCreate(ctx context.Context, in *WebhookCreateRequest) (*WebhookCreateResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.WebhookCreate(ctx, in)
+}
+
+func (m *defaultProductRPC) WebhookList(ctx context.Context, in *WebhookListRequest) (*WebhookListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.WebhookList(ctx, in)
+}
+
+func (m *defaultProductRPC) WebhookUpdate(ctx context.Context, in *WebhookUpdateRequest) (*WebhookUpdateResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.WebhookUpdate(ctx, in)
+}
+
+func (m *defaultProductRPC) WebhookDelete(ctx context.Context, in *WebhookDeleteRequest) (*WebhookDeleteResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.WebhookDelete(ctx, in)
+}
+
+func (m *defaultProductRPC) WebhookDeliveryList(ctx context.Context, in *WebhookDeliveryListRequest) (*WebhookDeliveryListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.WebhookDeliveryList(ctx, in)
+}
+
+func (m *defaultProductRPC) RedirectList(ctx context.Context, in *RedirectListRequest) (*RedirectListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.RedirectList(ctx, in)
+}
+
+func (m *defaultProductRPC) RedirectDelete(ctx context.Context, in *RedirectDeleteRequest) (*RedirectDeleteResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.RedirectDelete(ctx, in)
+}
+
+func (m *defaultProductRPC) SitemapGenerate(ctx context.Context, in *SitemapGenerateRequest) (product.ProductRPC_SitemapGenerateClient, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.SitemapGenerate(ctx, in)
+}
+
+func (m *defaultProductRPC) ProductJsonLd(ctx context.Context, in *ProductJsonLdRequest) (*ProductJsonLdResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ProductJsonLd(ctx, in)
+}
+
+func (m *defaultProductRPC) PriceListCreate(ctx context.Context, in *PriceListCreateRequest) (*PriceListCreateResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.PriceListCreate(ctx, in)
+}
+
+func (m *defaultProductRPC) PriceListUpdate(ctx context.Context, in *PriceListUpdateRequest) (*PriceListUpdateResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.PriceListUpdate(ctx, in)
+}
+
+func (m *defaultProductRPC) PriceListList(ctx context.Context, in *PriceListListRequest) (*PriceListListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.PriceListList(ctx, in)
+}
+
+func (m *defaultProductRPC) PriceListDelete(ctx context.Context, in *PriceListDeleteRequest) (*PriceListDeleteResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.PriceListDelete(ctx, in)
+}
+
+func (m *defaultProductRPC) PriceListItemSet(ctx context.Context, in *PriceListItemSetRequest) (*PriceListItemSetResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.PriceListItemSet(ctx, in)
+}
+
+func (m *defaultProductRPC) PriceListItemList(ctx context.Context, in *PriceListItemListRequest) (*PriceListItemListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.PriceListItemList(ctx, in)
+}
+
+func (m *defaultProductRPC) SaleCreate(ctx context.Context, in *SaleCreateRequest) (*SaleCreateResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.SaleCreate(ctx, in)
+}
+
+func (m *defaultProductRPC) SaleList(ctx context.Context, in *SaleListRequest) (*SaleListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.SaleList(ctx, in)
+}
+
+func (m *defaultProductRPC) SaleCancel(ctx context.Context, in *SaleCancelRequest) (*SaleCancelResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.SaleCancel(ctx, in)
+}
+
+func (m *defaultProductRPC) SalePreview(ctx context.Context, in *SalePreviewRequest) (*SalePreviewResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.SalePreview(ctx, in)
+}
+
+func (m *defaultProductRPC) ProductBulkPriceUpdate(ctx context.Context, in *ProductBulkPriceUpdateRequest) (product.ProductRPC_ProductBulkPriceUpdateClient, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ProductBulkPriceUpdate(ctx, in)
+}
+
+func (m *defaultProductRPC) MetafieldSet(ctx context.Context, in *MetafieldSetRequest) (*MetafieldSetResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.MetafieldSet(ctx, in)
+}
+
+func (m *defaultProductRPC) MetafieldList(ctx context.Context, in *MetafieldListRequest) (*MetafieldListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.MetafieldList(ctx, in)
+}
+
+func (m *defaultProductRPC) MetafieldDelete(ctx context.Context, in *MetafieldDeleteRequest) (*MetafieldDeleteResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.MetafieldDelete(ctx, in)
+}
+
+func (m *defaultProductRPC) TranslationSet(ctx context.Context, in *TranslationSetRequest) (*TranslationSetResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.TranslationSet(ctx, in)
+}
+
+func (m *defaultProductRPC) TranslationList(ctx context.Context, in *TranslationListRequest) (*TranslationListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.TranslationList(ctx, in)
+}
+
+func (m *defaultProductRPC) TranslationDelete(ctx context.Context, in *TranslationDeleteRequest) (*TranslationDeleteResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.TranslationDelete(ctx, in)
+}
+
+func (m *defaultProductRPC) TranslationMissing(ctx context.Context, in *TranslationMissingRequest) (*TranslationMissingResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.TranslationMissing(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewCreate(ctx context.Context, in *ReviewCreateRequest) (*ReviewCreateResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewCreate(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewUpdate(ctx context.Context, in *ReviewUpdateRequest) (*ReviewUpdateResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewUpdate(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewDelete(ctx context.Context, in *ReviewDeleteRequest) (*ReviewDeleteResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewDelete(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewDetail(ctx context.Context, in *ReviewDetailRequest) (*ReviewDetailResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewDetail(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewList(ctx context.Context, in *ReviewListRequest) (*ReviewListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewList(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewModerate(ctx context.Context, in *ReviewModerateRequest) (*ReviewModerateResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewModerate(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewPin(ctx context.Context, in *ReviewPinRequest) (*ReviewPinResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewPin(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewShow(ctx context.Context, in *ReviewShowRequest) (*ReviewShowResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewShow(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewReply(ctx context.Context, in *ReviewReplyRequest) (*ReviewReplyResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewReply(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewVote(ctx context.Context, in *ReviewVoteRequest) (*ReviewVoteResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewVote(ctx, in)
+}
+
+func (m *defaultProductRPC) ProductRatingList(ctx context.Context, in *ProductRatingListRequest) (*ProductRatingListResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ProductRatingList(ctx, in)
+}
+
+func (m *defaultProductRPC) ReviewImport(ctx context.Context) (product.ProductRPC_ReviewImportClient, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ReviewImport(ctx)
+}
+
+func (m *defaultProductRPC) ProductBatchGet(ctx context.Context, in *ProductBatchGetRequest) (*ProductBatchGetResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ProductBatchGet(ctx, in)
+}
+
+func (m *defaultProductRPC) ProductPatch(ctx context.Context, in *ProductPatchRequest) (*ProductPatchResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ProductPatch(ctx, in)
+}
+
+func (m *defaultProductRPC) ProductVariantPatch(ctx context.Context, in *ProductVariantPatchRequest) (*ProductVariantPatchResponse, error) {
+	client := product.NewProductRPCClient(m.cli.Conn())
+	return client.ProductVariantPatch(ctx, in)
+}